@@ -0,0 +1,127 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// BackoffOptions configures the delays between the retries of a failed watch.
+// The delay starts at Initial and is multiplied by Multiplier after every retry, up to Max.
+// Jitter randomizes every delay by up to the given fraction, e.g. 0.2 for ±20%.
+// MaxRetries limits the number of retries, zero retries until the context is canceled.
+type BackoffOptions struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+	MaxRetries int
+}
+
+// DefaultBackoff is used by WatchChan and fills the unset fields of other BackoffOptions.
+var DefaultBackoff = BackoffOptions{
+	Initial:    2 * time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Backoff computes the delays between retries.
+// It isn't safe for concurrent use.
+type Backoff struct {
+	options BackoffOptions
+	retries int
+}
+
+// NewBackoff returns a new Backoff.
+func NewBackoff(o BackoffOptions) *Backoff {
+	if o.Initial <= 0 {
+		o.Initial = DefaultBackoff.Initial
+	}
+	if o.Max <= 0 {
+		o.Max = DefaultBackoff.Max
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = DefaultBackoff.Multiplier
+	}
+	return &Backoff{options: o}
+}
+
+// Next returns the delay before the next retry.
+// It returns false if MaxRetries is exceeded.
+func (b *Backoff) Next() (time.Duration, bool) {
+	if b.options.MaxRetries > 0 && b.retries >= b.options.MaxRetries {
+		return 0, false
+	}
+
+	d := float64(b.options.Initial)
+	for i := 0; i < b.retries && d < float64(b.options.Max); i++ {
+		d *= b.options.Multiplier
+	}
+	if d > float64(b.options.Max) {
+		d = float64(b.options.Max)
+	}
+	if b.options.Jitter > 0 {
+		d += d * b.options.Jitter * (2*rand.Float64() - 1)
+	}
+	b.retries++
+	return time.Duration(d), true
+}
+
+// Reset starts over with the Initial delay, e.g. after a successful watch.
+func (b *Backoff) Reset() {
+	b.retries = 0
+}
+
+// Wait blocks for the next delay.
+// It returns false if MaxRetries is exceeded or ctx is canceled.
+func (b *Backoff) Wait(ctx context.Context) bool {
+	d, ok := b.Next()
+	if !ok {
+		return false
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// WithBackoff makes the backend retry failed watches with the given backoff
+// instead of returning the error.
+func WithBackoff(b BackoffOptions) WatchOption {
+	return func(o *WatchOptions) {
+		o.Backoff = &b
+	}
+}
+
+// Retry calls watch until it succeeds, if a Backoff is configured.
+// Canceled and unsupported watches aren't retried.
+// The error of the last call is returned if the retries are exhausted or ctx is canceled.
+func (o *WatchOptions) Retry(ctx context.Context, watch func() (uint64, error)) (uint64, error) {
+	index, err := watch()
+	if o.Backoff == nil {
+		return index, err
+	}
+
+	b := NewBackoff(*o.Backoff)
+	for err != nil && err != ErrWatchCanceled && err != ErrWatchNotSupported {
+		if !b.Wait(ctx) {
+			if ctx.Err() != nil {
+				return o.WaitIndex, ErrWatchCanceled
+			}
+			return index, err
+		}
+		index, err = watch()
+	}
+	return index, err
+}
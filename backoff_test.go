@@ -0,0 +1,79 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestBackoff(t *C) {
+	b := NewBackoff(BackoffOptions{Initial: time.Second, Max: 5 * time.Second, MaxRetries: 5})
+	var delays []time.Duration
+	for {
+		d, ok := b.Next()
+		if !ok {
+			break
+		}
+		delays = append(delays, d)
+	}
+	t.Check(delays, DeepEquals, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second})
+
+	b.Reset()
+	d, ok := b.Next()
+	t.Check(ok, Equals, true)
+	t.Check(d, Equals, time.Second)
+
+	b = NewBackoff(BackoffOptions{Initial: time.Second, Jitter: 0.5})
+	for i := 0; i < 20; i++ {
+		b.Reset()
+		d, _ := b.Next()
+		t.Check(d >= 500*time.Millisecond && d <= 1500*time.Millisecond, Equals, true, Commentf("delay %v", d))
+	}
+}
+
+func (s *FilterSuite) TestRetry(t *C) {
+	fail := errors.New("connection refused")
+	calls := 0
+	watch := func() (uint64, error) {
+		calls++
+		if calls < 3 {
+			return 0, fail
+		}
+		return 42, nil
+	}
+
+	var options WatchOptions
+	_, err := options.Retry(context.Background(), watch)
+	t.Check(err, Equals, fail)
+	t.Check(calls, Equals, 1)
+
+	calls = 0
+	WithBackoff(BackoffOptions{Initial: time.Millisecond})(&options)
+	index, err := options.Retry(context.Background(), watch)
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+	t.Check(calls, Equals, 3)
+
+	calls = -10
+	WithBackoff(BackoffOptions{Initial: time.Millisecond, MaxRetries: 2})(&options)
+	_, err = options.Retry(context.Background(), watch)
+	t.Check(err, Equals, fail)
+	t.Check(calls, Equals, -7)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	options.WaitIndex = 7
+	index, err = options.Retry(ctx, func() (uint64, error) { return 0, fail })
+	t.Check(err, Equals, ErrWatchCanceled)
+	t.Check(index, Equals, uint64(7))
+}
@@ -0,0 +1,150 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"sync"
+)
+
+// Broadcaster shares one upstream watch per prefix between any number of subscribers.
+// It wraps a ReadWatcher and implements the ReadWatcher interface itself,
+// so it can be used as a drop-in replacement for the wrapped backend.
+type Broadcaster struct {
+	ReadWatcher
+
+	mu       sync.Mutex
+	watchers map[string]*prefixWatch
+	// lastIndex is the last index seen upstream per prefix, the next upstream watch starts from it.
+	lastIndex map[string]uint64
+}
+
+type broadcastResponse struct {
+	waitIndex uint64
+	err       error
+}
+
+// prefixWatch is the upstream watch of a single prefix.
+type prefixWatch struct {
+	cancel      context.CancelFunc
+	subscribers map[chan broadcastResponse]struct{}
+}
+
+// NewBroadcaster returns a new Broadcaster for the given backend.
+func NewBroadcaster(rw ReadWatcher) *Broadcaster {
+	return &Broadcaster{
+		ReadWatcher: rw,
+		watchers:    make(map[string]*prefixWatch),
+		lastIndex:   make(map[string]uint64),
+	}
+}
+
+// WatchPrefix blocks until the shared upstream watch of prefix reports a change,
+// or until ctx is canceled.
+// If the WaitIndex differs from the last index seen upstream, WatchPrefix returns immediately
+// since the subscriber has missed a change. Before the first upstream response, the upstream
+// watch starts at the WaitIndex of the subscriber that starts it.
+// The Keys option is ignored, the upstream watch always covers the whole prefix.
+// The other options of the subscriber that starts the upstream watch are passed on to it.
+func (b *Broadcaster) WatchPrefix(ctx context.Context, prefix string, opts ...WatchOption) (uint64, error) {
+	var options WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	respChan := make(chan broadcastResponse, 1)
+
+	b.mu.Lock()
+	lastIndex := b.lastIndex[prefix]
+	if options.WaitIndex != 0 && lastIndex != 0 && options.WaitIndex != lastIndex {
+		b.mu.Unlock()
+		return lastIndex, nil
+	}
+	if lastIndex == 0 {
+		lastIndex = options.WaitIndex
+	}
+	w, ok := b.watchers[prefix]
+	if !ok {
+		wctx, cancel := context.WithCancel(context.Background())
+		w = &prefixWatch{
+			cancel:      cancel,
+			subscribers: make(map[chan broadcastResponse]struct{}),
+		}
+		b.watchers[prefix] = w
+		go b.run(wctx, prefix, w, lastIndex, opts)
+	}
+	w.subscribers[respChan] = struct{}{}
+	b.mu.Unlock()
+
+	select {
+	case r := <-respChan:
+		return r.waitIndex, r.err
+	case <-ctx.Done():
+		b.unsubscribe(prefix, w, respChan)
+		return options.WaitIndex, ErrWatchCanceled
+	}
+}
+
+// unsubscribe removes respChan from the watch and stops the upstream watch
+// if it was the last subscriber.
+// A response that was already sent to respChan is dropped.
+func (b *Broadcaster) unsubscribe(prefix string, w *prefixWatch, respChan chan broadcastResponse) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(w.subscribers, respChan)
+	if len(w.subscribers) == 0 && b.watchers[prefix] == w {
+		w.cancel()
+		delete(b.watchers, prefix)
+	}
+}
+
+// run is the upstream watch of a prefix, starting at waitIndex. It's the AfterIndex as well,
+// so backends that watch from a revision (etcd) don't miss the changes between two runs.
+// The response is sent to all current subscribers and the watch ends, so no
+// upstream watch is left running without subscribers. The next subscriber starts
+// a new one at the last index, a change in between is reported immediately.
+func (b *Broadcaster) run(ctx context.Context, prefix string, w *prefixWatch, waitIndex uint64, opts []WatchOption) {
+	opts = append(opts[:len(opts):len(opts)], WithKeys([]string{prefix}), WithWaitIndex(waitIndex))
+	if waitIndex != 0 {
+		opts = append(opts, WithAfterIndex(waitIndex))
+	}
+	index, err := b.ReadWatcher.WatchPrefix(ctx, prefix, opts...)
+	if ctx.Err() != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.lastIndex[prefix] = index
+	}
+	for s := range w.subscribers {
+		s <- broadcastResponse{index, err}
+		delete(w.subscribers, s)
+	}
+	if b.watchers[prefix] == w {
+		delete(b.watchers, prefix)
+	}
+	w.cancel()
+}
+
+// Close stops all upstream watches and closes the wrapped backend.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	for prefix, w := range b.watchers {
+		w.cancel()
+		for s := range w.subscribers {
+			s <- broadcastResponse{b.lastIndex[prefix], ErrWatchCanceled}
+			delete(w.subscribers, s)
+		}
+		delete(b.watchers, prefix)
+	}
+	b.mu.Unlock()
+	b.ReadWatcher.Close()
+}
@@ -0,0 +1,141 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// waitForSubscribers waits until the upstream watch of prefix has n subscribers.
+func waitForSubscribers(b *Broadcaster, prefix string, n int) {
+	for {
+		b.mu.Lock()
+		w, ok := b.watchers[prefix]
+		subscribed := ok && len(w.subscribers) == n
+		b.mu.Unlock()
+		if subscribed {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *FilterSuite) TestBroadcasterFanOut(t *C) {
+	c := newTestClient(nil)
+	b := NewBroadcaster(c)
+	defer b.Close()
+
+	wg := sync.WaitGroup{}
+	indexes := make(chan uint64, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := b.WatchPrefix(context.Background(), "/app")
+			t.Check(err, IsNil)
+			indexes <- n
+		}()
+	}
+
+	waitForSubscribers(b, "/app", 3)
+	c.changes <- 42
+	wg.Wait()
+	close(indexes)
+
+	for n := range indexes {
+		t.Check(n, Equals, uint64(42))
+	}
+	c.mu.Lock()
+	t.Check(c.watches, Equals, 1)
+	c.mu.Unlock()
+}
+
+func (s *FilterSuite) TestBroadcasterMissedChange(t *C) {
+	c := newTestClient(nil)
+	b := NewBroadcaster(c)
+	defer b.Close()
+
+	go func() {
+		c.changes <- 2
+	}()
+	n, err := b.WatchPrefix(context.Background(), "/app")
+	t.Check(err, IsNil)
+	t.Check(n, Equals, uint64(2))
+
+	n, err = b.WatchPrefix(context.Background(), "/app", WithWaitIndex(1))
+	t.Check(err, IsNil)
+	t.Check(n, Equals, uint64(2))
+}
+
+func (s *FilterSuite) TestBroadcasterStopsWithoutSubscribers(t *C) {
+	c := newTestClient(nil)
+	b := NewBroadcaster(c)
+	defer b.Close()
+
+	go func() {
+		c.changes <- 2
+	}()
+	n, err := b.WatchPrefix(context.Background(), "/app")
+	t.Check(err, IsNil)
+	t.Check(n, Equals, uint64(2))
+
+	// the upstream watch ended with the response
+	b.mu.Lock()
+	t.Check(b.watchers, HasLen, 0)
+	b.mu.Unlock()
+
+	// a returning subscriber continues at the last index
+	go func() {
+		c.changes <- 3
+	}()
+	n, err = b.WatchPrefix(context.Background(), "/app", WithWaitIndex(2))
+	t.Check(err, IsNil)
+	t.Check(n, Equals, uint64(3))
+	// the changes between the upstream watches are reported by backends that watch from a revision
+	c.mu.Lock()
+	t.Check(c.options.WaitIndex, Equals, uint64(2))
+	t.Check(c.options.AfterIndex, Equals, uint64(2))
+	c.mu.Unlock()
+}
+
+func (s *FilterSuite) TestBroadcasterFirstWaitIndex(t *C) {
+	c := newTestClient(nil)
+	b := NewBroadcaster(c)
+	defer b.Close()
+
+	// the first upstream watch blocks at the index of the subscriber instead of reporting a change
+	go func() {
+		c.changes <- 8
+	}()
+	n, err := b.WatchPrefix(context.Background(), "/app", WithWaitIndex(7))
+	t.Check(err, IsNil)
+	t.Check(n, Equals, uint64(8))
+	c.mu.Lock()
+	t.Check(c.options.WaitIndex, Equals, uint64(7))
+	c.mu.Unlock()
+}
+
+func (s *FilterSuite) TestBroadcasterCancel(t *C) {
+	c := newTestClient(nil)
+	b := NewBroadcaster(c)
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := b.WatchPrefix(ctx, "/app")
+	t.Check(err, Equals, ErrWatchCanceled)
+
+	b.mu.Lock()
+	t.Check(b.watchers, HasLen, 0)
+	b.mu.Unlock()
+}
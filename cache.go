@@ -0,0 +1,124 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TTLGetter returns a TTL hint for every key alongside the values.
+// A TTL of zero means that the backend has no opinion about the key.
+type TTLGetter interface {
+	GetValuesWithTTL(keys []string) (map[string]string, map[string]time.Duration, error)
+}
+
+type cacheEntry struct {
+	vars    map[string]string
+	expires time.Time
+}
+
+// Cache caches the results of GetValues.
+// Entries expire after the TTL given to NewCache, or earlier if the backend
+// implements TTLGetter and reports a shorter TTL for one of the keys.
+// A successful WatchPrefix call invalidates the whole cache.
+type Cache struct {
+	ReadWatcher
+
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// generation is incremented by Invalidate, reads that started
+	// before don't store their results.
+	generation uint64
+}
+
+// NewCache returns a new Cache for the given backend.
+func NewCache(rw ReadWatcher, ttl time.Duration) *Cache {
+	return &Cache{
+		ReadWatcher: rw,
+		ttl:         ttl,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+// cacheKey returns the same key for the same set of prefixes.
+func cacheKey(keys []string) string {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	c := make(map[string]string, len(vars))
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
+
+// GetValues returns the cached values of keys or queries the backend if they have expired.
+func (c *Cache) GetValues(keys []string) (map[string]string, error) {
+	key := cacheKey(keys)
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return copyVars(entry.vars), nil
+	}
+
+	var vars map[string]string
+	var ttls map[string]time.Duration
+	var err error
+	if tg, ok := c.ReadWatcher.(TTLGetter); ok {
+		vars, ttls, err = tg.GetValuesWithTTL(keys)
+	} else {
+		vars, err = c.ReadWatcher.GetValues(keys)
+	}
+	if err != nil {
+		return vars, err
+	}
+
+	ttl := c.ttl
+	for _, t := range ttls {
+		if t > 0 && t < ttl {
+			ttl = t
+		}
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[key] = cacheEntry{vars: copyVars(vars), expires: now.Add(ttl)}
+	}
+	c.mu.Unlock()
+	return vars, nil
+}
+
+// Invalidate removes all entries from the cache.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[string]cacheEntry)
+	c.generation++
+	c.mu.Unlock()
+}
+
+// WatchPrefix watches the backend and invalidates the cache if a change was reported.
+func (c *Cache) WatchPrefix(ctx context.Context, prefix string, opts ...WatchOption) (uint64, error) {
+	index, err := c.ReadWatcher.WatchPrefix(ctx, prefix, opts...)
+	if err == nil {
+		c.Invalidate()
+	}
+	return index, err
+}
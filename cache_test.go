@@ -0,0 +1,107 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ttlClient struct {
+	*testClient
+	ttl time.Duration
+}
+
+func (c *ttlClient) GetValuesWithTTL(keys []string) (map[string]string, map[string]time.Duration, error) {
+	vars, err := c.GetValues(keys)
+	ttls := make(map[string]time.Duration)
+	for k := range vars {
+		ttls[k] = c.ttl
+	}
+	return vars, ttls, err
+}
+
+func (s *FilterSuite) TestCache(t *C) {
+	c := newTestClient(map[string]string{"/app/name": "easykv"})
+	cache := NewCache(c, time.Hour)
+
+	vars, err := cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+
+	c.mu.Lock()
+	c.data["/app/name"] = "changed"
+	c.mu.Unlock()
+
+	vars, err = cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/app/name"], Equals, "easykv")
+
+	go func() { c.changes <- 1 }()
+	_, err = cache.WatchPrefix(context.Background(), "/app")
+	t.Assert(err, IsNil)
+
+	vars, err = cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/app/name"], Equals, "changed")
+}
+
+func (s *FilterSuite) TestCacheTTLHints(t *C) {
+	c := &ttlClient{newTestClient(map[string]string{"/app/name": "easykv"}), 50 * time.Millisecond}
+	cache := NewCache(c, time.Hour)
+
+	_, err := cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+
+	c.mu.Lock()
+	c.data["/app/name"] = "changed"
+	c.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+
+	vars, err := cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/app/name"], Equals, "changed")
+}
+
+// racingClient runs during after it read the values, like a change reported while a read is running.
+type racingClient struct {
+	*testClient
+	during func()
+}
+
+func (c *racingClient) GetValues(keys []string) (map[string]string, error) {
+	vars, err := c.testClient.GetValues(keys)
+	if during := c.during; during != nil {
+		c.during = nil
+		during()
+	}
+	return vars, err
+}
+
+func (s *FilterSuite) TestCacheInvalidateDuringRead(t *C) {
+	c := &racingClient{testClient: newTestClient(map[string]string{"/app/name": "easykv"})}
+	cache := NewCache(c, time.Hour)
+	c.during = func() {
+		c.mu.Lock()
+		c.data["/app/name"] = "changed"
+		c.mu.Unlock()
+		cache.Invalidate()
+	}
+
+	vars, err := cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/app/name"], Equals, "easykv")
+
+	// the values of the read that was overtaken by Invalidate aren't cached
+	vars, err = cache.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/app/name"], Equals, "changed")
+}
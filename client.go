@@ -10,14 +10,39 @@
 
 package easykv
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // WatchOptions represents options for watch operations
 type WatchOptions struct {
-	WaitIndex uint64
-	Keys      []string
+	WaitIndex  uint64
+	Keys       []string
+	IndexStore IndexStore
+	Backoff    *BackoffOptions
+	Interval   time.Duration
+	AfterIndex uint64
+
+	MaxWait     time.Duration
+	Consistency Consistency
+	UseCache    bool
+	MinInterval time.Duration
 }
 
+// Consistency is the consistency mode of reads in backends that support several (consul).
+type Consistency int
+
+// The consistency modes.
+const (
+	// ConsistencyDefault leaves the mode to the backend.
+	ConsistencyDefault Consistency = iota
+	// ConsistencyStale allows any server to answer, the value may be stale.
+	ConsistencyStale
+	// ConsistencyConsistent requires the leader to confirm its leadership before answering.
+	ConsistencyConsistent
+)
+
 // WatchOption configures the WatchPrefix operation
 type WatchOption func(*WatchOptions)
 
@@ -35,9 +60,74 @@ func WithWaitIndex(waitIndex uint64) WatchOption {
 	}
 }
 
+// WithIndexStore persists the last seen index of the watcher in s.
+// If no WaitIndex is set, the watcher resumes from the persisted index.
+// Only backends with a meaningful index (consul, etcd) support this option.
+func WithIndexStore(s IndexStore) WatchOption {
+	return func(o *WatchOptions) {
+		o.IndexStore = s
+	}
+}
+
+// WithInterval sets the poll interval of backends that watch by polling (vault, redis and files).
+func WithInterval(d time.Duration) WatchOption {
+	return func(o *WatchOptions) {
+		o.Interval = d
+	}
+}
+
+// WithAfterIndex reports only changes after the given index, e.g. the revision
+// of a consistent read (etcd), so no change between the read and the watch is missed.
+func WithAfterIndex(index uint64) WatchOption {
+	return func(o *WatchOptions) {
+		o.AfterIndex = index
+	}
+}
+
+// WithMaxWait limits how long a blocking query waits for a change before it returns (consul).
+func WithMaxWait(d time.Duration) WatchOption {
+	return func(o *WatchOptions) {
+		o.MaxWait = d
+	}
+}
+
+// WithConsistency sets the consistency mode of the watch (consul).
+func WithConsistency(c Consistency) WatchOption {
+	return func(o *WatchOptions) {
+		o.Consistency = c
+	}
+}
+
+// WithUseCache answers the watch from the agent cache where it is supported (consul).
+func WithUseCache() WatchOption {
+	return func(o *WatchOptions) {
+		o.UseCache = true
+	}
+}
+
+// WithMinInterval makes a watch return at most once per d, even if the backend answers
+// immediately or fails, to avoid tight loops against degraded clusters (consul).
+func WithMinInterval(d time.Duration) WatchOption {
+	return func(o *WatchOptions) {
+		o.MinInterval = d
+	}
+}
+
 // A ReadWatcher - can get values and watch a prefix for changes
 type ReadWatcher interface {
 	GetValues(keys []string) (map[string]string, error)
 	WatchPrefix(ctx context.Context, prefix string, opts ...WatchOption) (uint64, error)
 	Close()
 }
+
+// A Writer can set and delete keys.
+type Writer interface {
+	SetValues(values map[string]string) error
+	DeleteValues(keys []string) error
+}
+
+// A ReadWriter - can get, watch, set and delete values
+type ReadWriter interface {
+	ReadWatcher
+	Writer
+}
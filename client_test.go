@@ -0,0 +1,76 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+// testClient is a ReadWatcher whose WatchPrefix returns whenever a value is sent on changes.
+type testClient struct {
+	mu      sync.Mutex
+	data    map[string]string
+	err     error
+	watches int
+	changes chan uint64
+	// options are the options of the last watch.
+	options WatchOptions
+}
+
+func newTestClient(data map[string]string) *testClient {
+	return &testClient{
+		data:    data,
+		changes: make(chan uint64),
+	}
+}
+
+func (c *testClient) GetValues(keys []string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vars := make(map[string]string)
+	for k, v := range c.data {
+		for _, key := range keys {
+			if strings.HasPrefix(path.Join("/", k), path.Join("/", key)) {
+				vars[k] = v
+			}
+		}
+	}
+	return vars, c.err
+}
+
+func (c *testClient) WatchPrefix(ctx context.Context, prefix string, opts ...WatchOption) (uint64, error) {
+	c.mu.Lock()
+	c.watches++
+	c.options = WatchOptions{}
+	for _, o := range opts {
+		o(&c.options)
+	}
+	c.mu.Unlock()
+	select {
+	case i := <-c.changes:
+		return i, nil
+	case <-ctx.Done():
+		return 0, ErrWatchCanceled
+	}
+}
+
+func (c *testClient) Close() {}
@@ -0,0 +1,229 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Command easykv queries, exports, watches and compares easykv backends.
+//
+// Usage:
+//
+//	easykv -backend <uri> get <prefix>...
+//	easykv -backend <uri> export [-format json|yaml|dotenv|shell] <prefix>...
+//	easykv -backend <uri> watch [-exec cmd] <prefix>
+//	easykv diff <uriA> <uriB> <prefix>...
+//
+// The backend URI can also be set with the EASYKV_BACKEND environment variable.
+// See the factory package for the supported URIs.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/export"
+	"github.com/HeavyHorst/easykv/factory"
+)
+
+const usage = `Usage:
+  easykv -backend <uri> get <prefix>...
+  easykv -backend <uri> export [-format json|yaml|dotenv|shell] <prefix>...
+  easykv -backend <uri> watch [-exec cmd] <prefix>
+  easykv diff <uriA> <uriB> <prefix>...
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("easykv", flag.ExitOnError)
+	backend := flags.String("backend", os.Getenv("EASYKV_BACKEND"), "backend `uri`, e.g. consul://127.0.0.1:8500")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	var err error
+	cmd, args := flags.Arg(0), flags.Args()[1:]
+	switch cmd {
+	case "get":
+		err = get(os.Stdout, *backend, args)
+	case "export":
+		err = exportCmd(os.Stdout, *backend, args)
+	case "watch":
+		err = watch(ctx, os.Stdout, *backend, args)
+	case "diff":
+		err = diff(os.Stdout, args)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "easykv:", err)
+		os.Exit(1)
+	}
+}
+
+// open creates the backend or returns an error if no uri was given.
+func open(uri string) (easykv.ReadWatcher, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("no backend given, use -backend or EASYKV_BACKEND")
+	}
+	return factory.New(uri)
+}
+
+// prefixes returns args or the root prefix if args is empty.
+func prefixes(args []string) []string {
+	if len(args) == 0 {
+		return []string{"/"}
+	}
+	return args
+}
+
+func getValues(uri string, keys []string) (map[string]string, error) {
+	rw, err := open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer rw.Close()
+	return rw.GetValues(prefixes(keys))
+}
+
+func get(w io.Writer, uri string, args []string) error {
+	vars, err := getValues(uri, args)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s=%s\n", k, vars[k])
+	}
+	return nil
+}
+
+func exportCmd(w io.Writer, uri string, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "json", "output `format`: json, yaml, dotenv or shell")
+	flags.Parse(args)
+
+	vars, err := getValues(uri, flags.Args())
+	if err != nil {
+		return err
+	}
+	return export.Write(w, export.Format(*format), vars)
+}
+
+func watch(ctx context.Context, w io.Writer, uri string, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	command := flags.String("exec", "", "`command` that is run with sh -c on every change")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("watch needs exactly one prefix")
+	}
+	prefix := flags.Arg(0)
+
+	rw, err := open(uri)
+	if err != nil {
+		return err
+	}
+	defer rw.Close()
+
+	// the first notification of some backends only returns the current index,
+	// so only notifications that change a value are reported
+	vars, err := rw.GetValues([]string{prefix})
+	if err != nil {
+		return err
+	}
+
+	indexc, errc := easykv.WatchChan(ctx, rw, prefix)
+	for {
+		select {
+		case index, ok := <-indexc:
+			if !ok {
+				return nil
+			}
+			newVars, err := rw.GetValues([]string{prefix})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "easykv:", err)
+				continue
+			}
+			if easykv.Diff(vars, newVars).Empty() {
+				continue
+			}
+			vars = newVars
+			fmt.Fprintf(w, "change detected (index %d)\n", index)
+			if *command != "" {
+				c := exec.Command("sh", "-c", *command)
+				c.Stdout, c.Stderr = os.Stdout, os.Stderr
+				if err := c.Run(); err != nil {
+					fmt.Fprintln(os.Stderr, "easykv:", err)
+				}
+			}
+		case err, ok := <-errc:
+			if !ok {
+				return nil
+			}
+			if err == easykv.ErrWatchNotSupported {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "easykv: watch error:", err)
+		}
+	}
+}
+
+func diff(w io.Writer, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("diff needs two backend uris")
+	}
+
+	a, err := getValues(args[0], args[2:])
+	if err != nil {
+		return err
+	}
+	b, err := getValues(args[1], args[2:])
+	if err != nil {
+		return err
+	}
+
+	d := easykv.Diff(a, b)
+	for _, k := range d.Removed {
+		fmt.Fprintf(w, "- %s=%s\n", k, a[k])
+	}
+	for _, k := range d.Added {
+		fmt.Fprintf(w, "+ %s=%s\n", k, b[k])
+	}
+	for _, k := range d.Changed {
+		fmt.Fprintf(w, "~ %s=%s -> %s\n", k, a[k], b[k])
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+// writeFile writes a yaml file to dir and returns its file:// uri.
+func writeFile(t *C, dir, name, content string) string {
+	p := filepath.Join(dir, name)
+	t.Assert(ioutil.WriteFile(p, []byte(content), 0644), IsNil)
+	return "file://" + p
+}
+
+func (s *FilterSuite) TestGet(t *C) {
+	uri := writeFile(t, t.MkDir(), "config.yml", "app:\n  name: web\n  port: \"80\"\ndb:\n  host: localhost\n")
+
+	var out bytes.Buffer
+	t.Assert(get(&out, uri, []string{"/app"}), IsNil)
+	t.Check(out.String(), Equals, "/app/name=web\n/app/port=80\n")
+
+	out.Reset()
+	t.Assert(get(&out, uri, nil), IsNil)
+	t.Check(out.String(), Equals, "/app/name=web\n/app/port=80\n/db/host=localhost\n")
+
+	t.Check(get(&out, "", nil), ErrorMatches, "no backend given, use -backend or EASYKV_BACKEND")
+}
+
+func (s *FilterSuite) TestExport(t *C) {
+	uri := writeFile(t, t.MkDir(), "config.yml", "app:\n  name: web\n")
+
+	var out bytes.Buffer
+	t.Assert(exportCmd(&out, uri, []string{"-format", "dotenv", "/app"}), IsNil)
+	t.Check(out.String(), Equals, "APP_NAME=\"web\"\n")
+
+	out.Reset()
+	t.Assert(exportCmd(&out, uri, []string{"/app"}), IsNil)
+	t.Check(strings.Join(strings.Fields(out.String()), ""), Equals, `{"app":{"name":"web"}}`)
+}
+
+func (s *FilterSuite) TestDiff(t *C) {
+	dir := t.MkDir()
+	a := writeFile(t, dir, "a.yml", "app:\n  name: web\n  port: \"80\"\n  user: www\n")
+	b := writeFile(t, dir, "b.yml", "app:\n  name: api\n  port: \"80\"\n  debug: \"true\"\n")
+
+	var out bytes.Buffer
+	t.Assert(diff(&out, []string{a, b, "/app"}), IsNil)
+	t.Check(out.String(), Equals, "- /app/user=www\n+ /app/debug=true\n~ /app/name=web -> api\n")
+
+	t.Check(diff(&out, []string{a}), ErrorMatches, "diff needs two backend uris")
+}
+
+// syncBuffer is a bytes.Buffer that can be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (s *FilterSuite) TestWatch(t *C) {
+	dir := t.MkDir()
+	uri := writeFile(t, dir, "config.yml", "app:\n  name: web\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- watch(ctx, &out, uri, []string{"/app"}) }()
+
+	// writes that don't change a value aren't reported
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; i < 5; i++ {
+		writeFile(t, dir, "config.yml", "app:\n  name: web\n")
+		time.Sleep(20 * time.Millisecond)
+	}
+	for out.String() == "" && time.Now().Before(deadline) {
+		writeFile(t, dir, "config.yml", "app:\n  name: api\n")
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	t.Assert(<-done, IsNil)
+	t.Check(out.String(), Matches, `change detected \(index \d+\)\n`)
+}
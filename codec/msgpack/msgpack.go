@@ -0,0 +1,22 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package msgpack provides an easykv.Decoder for msgpack encoded values.
+package msgpack
+
+import (
+	"github.com/HeavyHorst/easykv"
+	"github.com/vmihailenco/msgpack"
+)
+
+// Decoder decodes msgpack encoded values.
+var Decoder easykv.Decoder = easykv.DecoderFunc(func(data []byte) (interface{}, error) {
+	var v interface{}
+	err := msgpack.Unmarshal(data, &v)
+	return v, err
+})
@@ -0,0 +1,33 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/vmihailenco/msgpack"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestDecode(t *C) {
+	data, err := msgpack.Marshal(map[string]interface{}{"url": "www.google.de", "user": "Boris"})
+	t.Assert(err, IsNil)
+
+	v, err := Decoder.Decode(data)
+	t.Assert(err, IsNil)
+	t.Check(v, DeepEquals, map[string]interface{}{"url": "www.google.de", "user": "Boris"})
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package protobuf provides an easykv.Decoder for protobuf encoded values.
+package protobuf
+
+import (
+	"encoding/json"
+
+	"github.com/HeavyHorst/easykv"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Options contains all values that are needed to decode protobuf messages.
+type Options struct {
+	Registry *protoregistry.Types
+}
+
+// Option configures the protobuf decoder.
+type Option func(*Options)
+
+// WithRegistry sets the schema registry that is used to look up the message type.
+// Defaults to protoregistry.GlobalTypes.
+func WithRegistry(r *protoregistry.Types) Option {
+	return func(o *Options) {
+		o.Registry = r
+	}
+}
+
+// NewDecoder returns a decoder for values of the protobuf message type with the given full name.
+// The message is decoded into the same nested structure as its json representation.
+func NewDecoder(messageName string, opts ...Option) (easykv.Decoder, error) {
+	options := Options{Registry: protoregistry.GlobalTypes}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	mt, err := options.Registry.FindMessageByName(protoreflect.FullName(messageName))
+	if err != nil {
+		return nil, err
+	}
+
+	return easykv.DecoderFunc(func(data []byte) (interface{}, error) {
+		m := mt.New().Interface()
+		if err := proto.Unmarshal(data, m); err != nil {
+			return nil, err
+		}
+		js, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		err = json.Unmarshal(js, &v)
+		return v, err
+	}), nil
+}
@@ -0,0 +1,43 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package protobuf
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestDecode(t *C) {
+	m, err := structpb.NewStruct(map[string]interface{}{"url": "www.google.de", "port": 5432})
+	t.Assert(err, IsNil)
+	data, err := proto.Marshal(m)
+	t.Assert(err, IsNil)
+
+	d, err := NewDecoder("google.protobuf.Struct")
+	t.Assert(err, IsNil)
+	v, err := d.Decode(data)
+	t.Assert(err, IsNil)
+	t.Check(v, DeepEquals, map[string]interface{}{"url": "www.google.de", "port": float64(5432)})
+}
+
+func (s *FilterSuite) TestUnknownMessage(t *C) {
+	_, err := NewDecoder("does.not.Exist")
+	t.Check(err, NotNil)
+}
@@ -0,0 +1,296 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package conformance checks that an easykv.ReadWatcher behaves like the in-tree backends.
+// Third-party backends can run it from their own tests, with a *testing.T
+// or any other T like a gocheck *check.C:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, func(data map[string]string) (easykv.ReadWatcher, error) {
+//			// write data to a fresh backend
+//			return New(...)
+//		})
+//	}
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// Root is the prefix all keys of the cases are written below.
+// Every case uses its own subtree Root/<case name>.
+const Root = "/conformance"
+
+// T reports failed cases, it is implemented by *testing.T.
+type T interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Setup returns the backend under test, filled with data.
+// It is called once for every case, the backend is closed after the case.
+type Setup func(data map[string]string) (easykv.ReadWatcher, error)
+
+// Options configures Run.
+type Options struct {
+	Skip    []string
+	NoWatch bool
+	Timeout time.Duration
+}
+
+// Option configures Run.
+type Option func(*Options)
+
+// WithSkip skips the cases with the given names, e.g. for documented differences of a backend.
+func WithSkip(names ...string) Option {
+	return func(o *Options) {
+		o.Skip = append(o.Skip, names...)
+	}
+}
+
+// WithoutWatch expects WatchPrefix to return easykv.ErrWatchNotSupported.
+func WithoutWatch() Option {
+	return func(o *Options) {
+		o.NoWatch = true
+	}
+}
+
+// WithTimeout sets how long the watch cases wait for WatchPrefix to return. Defaults to 10 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// getCase is a GetValues case. All keys are relative to the root of the case.
+type getCase struct {
+	name string
+	data map[string]string
+	keys []string
+	want map[string]string
+}
+
+var getCases = []getCase{
+	{
+		name: "prefix",
+		data: map[string]string{"/app/db/url": "db:5432", "/app/db/user": "admin", "/other/key": "x"},
+		keys: []string{"/app"},
+		want: map[string]string{"/app/db/url": "db:5432", "/app/db/user": "admin"},
+	},
+	{
+		name: "multiple prefixes",
+		data: map[string]string{"/a/1": "1", "/b/2": "2", "/c/3": "3"},
+		keys: []string{"/a", "/b"},
+		want: map[string]string{"/a/1": "1", "/b/2": "2"},
+	},
+	{
+		name: "overlapping prefixes",
+		data: map[string]string{"/app/db/url": "db:5432", "/app/name": "easykv"},
+		keys: []string{"/app", "/app/db"},
+		want: map[string]string{"/app/db/url": "db:5432", "/app/name": "easykv"},
+	},
+	{
+		// prefixes are plain string prefixes, like in the etcd and consul backends
+		name: "plain string prefix",
+		data: map[string]string{"/app/name": "app", "/application/name": "application"},
+		keys: []string{"/app"},
+		want: map[string]string{"/app/name": "app", "/application/name": "application"},
+	},
+	{
+		name: "trailing slash",
+		data: map[string]string{"/app/name": "easykv", "/application/name": "application"},
+		keys: []string{"/app/"},
+		want: map[string]string{"/app/name": "easykv"},
+	},
+	{
+		name: "exact key",
+		data: map[string]string{"/app/name": "easykv", "/app/version": "1"},
+		keys: []string{"/app/name"},
+		want: map[string]string{"/app/name": "easykv"},
+	},
+	{
+		name: "missing prefix",
+		data: map[string]string{"/app/name": "easykv"},
+		keys: []string{"/missing"},
+		want: map[string]string{},
+	},
+	{
+		name: "unicode",
+		data: map[string]string{"/app/ünïcödé/键": "värde ✓", "/app/emoji": "🚀"},
+		keys: []string{"/app"},
+		want: map[string]string{"/app/ünïcödé/键": "värde ✓", "/app/emoji": "🚀"},
+	},
+	{
+		name: "empty values",
+		data: map[string]string{"/app/empty": "", "/app/name": "easykv"},
+		keys: []string{"/app"},
+		want: map[string]string{"/app/empty": "", "/app/name": "easykv"},
+	},
+	{
+		name: "deep nesting",
+		data: map[string]string{"/a/b/c/d/e/f/g": "deep"},
+		keys: []string{"/a/b/c"},
+		want: map[string]string{"/a/b/c/d/e/f/g": "deep"},
+	},
+}
+
+// Names returns the names of all cases.
+func Names() []string {
+	names := make([]string, 0, len(getCases)+2)
+	for _, gc := range getCases {
+		names = append(names, gc.name)
+	}
+	return append(names, "watch cancellation", "watch change")
+}
+
+// root returns the root of the case name.
+func root(name string) string {
+	return Root + "/" + strings.Replace(name, " ", "-", -1)
+}
+
+// under returns vars with all keys moved below r.
+func under(r string, vars map[string]string) map[string]string {
+	moved := make(map[string]string, len(vars))
+	for k, v := range vars {
+		moved[r+k] = v
+	}
+	return moved
+}
+
+// Run runs all cases against the backends returned by setup.
+func Run(t T, setup Setup, opts ...Option) {
+	options := Options{Timeout: 10 * time.Second}
+	for _, o := range opts {
+		o(&options)
+	}
+	skip := make(map[string]bool)
+	for _, name := range options.Skip {
+		skip[name] = true
+	}
+
+	for _, gc := range getCases {
+		if skip[gc.name] {
+			continue
+		}
+		r := root(gc.name)
+		rw, err := setup(under(r, gc.data))
+		if err != nil {
+			t.Errorf("case %s: setup: %v", gc.name, err)
+			continue
+		}
+
+		keys := make([]string, len(gc.keys))
+		for i, k := range gc.keys {
+			keys[i] = r + k
+		}
+		vars, err := rw.GetValues(keys)
+		if err != nil {
+			t.Errorf("case %s: %v", gc.name, err)
+		} else if want := under(r, gc.want); !reflect.DeepEqual(vars, want) {
+			t.Errorf("case %s: got %v, want %v", gc.name, vars, want)
+		}
+		rw.Close()
+	}
+
+	if !skip["watch cancellation"] {
+		watchCancellation(t, setup, options)
+	}
+	if !skip["watch change"] && !options.NoWatch {
+		watchChange(t, setup, options)
+	}
+}
+
+// watch runs WatchPrefix in the background.
+func watch(ctx context.Context, rw easykv.ReadWatcher, prefix string) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := rw.WatchPrefix(ctx, prefix, easykv.WithKeys([]string{prefix}))
+		errc <- err
+	}()
+	return errc
+}
+
+// watchCancellation checks that a canceled watch returns easykv.ErrWatchCanceled.
+func watchCancellation(t T, setup Setup, options Options) {
+	const name = "watch cancellation"
+	r := root(name)
+	rw, err := setup(map[string]string{r + "/app/name": "easykv"})
+	if err != nil {
+		t.Errorf("case %s: setup: %v", name, err)
+		return
+	}
+	defer rw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := watch(ctx, rw, r+"/app")
+	cancel()
+
+	want := easykv.ErrWatchCanceled
+	if options.NoWatch {
+		want = easykv.ErrWatchNotSupported
+	}
+	select {
+	case err := <-errc:
+		if err != want {
+			t.Errorf("case %s: got %v, want %v", name, err, want)
+		}
+	case <-time.After(options.Timeout):
+		t.Errorf("case %s: WatchPrefix didn't return after the context was canceled", name)
+	}
+}
+
+// writeInterval is the time watchChange waits for the watch to return before it writes again.
+const writeInterval = 100 * time.Millisecond
+
+// watchChange checks that a write below the prefix ends the watch.
+// The value is written again until the watch returns, since a write
+// before the watch is established isn't seen by it.
+// It is skipped if the backend doesn't implement easykv.Writer.
+func watchChange(t T, setup Setup, options Options) {
+	const name = "watch change"
+	r := root(name)
+	rw, err := setup(map[string]string{r + "/app/name": "easykv"})
+	if err != nil {
+		t.Errorf("case %s: setup: %v", name, err)
+		return
+	}
+	defer rw.Close()
+
+	w, ok := rw.(easykv.Writer)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := watch(ctx, rw, r+"/app")
+	timeout := time.After(options.Timeout)
+	for i := 1; ; i++ {
+		if err := w.SetValues(map[string]string{r + "/app/name": fmt.Sprintf("changed %d", i)}); err != nil {
+			t.Errorf("case %s: write: %v", name, err)
+			return
+		}
+
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Errorf("case %s: %v", name, err)
+			}
+			return
+		case <-timeout:
+			t.Errorf("case %s: WatchPrefix didn't return after a change", name)
+			return
+		case <-time.After(writeInterval):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package conformance_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/conformance"
+	"github.com/HeavyHorst/easykv/file"
+	yaml "gopkg.in/yaml.v2"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+// nest converts flat keys into the nested maps of a yaml file.
+func nest(data map[string]string) map[string]interface{} {
+	root := make(map[string]interface{})
+	for k, v := range data {
+		parts := strings.Split(strings.Trim(k, "/"), "/")
+		node := root
+		for _, p := range parts[:len(parts)-1] {
+			child, ok := node[p].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[p] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = v
+	}
+	return root
+}
+
+func (s *FilterSuite) TestFile(t *C) {
+	dir := t.MkDir()
+	n := 0
+	conformance.Run(t, func(data map[string]string) (easykv.ReadWatcher, error) {
+		out, err := yaml.Marshal(nest(data))
+		if err != nil {
+			return nil, err
+		}
+		n++
+		p := filepath.Join(dir, fmt.Sprintf("%d.yml", n))
+		if err := ioutil.WriteFile(p, out, 0600); err != nil {
+			return nil, err
+		}
+		return file.New(p)
+	}, conformance.WithTimeout(5*time.Second))
+}
+
+// recordT records the reported failures.
+type recordT struct {
+	errors []string
+}
+
+func (r *recordT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (s *FilterSuite) TestReportsFailures(t *C) {
+	r := &recordT{}
+	conformance.Run(r, func(data map[string]string) (easykv.ReadWatcher, error) {
+		return nil, errors.New("no backend")
+	})
+	t.Check(r.errors, HasLen, len(conformance.Names()))
+	t.Check(r.errors[0], Equals, "case prefix: setup: no backend")
+}
@@ -0,0 +1,119 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package consul
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// catalogKey checks if key is below the catalog prefix and returns the rest of the key,
+// e.g. services/web for /_catalog/services/web.
+func (c *Client) catalogKey(key string) (string, bool) {
+	c.mu.RLock()
+	prefix := c.options.CatalogPrefix
+	c.mu.RUnlock()
+	if prefix == "" {
+		return "", false
+	}
+
+	prefix = path.Join("/", prefix)
+	key = path.Join("/", key)
+	if key != prefix && !strings.HasPrefix(key, prefix+"/") {
+		return "", false
+	}
+	return strings.Trim(strings.TrimPrefix(key, prefix), "/"), true
+}
+
+// readCatalog adds the healthy instances of the services below rest to vars:
+//
+//	<prefix>/services/<name>/<id>/address
+//	<prefix>/services/<name>/<id>/port
+//	<prefix>/services/<name>/<id>/tags  (comma separated)
+//	<prefix>/services/<name>/<id>/node
+func (c *Client) readCatalog(cli *api.Client, rest string, q *api.QueryOptions, vars map[string]string) error {
+	parts := strings.SplitN(rest, "/", 3)
+	if parts[0] != "" && parts[0] != "services" {
+		return nil
+	}
+
+	var names []string
+	if len(parts) > 1 && parts[1] != "" {
+		names = []string{parts[1]}
+	} else {
+		services, _, err := cli.Catalog().Services(q)
+		if err != nil {
+			return err
+		}
+		for name := range services {
+			names = append(names, name)
+		}
+	}
+
+	c.mu.RLock()
+	prefix := path.Join("/", c.options.CatalogPrefix)
+	c.mu.RUnlock()
+	want := path.Join(prefix, rest)
+
+	for _, name := range names {
+		entries, _, err := cli.Health().Service(name, "", true, q)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			address := e.Service.Address
+			if address == "" {
+				address = e.Node.Address
+			}
+			instance := path.Join(prefix, "services", name, e.Service.ID)
+			for k, v := range map[string]string{
+				"address": address,
+				"port":    strconv.Itoa(e.Service.Port),
+				"tags":    strings.Join(e.Service.Tags, ","),
+				"node":    e.Node.Node,
+			} {
+				key := path.Join(instance, k)
+				if key == want || strings.HasPrefix(key, want+"/") || want == prefix {
+					vars[key] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// blockingQuery runs a blocking query for prefix and returns the index of the response.
+// Below the catalog prefix, the health of a single service or of all services is watched.
+func (c *Client) blockingQuery(cli *api.Client, prefix string, q *api.QueryOptions) (uint64, error) {
+	rest, ok := c.catalogKey(prefix)
+	if !ok {
+		_, meta, err := cli.KV().List(prefix, q)
+		if err != nil {
+			return 0, err
+		}
+		return meta.LastIndex, nil
+	}
+
+	parts := strings.SplitN(rest, "/", 3)
+	var meta *api.QueryMeta
+	var err error
+	if len(parts) > 1 && parts[1] != "" {
+		_, meta, err = cli.Health().Service(parts[1], "", true, q)
+	} else {
+		// every registration, deregistration and health change touches the checks
+		_, meta, err = cli.Health().State(api.HealthAny, q)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return meta.LastIndex, nil
+}
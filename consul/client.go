@@ -16,16 +16,29 @@ package consul
 
 import (
 	"context"
+	"net"
+	"net/http"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
 	"github.com/hashicorp/consul/api"
 )
 
 // Client is a wrapper around the consul KV-client.
 type Client struct {
-	client *api.KV
+	nodes []string
+
+	mu        sync.RWMutex
+	apiClient *api.Client
+	client    *api.KV
+	options   Options
+	reloaded  chan struct{}
+
+	stopWatch context.CancelFunc
 }
 
 // New returns a new client to Consul for the given address.
@@ -35,52 +48,290 @@ func New(nodes []string, opts ...Option) (*Client, error) {
 		o(&options)
 	}
 
+	c := &Client{nodes: nodes}
+	if err := c.connect(options); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopWatch = cancel
+	go credentials.WatchRefs(ctx, func() error { return c.Reload() }, []credentials.Ref{options.TokenRef},
+		credentials.WithErrorHandler(func(err error) {
+			c.logger().Warn("can't reload consul credentials", "err", err)
+		}),
+	)
+	return c, nil
+}
+
+// connect creates a new consul client with the given options and replaces the current one.
+// The token reference is read again on every call.
+func (c *Client) connect(options Options) error {
 	conf := api.DefaultConfig()
 
-	conf.Scheme = options.Scheme
+	// agents are reached with https if TLS is configured and no scheme is given
+	scheme := options.Scheme
+	if scheme == "" && options.TLS.Enabled() {
+		scheme = "https"
+	}
 
-	if len(nodes) > 0 {
-		conf.Address = nodes[0]
+	if len(c.nodes) > 0 {
+		conf.Address = c.nodes[0]
 	}
 
-	tlsConfig := api.TLSConfig{}
-	if options.TLS.ClientCert != "" && options.TLS.ClientKey != "" {
-		tlsConfig.CertFile = options.TLS.ClientCert
-		tlsConfig.KeyFile = options.TLS.ClientKey
+	token, err := options.TokenRef.Resolve(options.Token)
+	if err != nil {
+		return err
 	}
+	if token != "" {
+		conf.Token = token
+	}
+	conf.Namespace = options.Namespace
+	conf.Partition = options.Partition
+	conf.Datacenter = options.Datacenter
 
-	if options.TLS.ClientCaKeys != "" {
-		tlsConfig.CAFile = options.TLS.ClientCaKeys
+	proxyFunc, err := easykv.ProxyFunc(options.Proxy)
+	if err != nil {
+		return err
 	}
+	conf.Transport.Proxy = proxyFunc
 
-	conf.TLSConfig = tlsConfig
+	switch {
+	case options.ConnectProxy != "":
+		// the local Connect proxy terminates the mTLS connection to the agent,
+		// all requests are sent to it with plain http
+		scheme = "http"
+		proxyAddr := options.ConnectProxy
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		conf.Transport.Proxy = nil
+		conf.Transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", proxyAddr)
+		}
+		conf.HttpClient = &http.Client{Transport: conf.Transport}
+	case options.TLS.Enabled():
+		tlsConfig, err := options.TLS.Config()
+		if err != nil {
+			return err
+		}
+		conf.Transport.TLSClientConfig = tlsConfig
+		conf.HttpClient = &http.Client{Transport: conf.Transport}
+	}
+	if scheme != "" {
+		conf.Scheme = scheme
+	}
 
 	client, err := api.NewClient(conf)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	c.mu.Lock()
+	c.apiClient = client
+	c.client = client.KV()
+	c.options = options
+	if c.reloaded != nil {
+		close(c.reloaded)
+	}
+	c.reloaded = make(chan struct{})
+	c.mu.Unlock()
+	return nil
+}
+
+// Reload applies opts on top of the current options and replaces the consul client.
+// This allows to rotate the ACL token and TLS certificates of a running client.
+// Running watches send their blocking query again with the new client.
+func (c *Client) Reload(opts ...Option) error {
+	c.mu.RLock()
+	options := c.options
+	c.mu.RUnlock()
+	for _, o := range opts {
+		o(&options)
+	}
+	if err := c.connect(options); err != nil {
+		easykv.LoggerOrDiscard(options.Logger).Warn("consul client reload failed", "err", err)
+		return err
 	}
-	return &Client{client.KV()}, nil
+	c.logger().Info("consul client reloaded")
+	return nil
 }
 
-// Close is only meant to fulfill the easykv.ReadWatcher interface.
-// Does nothing.
-func (c *Client) Close() {}
+// logger returns the current Logger.
+func (c *Client) logger() easykv.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return easykv.LoggerOrDiscard(c.options.Logger)
+}
+
+// queryOptions returns the options of a read in the datacenter dc.
+// The non-zero knobs of w take precedence over the QueryOptions of the client.
+func (c *Client) queryOptions(dc string, w easykv.WatchOptions) *api.QueryOptions {
+	c.mu.RLock()
+	q := c.options.Query
+	c.mu.RUnlock()
+
+	if w.MaxWait > 0 {
+		q.MaxWait = w.MaxWait
+	}
+	if w.Consistency != easykv.ConsistencyDefault {
+		q.Consistency = w.Consistency
+	}
+	q.UseCache = q.UseCache || w.UseCache
+
+	return &api.QueryOptions{
+		Datacenter:        dc,
+		WaitIndex:         w.WaitIndex,
+		WaitTime:          q.MaxWait,
+		AllowStale:        q.Consistency == easykv.ConsistencyStale,
+		RequireConsistent: q.Consistency == easykv.ConsistencyConsistent,
+		UseCache:          q.UseCache,
+	}
+}
+
+// aclNotFound checks if consul doesn't know the ACL token, e.g. because it was rotated.
+func aclNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ACL not found")
+}
+
+// api returns the current consul client.
+func (c *Client) api() *api.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.apiClient
+}
+
+// withToken runs f with the current consul client. If consul rejects the ACL token
+// and the token is read from a file or an environment variable, the token is
+// read again and f runs once more, so a rotated token works before the file watcher notices it.
+func (c *Client) withToken(f func(cli *api.Client) error) error {
+	cli := c.api()
+	err := f(cli)
+	if !c.reloadToken(cli, err) {
+		return err
+	}
+	return f(c.api())
+}
+
+// reloadToken reads the token again if err rejected the token of cli.
+// It reports whether the request should be sent again.
+func (c *Client) reloadToken(cli *api.Client, err error) bool {
+	if !aclNotFound(err) {
+		return false
+	}
+	c.mu.RLock()
+	ref, current := c.options.TokenRef, c.apiClient
+	c.mu.RUnlock()
+	if ref.IsZero() {
+		return false
+	}
+	if current == cli {
+		if rerr := c.Reload(); rerr != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Close stops watching the referenced token file.
+func (c *Client) Close() {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+}
 
 // GetValues is used to lookup all keys with a prefix.
-// Several prefixes can be specified in the keys array.
+// Several prefixes can be specified in the keys array, they are read in a single transaction.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
+	vars, _, err := c.getValues(keys, "", nil)
+	return vars, err
+}
+
+// GetValuesWithIndex is used to lookup all keys with a prefix like GetValues.
+// It additionally returns the index of the read, which can be passed to WatchPrefix
+// with easykv.WithAfterIndex to watch exactly the changes after the read.
+func (c *Client) GetValuesWithIndex(keys []string) (map[string]string, uint64, error) {
+	return c.getValues(keys, "", nil)
+}
+
+// GetValuesExplain is used to lookup all keys with a prefix.
+// It additionally returns all LIST and TXN requests that were sent to consul.
+func (c *Client) GetValuesExplain(keys []string) (map[string]string, []easykv.Operation, error) {
+	var tr easykv.Trace
+	vars, _, err := c.getValues(keys, "", &tr)
+	return vars, tr.Operations(), err
+}
+
+// getValues reads all prefixes from the datacenter dc, or the datacenter of the client if dc is empty.
+// It returns the index of the KV read.
+func (c *Client) getValues(keys []string, dc string, tr *easykv.Trace) (map[string]string, uint64, error) {
 	vars := make(map[string]string)
-	for _, key := range keys {
-		key := strings.TrimPrefix(key, "/")
-		pairs, _, err := c.client.List(key, nil)
-		if err != nil {
-			return vars, err
+	index, err := c.read(keys, dc, tr, vars, func(p *api.KVPair) {
+		vars[path.Join("/", p.Key)] = string(p.Value)
+	})
+	return vars, index, err
+}
+
+// GetValuesWithMetadata is used to lookup all keys with a prefix like GetValues.
+// It additionally returns the flags and the create and modify index of the keys,
+// the pseudo keys of the catalog only have a value.
+func (c *Client) GetValuesWithMetadata(keys []string) (map[string]easykv.KeyMetadata, error) {
+	md := make(map[string]easykv.KeyMetadata)
+	catalog := make(map[string]string)
+	_, err := c.read(keys, "", nil, catalog, func(p *api.KVPair) {
+		md[path.Join("/", p.Key)] = easykv.KeyMetadata{
+			Value:       string(p.Value),
+			CreateIndex: p.CreateIndex,
+			ModifyIndex: p.ModifyIndex,
+			Flags:       p.Flags,
 		}
-		for _, p := range pairs {
-			vars[path.Join("/", p.Key)] = string(p.Value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range catalog {
+		md[k] = easykv.KeyMetadata{Value: v}
+	}
+	return md, nil
+}
+
+// read calls visit for every key below the prefixes in the datacenter dc and adds the
+// pseudo keys of the catalog to catalog. A single prefix is listed, several ones are read
+// in a transaction. It returns the index of the KV read.
+func (c *Client) read(keys []string, dc string, tr *easykv.Trace, catalog map[string]string, visit func(*api.KVPair)) (uint64, error) {
+	var prefixes []string
+	for _, key := range keys {
+		if rest, ok := c.catalogKey(key); ok {
+			start := time.Now()
+			err := c.withToken(func(cli *api.Client) error {
+				return c.readCatalog(cli, rest, c.queryOptions(dc, easykv.WatchOptions{}), catalog)
+			})
+			tr.Record("CATALOG", key, start, err)
+			if err != nil {
+				return 0, err
+			}
+			continue
 		}
+		prefixes = append(prefixes, strings.TrimPrefix(key, "/"))
+	}
+
+	if len(prefixes) != 1 {
+		return c.readTxn(prefixes, dc, tr, visit)
+	}
+
+	start := time.Now()
+	var pairs api.KVPairs
+	var meta *api.QueryMeta
+	err := c.withToken(func(cli *api.Client) error {
+		var err error
+		pairs, meta, err = cli.KV().List(prefixes[0], c.queryOptions(dc, easykv.WatchOptions{}))
+		return err
+	})
+	tr.Record("LIST", prefixes[0], start, err)
+	if err != nil {
+		return 0, err
 	}
-	return vars, nil
+	for _, p := range pairs {
+		visit(p)
+	}
+	return meta.LastIndex, nil
 }
 
 type watchResponse struct {
@@ -89,30 +340,155 @@ type watchResponse struct {
 }
 
 // WatchPrefix watches a specific prefix for changes.
+// Without a WaitIndex, the AfterIndex, e.g. the index of GetValuesWithIndex, is the index of the blocking query.
+// If the index of consul went backwards, the new index is returned. With a MinInterval,
+// the watch returns at most once per interval.
+// Failed watches are retried if a Backoff is set in the WatchOptions.
 func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
 	var options easykv.WatchOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
-	respChan := make(chan watchResponse)
-	go func() {
-		opts := api.QueryOptions{
-			WaitIndex: options.WaitIndex,
-		}
-		_, meta, err := c.client.List(prefix, &opts)
-		if err != nil {
-			respChan <- watchResponse{options.WaitIndex, err}
-			return
-		}
-		respChan <- watchResponse{meta.LastIndex, err}
-	}()
+	return options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, "", options)
+	})
+}
+
+// watchPrefix runs a single watch in the datacenter dc.
+func (c *Client) watchPrefix(ctx context.Context, prefix, dc string, options easykv.WatchOptions) (uint64, error) {
+	if err := options.ResumeIndex(prefix); err != nil {
+		return 0, err
+	}
+	if options.WaitIndex == 0 {
+		options.WaitIndex = options.AfterIndex
+	}
+
+	logger := c.logger()
+	logger.Debug("watching consul prefix", "prefix", prefix, "datacenter", dc, "waitIndex", options.WaitIndex)
+
+	started := time.Now()
+	retried := false
 	for {
+		c.mu.RLock()
+		cli, reloaded := c.apiClient, c.reloaded
+		c.mu.RUnlock()
+
+		// the blocking query is sent again if the client is reloaded in the meantime
+		qctx, cancel := context.WithCancel(ctx)
+		respChan := make(chan watchResponse, 1)
+		go func() {
+			index, err := c.blockingQuery(cli, prefix, c.queryOptions(dc, options).WithContext(qctx))
+			if err != nil {
+				respChan <- watchResponse{options.WaitIndex, err}
+				return
+			}
+			respChan <- watchResponse{index, err}
+		}()
+
 		select {
 		case <-ctx.Done():
+			cancel()
 			return options.WaitIndex, easykv.ErrWatchCanceled
+		case <-reloaded:
+			cancel()
+			logger.Debug("consul client reloaded, restarting watch", "prefix", prefix)
 		case r := <-respChan:
-			return r.waitIndex, r.err
+			cancel()
+			if r.err != nil {
+				if !retried && c.reloadToken(cli, r.err) {
+					retried = true
+					continue
+				}
+				logger.Warn("consul watch failed", "prefix", prefix, "err", r.err)
+				throttle(ctx, started, options.MinInterval)
+				return r.waitIndex, r.err
+			}
+
+			index := r.waitIndex
+			if index < options.WaitIndex {
+				// e.g. after a snapshot restore, report a change and block on the new index
+				logger.Warn("consul index went backwards, resetting the watch", "prefix", prefix,
+					"waitIndex", options.WaitIndex, "index", index)
+			}
+			if index == 0 {
+				// consul indexes start at 1, 0 would turn the next query into a non-blocking one
+				index = 1
+			}
+			throttle(ctx, started, options.MinInterval)
+			return index, options.SaveIndex(prefix, index)
+		}
+	}
+}
+
+// throttle waits until d has passed since start or ctx is canceled.
+func throttle(ctx context.Context, start time.Time, d time.Duration) {
+	wait := d - time.Since(start)
+	if wait <= 0 {
+		return
+	}
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+	}
+}
+
+// SetValues writes all key-value pairs to consul.
+func (c *Client) SetValues(values map[string]string) error {
+	for k, v := range values {
+		err := c.withToken(func(cli *api.Client) error {
+			_, err := cli.KV().Put(&api.KVPair{Key: strings.TrimPrefix(k, "/"), Value: []byte(v)}, nil)
+			return err
+		})
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// DeleteValues deletes all given keys from consul.
+func (c *Client) DeleteValues(keys []string) error {
+	for _, k := range keys {
+		err := c.withToken(func(cli *api.Client) error {
+			_, err := cli.KV().Delete(strings.TrimPrefix(k, "/"), nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetValue returns the value of a single key.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	return c.getValue(ctx, key, "")
+}
+
+func (c *Client) getValue(ctx context.Context, key, dc string) (string, error) {
+	if _, ok := c.catalogKey(key); ok {
+		vars, _, err := c.getValues([]string{key}, dc, nil)
+		if err != nil {
+			return "", err
+		}
+		if v, ok := vars[path.Join("/", key)]; ok {
+			return v, nil
+		}
+		return "", easykv.ErrKeyNotFound
+	}
+
+	var pair *api.KVPair
+	err := c.withToken(func(cli *api.Client) error {
+		var err error
+		pair, _, err = cli.KV().Get(strings.TrimPrefix(key, "/"), c.queryOptions(dc, easykv.WatchOptions{}).WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if pair == nil {
+		return "", easykv.ErrKeyNotFound
+	}
+	return string(pair.Value), nil
 }
@@ -10,10 +10,22 @@ package consul
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/conformance"
+	"github.com/HeavyHorst/easykv/credentials"
 	"github.com/HeavyHorst/easykv/testutils"
 	"github.com/hashicorp/consul/api"
 
@@ -80,3 +92,399 @@ func (s *FilterSuite) TestWatchPrefixCancel(t *C) {
 	cancel()
 	wg.Wait()
 }
+
+func (s *FilterSuite) TestReload(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "new-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "ACL not found")
+			return
+		}
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"), WithToken("old-token"))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/app"})
+	t.Check(err, NotNil)
+
+	t.Assert(c.Reload(WithToken("new-token")), IsNil)
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+}
+
+func (s *FilterSuite) TestNamespace(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ns") != "team-a" || r.URL.Query().Get("partition") != "web" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"), WithNamespace("team-a"), WithPartition("web"))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+
+	index, err := c.WatchPrefix(context.Background(), "app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+}
+
+func (s *FilterSuite) TestDatacenter(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprintf(w, `[{"Key": "dc", "Value": "%s"}]`, base64.StdEncoding.EncodeToString([]byte(r.URL.Query().Get("dc"))))
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"), WithDatacenter("dc1"))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/dc"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/dc": "dc1"})
+
+	dc2 := c.Datacenter("dc2")
+	vars, err = dc2.GetValues([]string{"/dc"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/dc": "dc2"})
+	v, err := dc2.GetValue(context.Background(), "/dc")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "dc2")
+	index, err := dc2.WatchPrefix(context.Background(), "dc")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+}
+
+func (s *FilterSuite) TestQueryOptions(t *C) {
+	var mu sync.Mutex
+	var queries []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"),
+		WithQueryOptions(QueryOptions{MaxWait: time.Minute, Consistency: easykv.ConsistencyStale, UseCache: true}))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	_, err = c.WatchPrefix(context.Background(), "app", easykv.WithWaitIndex(7),
+		easykv.WithMaxWait(10*time.Second), easykv.WithConsistency(easykv.ConsistencyConsistent))
+	t.Assert(err, IsNil)
+
+	t.Assert(queries, HasLen, 2)
+	_, stale := queries[0]["stale"]
+	_, cached := queries[0]["cached"]
+	t.Check(stale && cached, Equals, true)
+
+	_, stale = queries[1]["stale"]
+	_, consistent := queries[1]["consistent"]
+	t.Check(stale, Equals, false)
+	t.Check(consistent, Equals, true)
+	t.Check(queries[1].Get("index"), Equals, "7")
+	t.Check(queries[1].Get("wait"), Equals, "10000ms")
+}
+
+func (s *FilterSuite) TestTxn(t *C) {
+	var mu sync.Mutex
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("index"))
+		mu.Unlock()
+		w.Header().Set("X-Consul-Index", "42")
+		if r.URL.Path == "/v1/txn" {
+			fmt.Fprint(w, `{"Results": [{"KV": {"Key": "app/name", "Value": "ZWFzeWt2"}}, {"KV": {"Key": "db/url", "Value": "bG9jYWxob3N0"}}]}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"))
+	t.Assert(err, IsNil)
+	vars, index, err := c.GetValuesWithIndex([]string{"/app", "/db"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv", "/db/url": "localhost"})
+	t.Check(index, Equals, uint64(42))
+
+	_, err = c.WatchPrefix(context.Background(), "app", easykv.WithAfterIndex(index))
+	t.Assert(err, IsNil)
+	t.Check(requests, DeepEquals, []string{"PUT /v1/txn ", "GET /v1/kv/app 42"})
+}
+
+func (s *FilterSuite) TestGetValuesWithMetadata(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/feature", "Value": "b24=", "Flags": 3, "CreateIndex": 7, "ModifyIndex": 9}]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"))
+	t.Assert(err, IsNil)
+	md, err := easykv.GetValuesWithMetadata(c, []string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(md, DeepEquals, map[string]easykv.KeyMetadata{
+		"/app/feature": {Value: "on", CreateIndex: 7, ModifyIndex: 9, Flags: 3},
+	})
+}
+
+func (s *FilterSuite) TestWatchIndexReset(t *C) {
+	var mu sync.Mutex
+	index := "5"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		w.Header().Set("X-Consul-Index", index)
+		mu.Unlock()
+		fmt.Fprint(w, `[]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"))
+	t.Assert(err, IsNil)
+
+	// the index went backwards
+	i, err := c.WatchPrefix(context.Background(), "app", easykv.WithWaitIndex(42))
+	t.Assert(err, IsNil)
+	t.Check(i, Equals, uint64(5))
+
+	mu.Lock()
+	index = "0"
+	mu.Unlock()
+	i, err = c.WatchPrefix(context.Background(), "app", easykv.WithWaitIndex(5))
+	t.Assert(err, IsNil)
+	t.Check(i, Equals, uint64(1))
+}
+
+func (s *FilterSuite) TestWatchMinInterval(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"))
+	t.Assert(err, IsNil)
+
+	start := time.Now()
+	_, err = c.WatchPrefix(context.Background(), "app", easykv.WithMinInterval(200*time.Millisecond))
+	t.Check(err, NotNil)
+	t.Check(time.Since(start) >= 200*time.Millisecond, Equals, true)
+
+	// a canceled watch doesn't wait
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = c.WatchPrefix(ctx, "app", easykv.WithMinInterval(time.Minute))
+	t.Check(err, NotNil)
+	t.Check(time.Since(start) < time.Second, Equals, true)
+}
+
+func (s *FilterSuite) TestTLS(t *C) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	ca := filepath.Join(t.MkDir(), "ca.pem")
+	t.Assert(ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600), IsNil)
+
+	// the scheme defaults to https with TLS
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "https://")}, WithTLSOptions(TLSOptions{ClientCaKeys: ca, ServerName: "example.com"}))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+}
+
+func (s *FilterSuite) TestConnectProxy(t *C) {
+	var host string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{"consul.service.consul:8500"}, WithConnectProxy(strings.TrimPrefix(ts.URL, "http://")),
+		WithTLSOptions(TLSOptions{ClientCaKeys: "/nonexistent/ca.pem"}))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+	t.Check(host, Equals, "consul.service.consul:8500")
+}
+
+func (s *FilterSuite) TestTokenFileRotation(t *C) {
+	var mu sync.Mutex
+	token := "token-1"
+	watches := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		valid := r.Header.Get("X-Consul-Token") == token
+		if valid && r.URL.Query().Get("index") != "" {
+			watches[token]++
+		}
+		mu.Unlock()
+		if !valid {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "ACL not found")
+			return
+		}
+		if r.URL.Query().Get("index") != "" {
+			// block until the client gives up
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	dir := t.MkDir()
+	p := filepath.Join(dir, "token")
+	t.Assert(ioutil.WriteFile(p, []byte("token-1\n"), 0600), IsNil)
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"), WithTokenFrom(credentials.File(p)))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	// the running watch restarts with the rotated token
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.WatchPrefix(ctx, "app", easykv.WithWaitIndex(42))
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// consul rejects the old token before the file watcher notices the rotation
+	mu.Lock()
+	token = "token-2"
+	mu.Unlock()
+	t.Assert(ioutil.WriteFile(p, []byte("token-2\n"), 0600), IsNil)
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	t.Check(<-done, Equals, easykv.ErrWatchCanceled)
+	mu.Lock()
+	t.Check(watches, DeepEquals, map[string]int{"token-1": 1, "token-2": 1})
+	mu.Unlock()
+}
+
+func (s *FilterSuite) TestCatalog(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "42")
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			fmt.Fprint(w, `{"web": ["http"], "db": []}`)
+		case "/v1/health/service/web":
+			t.Check(r.URL.Query().Get("passing"), Equals, "1")
+			fmt.Fprint(w, `[{"Node": {"Node": "node-1", "Address": "10.0.0.1"},
+				"Service": {"ID": "web-1", "Service": "web", "Port": 8080, "Tags": ["http", "v2"]}}]`)
+		case "/v1/health/service/db":
+			fmt.Fprint(w, `[{"Node": {"Node": "node-2", "Address": "10.0.0.2"},
+				"Service": {"ID": "db-1", "Service": "db", "Address": "10.0.1.2", "Port": 5432}}]`)
+		case "/v1/health/state/any":
+			w.Header().Set("X-Consul-Index", "43")
+			fmt.Fprint(w, `[]`)
+		case "/v1/kv/app":
+			fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"), WithCatalog("/_catalog"))
+	t.Assert(err, IsNil)
+
+	vars, err := c.GetValues([]string{"/_catalog/services/web", "/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/_catalog/services/web/web-1/address": "10.0.0.1",
+		"/_catalog/services/web/web-1/port":    "8080",
+		"/_catalog/services/web/web-1/tags":    "http,v2",
+		"/_catalog/services/web/web-1/node":    "node-1",
+		"/app/name":                            "easykv",
+	})
+
+	vars, err = c.GetValues([]string{"/_catalog"})
+	t.Assert(err, IsNil)
+	t.Check(vars, HasLen, 8)
+	t.Check(vars["/_catalog/services/db/db-1/address"], Equals, "10.0.1.2")
+
+	v, err := c.GetValue(context.Background(), "/_catalog/services/db/db-1/port")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "5432")
+
+	index, err := c.WatchPrefix(context.Background(), "/_catalog/services/web", easykv.WithWaitIndex(41))
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+	index, err = c.WatchPrefix(context.Background(), "/_catalog", easykv.WithWaitIndex(41))
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(43))
+}
+
+func (s *FilterSuite) TestWatchBackoff(t *C) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "no cluster leader")
+			return
+		}
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[{"Key": "app/name", "Value": "ZWFzeWt2"}]`)
+	}))
+	defer ts.Close()
+
+	c, err := New([]string{strings.TrimPrefix(ts.URL, "http://")}, WithScheme("http"))
+	t.Assert(err, IsNil)
+	index, err := c.WatchPrefix(context.Background(), "app", easykv.WithBackoff(easykv.BackoffOptions{Initial: time.Millisecond}))
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+	t.Check(requests, Equals, 3)
+}
+
+func (s *FilterSuite) TestLock(t *C) {
+	c, err := New([]string{"localhost:8500"}, WithScheme("http"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	testutils.Lock(t, c, "/locktest")
+	testutils.LockValues(t, c, "/lockvaluetest")
+}
+
+func (s *FilterSuite) TestConformance(t *C) {
+	conformance.Run(t, func(data map[string]string) (easykv.ReadWatcher, error) {
+		c, err := New([]string{"localhost:8500"}, WithScheme("http"))
+		if err != nil {
+			return nil, err
+		}
+		if err := c.SetValues(data); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	})
+}
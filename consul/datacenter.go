@@ -0,0 +1,55 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package consul
+
+import (
+	"context"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// DatacenterClient reads and watches the keys of a single datacenter
+// with the connection of a Client, e.g. the central datacenter of config
+// that is replicated with consul-replicate.
+type DatacenterClient struct {
+	client     *Client
+	datacenter string
+}
+
+// Datacenter returns a client for the datacenter dc that shares the connection,
+// token and reloads of c.
+func (c *Client) Datacenter(dc string) *DatacenterClient {
+	return &DatacenterClient{client: c, datacenter: dc}
+}
+
+// GetValues is used to lookup all keys with a prefix in the datacenter.
+func (d *DatacenterClient) GetValues(keys []string) (map[string]string, error) {
+	vars, _, err := d.client.getValues(keys, d.datacenter, nil)
+	return vars, err
+}
+
+// GetValue returns the value of a single key in the datacenter.
+func (d *DatacenterClient) GetValue(ctx context.Context, key string) (string, error) {
+	return d.client.getValue(ctx, key, d.datacenter)
+}
+
+// WatchPrefix watches a specific prefix in the datacenter for changes.
+func (d *DatacenterClient) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options.Retry(ctx, func() (uint64, error) {
+		return d.client.watchPrefix(ctx, prefix, d.datacenter, options)
+	})
+}
+
+// Close does nothing, the connection is closed with the Client.
+func (d *DatacenterClient) Close() {}
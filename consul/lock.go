@@ -0,0 +1,113 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package consul
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/hashicorp/consul/api"
+)
+
+// minSessionTTL is the smallest session ttl consul accepts.
+const minSessionTTL = 10 * time.Second
+
+// session is a lock held with a consul session.
+// The session is renewed by the client and deletes the keys it holds when it ends.
+type session struct {
+	lock   *api.Lock
+	client *api.Client
+	id     string
+	lost   <-chan struct{}
+	once   sync.Once
+	stop   chan struct{}
+}
+
+func (s *session) Done() <-chan struct{} {
+	return s.lost
+}
+
+// SetValue acquires key with the session and writes value to it,
+// the key is deleted when the session is destroyed or invalidated.
+func (s *session) SetValue(ctx context.Context, key, value string) error {
+	select {
+	case <-s.lost:
+		return easykv.ErrLockLost
+	case <-s.stop:
+		return easykv.ErrLockLost
+	default:
+	}
+	p := &api.KVPair{Key: strings.TrimPrefix(key, "/"), Value: []byte(value), Session: s.id}
+	ok, _, err := s.client.KV().Acquire(p, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("can't acquire %s with the lock session", key)
+	}
+	return nil
+}
+
+func (s *session) Unlock() error {
+	err := s.lock.Unlock()
+	s.once.Do(func() { close(s.stop) })
+	if _, derr := s.client.Session().Destroy(s.id, nil); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// Lock acquires the lock on key with a consul session.
+// Consul doesn't accept session ttls below 10 seconds, smaller values are raised.
+func (c *Client) Lock(ctx context.Context, key string, ttl time.Duration) (easykv.Session, error) {
+	if ttl < minSessionTTL {
+		ttl = minSessionTTL
+	}
+
+	c.mu.RLock()
+	client := c.apiClient
+	c.mu.RUnlock()
+
+	id, _, err := client.Session().Create(&api.SessionEntry{
+		Name:     "easykv lock " + key,
+		TTL:      ttl.String(),
+		Behavior: api.SessionBehaviorDelete,
+	}, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	stop := make(chan struct{})
+	go client.Session().RenewPeriodic(ttl.String(), id, nil, stop)
+
+	lock, err := client.LockOpts(&api.LockOptions{
+		Key:     strings.TrimPrefix(key, "/"),
+		Session: id,
+	})
+	if err != nil {
+		close(stop)
+		return nil, err
+	}
+
+	lost, err := lock.Lock(ctx.Done())
+	if err != nil {
+		close(stop)
+		return nil, err
+	}
+	if lost == nil {
+		// the stop channel was closed before the lock was acquired
+		close(stop)
+		return nil, ctx.Err()
+	}
+	c.logger().Debug("acquired consul lock", "key", key, "session", id)
+	return &session{lock: lock, client: client, id: id, lost: lost, stop: stop}, nil
+}
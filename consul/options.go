@@ -8,32 +8,142 @@
 
 package consul
 
+import (
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
 // Options contains all values that are needed to connect to consul.
 type Options struct {
-	Scheme string
-	TLS    TLSOptions
+	Scheme   string
+	Token    string
+	TokenRef credentials.Ref
+	TLS      TLSOptions
+	Proxy    string
+	Logger   easykv.Logger
+
+	// Namespace and Partition scope all requests in Consul Enterprise.
+	Namespace string
+	Partition string
+
+	// Datacenter is the datacenter of all requests, the datacenter of the agent by default.
+	Datacenter string
+
+	Query QueryOptions
+
+	// CatalogPrefix is the prefix of the pseudo keys of the service catalog.
+	CatalogPrefix string
+
+	// ConnectProxy is the address of a local Connect proxy all requests are sent through.
+	ConnectProxy string
 }
 
-// TLSOptions contains all certificates and keys.
-type TLSOptions struct {
-	ClientCert   string
-	ClientKey    string
-	ClientCaKeys string
+// QueryOptions tunes the reads and blocking queries of the client.
+// MaxWait limits how long a blocking query waits for a change, 5m by default.
+// Consistency allows stale reads or requires consistent ones, UseCache answers
+// reads from the agent cache on the endpoints that support it.
+// The WatchOptions of a single watch take precedence.
+type QueryOptions struct {
+	MaxWait     time.Duration
+	Consistency easykv.Consistency
+	UseCache    bool
 }
 
+// TLSOptions contains all certificates and keys.
+type TLSOptions = easykv.TLSOptions
+
 // Option configures the consul client.
 type Option func(*Options)
 
 // WithScheme sets the consul uri scheme.
+// Defaults to https if TLS is configured and to http otherwise.
 func WithScheme(scheme string) Option {
 	return func(o *Options) {
 		o.Scheme = scheme
 	}
 }
 
+// WithToken sets the consul ACL token.
+func WithToken(token string) Option {
+	return func(o *Options) {
+		o.Token = token
+	}
+}
+
+// WithTokenFrom reads the consul ACL token from a file or an environment variable.
+func WithTokenFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.TokenRef = ref
+	}
+}
+
 // WithTLSOptions sets the TLSOptions.
 func WithTLSOptions(tls TLSOptions) Option {
 	return func(o *Options) {
 		o.TLS = tls
 	}
 }
+
+// WithProxy sets the URL of the http, https or socks5 proxy.
+// Defaults to the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables,
+// easykv.NoProxy disables proxies.
+func WithProxy(rawurl string) Option {
+	return func(o *Options) {
+		o.Proxy = rawurl
+	}
+}
+
+// WithNamespace sets the Consul Enterprise namespace of all reads, writes and watches.
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.Namespace = namespace
+	}
+}
+
+// WithPartition sets the Consul Enterprise admin partition of all reads, writes and watches.
+func WithPartition(partition string) Option {
+	return func(o *Options) {
+		o.Partition = partition
+	}
+}
+
+// WithDatacenter sets the datacenter of all requests. Client.Datacenter reads another one per call.
+func WithDatacenter(dc string) Option {
+	return func(o *Options) {
+		o.Datacenter = dc
+	}
+}
+
+// WithQueryOptions sets the QueryOptions of all reads and watches.
+func WithQueryOptions(q QueryOptions) Option {
+	return func(o *Options) {
+		o.Query = q
+	}
+}
+
+// WithCatalog exposes the healthy instances of the services in the catalog as pseudo keys
+// below prefix, e.g. /_catalog/services/web/<id>/address. WatchPrefix reports changes
+// of the instances and their health.
+func WithCatalog(prefix string) Option {
+	return func(o *Options) {
+		o.CatalogPrefix = prefix
+	}
+}
+
+// WithConnectProxy sends all requests to the local Connect proxy at addr, e.g. the
+// upstream listener of a sidecar for the consul service. The proxy establishes the
+// mTLS connection, so the requests are sent with plain http and the TLSOptions are ignored.
+func WithConnectProxy(addr string) Option {
+	return func(o *Options) {
+		o.ConnectProxy = addr
+	}
+}
+
+// WithLogger sets the Logger that reports reloads and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package consul
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/hashicorp/consul/api"
+)
+
+// maxTxnOps is the number of operations consul accepts in a single transaction.
+const maxTxnOps = 64
+
+// txnError returns the errors of a rolled back transaction.
+func txnError(errs api.TxnErrors) error {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.What)
+	}
+	return errors.New("consul transaction failed: " + strings.Join(msgs, ", "))
+}
+
+// readTxn reads the prefixes with the get-tree operations of a transaction and calls visit
+// for every key, so all of them are read from the same snapshot in a single round trip. More than maxTxnOps prefixes are
+// split into several transactions. It returns the index of the oldest snapshot.
+func (c *Client) readTxn(prefixes []string, dc string, tr *easykv.Trace, visit func(*api.KVPair)) (uint64, error) {
+	var index uint64
+	for len(prefixes) > 0 {
+		n := len(prefixes)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		batch := prefixes[:n]
+		prefixes = prefixes[n:]
+
+		ops := make(api.TxnOps, 0, len(batch))
+		for _, p := range batch {
+			ops = append(ops, &api.TxnOp{KV: &api.KVTxnOp{Verb: api.KVGetTree, Key: p}})
+		}
+
+		start := time.Now()
+		var resp *api.TxnResponse
+		var meta *api.QueryMeta
+		err := c.withToken(func(cli *api.Client) error {
+			var ok bool
+			var err error
+			ok, resp, meta, err = cli.Txn().Txn(ops, c.queryOptions(dc, easykv.WatchOptions{}))
+			if err == nil && !ok {
+				err = txnError(resp.Errors)
+			}
+			return err
+		})
+		tr.Record("TXN", strings.Join(batch, ","), start, err)
+		if err != nil {
+			return index, err
+		}
+
+		for _, r := range resp.Results {
+			if r.KV != nil {
+				visit(r.KV)
+			}
+		}
+		if index == 0 || meta.LastIndex < index {
+			index = meta.LastIndex
+		}
+	}
+	return index, nil
+}
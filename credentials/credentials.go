@@ -0,0 +1,79 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package credentials watches credential files (tokens, passwords, certificates)
+// to reload them into running clients.
+//
+// Example:
+//
+//	go credentials.WatchFile(ctx, "/run/secrets/vault-token", func(data []byte) error {
+//		return client.Reload(vault.WithToken(strings.TrimSpace(string(data))))
+//	})
+//
+// The backends accept a Ref wherever a credential can be passed inline,
+// to read it from a file or an environment variable. Clients reload themselves
+// when a referenced file changes:
+//
+//	vault.New(address, "approle",
+//		vault.WithRoleIDFrom(credentials.Env("VAULT_ROLE_ID")),
+//		vault.WithSecretIDFrom(credentials.File("/run/secrets/vault-secret-id")),
+//	)
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+)
+
+// WatchFile calls reload with the new contents of the file at path whenever they change.
+// The file is polled, so it also works for atomically replaced files like kubernetes secrets.
+// If reload fails, it is called again with the same contents in the next interval.
+// WatchFile blocks until ctx is canceled.
+func WatchFile(ctx context.Context, path string, reload func(data []byte) error, opts ...Option) {
+	options := Options{Interval: DefaultInterval}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	handle := func(err error) {
+		if options.ErrorHandler != nil {
+			options.ErrorHandler(err)
+		}
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		handle(err)
+	}
+
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			handle(err)
+			continue
+		}
+		if bytes.Equal(data, current) {
+			continue
+		}
+		if err := reload(data); err != nil {
+			handle(err)
+			continue
+		}
+		current = data
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package credentials
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestWatchFile(t *C) {
+	p := filepath.Join(t.MkDir(), "token")
+	t.Assert(ioutil.WriteFile(p, []byte("old"), 0600), IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan string, 10)
+	errs := make(chan error, 10)
+	fail := true
+	go WatchFile(ctx, p, func(data []byte) error {
+		if fail {
+			fail = false
+			return errors.New("reload failed")
+		}
+		reloads <- string(data)
+		return nil
+	}, WithInterval(10*time.Millisecond), WithErrorHandler(func(err error) { errs <- err }))
+
+	time.Sleep(50 * time.Millisecond)
+	t.Assert(ioutil.WriteFile(p, []byte("new"), 0600), IsNil)
+
+	select {
+	case err := <-errs:
+		t.Check(err, ErrorMatches, "reload failed")
+	case <-time.After(time.Second):
+		t.Fatal("reload wasn't called")
+	}
+
+	select {
+	case data := <-reloads:
+		t.Check(data, Equals, "new")
+	case <-time.After(time.Second):
+		t.Fatal("failed reload wasn't retried")
+	}
+
+	select {
+	case data := <-reloads:
+		t.Fatalf("unexpected reload with %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *FilterSuite) TestRef(t *C) {
+	v, err := Ref{}.Resolve("inline")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "inline")
+
+	p := filepath.Join(t.MkDir(), "password")
+	t.Assert(ioutil.WriteFile(p, []byte("  from-file\n"), 0600), IsNil)
+	v, err = File(p).Resolve("inline")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "from-file")
+
+	os.Setenv("EASYKV_TEST_PASSWORD", "from-env")
+	defer os.Unsetenv("EASYKV_TEST_PASSWORD")
+	v, err = Env("EASYKV_TEST_PASSWORD").Resolve("inline")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "from-env")
+
+	_, err = Env("EASYKV_TEST_MISSING").Resolve("inline")
+	t.Check(err, ErrorMatches, "environment variable EASYKV_TEST_MISSING is not set")
+	_, err = File(p + ".missing").Resolve("inline")
+	t.Check(err, NotNil)
+}
+
+func (s *FilterSuite) TestWatchRefs(t *C) {
+	p := filepath.Join(t.MkDir(), "token")
+	t.Assert(ioutil.WriteFile(p, []byte("old"), 0600), IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		WatchRefs(ctx, func() error {
+			reloads <- struct{}{}
+			return nil
+		}, []Ref{Env("EASYKV_TEST_TOKEN"), File(p)}, WithInterval(10*time.Millisecond))
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	t.Assert(ioutil.WriteFile(p, []byte("new"), 0600), IsNil)
+
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("reload wasn't called")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WatchRefs didn't return")
+	}
+}
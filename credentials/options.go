@@ -0,0 +1,38 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package credentials
+
+import "time"
+
+// DefaultInterval is the default interval in which a file is checked for changes.
+const DefaultInterval = 10 * time.Second
+
+// Options configures WatchFile.
+type Options struct {
+	Interval     time.Duration
+	ErrorHandler func(error)
+}
+
+// Option configures WatchFile.
+type Option func(*Options)
+
+// WithInterval sets the interval in which the file is checked for changes.
+func WithInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// WithErrorHandler sets a function that is called with every error
+// of reading the file or of the reload function.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *Options) {
+		o.ErrorHandler = fn
+	}
+}
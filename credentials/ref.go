@@ -0,0 +1,77 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Ref references a credential that is stored in a file or in an environment variable
+// instead of being passed inline. The contents of a file are trimmed of surrounding whitespace.
+type Ref struct {
+	File string
+	Env  string
+}
+
+// File returns a Ref to the credential in the file at path.
+func File(path string) Ref {
+	return Ref{File: path}
+}
+
+// Env returns a Ref to the credential in the environment variable name.
+func Env(name string) Ref {
+	return Ref{Env: name}
+}
+
+// IsZero reports whether r references nothing.
+func (r Ref) IsZero() bool {
+	return r == Ref{}
+}
+
+// Resolve returns the referenced credential, or value if r references nothing.
+func (r Ref) Resolve(value string) (string, error) {
+	switch {
+	case r.File != "":
+		data, err := ioutil.ReadFile(r.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	case r.Env != "":
+		v, ok := os.LookupEnv(r.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", r.Env)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// WatchRefs calls reload whenever the file of one of the refs changes.
+// Refs to environment variables are ignored, they can't change while the process is running.
+// WatchRefs blocks until ctx is canceled.
+func WatchRefs(ctx context.Context, reload func() error, refs []Ref, opts ...Option) {
+	var wg sync.WaitGroup
+	for _, r := range refs {
+		if r.File == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			WatchFile(ctx, path, func([]byte) error { return reload() }, opts...)
+		}(r.File)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,83 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"sort"
+)
+
+// Difference contains the sorted keys that differ between two snapshots.
+type Difference struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether both snapshots were equal.
+func (d Difference) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares two GetValues snapshots.
+func Diff(oldVars, newVars map[string]string) Difference {
+	var d Difference
+	for k, v := range newVars {
+		old, ok := oldVars[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, k)
+		case old != v:
+			d.Changed = append(d.Changed, k)
+		}
+	}
+	for k := range oldVars {
+		if _, ok := newVars[k]; !ok {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// WatchDiff watches prefix until its values differ from oldVars.
+// It returns the new values, the difference to oldVars and the index of the watch.
+// Watch notifications that don't change any value are skipped.
+// All options are passed on to the backend, the WaitIndex and the AfterIndex are the index
+// of the last notification after the first one.
+func WatchDiff(ctx context.Context, rw ReadWatcher, prefix string, oldVars map[string]string, opts ...WatchOption) (map[string]string, Difference, uint64, error) {
+	var options WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Keys == nil {
+		options.Keys = []string{prefix}
+	}
+
+	index, after := options.WaitIndex, options.AfterIndex
+	for {
+		var err error
+		index, err = rw.WatchPrefix(ctx, prefix, append(opts[:len(opts):len(opts)],
+			WithKeys(options.Keys), WithWaitIndex(index), WithAfterIndex(after))...)
+		if err != nil {
+			return nil, Difference{}, index, err
+		}
+		after = index
+
+		newVars, err := rw.GetValues([]string{prefix})
+		if err != nil {
+			return nil, Difference{}, index, err
+		}
+		if d := Diff(oldVars, newVars); !d.Empty() {
+			return newVars, d, index, nil
+		}
+	}
+}
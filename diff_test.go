@@ -0,0 +1,63 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestDiff(t *C) {
+	d := Diff(map[string]string{
+		"/app/a": "1",
+		"/app/b": "2",
+		"/app/c": "3",
+	}, map[string]string{
+		"/app/b": "2",
+		"/app/c": "4",
+		"/app/d": "5",
+	})
+	t.Check(d, DeepEquals, Difference{
+		Added:   []string{"/app/d"},
+		Removed: []string{"/app/a"},
+		Changed: []string{"/app/c"},
+	})
+	t.Check(d.Empty(), Equals, false)
+	t.Check(Diff(map[string]string{"/a": "1"}, map[string]string{"/a": "1"}).Empty(), Equals, true)
+}
+
+func (s *FilterSuite) TestWatchDiff(t *C) {
+	c := newTestClient(map[string]string{"/app/a": "1"})
+	old, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+
+	go func() {
+		// a notification without changes is skipped
+		c.changes <- 1
+		for {
+			c.mu.Lock()
+			if c.watches == 2 {
+				c.data["/app/a"] = "2"
+				c.mu.Unlock()
+				break
+			}
+			c.mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.changes <- 2
+	}()
+
+	vars, d, index, err := WatchDiff(context.Background(), c, "/app", old)
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(2))
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "2"})
+	t.Check(d.Changed, DeepEquals, []string{"/app/a"})
+}
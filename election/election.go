@@ -0,0 +1,162 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package election implements leader election on top of backends
+// that implement easykv.Locker (etcd, consul, zookeeper and redis).
+//
+// The candidates lock prefix/lock, the elected leader writes its value to prefix/leader
+// with its lock session. The value is bound to the session: it's deleted when the leader
+// resigns or dies, with the etcd lease, the consul session, the ephemeral zookeeper node
+// or the redis ttl of the lock.
+package election
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// ErrNotWriter is returned by Campaign if the lock sessions of the backend can't write values.
+var ErrNotWriter = errors.New("election: lock session doesn't implement easykv.SessionWriter")
+
+// ErrNotLeader is returned by Resign if the election wasn't won.
+var ErrNotLeader = errors.New("election: not the leader")
+
+// backend is a backend that supports elections.
+type backend interface {
+	easykv.ReadWatcher
+	easykv.Locker
+}
+
+// Election is a leader election on a key prefix.
+type Election struct {
+	backend   backend
+	lockKey   string
+	leaderKey string
+	options   Options
+
+	mu      sync.Mutex
+	session easykv.Session
+}
+
+// New returns a new Election on prefix.
+func New(rw easykv.ReadWatcher, prefix string, opts ...Option) (*Election, error) {
+	options := Options{TTL: 15 * time.Second, PollInterval: 5 * time.Second}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	b, ok := rw.(backend)
+	if !ok {
+		return nil, easykv.ErrLockNotSupported
+	}
+
+	return &Election{
+		backend:   b,
+		lockKey:   path.Join("/", prefix, "lock"),
+		leaderKey: path.Join("/", prefix, "leader"),
+		options:   options,
+	}, nil
+}
+
+// Campaign blocks until the election is won or ctx is canceled.
+// The elected candidate publishes value as the leader.
+func (e *Election) Campaign(ctx context.Context, value string) error {
+	session, err := e.backend.Lock(ctx, e.lockKey, e.options.TTL)
+	if err != nil {
+		return err
+	}
+	w, ok := session.(easykv.SessionWriter)
+	if !ok {
+		session.Unlock()
+		return ErrNotWriter
+	}
+	if err := w.SetValue(ctx, e.leaderKey, value); err != nil {
+		session.Unlock()
+		return err
+	}
+
+	e.mu.Lock()
+	e.session = session
+	e.mu.Unlock()
+	return nil
+}
+
+// Done is closed when the leadership is lost.
+// It returns nil if the election wasn't won.
+func (e *Election) Done() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session == nil {
+		return nil
+	}
+	return e.session.Done()
+}
+
+// Resign gives up the leadership, unlocking the session deletes the leader value.
+func (e *Election) Resign() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session == nil {
+		return ErrNotLeader
+	}
+
+	err := e.session.Unlock()
+	e.session = nil
+	return err
+}
+
+// Leader returns the value of the current leader.
+// It returns easykv.ErrKeyNotFound if there is no leader.
+func (e *Election) Leader(ctx context.Context) (string, error) {
+	return easykv.GetValue(ctx, e.backend, e.leaderKey)
+}
+
+// Observe sends the value of the current leader and every change of it.
+// An empty value means that there is no leader.
+// The channel is closed when ctx is canceled.
+func (e *Election) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+
+		var index uint64
+		var last string
+		first := true
+		for {
+			v, err := e.Leader(ctx)
+			if err == nil || err == easykv.ErrKeyNotFound {
+				if first || v != last {
+					select {
+					case ch <- v:
+					case <-ctx.Done():
+						return
+					}
+					first, last = false, v
+				}
+			}
+
+			index, err = e.backend.WatchPrefix(ctx, e.leaderKey, easykv.WithWaitIndex(index), easykv.WithKeys([]string{e.leaderKey}))
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				select {
+				case <-time.After(e.options.PollInterval):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,234 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package election
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+// testBackend is an in-memory backend with a single lock.
+type testBackend struct {
+	mu      sync.Mutex
+	data    map[string]string
+	version uint64
+	changed chan struct{}
+	lock    chan struct{}
+	session *testSession
+}
+
+func newTestBackend() *testBackend {
+	return &testBackend{
+		data:    make(map[string]string),
+		changed: make(chan struct{}),
+		lock:    make(chan struct{}, 1),
+	}
+}
+
+func (b *testBackend) GetValues(keys []string) (map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	vars := make(map[string]string)
+	for _, k := range keys {
+		if v, ok := b.data[k]; ok {
+			vars[k] = v
+		}
+	}
+	return vars, nil
+}
+
+func (b *testBackend) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	b.mu.Lock()
+	version, changed := b.version, b.changed
+	b.mu.Unlock()
+	if options.WaitIndex < version {
+		return version, nil
+	}
+	select {
+	case <-changed:
+		return version + 1, nil
+	case <-ctx.Done():
+		return 0, easykv.ErrWatchCanceled
+	}
+}
+
+func (b *testBackend) Close() {}
+
+func (b *testBackend) notify() {
+	b.version++
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *testBackend) setValue(key, value string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data[key] = value
+	b.notify()
+}
+
+func (b *testBackend) deleteValues(keys []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, k := range keys {
+		delete(b.data, k)
+	}
+	b.notify()
+}
+
+// testSession deletes its values when it's unlocked or lost, like a lease.
+type testSession struct {
+	b      *testBackend
+	once   sync.Once
+	done   chan struct{}
+	values []string
+}
+
+func (s *testSession) Done() <-chan struct{} { return s.done }
+
+func (s *testSession) SetValue(ctx context.Context, key, value string) error {
+	s.b.setValue(key, value)
+	s.values = append(s.values, key)
+	return nil
+}
+
+// expire ends the session like an expired lease.
+func (s *testSession) expire() {
+	s.once.Do(func() {
+		s.b.deleteValues(s.values)
+		close(s.done)
+		<-s.b.lock
+	})
+}
+
+func (s *testSession) Unlock() error {
+	s.expire()
+	return nil
+}
+
+func (b *testBackend) Lock(ctx context.Context, key string, ttl time.Duration) (easykv.Session, error) {
+	select {
+	case b.lock <- struct{}{}:
+		s := &testSession{b: b, done: make(chan struct{})}
+		b.mu.Lock()
+		b.session = s
+		b.mu.Unlock()
+		return s, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *FilterSuite) TestCampaign(t *C) {
+	b := newTestBackend()
+	e1, err := New(b, "/election")
+	t.Assert(err, IsNil)
+	e2, err := New(b, "/election")
+	t.Assert(err, IsNil)
+
+	_, err = e1.Leader(context.Background())
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+	t.Check(e1.Resign(), Equals, ErrNotLeader)
+
+	t.Assert(e1.Campaign(context.Background(), "node1"), IsNil)
+	leader, err := e2.Leader(context.Background())
+	t.Assert(err, IsNil)
+	t.Check(leader, Equals, "node1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	t.Check(e2.Campaign(ctx, "node2"), Equals, context.DeadlineExceeded)
+
+	done := e1.Done()
+	t.Assert(e1.Resign(), IsNil)
+	<-done
+
+	t.Assert(e2.Campaign(context.Background(), "node2"), IsNil)
+	leader, err = e1.Leader(context.Background())
+	t.Assert(err, IsNil)
+	t.Check(leader, Equals, "node2")
+}
+
+func (s *FilterSuite) TestObserve(t *C) {
+	b := newTestBackend()
+	e, err := New(b, "/election")
+	t.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := e.Observe(ctx)
+	t.Check(<-ch, Equals, "")
+
+	t.Assert(e.Campaign(context.Background(), "node1"), IsNil)
+	t.Check(<-ch, Equals, "node1")
+	t.Assert(e.Resign(), IsNil)
+	t.Check(<-ch, Equals, "")
+
+	cancel()
+	for range ch {
+	}
+}
+
+func (s *FilterSuite) TestLeaderDies(t *C) {
+	b := newTestBackend()
+	e1, err := New(b, "/election")
+	t.Assert(err, IsNil)
+	e2, err := New(b, "/election")
+	t.Assert(err, IsNil)
+
+	t.Assert(e1.Campaign(context.Background(), "node1"), IsNil)
+	done := e1.Done()
+	b.session.expire()
+	<-done
+
+	_, err = e2.Leader(context.Background())
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+	t.Assert(e2.Campaign(context.Background(), "node2"), IsNil)
+	leader, err := e1.Leader(context.Background())
+	t.Assert(err, IsNil)
+	t.Check(leader, Equals, "node2")
+}
+
+// lockOnlyBackend returns sessions that can't write values.
+type lockOnlyBackend struct {
+	*testBackend
+}
+
+func (b lockOnlyBackend) Lock(ctx context.Context, key string, ttl time.Duration) (easykv.Session, error) {
+	session, err := b.testBackend.Lock(ctx, key, ttl)
+	return struct{ easykv.Session }{session}, err
+}
+
+func (s *FilterSuite) TestNotSupported(t *C) {
+	_, err := New(struct{ easykv.ReadWatcher }{newTestBackend()}, "/election")
+	t.Check(err, Equals, easykv.ErrLockNotSupported)
+
+	b := lockOnlyBackend{newTestBackend()}
+	e, err := New(b, "/election")
+	t.Assert(err, IsNil)
+	t.Check(e.Campaign(context.Background(), "node1"), Equals, ErrNotWriter)
+	// the lock was released
+	t.Assert(e.Campaign(context.Background(), "node1"), Equals, ErrNotWriter)
+}
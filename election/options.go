@@ -0,0 +1,35 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package election
+
+import "time"
+
+// Options contains all values that configure an Election.
+type Options struct {
+	TTL          time.Duration
+	PollInterval time.Duration
+}
+
+// Option configures an Election.
+type Option func(*Options)
+
+// WithTTL sets the ttl of the leader lock. Defaults to 15 seconds.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithPollInterval sets the interval in which Observe polls the leader
+// if the backend doesn't support watches. Defaults to 5 seconds.
+func WithPollInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.PollInterval = d
+	}
+}
@@ -13,9 +13,12 @@
 package env
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"strings"
+	"text/template"
 
 	"github.com/HeavyHorst/easykv"
 )
@@ -24,11 +27,34 @@ var replacer = strings.NewReplacer("/", "_")
 var cleanReplacer = strings.NewReplacer("_", "/")
 
 // Client provides a shell for the env client
-type Client struct{}
+type Client struct {
+	aliases  map[string]string
+	computed map[string]*template.Template
+	logger   easykv.Logger
+}
 
 // New returns a new client
-func New() (*Client, error) {
-	return &Client{}, nil
+func New(opts ...Option) (*Client, error) {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c := &Client{
+		aliases:  options.Aliases,
+		computed: make(map[string]*template.Template),
+		logger:   easykv.LoggerOrDiscard(options.Logger),
+	}
+	for key, text := range options.Computed {
+		tmpl, err := template.New(key).Funcs(template.FuncMap{
+			"getv": func(string) (string, error) { return "", nil },
+		}).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		c.computed[key] = tmpl
+	}
+	return c, nil
 }
 
 // Close is only meant to fulfill the easykv.ReadWatcher interface.
@@ -38,12 +64,7 @@ func (c *Client) Close() {}
 // GetValues is used to lookup all keys with a prefix.
 // Several prefixes can be specified in the keys array.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
-	allEnvVars := os.Environ()
-	envMap := make(map[string]string)
-	for _, e := range allEnvVars {
-		index := strings.Index(e, "=")
-		envMap[e[:index]] = e[index+1:]
-	}
+	envMap := environ()
 	vars := make(map[string]string)
 	for _, key := range keys {
 		k := transform(key)
@@ -52,11 +73,65 @@ func (c *Client) GetValues(keys []string) (map[string]string, error) {
 				vars[clean(envKey)] = envValue
 			}
 		}
+
+		for alias, target := range c.aliases {
+			if strings.HasPrefix(alias, key) {
+				value, ok := envMap[transform(target)]
+				if !ok {
+					c.logger.Debug("skipping alias with unset target", "alias", alias, "target", target)
+					continue
+				}
+				vars[alias] = value
+			}
+		}
+
+		for computed, tmpl := range c.computed {
+			if strings.HasPrefix(computed, key) {
+				value, err := execute(tmpl, envMap)
+				if err != nil {
+					c.logger.Warn("can't compute key", "key", computed, "err", err)
+					return vars, err
+				}
+				vars[computed] = value
+			}
+		}
 	}
 
 	return vars, nil
 }
 
+// execute evaluates the template of a computed key.
+func execute(tmpl *template.Template, envMap map[string]string) (string, error) {
+	var buf bytes.Buffer
+	t, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	t.Funcs(template.FuncMap{
+		"getv": func(key string) (string, error) {
+			value, ok := envMap[transform(key)]
+			if !ok {
+				return "", fmt.Errorf("computed key %s: key %s not found", tmpl.Name(), key)
+			}
+			return value, nil
+		},
+	})
+	if err := t.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// environ returns all environment variables as a map.
+func environ() map[string]string {
+	envMap := make(map[string]string)
+	for _, e := range os.Environ() {
+		index := strings.Index(e, "=")
+		envMap[e[:index]] = e[index+1:]
+	}
+	return envMap
+}
+
 func transform(key string) string {
 	k := strings.TrimPrefix(key, "/")
 	return strings.ToUpper(replacer.Replace(k))
@@ -71,3 +146,17 @@ func clean(key string) string {
 func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
 	return 0, easykv.ErrWatchNotSupported
 }
+
+// GetValue returns the value of a single key.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	if tmpl, ok := c.computed[key]; ok {
+		return execute(tmpl, environ())
+	}
+	if target, ok := c.aliases[key]; ok {
+		key = target
+	}
+	if value, ok := os.LookupEnv(transform(key)); ok {
+		return value, nil
+	}
+	return "", easykv.ErrKeyNotFound
+}
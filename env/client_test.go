@@ -9,9 +9,11 @@
 package env
 
 import (
+	"context"
 	"os"
 	"testing"
 
+	"github.com/HeavyHorst/easykv"
 	"github.com/HeavyHorst/easykv/testutils"
 
 	. "gopkg.in/check.v1"
@@ -20,10 +22,33 @@ import (
 // Hook up gocheck into the "go test" runner.
 func Test(t *testing.T) { TestingT(t) }
 
-type FilterSuite struct{}
+type FilterSuite struct {
+	// restore resets the environment variables set by the test.
+	restore []func()
+}
 
 var _ = Suite(&FilterSuite{})
 
+// setenv sets an environment variable until the end of the test.
+func (s *FilterSuite) setenv(key, value string) {
+	old, ok := os.LookupEnv(key)
+	s.restore = append(s.restore, func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+	os.Setenv(key, value)
+}
+
+func (s *FilterSuite) TearDownTest(t *C) {
+	for i := len(s.restore) - 1; i >= 0; i-- {
+		s.restore[i]()
+	}
+	s.restore = nil
+}
+
 func (s *FilterSuite) TestTransform(t *C) {
 	dat := transform("/foo/bar/test")
 	t.Check(dat, Equals, "FOO_BAR_TEST")
@@ -41,11 +66,51 @@ func (s *FilterSuite) TestWatchPrefix(t *C) {
 
 func (s *FilterSuite) TestGetValues(t *C) {
 	//set some env vars
-	os.Setenv("PREMTEST_DATABASE_URL", "www.google.de")
-	os.Setenv("PREMTEST_DATABASE_USER", "Boris")
-	os.Setenv("REMTEST_DATABASE_HOSTS_192.168.0.1", "test1")
-	os.Setenv("REMTEST_DATABASE_HOSTS_192.168.0.2", "test2")
+	s.setenv("PREMTEST_DATABASE_URL", "www.google.de")
+	s.setenv("PREMTEST_DATABASE_USER", "Boris")
+	s.setenv("REMTEST_DATABASE_HOSTS_192.168.0.1", "test1")
+	s.setenv("REMTEST_DATABASE_HOSTS_192.168.0.2", "test2")
 
 	c, _ := New()
 	testutils.GetValues(t, c)
 }
+
+func (s *FilterSuite) TestAliasesAndComputedKeys(t *C) {
+	s.setenv("DB_HOST", "localhost")
+	s.setenv("DB_PORT", "5432")
+	s.setenv("DB_NAME", "app")
+
+	c, err := New(
+		WithAlias("/legacy/dbhost", "/db/host"),
+		WithComputedKey("/db/url", `postgres://{{getv "/db/host"}}:{{getv "/db/port"}}/{{getv "/db/name"}}`),
+	)
+	t.Assert(err, IsNil)
+
+	vars, err := c.GetValues([]string{"/legacy", "/db/url"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/legacy/dbhost": "localhost",
+		"/db/url":        "postgres://localhost:5432/app",
+	})
+
+	c, err = New(WithComputedKey("/broken", `{{getv "/does/not/exist"}}`))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/broken"})
+	t.Check(err, NotNil)
+}
+
+func (s *FilterSuite) TestGetValue(t *C) {
+	s.setenv("PREMTEST_DATABASE_URL", "www.google.de")
+
+	c, _ := New(WithAlias("/legacy/url", "/premtest/database/url"))
+	v, err := c.GetValue(context.Background(), "/premtest/database/url")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "www.google.de")
+
+	v, err = c.GetValue(context.Background(), "/legacy/url")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "www.google.de")
+
+	_, err = c.GetValue(context.Background(), "/does/not/exist")
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+}
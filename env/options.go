@@ -0,0 +1,50 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package env
+
+import "github.com/HeavyHorst/easykv"
+
+// Options contains all values that are needed to configure the env client.
+type Options struct {
+	Aliases  map[string]string
+	Computed map[string]string
+	Logger   easykv.Logger
+}
+
+// Option configures the env client.
+type Option func(*Options)
+
+// WithAlias adds the key alias which always has the value of the key target.
+func WithAlias(alias, target string) Option {
+	return func(o *Options) {
+		if o.Aliases == nil {
+			o.Aliases = make(map[string]string)
+		}
+		o.Aliases[alias] = target
+	}
+}
+
+// WithComputedKey adds the key whose value is computed from the text/template tmpl at read time.
+// Other keys can be read in the template with the getv function, e.g.
+// postgres://{{getv "/db/host"}}:{{getv "/db/port"}}/{{getv "/db/name"}}
+func WithComputedKey(key, tmpl string) Option {
+	return func(o *Options) {
+		if o.Computed == nil {
+			o.Computed = make(map[string]string)
+		}
+		o.Computed[key] = tmpl
+	}
+}
+
+// WithLogger sets the Logger that reports unset alias targets and failing computed keys.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
@@ -15,3 +15,54 @@ var ErrWatchNotSupported = errors.New("this backend doesn't support watches - us
 
 // ErrWatchCanceled is returned if the watcher is canceled.
 var ErrWatchCanceled = errors.New("watcher error: watcher canceled")
+
+// ErrReferenceCycle is wrapped in a ReferenceError if references refer to each other.
+var ErrReferenceCycle = errors.New("reference cycle detected")
+
+// ErrKeyNotFound is returned by GetValue if the key doesn't exist.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrLockNotSupported is returned by Lock if the backend doesn't implement Locker.
+var ErrLockNotSupported = errors.New("this backend doesn't support locks")
+
+// ErrLockLost is returned by SessionWriter.SetValue if the lock was lost or unlocked.
+var ErrLockLost = errors.New("the lock was lost")
+
+// ErrAuthentication classifies errors of rejected credentials.
+var ErrAuthentication = errors.New("authentication failed")
+
+// ErrPermissionDenied classifies errors of requests the credentials aren't allowed to make.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrConnection classifies errors of backends that can't be reached or aren't available.
+var ErrConnection = errors.New("connection failed")
+
+// BackendError is a backend error classified by its Kind,
+// which is one of ErrAuthentication, ErrPermissionDenied or ErrConnection.
+type BackendError struct {
+	Kind error
+	Err  error
+}
+
+func (e *BackendError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Is reports whether target is the Kind of the error.
+func (e *BackendError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error.
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind returns the Kind of the first *BackendError in the chain of err, otherwise nil.
+func ErrorKind(err error) error {
+	var e *BackendError
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return nil
+}
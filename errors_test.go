@@ -0,0 +1,31 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"errors"
+	"fmt"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestBackendError(t *C) {
+	err := &BackendError{Kind: ErrPermissionDenied, Err: errors.New("no policy")}
+	t.Check(err, ErrorMatches, "permission denied: no policy")
+	t.Check(err.Is(ErrPermissionDenied), Equals, true)
+	t.Check(err.Is(ErrAuthentication), Equals, false)
+	t.Check(ErrorKind(err), Equals, ErrPermissionDenied)
+	t.Check(ErrorKind(ErrKeyNotFound), IsNil)
+	t.Check(ErrorKind(nil), IsNil)
+
+	// wrapped backend errors keep their kind
+	t.Check(ErrorKind(fmt.Errorf("read /app: %w", err)), Equals, ErrPermissionDenied)
+	t.Check(ErrorKind(&ReferenceError{Reference: "${vault:secret/db}", Err: err}), Equals, ErrPermissionDenied)
+	t.Check(ErrorKind(&DecodeError{Key: "/app", Err: err}), Equals, ErrPermissionDenied)
+}
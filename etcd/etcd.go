@@ -10,34 +10,96 @@ package etcd
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/HeavyHorst/easykv"
 	"github.com/HeavyHorst/easykv/etcd/etcdv2"
 	"github.com/HeavyHorst/easykv/etcd/etcdv3"
+	"github.com/HeavyHorst/easykv/etcd/gateway"
 )
 
 // ErrUnknownAPILevel is returned if no valid api level is given
 var ErrUnknownAPILevel = errors.New("unknown etcd api level - must be 2 or 3")
 
-// New returns an *etcd{2,3}.Client with a connection to named machines.
+// ErrIncompleteClientCert is returned if only one of the client certificate and key is set.
+var ErrIncompleteClientCert = errors.New("the etcd client certificate and key must be set together")
+
+// endpoints adds the scheme to all machines without one,
+// https if TLS is enabled and http otherwise.
+func endpoints(machines []string, tls bool) []string {
+	scheme := "http://"
+	if tls {
+		scheme = "https://"
+	}
+	nodes := make([]string, len(machines))
+	for i, m := range machines {
+		if !strings.Contains(m, "://") {
+			m = scheme + m
+		}
+		nodes[i] = m
+	}
+	return nodes
+}
+
+// New returns an *etcd{2,3}.Client with a connection to named machines,
+// or a *gateway.Client for api level 3 with WithGateway.
+// Machines without a scheme are reached with https if TLS is enabled.
+// With WithDiscoverySRV, the machines are discovered from DNS SRV records instead.
+// Clusters that require mutual TLS are configured with the ClientCert,
+// ClientKey and ClientCaKeys of the TLSOptions.
 func New(machines []string, opts ...Option) (easykv.ReadWatcher, error) {
 	var options Options
 	for _, o := range opts {
 		o(&options)
 	}
-	options.Nodes = machines
+	if (options.TLS.ClientCert == "") != (options.TLS.ClientKey == "") {
+		return nil, ErrIncompleteClientCert
+	}
+	if options.DiscoverySRV != "" {
+		var err error
+		if machines, err = DiscoverSRV(options.DiscoverySRV); err != nil {
+			return nil, err
+		}
+	}
+	options.Nodes = endpoints(machines, options.TLS.Enabled())
+
+	password, err := options.PasswordRef.Resolve(options.Auth.Password)
+	if err != nil {
+		return nil, err
+	}
+	options.Auth.Password = password
 
 	ba := false
 	if options.Auth.Password != "" && options.Auth.Username != "" {
 		ba = true
 	}
 
+	if options.Version == 3 && options.Gateway {
+		return gateway.NewEtcdClient(options.Nodes, options.TLS.ClientCert, options.TLS.ClientKey, options.TLS.ClientCaKeys, ba, options.Auth.Username, options.Auth.Password,
+			gateway.WithLogger(options.Logger), gateway.WithTLS(options.TLS), gateway.WithProxy(options.Proxy),
+			gateway.WithPasswordFrom(options.PasswordRef))
+	}
+
 	if options.Version == 3 {
-		return etcdv3.NewEtcdClient(options.Nodes, options.TLS.ClientCert, options.TLS.ClientKey, options.TLS.ClientCaKeys, ba, options.Auth.Username, options.Auth.Password)
+		v3opts := []etcdv3.Option{
+			etcdv3.WithLogger(options.Logger),
+			etcdv3.WithTLS(options.TLS),
+			etcdv3.WithPasswordFrom(options.PasswordRef),
+			etcdv3.WithDial(options.Dial),
+			etcdv3.WithNamespace(options.Namespace),
+			etcdv3.WithSerializable(options.Serializable),
+		}
+		if options.DiscoverySRV != "" {
+			v3opts = append(v3opts, etcdv3.WithEndpointRefresh(func() ([]string, error) {
+				return DiscoverSRV(options.DiscoverySRV)
+			}, options.DiscoveryInterval))
+		}
+		return etcdv3.NewEtcdClient(options.Nodes, options.TLS.ClientCert, options.TLS.ClientKey, options.TLS.ClientCaKeys, ba, options.Auth.Username, options.Auth.Password, v3opts...)
 	}
 
 	if options.Version == 2 {
-		return etcdv2.NewEtcdClient(options.Nodes, options.TLS.ClientCert, options.TLS.ClientKey, options.TLS.ClientCaKeys, ba, options.Auth.Username, options.Auth.Password)
+		return etcdv2.NewEtcdClient(options.Nodes, options.TLS.ClientCert, options.TLS.ClientKey, options.TLS.ClientCaKeys, ba, options.Auth.Username, options.Auth.Password, etcdv2.WithLogger(options.Logger), etcdv2.WithTLS(options.TLS),
+			etcdv2.WithPasswordFrom(options.PasswordRef))
 	}
 
 	return nil, ErrUnknownAPILevel
@@ -9,7 +9,9 @@
 package etcd
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -22,6 +24,46 @@ type FilterSuite struct{}
 
 var _ = Suite(&FilterSuite{})
 
+func (s *FilterSuite) TestEndpoints(t *C) {
+	machines := []string{"10.0.0.1:2379", "http://10.0.0.2:2379", "https://10.0.0.3:2379"}
+	t.Check(endpoints(machines, false), DeepEquals, []string{"http://10.0.0.1:2379", "http://10.0.0.2:2379", "https://10.0.0.3:2379"})
+	t.Check(endpoints(machines, true), DeepEquals, []string{"https://10.0.0.1:2379", "http://10.0.0.2:2379", "https://10.0.0.3:2379"})
+}
+
+func (s *FilterSuite) TestDiscoverSRV(t *C) {
+	defer func(l func(string, string, string) (string, []*net.SRV, error)) { lookupSRV = l }(lookupSRV)
+	records := map[string][]*net.SRV{
+		"etcd-client-ssl": {{Target: "etcd-2.example.com.", Port: 2379}, {Target: "etcd-1.example.com.", Port: 2379}},
+	}
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		t.Check(name, Equals, "example.com")
+		if addrs, ok := records[service]; ok {
+			return "", addrs, nil
+		}
+		return "", nil, errors.New("no such host")
+	}
+
+	eps, err := DiscoverSRV("example.com")
+	t.Assert(err, IsNil)
+	t.Check(eps, DeepEquals, []string{"https://etcd-1.example.com:2379", "https://etcd-2.example.com:2379"})
+
+	records["etcd-client"] = []*net.SRV{{Target: "etcd-3.example.com.", Port: 2380}}
+	eps, err = DiscoverSRV("example.com")
+	t.Assert(err, IsNil)
+	t.Check(eps, DeepEquals, []string{"http://etcd-3.example.com:2380", "https://etcd-1.example.com:2379", "https://etcd-2.example.com:2379"})
+
+	records = nil
+	_, err = DiscoverSRV("example.com")
+	t.Check(err, ErrorMatches, "can't discover the etcd endpoints of example.com: no such host")
+}
+
+func (s *FilterSuite) TestIncompleteClientCert(t *C) {
+	_, err := New([]string{"127.0.0.1:2379"}, WithTLSOptions(TLSOptions{ClientCert: "client.pem", ClientCaKeys: "ca.pem"}))
+	t.Check(err, Equals, ErrIncompleteClientCert)
+	_, err = New([]string{"127.0.0.1:2379"}, WithTLSOptions(TLSOptions{ClientKey: "client-key.pem"}))
+	t.Check(err, Equals, ErrIncompleteClientCert)
+}
+
 func (s *FilterSuite) TestNew(t *C) {
 	ba := BasicAuthOptions{
 		Username: "",
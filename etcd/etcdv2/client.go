@@ -16,29 +16,42 @@
 package etcdv2
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"context"
 
 	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
 	"github.com/coreos/etcd/client"
 )
 
 // Client is a wrapper around the etcd client
 type Client struct {
+	mu     sync.RWMutex
 	client client.KeysAPI
+	cfg    client.Config
+
+	logger      easykv.Logger
+	tls         easykv.TLSOptions
+	passwordRef credentials.Ref
+	stopWatch   context.CancelFunc
 }
 
 // NewEtcdClient returns an *etcd.Client with a connection to named machines.
-func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool, username string, password string) (*Client, error) {
+func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool, username string, password string, opts ...Option) (*Client, error) {
+	kc := &Client{
+		logger: easykv.DiscardLogger,
+		tls:    easykv.TLSOptions{ClientCert: cert, ClientKey: key, ClientCaKeys: caCert},
+	}
+	for _, o := range opts {
+		o(kc)
+	}
+
 	var c client.Client
-	var kapi client.KeysAPI
 	var err error
 	var transport = &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -49,10 +62,6 @@ func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool,
 		TLSHandshakeTimeout: 10 * time.Second,
 	}
 
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: false,
-	}
-
 	cfg := client.Config{
 		Endpoints:               machines,
 		HeaderTimeoutPerRequest: time.Duration(3) * time.Second,
@@ -63,54 +72,93 @@ func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool,
 		cfg.Password = password
 	}
 
-	if caCert != "" {
-		certBytes, err := ioutil.ReadFile(caCert)
-		if err != nil {
-			return &Client{kapi}, err
-		}
-
-		caCertPool := x509.NewCertPool()
-		ok := caCertPool.AppendCertsFromPEM(certBytes)
+	tlsConfig, err := kc.tls.Config()
+	if err != nil {
+		return kc, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	cfg.Transport = transport
 
-		if ok {
-			tlsConfig.RootCAs = caCertPool
-		}
+	c, err = client.New(cfg)
+	if err != nil {
+		kc.logger.Warn("can't create etcd client", "endpoints", machines, "err", err)
+		return kc, err
 	}
 
-	if cert != "" && key != "" {
-		tlsCert, err := tls.LoadX509KeyPair(cert, key)
-		if err != nil {
-			return &Client{kapi}, err
-		}
-		tlsConfig.Certificates = []tls.Certificate{tlsCert}
+	kc.client = client.NewKeysAPI(c)
+	kc.cfg = cfg
+	kc.logger.Info("created etcd client", "endpoints", machines, "auth", basicAuth)
+
+	if basicAuth && !kc.passwordRef.IsZero() {
+		ctx, cancel := context.WithCancel(context.Background())
+		kc.stopWatch = cancel
+		go credentials.WatchRefs(ctx, kc.reload, []credentials.Ref{kc.passwordRef},
+			credentials.WithErrorHandler(func(err error) {
+				kc.logger.Warn("can't reload the etcd password", "err", err)
+			}),
+		)
 	}
+	return kc, nil
+}
 
-	transport.TLSClientConfig = tlsConfig
-	cfg.Transport = transport
+// reload reads the password again from the password reference and replaces the client.
+func (c *Client) reload() error {
+	password, err := c.passwordRef.Resolve("")
+	if err != nil {
+		return err
+	}
 
-	c, err = client.New(cfg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := c.cfg
+	cfg.Password = password
+	cli, err := client.New(cfg)
 	if err != nil {
-		return &Client{kapi}, err
+		return err
 	}
+	c.client, c.cfg = client.NewKeysAPI(cli), cfg
+	c.logger.Info("reloaded the etcd password", "endpoints", cfg.Endpoints)
+	return nil
+}
 
-	kapi = client.NewKeysAPI(c)
-	return &Client{kapi}, nil
+// api returns the current keys api.
+func (c *Client) api() client.KeysAPI {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
 }
 
-// Close is only meant to fulfill the easykv.ReadWatcher interface.
-// Does nothing.
-func (c *Client) Close() {}
+// Close stops watching the password file.
+func (c *Client) Close() {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+}
 
 // GetValues is used to lookup all keys with a prefix.
 // Several prefixes can be specified in the keys array.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
+	return c.getValues(keys, nil)
+}
+
+// GetValuesExplain is used to lookup all keys with a prefix.
+// It additionally returns all GET requests that were sent to etcd.
+func (c *Client) GetValuesExplain(keys []string) (map[string]string, []easykv.Operation, error) {
+	var tr easykv.Trace
+	vars, err := c.getValues(keys, &tr)
+	return vars, tr.Operations(), err
+}
+
+func (c *Client) getValues(keys []string, tr *easykv.Trace) (map[string]string, error) {
 	vars := make(map[string]string)
 	for _, key := range keys {
-		resp, err := c.client.Get(context.Background(), key, &client.GetOptions{
+		start := time.Now()
+		resp, err := c.api().Get(context.Background(), key, &client.GetOptions{
 			Recursive: true,
 			Sort:      true,
 			Quorum:    true,
 		})
+		tr.Record("GET", key, start, err)
 		if err != nil {
 			return vars, err
 		}
@@ -138,16 +186,34 @@ func nodeWalk(node *client.Node, vars map[string]string) error {
 }
 
 // WatchPrefix watches a specific prefix for changes.
+// Failed watches are retried if a Backoff is set in the WatchOptions.
 func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
 	var options easykv.WatchOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
+	return options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, options)
+	})
+}
+
+// watchPrefix runs a single watch.
+func (c *Client) watchPrefix(ctx context.Context, prefix string, options easykv.WatchOptions) (uint64, error) {
+	if err := options.ResumeIndex(prefix); err != nil {
+		return 0, err
+	}
+
 	// Setting AfterIndex to 0 (default) means that the Watcher
 	// should start watching for events starting at the current
 	// index, whatever that may be.
-	watcher := c.client.Watcher(prefix, &client.WatcherOptions{AfterIndex: uint64(0), Recursive: true})
+	// A resumed watch starts right after the persisted index.
+	var afterIndex uint64
+	if options.IndexStore != nil {
+		afterIndex = options.WaitIndex
+	}
+	c.logger.Debug("watching etcd prefix", "prefix", prefix, "afterIndex", afterIndex)
+	watcher := c.api().Watcher(prefix, &client.WatcherOptions{AfterIndex: afterIndex, Recursive: true})
 	etcdctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -160,9 +226,11 @@ func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.
 			switch e := err.(type) {
 			case *client.Error:
 				if e.Code == 401 {
+					c.logger.Info("etcd event index cleared, restarting watch", "prefix", prefix)
 					return 0, nil
 				}
 			}
+			c.logger.Warn("etcd watch failed", "prefix", prefix, "err", err)
 			return options.WaitIndex, err
 		}
 
@@ -172,8 +240,43 @@ func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.
 		// is reducing the scope of keys that can trigger updates.
 		for _, k := range options.Keys {
 			if strings.HasPrefix(resp.Node.Key, k) {
-				return resp.Node.ModifiedIndex, err
+				return resp.Node.ModifiedIndex, options.SaveIndex(prefix, resp.Node.ModifiedIndex)
 			}
 		}
 	}
 }
+
+// SetValues writes all key-value pairs to etcd.
+func (c *Client) SetValues(values map[string]string) error {
+	for k, v := range values {
+		if _, err := c.api().Set(context.Background(), k, v, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteValues deletes all given keys from etcd.
+func (c *Client) DeleteValues(keys []string) error {
+	for _, k := range keys {
+		if _, err := c.api().Delete(context.Background(), k, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetValue returns the value of a single key.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	resp, err := c.api().Get(ctx, key, &client.GetOptions{Quorum: true})
+	if err != nil {
+		if e, ok := err.(client.Error); ok && e.Code == client.ErrorCodeKeyNotFound {
+			return "", easykv.ErrKeyNotFound
+		}
+		return "", err
+	}
+	if resp.Node == nil || resp.Node.Dir {
+		return "", easykv.ErrKeyNotFound
+	}
+	return resp.Node.Value, nil
+}
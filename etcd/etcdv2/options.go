@@ -0,0 +1,39 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package etcdv2
+
+import (
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
+// Option configures the etcdv2 client.
+type Option func(*Client)
+
+// WithLogger sets the Logger that reports connections and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(c *Client) {
+		c.logger = easykv.LoggerOrDiscard(l)
+	}
+}
+
+// WithTLS sets the TLSOptions. They replace the certificates given to NewEtcdClient.
+func WithTLS(tls easykv.TLSOptions) Option {
+	return func(c *Client) {
+		c.tls = tls
+	}
+}
+
+// WithPasswordFrom sets the reference the password is read from again
+// whenever the referenced file changes.
+func WithPasswordFrom(ref credentials.Ref) Option {
+	return func(c *Client) {
+		c.passwordRef = ref
+	}
+}
@@ -9,102 +9,480 @@
 package etcdv3
 
 import (
+	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"context"
 
 	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
 	"github.com/coreos/etcd/clientv3"
-	"github.com/coreos/etcd/pkg/transport"
+	"github.com/coreos/etcd/clientv3/namespace"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"github.com/coreos/etcd/mvcc/mvccpb"
 )
 
 // Client is a wrapper around the etcd client
 type Client struct {
-	client *clientv3.Client
+	mu          sync.RWMutex
+	client      *clientv3.Client
+	cfg         clientv3.Config
+	passwordRef credentials.Ref
+
+	logger       easykv.Logger
+	tls          easykv.TLSOptions
+	dial         DialOptions
+	namespace    string
+	serializable bool
+
+	resolve         func() ([]string, error)
+	refreshInterval time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+	stopWatch       context.CancelFunc
 }
 
 // NewEtcdClient returns an *etcdv3.Client with a connection to named machines.
-func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool, username string, password string) (*Client, error) {
-	var cli *clientv3.Client
+func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool, username string, password string, opts ...Option) (*Client, error) {
+	c := &Client{
+		logger: easykv.DiscardLogger,
+		tls:    easykv.TLSOptions{ClientCert: cert, ClientKey: key, ClientCaKeys: caCert},
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
 	cfg := clientv3.Config{
-		Endpoints:   machines,
-		DialTimeout: 5 * time.Second,
+		Endpoints:            machines,
+		DialTimeout:          5 * time.Second,
+		DialKeepAliveTime:    c.dial.KeepAliveTime,
+		DialKeepAliveTimeout: c.dial.KeepAliveTimeout,
+		PermitWithoutStream:  c.dial.PermitWithoutStream,
+	}
+	if c.dial.Timeout > 0 {
+		cfg.DialTimeout = c.dial.Timeout
 	}
-	tlsInfo := &transport.TLSInfo{}
 
 	if basicAuth {
 		cfg.Username = username
 		cfg.Password = password
 	}
 
-	tls := false
-	if caCert != "" {
-		tlsInfo.TrustedCAFile = caCert
-		tls = true
+	tls := c.tls.Enabled()
+	if tls {
+		clientConf, err := c.tls.Config()
+		if err != nil {
+			return c, err
+		}
+		cfg.TLS = clientConf
 	}
-	if cert != "" && key != "" {
-		tlsInfo.CertFile = cert
-		tlsInfo.KeyFile = key
-		tls = true
+
+	var err error
+	c.cfg = cfg
+	c.client, err = c.newClient(cfg)
+	if err != nil {
+		c.logger.Warn("can't connect to etcd", "endpoints", machines, "err", err)
+		return c, err
 	}
+	c.logger.Info("connected to etcd", "endpoints", machines, "tls", tls, "auth", basicAuth, "namespace", c.namespace)
 
-	if tls {
-		clientConf, err := tlsInfo.ClientConfig()
+	if c.resolve != nil && c.refreshInterval > 0 {
+		c.stop = make(chan struct{})
+		go c.refreshEndpoints()
+	}
+	if basicAuth && !c.passwordRef.IsZero() {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.stopWatch = cancel
+		go credentials.WatchRefs(ctx, func() error { return c.reconnect(c.api()) }, []credentials.Ref{c.passwordRef},
+			credentials.WithErrorHandler(func(err error) {
+				c.logger.Warn("can't log in to etcd with the new password", "err", err)
+			}),
+		)
+	}
+	return c, nil
+}
+
+// refreshEndpoints resolves the endpoints every refreshInterval until the client is closed
+// and updates the client if they changed.
+func (c *Client) refreshEndpoints() {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		eps, err := c.resolve()
 		if err != nil {
-			return &Client{cli}, err
+			c.logger.Warn("can't resolve the etcd endpoints", "err", err)
+			continue
+		}
+		c.mu.Lock()
+		if !sameEndpoints(eps, c.cfg.Endpoints) {
+			c.cfg.Endpoints = eps
+			c.client.SetEndpoints(eps...)
+			c.logger.Info("etcd endpoints changed", "endpoints", eps)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// sameEndpoints reports whether a and b contain the same endpoints in the same order.
+func sameEndpoints(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
-		cfg.TLS = clientConf
 	}
+	return true
+}
 
+// newClient creates an etcd client with cfg.
+// All keys, watches and leases of the client are scoped to the namespace.
+func (c *Client) newClient(cfg clientv3.Config) (*clientv3.Client, error) {
 	cli, err := clientv3.New(cfg)
+	if err != nil || c.namespace == "" {
+		return cli, err
+	}
+	cli.KV = namespace.NewKV(cli.KV, c.namespace)
+	cli.Watcher = namespace.NewWatcher(cli.Watcher, c.namespace)
+	cli.Lease = namespace.NewLease(cli.Lease, c.namespace)
+	return cli, nil
+}
+
+// requestTimeout returns the timeout of a single request.
+func (c *Client) requestTimeout() time.Duration {
+	if c.dial.RequestTimeout > 0 {
+		return c.dial.RequestTimeout
+	}
+	return 3 * time.Second
+}
+
+// api returns the current etcd client.
+func (c *Client) api() *clientv3.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+// authError checks if etcd rejected the credentials or the auth token of a request.
+func authError(err error) bool {
+	switch rpctypes.Error(err) {
+	case rpctypes.ErrAuthFailed, rpctypes.ErrInvalidAuthToken:
+		return true
+	}
+	return false
+}
+
+// withAuth runs f with the current client. If etcd rejects the credentials,
+// the client logs in again with the current password and f runs once more.
+func (c *Client) withAuth(f func(cli *clientv3.Client) error) error {
+	cli := c.api()
+	err := f(cli)
+	if !authError(err) {
+		return err
+	}
+	if rerr := c.reconnect(cli); rerr != nil {
+		c.logger.Warn("can't log in to etcd again", "err", rerr)
+		return err
+	}
+	return f(c.api())
+}
+
+// reconnect replaces the client old with a new client that logs in with the
+// password read again from the password reference.
+// Nothing happens if old was already replaced.
+func (c *Client) reconnect(old *clientv3.Client) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != old {
+		return nil
+	}
+
+	cfg := c.cfg
+	if cfg.Username != "" && !c.passwordRef.IsZero() {
+		password, err := c.passwordRef.Resolve("")
+		if err != nil {
+			return err
+		}
+		cfg.Password = password
+	}
+	cli, err := c.newClient(cfg)
 	if err != nil {
-		return &Client{cli}, err
+		return err
 	}
-	return &Client{cli}, nil
+	old.Close()
+	c.client, c.cfg = cli, cfg
+	c.logger.Info("logged in to etcd again", "endpoints", cfg.Endpoints)
+	return nil
 }
 
-// Close closes the etcdv3 client connection.
+// Close closes the etcdv3 client connection and stops watching the password file.
 func (c *Client) Close() {
-	if c.client != nil {
-		c.client.Close()
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+	if c.stop != nil {
+		c.stopOnce.Do(func() { close(c.stop) })
+	}
+	if cli := c.api(); cli != nil {
+		cli.Close()
 	}
 }
 
+// maxTxnOps is the default limit of operations in a single etcd txn.
+const maxTxnOps = 128
+
 // GetValues is used to lookup all keys with a prefix.
 // Several prefixes can be specified in the keys array.
+// All prefixes are read from the same revision.
+// The reads are linearizable unless WithSerializable is set.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
+	vars, _, err := c.getValues(keys, nil)
+	return vars, err
+}
+
+// GetValuesWithRevision is used to lookup all keys with a prefix like GetValues.
+// It additionally returns the revision all prefixes were read from,
+// which can be passed to WatchPrefix with easykv.WithAfterIndex
+// to watch exactly the changes after the read.
+func (c *Client) GetValuesWithRevision(keys []string) (map[string]string, uint64, error) {
+	vars, rev, err := c.getValues(keys, nil)
+	return vars, uint64(rev), err
+}
+
+// GetValuesExplain is used to lookup all keys with a prefix.
+// It additionally returns all RANGE requests that were sent to etcd.
+func (c *Client) GetValuesExplain(keys []string) (map[string]string, []easykv.Operation, error) {
+	var tr easykv.Trace
+	vars, _, err := c.getValues(keys, &tr)
+	return vars, tr.Operations(), err
+}
+
+// getValues reads all prefixes in a single txn and returns the values and the revision of the read.
+func (c *Client) getValues(keys []string, tr *easykv.Trace) (map[string]string, int64, error) {
 	vars := make(map[string]string)
-	for _, key := range keys {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(3)*time.Second)
-		resp, err := c.client.Get(ctx, key, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortDescend))
-		cancel()
+	rev, err := c.readRanges(keys, tr, func(kv *mvccpb.KeyValue) {
+		vars[string(kv.Key)] = string(kv.Value)
+	})
+	return vars, rev, err
+}
+
+// readRanges reads all prefixes in a single txn, calls visit for every key and
+// returns the revision of the read.
+// If there are more prefixes than fit into one txn, the following txns read the same revision.
+func (c *Client) readRanges(keys []string, tr *easykv.Trace, visit func(kv *mvccpb.KeyValue)) (int64, error) {
+	var rev int64
+	for len(keys) > 0 {
+		chunk := keys
+		if len(chunk) > maxTxnOps {
+			chunk = chunk[:maxTxnOps]
+		}
+		keys = keys[len(chunk):]
+
+		ops := make([]clientv3.Op, len(chunk))
+		for i, key := range chunk {
+			opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortDescend)}
+			if rev > 0 {
+				opts = append(opts, clientv3.WithRev(rev))
+			}
+			if c.serializable {
+				opts = append(opts, clientv3.WithSerializable())
+			}
+			ops[i] = clientv3.OpGet(key, opts...)
+		}
+
+		var resp *clientv3.TxnResponse
+		err := c.withAuth(func(cli *clientv3.Client) error {
+			ctx, cancel := context.WithTimeout(context.Background(), c.requestTimeout())
+			defer cancel()
+			start := time.Now()
+			var err error
+			resp, err = cli.Txn(ctx).Then(ops...).Commit()
+			for _, key := range chunk {
+				tr.Record("RANGE", key, start, err)
+			}
+			return err
+		})
 		if err != nil {
-			return vars, err
+			return rev, err
+		}
+		if rev == 0 {
+			rev = resp.Header.Revision
+		}
+		for _, r := range resp.Responses {
+			for _, kv := range r.GetResponseRange().Kvs {
+				visit(kv)
+			}
+		}
+	}
+	return rev, nil
+}
+
+// GetValuesWithMetadata is used to lookup all keys with a prefix like GetValues.
+// For keys attached to a lease, it additionally returns the lease ID and its remaining TTL,
+// so ephemeral keys like service registrations can be told apart from durable config.
+func (c *Client) GetValuesWithMetadata(keys []string) (map[string]easykv.KeyMetadata, error) {
+	md := make(map[string]easykv.KeyMetadata)
+	_, err := c.readRanges(keys, nil, func(kv *mvccpb.KeyValue) {
+		md[string(kv.Key)] = easykv.KeyMetadata{
+			Value:       string(kv.Value),
+			Version:     uint64(kv.Version),
+			CreateIndex: uint64(kv.CreateRevision),
+			ModifyIndex: uint64(kv.ModRevision),
+			LeaseID:     kv.Lease,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ttls := make(map[int64]time.Duration)
+	for k, m := range md {
+		if m.LeaseID == 0 {
+			continue
 		}
-		for _, ev := range resp.Kvs {
-			vars[string(ev.Key)] = string(ev.Value)
+		ttl, ok := ttls[m.LeaseID]
+		if !ok {
+			if ttl, err = c.leaseTTL(m.LeaseID); err != nil {
+				return nil, err
+			}
+			ttls[m.LeaseID] = ttl
 		}
+		m.TTL = ttl
+		md[k] = m
+	}
+	return md, nil
+}
+
+// leaseTTL returns the remaining TTL of a lease, 0 if it has already expired.
+func (c *Client) leaseTTL(id int64) (time.Duration, error) {
+	var resp *clientv3.LeaseTimeToLiveResponse
+	err := c.withAuth(func(cli *clientv3.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), c.requestTimeout())
+		defer cancel()
+		var err error
+		resp, err = cli.TimeToLive(ctx, clientv3.LeaseID(id))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.TTL < 0 {
+		return 0, nil
 	}
-	return vars, nil
+	return time.Duration(resp.TTL) * time.Second, nil
 }
 
 // WatchPrefix watches a specific prefix for changes.
+// With an AfterIndex, e.g. the revision of GetValuesWithRevision, the watch starts right after that revision.
+// Failed watches are retried if a Backoff is set in the WatchOptions.
 func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
 	var options easykv.WatchOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
-	etcdctx, cancel := context.WithCancel(ctx)
+	return options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, options)
+	})
+}
+
+// reconnectBackoff is the delay between the attempts to re-establish a failed watch.
+var reconnectBackoff = easykv.BackoffOptions{
+	Initial:    100 * time.Millisecond,
+	Max:        10 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// errWatchClosed is returned by watchOnce if etcd closed the watch channel.
+var errWatchClosed = errors.New("etcd watch channel closed")
+
+// watchPrefix runs a single watch.
+// If the watch fails or etcd closes it, it is re-established right after the
+// last revision it has seen until ctx is canceled. If that revision was compacted
+// in the meantime, the changes in between are lost and a change is reported
+// immediately, so the caller reads the whole prefix again.
+func (c *Client) watchPrefix(ctx context.Context, prefix string, options easykv.WatchOptions) (uint64, error) {
+	var rev int64
+	if options.IndexStore != nil {
+		if err := options.ResumeIndex(prefix); err != nil {
+			return 0, err
+		}
+		// a resumed watch starts right after the persisted revision
+		if options.WaitIndex > 0 {
+			rev = int64(options.WaitIndex) + 1
+		}
+	}
+	if rev == 0 && options.AfterIndex > 0 {
+		rev = int64(options.AfterIndex) + 1
+	}
+
+	c.logger.Debug("watching etcd prefix", "prefix", prefix, "waitIndex", options.WaitIndex)
+	b := easykv.NewBackoff(reconnectBackoff)
+	for {
+		index, next, err := c.watchOnce(ctx, prefix, rev, options)
+		if err == nil || err == easykv.ErrWatchCanceled || rpctypes.Error(err) == rpctypes.ErrPermissionDenied {
+			return index, err
+		}
+		if next > rev {
+			rev = next
+			b.Reset()
+		}
+
+		c.logger.Warn("etcd watch failed, reconnecting", "prefix", prefix, "revision", rev, "err", err)
+		if !b.Wait(ctx) {
+			return options.WaitIndex, easykv.ErrWatchCanceled
+		}
+		c.logger.Info("reconnected etcd watch", "prefix", prefix, "revision", rev)
+	}
+}
+
+// watchOnce watches prefix starting at rev, or at the current revision if rev is 0.
+// It returns the index of the first change of one of the watched keys, or the
+// error of the watch together with the revision the watch has to be resumed at.
+func (c *Client) watchOnce(ctx context.Context, prefix string, rev int64, options easykv.WatchOptions) (uint64, int64, error) {
+	// the created notification tells the revision the watch starts at
+	watchOpts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithCreatedNotify()}
+	if rev > 0 {
+		watchOpts = append(watchOpts, clientv3.WithRev(rev))
+	}
+
+	// without a leader the watch fails instead of hanging
+	etcdctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
 	defer cancel()
-	var err error
 
-	rch := c.client.Watch(etcdctx, prefix, clientv3.WithPrefix())
+	var next int64
+	cli := c.api()
+	rch := cli.Watch(etcdctx, prefix, watchOpts...)
 	for wresp := range rch {
-		if wresp.Err() != nil {
-			return options.WaitIndex, wresp.Err()
+		if wresp.CompactRevision != 0 {
+			c.logger.Warn("etcd watch revision was compacted, resyncing", "prefix", prefix, "revision", rev,
+				"compactRevision", wresp.CompactRevision)
+			current := uint64(wresp.Header.Revision)
+			if options.IndexStore != nil {
+				return current, 0, options.SaveIndex(prefix, current)
+			}
+			return current, 0, nil
+		}
+		if err := wresp.Err(); err != nil {
+			if authError(err) {
+				if rerr := c.reconnect(cli); rerr != nil {
+					c.logger.Warn("can't log in to etcd again", "err", rerr)
+				}
+			}
+			return 0, next, err
 		}
+		next = wresp.Header.Revision + 1
+
 		for _, ev := range wresp.Events {
 			// Only return if we have a key prefix we care about.
 			// This is not an exact match on the key so there is a chance
@@ -112,13 +490,71 @@ func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.
 			// is reducing the scope of keys that can trigger updates.
 			for _, k := range options.Keys {
 				if strings.HasPrefix(string(ev.Kv.Key), k) {
-					return uint64(ev.Kv.Version), err
+					// the index is the revision of the change, AfterIndex continues right after it
+					index := uint64(ev.Kv.ModRevision)
+					if options.IndexStore != nil {
+						return index, 0, options.SaveIndex(prefix, index)
+					}
+					return index, 0, nil
 				}
 			}
 		}
 	}
-	if ctx.Err() == context.Canceled {
-		return options.WaitIndex, easykv.ErrWatchCanceled
+	if ctx.Err() != nil {
+		return options.WaitIndex, 0, easykv.ErrWatchCanceled
+	}
+	return 0, next, errWatchClosed
+}
+
+// SetValues writes all key-value pairs to etcd.
+func (c *Client) SetValues(values map[string]string) error {
+	for k, v := range values {
+		err := c.withAuth(func(cli *clientv3.Client) error {
+			ctx, cancel := context.WithTimeout(context.Background(), c.requestTimeout())
+			defer cancel()
+			_, err := cli.Put(ctx, k, v)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteValues deletes all given keys from etcd.
+func (c *Client) DeleteValues(keys []string) error {
+	for _, k := range keys {
+		err := c.withAuth(func(cli *clientv3.Client) error {
+			ctx, cancel := context.WithTimeout(context.Background(), c.requestTimeout())
+			defer cancel()
+			_, err := cli.Delete(ctx, k)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetValue returns the value of a single key.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	var resp *clientv3.GetResponse
+	err := c.withAuth(func(cli *clientv3.Client) error {
+		var opts []clientv3.OpOption
+		if c.serializable {
+			opts = append(opts, clientv3.WithSerializable())
+		}
+		var err error
+		resp, err = cli.Get(ctx, key, opts...)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", easykv.ErrKeyNotFound
 	}
-	return 0, err
+	return string(resp.Kvs[0].Value), nil
 }
@@ -10,11 +10,16 @@ package etcdv3
 
 import (
 	"context"
+	"net"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/conformance"
 	"github.com/HeavyHorst/easykv/testutils"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
 
 	. "gopkg.in/check.v1"
 )
@@ -79,3 +84,180 @@ func (s *FilterSuite) TestWatchPrefixCancel(t *C) {
 	cancel()
 	wg.Wait()
 }
+
+func (s *FilterSuite) TestGetValuesWithRevision(t *C) {
+	c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	ctx := context.Background()
+	c.client.Put(ctx, "/revtest/a/key", "a")
+	c.client.Put(ctx, "/revtest/b/key", "b")
+	vars, rev, err := c.GetValuesWithRevision([]string{"/revtest/a", "/revtest/b"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/revtest/a/key": "a", "/revtest/b/key": "b"})
+
+	// the change between the read and the watch isn't missed
+	resp, err := c.client.Put(ctx, "/revtest/a/key", "changed")
+	t.Assert(err, IsNil)
+	t.Check(uint64(resp.Header.Revision) > rev, Equals, true)
+	wctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err = c.WatchPrefix(wctx, "/revtest", easykv.WithKeys([]string{"/revtest"}), easykv.WithAfterIndex(rev))
+	t.Assert(err, IsNil)
+}
+
+// memIndexStore keeps the watch indexes in memory.
+type memIndexStore map[string]uint64
+
+func (m memIndexStore) LoadIndex(prefix string) (uint64, error) {
+	return m[prefix], nil
+}
+
+func (m memIndexStore) SaveIndex(prefix string, index uint64) error {
+	m[prefix] = index
+	return nil
+}
+
+func (s *FilterSuite) TestWatchPrefixCompacted(t *C) {
+	c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	ctx := context.Background()
+	resp, err := c.client.Put(ctx, "/compacttest/key", "1")
+	t.Assert(err, IsNil)
+	old := resp.Header.Revision
+	resp, err = c.client.Put(ctx, "/compacttest/key", "2")
+	t.Assert(err, IsNil)
+	_, err = c.client.Compact(ctx, resp.Header.Revision)
+	t.Assert(err, IsNil)
+
+	// the persisted revision was compacted, the watch reports a change instead of failing
+	store := memIndexStore{"/compacttest": uint64(old - 1)}
+	index, err := c.WatchPrefix(ctx, "/compacttest", easykv.WithKeys([]string{"/compacttest"}), easykv.WithIndexStore(store))
+	t.Assert(err, IsNil)
+	t.Check(index >= uint64(resp.Header.Revision), Equals, true)
+	t.Check(store["/compacttest"], Equals, index)
+}
+
+func (s *FilterSuite) TestGetValuesWithMetadata(t *C) {
+	c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	lease, err := c.client.Grant(context.Background(), 60)
+	t.Assert(err, IsNil)
+	c.client.Put(context.Background(), "/metatest/service/a", "10.0.0.1", clientv3.WithLease(lease.ID))
+	c.client.Put(context.Background(), "/metatest/config/url", "www.google.de")
+
+	md, err := c.GetValuesWithMetadata([]string{"/metatest"})
+	t.Assert(err, IsNil)
+	t.Check(md["/metatest/service/a"].Ephemeral(), Equals, true)
+	t.Check(md["/metatest/service/a"].LeaseID, Equals, int64(lease.ID))
+	t.Check(md["/metatest/service/a"].TTL > 0, Equals, true)
+	t.Check(md["/metatest/config/url"].Ephemeral(), Equals, false)
+	t.Check(md["/metatest/config/url"].Value, Equals, "www.google.de")
+}
+
+func (s *FilterSuite) TestSerializable(t *C) {
+	c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "", WithSerializable(true))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	t.Assert(c.SetValues(map[string]string{"/serializable/url": "www.google.de"}), IsNil)
+	vars, err := c.GetValues([]string{"/serializable"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/serializable/url": "www.google.de"})
+	v, err := c.GetValue(context.Background(), "/serializable/url")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "www.google.de")
+}
+
+func (s *FilterSuite) TestNamespace(t *C) {
+	c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "", WithNamespace("/tenant-a"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	raw, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer raw.Close()
+
+	t.Assert(c.SetValues(map[string]string{"/app/url": "www.google.de"}), IsNil)
+	t.Assert(raw.SetValues(map[string]string{"/app/url": "other"}), IsNil)
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/url": "www.google.de"})
+
+	vars, err = raw.GetValues([]string{"/tenant-a/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/tenant-a/app/url": "www.google.de"})
+}
+
+func (s *FilterSuite) TestDialTimeout(t *C) {
+	// the endpoint accepts connections but never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Assert(err, IsNil)
+	defer l.Close()
+
+	c, err := NewEtcdClient([]string{"http://" + l.Addr().String()}, "", "", "", false, "", "",
+		WithDial(DialOptions{Timeout: 100 * time.Millisecond, RequestTimeout: 200 * time.Millisecond, KeepAliveTime: time.Second}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	start := time.Now()
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, NotNil)
+	t.Check(time.Since(start) < 2*time.Second, Equals, true)
+}
+
+func (s *FilterSuite) TestEndpointRefresh(t *C) {
+	var mu sync.Mutex
+	eps := []string{"http://127.0.0.1:2379"}
+	resolve := func() ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return eps, nil
+	}
+
+	c, err := NewEtcdClient([]string{"http://127.0.0.1:2379"}, "", "", "", false, "", "", WithEndpointRefresh(resolve, 10*time.Millisecond))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	mu.Lock()
+	eps = []string{"http://127.0.0.2:2379", "http://127.0.0.3:2379"}
+	mu.Unlock()
+	for i := 0; i < 100 && len(c.api().Endpoints()) != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Check(c.api().Endpoints(), DeepEquals, []string{"http://127.0.0.2:2379", "http://127.0.0.3:2379"})
+}
+
+func (s *FilterSuite) TestAuthError(t *C) {
+	t.Check(authError(rpctypes.ErrAuthFailed), Equals, true)
+	t.Check(authError(rpctypes.ErrGRPCInvalidAuthToken), Equals, true)
+	t.Check(authError(rpctypes.ErrPermissionDenied), Equals, false)
+	t.Check(authError(nil), Equals, false)
+}
+
+func (s *FilterSuite) TestLock(t *C) {
+	c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+	testutils.Lock(t, c, "/locktest")
+	testutils.LockValues(t, c, "/lockvaluetest")
+}
+
+func (s *FilterSuite) TestConformance(t *C) {
+	conformance.Run(t, func(data map[string]string) (easykv.ReadWatcher, error) {
+		c, err := NewEtcdClient([]string{"http://localhost:2379"}, "", "", "", false, "", "")
+		if err != nil {
+			return nil, err
+		}
+		if err := c.SetValues(data); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	})
+}
@@ -0,0 +1,71 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package etcdv3
+
+import (
+	"context"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+)
+
+// session is a lock held with an etcd lease.
+type session struct {
+	session *concurrency.Session
+	mutex   *concurrency.Mutex
+}
+
+func (s *session) Done() <-chan struct{} {
+	return s.session.Done()
+}
+
+// SetValue writes value to key with the lease of the lock,
+// the key is deleted when the lease is revoked or expires.
+func (s *session) SetValue(ctx context.Context, key, value string) error {
+	select {
+	case <-s.session.Done():
+		return easykv.ErrLockLost
+	default:
+	}
+	_, err := s.session.Client().Put(ctx, key, value, clientv3.WithLease(s.session.Lease()))
+	return err
+}
+
+func (s *session) Unlock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(3)*time.Second)
+	defer cancel()
+	err := s.mutex.Unlock(ctx)
+	if cerr := s.session.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Lock acquires the lock on key with an etcd lease.
+// The lease ttl is rounded up to full seconds.
+func (c *Client) Lock(ctx context.Context, key string, ttl time.Duration) (easykv.Session, error) {
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	s, err := concurrency.NewSession(c.api(), concurrency.WithTTL(seconds))
+	if err != nil {
+		return nil, err
+	}
+	m := concurrency.NewMutex(s, key)
+	if err := m.Lock(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	c.logger.Debug("acquired etcd lock", "key", key, "lease", s.Lease())
+	return &session{s, m}, nil
+}
@@ -0,0 +1,86 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package etcdv3
+
+import (
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
+// Option configures the etcdv3 client.
+type Option func(*Client)
+
+// WithLogger sets the Logger that reports connections and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(c *Client) {
+		c.logger = easykv.LoggerOrDiscard(l)
+	}
+}
+
+// WithTLS sets the TLSOptions. They replace the certificates given to NewEtcdClient.
+func WithTLS(tls easykv.TLSOptions) Option {
+	return func(c *Client) {
+		c.tls = tls
+	}
+}
+
+// WithPasswordFrom sets the reference the password is read from again
+// whenever the referenced file changes or etcd rejects the credentials,
+// e.g. after a password rotation. The client then logs in again.
+func WithPasswordFrom(ref credentials.Ref) Option {
+	return func(c *Client) {
+		c.passwordRef = ref
+	}
+}
+
+// DialOptions configures the connection to etcd, so unreachable endpoints fail fast.
+// Timeout limits the dial (5s by default) and RequestTimeout every request (3s by default).
+// KeepAliveTime enables gRPC keepalive pings, a connection fails if a ping isn't
+// answered within KeepAliveTimeout. PermitWithoutStream also pings idle connections.
+type DialOptions struct {
+	Timeout             time.Duration
+	KeepAliveTime       time.Duration
+	KeepAliveTimeout    time.Duration
+	PermitWithoutStream bool
+	RequestTimeout      time.Duration
+}
+
+// WithDial sets the DialOptions.
+func WithDial(d DialOptions) Option {
+	return func(c *Client) {
+		c.dial = d
+	}
+}
+
+// WithNamespace prepends namespace to all keys, e.g. /tenant-a, so several
+// tenants can share one etcd cluster. Keys are returned and watched without the namespace.
+func WithNamespace(namespace string) Option {
+	return func(c *Client) {
+		c.namespace = namespace
+	}
+}
+
+// WithEndpointRefresh resolves the endpoints with resolve every interval,
+// e.g. from DNS SRV records, so cluster membership changes are picked up.
+func WithEndpointRefresh(resolve func() ([]string, error), interval time.Duration) Option {
+	return func(c *Client) {
+		c.resolve = resolve
+		c.refreshInterval = interval
+	}
+}
+
+// WithSerializable serves reads from any member, including followers and learners,
+// instead of the leader. Reads have a lower latency but may return stale values.
+func WithSerializable(serializable bool) Option {
+	return func(c *Client) {
+		c.serializable = serializable
+	}
+}
@@ -0,0 +1,377 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package gateway talks to etcd through its HTTP/JSON gRPC gateway.
+// It is meant for environments where middleboxes block gRPC.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
+// requestTimeout limits every request except watches.
+const requestTimeout = 3 * time.Second
+
+// gRPC status codes of the gateway errors.
+const (
+	codePermissionDenied = 7
+	codeUnavailable      = 14
+	codeUnauthenticated  = 16
+)
+
+// Client is a wrapper around the etcd gRPC gateway.
+type Client struct {
+	endpoints []string
+	prefix    string
+	proxy     string
+	transport *http.Transport
+	http      *http.Client
+	logger    easykv.Logger
+	tls       easykv.TLSOptions
+
+	username    string
+	passwordRef credentials.Ref
+	stopWatch   context.CancelFunc
+
+	mu       sync.Mutex
+	password string
+	token    string
+	next     int
+}
+
+// NewEtcdClient returns a *gateway.Client for the gateways of the named machines.
+// The machines are tried in order until one of them answers.
+func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool, username string, password string, opts ...Option) (*Client, error) {
+	c := &Client{
+		endpoints: machines,
+		prefix:    "/v3",
+		logger:    easykv.DiscardLogger,
+		tls:       easykv.TLSOptions{ClientCert: cert, ClientKey: key, ClientCaKeys: caCert},
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if len(c.endpoints) == 0 {
+		return c, errors.New("no etcd gateway endpoints given")
+	}
+
+	proxyFunc, err := easykv.ProxyFunc(c.proxy)
+	if err != nil {
+		return c, err
+	}
+	c.transport = &http.Transport{
+		Proxy: proxyFunc,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if c.tls.Enabled() {
+		tlsConfig, err := c.tls.Config()
+		if err != nil {
+			return c, err
+		}
+		c.transport.TLSClientConfig = tlsConfig
+	}
+	c.http = &http.Client{Transport: c.transport}
+
+	if basicAuth {
+		c.username, c.password = username, password
+		if err := c.authenticate(); err != nil {
+			c.logger.Warn("can't log in to the etcd gateway", "endpoints", machines, "err", err)
+			return c, err
+		}
+		if !c.passwordRef.IsZero() {
+			ctx, cancel := context.WithCancel(context.Background())
+			c.stopWatch = cancel
+			go credentials.WatchRefs(ctx, c.reload, []credentials.Ref{c.passwordRef},
+				credentials.WithErrorHandler(func(err error) {
+					c.logger.Warn("can't log in to the etcd gateway with the new password", "err", err)
+				}),
+			)
+		}
+	}
+	c.logger.Info("using the etcd gateway", "endpoints", machines, "auth", basicAuth)
+	return c, nil
+}
+
+// rpcError is an error returned by the gateway.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return "etcd gateway: " + e.Message
+}
+
+// classify wraps err in a *easykv.BackendError if its code has a Kind.
+func (e *rpcError) classify() error {
+	var kind error
+	switch {
+	case e.Code == codeUnauthenticated || strings.Contains(e.Message, "authentication failed"):
+		kind = easykv.ErrAuthentication
+	case e.Code == codePermissionDenied:
+		kind = easykv.ErrPermissionDenied
+	case e.Code == codeUnavailable:
+		kind = easykv.ErrConnection
+	default:
+		return e
+	}
+	return &easykv.BackendError{Kind: kind, Err: e}
+}
+
+// readError returns the error of a failed response.
+func readError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	var e rpcError
+	if json.Unmarshal(body, &e) != nil || e.Message == "" {
+		e.Message = fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if e.Code == 0 && resp.StatusCode >= 500 {
+		e.Code = codeUnavailable
+	}
+	return e.classify()
+}
+
+// send posts data to the api method and returns the body of the response.
+// If an endpoint can't be reached, the next one is tried.
+func (c *Client) send(ctx context.Context, method string, data []byte) (io.ReadCloser, error) {
+	c.mu.Lock()
+	first, token := c.next, c.token
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := range c.endpoints {
+		n := (first + i) % len(c.endpoints)
+		req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.endpoints[n], "/")+c.prefix+method, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = &easykv.BackendError{Kind: easykv.ErrConnection, Err: err}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := readError(resp)
+			resp.Body.Close()
+			if easykv.ErrorKind(err) == easykv.ErrConnection {
+				lastErr = err
+				continue
+			}
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.next = n
+		c.mu.Unlock()
+		return resp.Body, nil
+	}
+	return nil, lastErr
+}
+
+// post sends req to the api method and returns the body of the response.
+// If the auth token was rejected, the client logs in again and sends req once more.
+func (c *Client) post(ctx context.Context, method string, req interface{}) (io.ReadCloser, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.send(ctx, method, data)
+	if easykv.ErrorKind(err) != easykv.ErrAuthentication || c.username == "" {
+		return body, err
+	}
+	if aerr := c.reload(); aerr != nil {
+		c.logger.Warn("can't log in to the etcd gateway again", "err", aerr)
+		return nil, err
+	}
+	return c.send(ctx, method, data)
+}
+
+// call sends req to the api method and decodes the response into resp.
+// The request is limited by requestTimeout.
+func (c *Client) call(ctx context.Context, method string, req, resp interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	body, err := c.post(ctx, method, req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(resp)
+}
+
+// reload reads the password again from the password reference and logs in again.
+func (c *Client) reload() error {
+	if !c.passwordRef.IsZero() {
+		password, err := c.passwordRef.Resolve("")
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.password = password
+		c.mu.Unlock()
+	}
+	return c.authenticate()
+}
+
+// authenticate logs in with the username and password and stores the auth token.
+func (c *Client) authenticate() error {
+	c.mu.Lock()
+	c.token = ""
+	password := c.password
+	c.mu.Unlock()
+
+	data, err := json.Marshal(map[string]string{"name": c.username, "password": password})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	body, err := c.send(ctx, "/auth/authenticate", data)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.token = resp.Token
+	c.mu.Unlock()
+	return nil
+}
+
+// Close closes the idle connections to the gateway and stops watching the password file.
+func (c *Client) Close() {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+}
+
+type responseHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type keyValue struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	Version     int64  `json:"version,string"`
+	ModRevision int64  `json:"mod_revision,string"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+	Revision int64  `json:"revision,string,omitempty"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []keyValue     `json:"kvs"`
+}
+
+// prefixEnd returns the end of the range of all keys with prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// the prefix is all 0xff, range to the end of the keyspace
+	return []byte{0}
+}
+
+// GetValues is used to lookup all keys with a prefix.
+// Several prefixes can be specified in the keys array.
+// All prefixes are read from the same revision.
+func (c *Client) GetValues(keys []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	var rev int64
+	for _, key := range keys {
+		var resp rangeResponse
+		if err := c.call(context.Background(), "/kv/range", rangeRequest{Key: []byte(key), RangeEnd: prefixEnd(key), Revision: rev}, &resp); err != nil {
+			return vars, err
+		}
+		if rev == 0 {
+			rev = resp.Header.Revision
+		}
+		for _, kv := range resp.Kvs {
+			vars[string(kv.Key)] = string(kv.Value)
+		}
+	}
+	return vars, nil
+}
+
+// GetValue returns the value of a single key.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	var resp rangeResponse
+	if err := c.call(ctx, "/kv/range", rangeRequest{Key: []byte(key)}, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", easykv.ErrKeyNotFound
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// SetValues writes all key-value pairs to etcd.
+func (c *Client) SetValues(values map[string]string) error {
+	for k, v := range values {
+		var resp struct{}
+		if err := c.call(context.Background(), "/kv/put", map[string][]byte{"key": []byte(k), "value": []byte(v)}, &resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteValues deletes all given keys from etcd.
+func (c *Client) DeleteValues(keys []string) error {
+	for _, k := range keys {
+		var resp struct{}
+		if err := c.call(context.Background(), "/kv/deleterange", map[string][]byte{"key": []byte(k)}, &resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+	"github.com/HeavyHorst/easykv/testutils"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+// fakeGateway serves the parts of the etcd gateway api the client uses.
+type fakeGateway struct {
+	mu      sync.Mutex
+	data    map[string]string
+	rev     int64
+	token   string
+	changed chan struct{}
+	// logins receives the passwords of all logins if it's set
+	logins chan string
+}
+
+func newFakeGateway(data map[string]string) *fakeGateway {
+	return &fakeGateway{data: data, rev: 1, changed: make(chan struct{}, 1)}
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]json.RawMessage
+	json.NewDecoder(r.Body).Decode(&req)
+	var key, end []byte
+	json.Unmarshal(req["key"], &key)
+	json.Unmarshal(req["range_end"], &end)
+
+	g.mu.Lock()
+	if g.token != "" && r.URL.Path != "/v3/auth/authenticate" && r.Header.Get("Authorization") != g.token {
+		g.mu.Unlock()
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 16, "message": "etcdserver: invalid auth token"})
+		return
+	}
+	defer g.mu.Unlock()
+
+	header := map[string]string{"revision": strconv.FormatInt(g.rev, 10)}
+	switch r.URL.Path {
+	case "/v3/auth/authenticate":
+		var name, password string
+		json.Unmarshal(req["name"], &name)
+		json.Unmarshal(req["password"], &password)
+		if g.logins != nil {
+			g.logins <- password
+		}
+		if name != "root" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 3, "message": "etcdserver: authentication failed, invalid user ID or password"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": g.token})
+	case "/v3/kv/range":
+		var keys []string
+		for k := range g.data {
+			if k == string(key) || (end != nil && k >= string(key) && k < string(end)) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		kvs := []map[string]interface{}{}
+		for _, k := range keys {
+			kvs = append(kvs, map[string]interface{}{"key": []byte(k), "value": []byte(g.data[k]), "version": "1", "mod_revision": "1"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"header": header, "kvs": kvs})
+	case "/v3/kv/put":
+		var value []byte
+		json.Unmarshal(req["value"], &value)
+		g.data[string(key)] = string(value)
+		g.rev++
+		select {
+		case g.changed <- struct{}{}:
+		default:
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"header": header})
+	case "/v3/watch":
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]interface{}{"result": map[string]interface{}{"header": header, "created": true}})
+		w.(http.Flusher).Flush()
+		g.mu.Unlock()
+		select {
+		case <-g.changed:
+		case <-r.Context().Done():
+			g.mu.Lock()
+			return
+		}
+		g.mu.Lock()
+		ev := map[string]interface{}{"kv": map[string]interface{}{"key": []byte("/app/name"), "version": "7", "mod_revision": strconv.FormatInt(g.rev, 10)}}
+		enc.Encode(map[string]interface{}{"result": map[string]interface{}{"header": header, "events": []interface{}{ev}}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *FilterSuite) TestGetValues(t *C) {
+	ts := httptest.NewServer(newFakeGateway(map[string]string{
+		"/premtest/database/url":              "www.google.de",
+		"/premtest/database/user":             "Boris",
+		"/remtest/database/hosts/192.168.0.1": "test1",
+		"/remtest/database/hosts/192.168.0.2": "test2",
+	}))
+	defer ts.Close()
+
+	c, err := NewEtcdClient([]string{ts.URL}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	t.Check(testutils.GetValues(t, c), IsNil)
+}
+
+func (s *FilterSuite) TestFailover(t *C) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	ts := httptest.NewServer(newFakeGateway(map[string]string{"/app/name": "easykv"}))
+	defer ts.Close()
+
+	c, err := NewEtcdClient([]string{down.URL, ts.URL}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	v, err := c.GetValue(context.Background(), "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "easykv")
+
+	_, err = c.GetValue(context.Background(), "/app/missing")
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+}
+
+func (s *FilterSuite) TestAuthentication(t *C) {
+	g := newFakeGateway(map[string]string{"/app/name": "easykv"})
+	g.token = "token-1"
+	ts := httptest.NewServer(g)
+	defer ts.Close()
+
+	_, err := NewEtcdClient([]string{ts.URL}, "", "", "", true, "nobody", "secret")
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrAuthentication)
+
+	c, err := NewEtcdClient([]string{ts.URL}, "", "", "", true, "root", "secret")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	// an expired token is replaced
+	g.mu.Lock()
+	g.token = "token-2"
+	g.mu.Unlock()
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+}
+
+func (s *FilterSuite) TestPasswordFrom(t *C) {
+	g := newFakeGateway(map[string]string{"/app/name": "easykv"})
+	g.token = "token-1"
+	g.logins = make(chan string, 10)
+	ts := httptest.NewServer(g)
+	defer ts.Close()
+
+	p := filepath.Join(t.MkDir(), "password")
+	t.Assert(ioutil.WriteFile(p, []byte("secret-1\n"), 0600), IsNil)
+	c, err := NewEtcdClient([]string{ts.URL}, "", "", "", true, "root", "secret-1", WithPasswordFrom(credentials.File(p)))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Check(<-g.logins, Equals, "secret-1")
+
+	// a rejected token is replaced with a login with the rotated password
+	t.Assert(ioutil.WriteFile(p, []byte("secret-2\n"), 0600), IsNil)
+	g.mu.Lock()
+	g.token = "token-2"
+	g.mu.Unlock()
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+	t.Check(<-g.logins, Equals, "secret-2")
+}
+
+func (s *FilterSuite) TestWatchPrefix(t *C) {
+	g := newFakeGateway(map[string]string{"/app/name": "easykv"})
+	ts := httptest.NewServer(g)
+	defer ts.Close()
+
+	c, err := NewEtcdClient([]string{ts.URL}, "", "", "", false, "", "")
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		c.SetValues(map[string]string{"/app/name": "changed"})
+	}()
+	index, err := c.WatchPrefix(context.Background(), "/app", easykv.WithKeys([]string{"/app"}))
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.WatchPrefix(ctx, "/app", easykv.WithKeys([]string{"/app"}))
+	t.Check(err, Equals, easykv.ErrWatchCanceled)
+}
+
+func (s *FilterSuite) TestPrefixEnd(t *C) {
+	t.Check(prefixEnd("/app"), DeepEquals, []byte("/apq"))
+	t.Check(bytes.Equal(prefixEnd("a\xff"), []byte("b")), Equals, true)
+	t.Check(prefixEnd("\xff"), DeepEquals, []byte{0})
+}
@@ -0,0 +1,54 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package gateway
+
+import (
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
+// Option configures the gateway client.
+type Option func(*Client)
+
+// WithLogger sets the Logger that reports connections and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(c *Client) {
+		c.logger = easykv.LoggerOrDiscard(l)
+	}
+}
+
+// WithTLS sets the TLSOptions. They replace the certificates given to NewEtcdClient.
+func WithTLS(tls easykv.TLSOptions) Option {
+	return func(c *Client) {
+		c.tls = tls
+	}
+}
+
+// WithPasswordFrom sets the reference the password is read from again
+// whenever the referenced file changes or the gateway rejects the auth token.
+func WithPasswordFrom(ref credentials.Ref) Option {
+	return func(c *Client) {
+		c.passwordRef = ref
+	}
+}
+
+// WithProxy sets the http, https or socks5 proxy URL, see easykv.ProxyFunc.
+func WithProxy(proxy string) Option {
+	return func(c *Client) {
+		c.proxy = proxy
+	}
+}
+
+// WithAPIPrefix sets the path prefix of the gateway api, /v3 by default.
+// etcd 3.3 serves the gateway below /v3beta.
+func WithAPIPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.prefix = prefix
+	}
+}
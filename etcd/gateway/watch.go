@@ -0,0 +1,179 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// reconnectBackoff is the delay between the attempts to re-establish a failed watch.
+var reconnectBackoff = easykv.BackoffOptions{
+	Initial:    100 * time.Millisecond,
+	Max:        10 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// errWatchClosed is returned by watchOnce if the gateway closed the watch stream.
+var errWatchClosed = errors.New("etcd gateway watch stream closed")
+
+type watchCreateRequest struct {
+	Key           []byte `json:"key"`
+	RangeEnd      []byte `json:"range_end"`
+	StartRevision int64  `json:"start_revision,string,omitempty"`
+}
+
+// watchResponse is a single message of the streamed watch response.
+type watchResponse struct {
+	Result *struct {
+		Header          responseHeader `json:"header"`
+		Canceled        bool           `json:"canceled"`
+		CancelReason    string         `json:"cancel_reason"`
+		CompactRevision int64          `json:"compact_revision,string"`
+		Events          []struct {
+			Kv keyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Code    int    `json:"grpc_code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// WatchPrefix watches a specific prefix for changes.
+// With an AfterIndex, e.g. a revision read before, the watch starts right after that revision.
+// Failed watches are retried if a Backoff is set in the WatchOptions.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, options)
+	})
+}
+
+// watchPrefix runs a single watch.
+// If the stream fails or the gateway closes it, it is re-established right after the
+// last revision it has seen until ctx is canceled. If that revision was compacted
+// in the meantime, a change is reported immediately.
+func (c *Client) watchPrefix(ctx context.Context, prefix string, options easykv.WatchOptions) (uint64, error) {
+	var rev int64
+	if options.IndexStore != nil {
+		if err := options.ResumeIndex(prefix); err != nil {
+			return 0, err
+		}
+		if options.WaitIndex > 0 {
+			rev = int64(options.WaitIndex) + 1
+		}
+	}
+	if rev == 0 && options.AfterIndex > 0 {
+		rev = int64(options.AfterIndex) + 1
+	}
+
+	c.logger.Debug("watching etcd prefix through the gateway", "prefix", prefix, "waitIndex", options.WaitIndex)
+	b := easykv.NewBackoff(reconnectBackoff)
+	for {
+		index, next, err := c.watchOnce(ctx, prefix, rev, options)
+		if err == nil || err == easykv.ErrWatchCanceled || easykv.ErrorKind(err) == easykv.ErrPermissionDenied {
+			return index, err
+		}
+		if next > rev {
+			rev = next
+			b.Reset()
+		}
+
+		c.logger.Warn("etcd gateway watch failed, reconnecting", "prefix", prefix, "revision", rev, "err", err)
+		if !b.Wait(ctx) {
+			return options.WaitIndex, easykv.ErrWatchCanceled
+		}
+	}
+}
+
+// watchOnce watches prefix starting at rev, or at the current revision if rev is 0.
+// It returns the index of the first change of one of the watched keys, or the
+// error of the watch together with the revision the watch has to be resumed at.
+func (c *Client) watchOnce(ctx context.Context, prefix string, rev int64, options easykv.WatchOptions) (uint64, int64, error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := map[string]watchCreateRequest{
+		"create_request": {Key: []byte(prefix), RangeEnd: prefixEnd(prefix), StartRevision: rev},
+	}
+	body, err := c.post(wctx, "/watch", req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return options.WaitIndex, 0, easykv.ErrWatchCanceled
+		}
+		return 0, 0, err
+	}
+	defer body.Close()
+
+	var next int64
+	dec := json.NewDecoder(body)
+	for {
+		var wresp watchResponse
+		if err := dec.Decode(&wresp); err != nil {
+			if ctx.Err() != nil {
+				return options.WaitIndex, 0, easykv.ErrWatchCanceled
+			}
+			return 0, next, errWatchClosed
+		}
+		if e := wresp.Error; e != nil {
+			err := (&rpcError{Code: e.Code, Message: e.Message}).classify()
+			if easykv.ErrorKind(err) == easykv.ErrAuthentication && c.username != "" {
+				if aerr := c.authenticate(); aerr != nil {
+					c.logger.Warn("can't log in to the etcd gateway again", "err", aerr)
+				}
+			}
+			return 0, next, err
+		}
+		r := wresp.Result
+		if r == nil {
+			continue
+		}
+		if r.CompactRevision != 0 {
+			c.logger.Warn("etcd watch revision was compacted, resyncing", "prefix", prefix, "revision", rev,
+				"compactRevision", r.CompactRevision)
+			current := uint64(r.Header.Revision)
+			if options.IndexStore != nil {
+				return current, 0, options.SaveIndex(prefix, current)
+			}
+			return current, 0, nil
+		}
+		if r.Canceled {
+			return 0, next, &rpcError{Message: "watch canceled: " + r.CancelReason}
+		}
+		if rev == 0 && next == 0 {
+			// the watch started at the current revision
+			next = r.Header.Revision + 1
+		}
+
+		for _, ev := range r.Events {
+			for _, k := range options.Keys {
+				if strings.HasPrefix(string(ev.Kv.Key), k) {
+					// the index is the revision of the change, AfterIndex continues right after it
+					index := uint64(ev.Kv.ModRevision)
+					if options.IndexStore != nil {
+						return index, 0, options.SaveIndex(prefix, index)
+					}
+					return index, 0, nil
+				}
+			}
+			next = ev.Kv.ModRevision + 1
+		}
+	}
+}
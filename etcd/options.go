@@ -8,20 +8,48 @@
 
 package etcd
 
+import (
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+	"github.com/HeavyHorst/easykv/etcd/etcdv3"
+)
+
 // Options contains all values that are needed to connect to etcd.
 type Options struct {
 	Nodes   []string
 	Version int
 	TLS     TLSOptions
 	Auth    BasicAuthOptions
+	Dial    DialOptions
+	Logger  easykv.Logger
+
+	// Namespace is prepended to all keys by the etcd v3 client.
+	Namespace string
+
+	// Serializable lets followers serve the reads of the etcd v3 client.
+	Serializable bool
+
+	// Gateway talks to the HTTP/JSON gRPC gateway of etcd v3 instead of gRPC,
+	// through the Proxy if it is set.
+	Gateway bool
+	Proxy   string
+
+	// DiscoverySRV is the domain the endpoints are discovered from instead of Nodes.
+	// The etcd v3 client resolves them again every DiscoveryInterval.
+	DiscoverySRV      string
+	DiscoveryInterval time.Duration
+
+	// PasswordRef replaces Auth.Password if it is set.
+	PasswordRef credentials.Ref
 }
 
 // TLSOptions contains all certificates and keys.
-type TLSOptions struct {
-	ClientCert   string
-	ClientKey    string
-	ClientCaKeys string
-}
+type TLSOptions = easykv.TLSOptions
+
+// DialOptions configures the connection timeouts and keepalive of the etcd v3 client.
+type DialOptions = etcdv3.DialOptions
 
 // BasicAuthOptions contains options regarding to basic authentication.
 type BasicAuthOptions struct {
@@ -46,9 +74,74 @@ func WithBasicAuth(b BasicAuthOptions) Option {
 	}
 }
 
+// WithPasswordFrom reads the basic auth password from a file or an environment variable.
+// The clients read the password again and log in again whenever the file changes,
+// the v3 and gateway clients also when etcd rejects the credentials.
+func WithPasswordFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.PasswordRef = ref
+	}
+}
+
+// WithDial sets the DialOptions, which are only supported by the etcd v3 client.
+func WithDial(d DialOptions) Option {
+	return func(o *Options) {
+		o.Dial = d
+	}
+}
+
+// WithNamespace prepends namespace to all keys, so several tenants can share one etcd cluster.
+// It is only supported by the etcd v3 client.
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.Namespace = namespace
+	}
+}
+
+// WithSerializable serves reads from any member instead of the leader, trading strict
+// linearizability for a lower latency and load on the leader.
+// It is only supported by the etcd v3 client.
+func WithSerializable(serializable bool) Option {
+	return func(o *Options) {
+		o.Serializable = serializable
+	}
+}
+
+// WithGateway talks JSON over HTTP to the gRPC gateway of etcd v3 instead of gRPC,
+// for environments where middleboxes block gRPC. Watches are streamed JSON responses.
+func WithGateway(gateway bool) Option {
+	return func(o *Options) {
+		o.Gateway = gateway
+	}
+}
+
+// WithProxy sets the http, https or socks5 proxy URL of the gateway, see easykv.ProxyFunc.
+func WithProxy(proxy string) Option {
+	return func(o *Options) {
+		o.Proxy = proxy
+	}
+}
+
+// WithDiscoverySRV discovers the endpoints from the DNS SRV records of domain
+// instead of using the given machines. The etcd v3 client resolves them again every interval,
+// so cluster membership changes are picked up. An interval of 0 disables the re-resolving.
+func WithDiscoverySRV(domain string, interval time.Duration) Option {
+	return func(o *Options) {
+		o.DiscoverySRV = domain
+		o.DiscoveryInterval = interval
+	}
+}
+
 // WithVersion sets the etcd api level. Valid levels are 2 and 3.
 func WithVersion(v int) Option {
 	return func(o *Options) {
 		o.Version = v
 	}
 }
+
+// WithLogger sets the Logger that reports connections and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
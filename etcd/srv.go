@@ -0,0 +1,53 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package etcd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ErrNoSRVRecords is returned if the discovery domain publishes no etcd client SRV records.
+var ErrNoSRVRecords = errors.New("no etcd client SRV records found")
+
+// lookupSRV is replaced in tests.
+var lookupSRV = net.LookupSRV
+
+// DiscoverSRV returns the client endpoints of the etcd cluster published in the SRV records
+// of domain, like etcd's --discovery-srv. Targets of _etcd-client-ssl._tcp records are reached
+// with https, targets of _etcd-client._tcp records with http.
+func DiscoverSRV(domain string) ([]string, error) {
+	var eps []string
+	var lastErr error
+	for _, s := range []struct{ service, scheme string }{
+		{"etcd-client-ssl", "https"},
+		{"etcd-client", "http"},
+	} {
+		_, addrs, err := lookupSRV(s.service, "tcp", domain)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, a := range addrs {
+			host := strings.TrimSuffix(a.Target, ".")
+			eps = append(eps, fmt.Sprintf("%s://%s", s.scheme, net.JoinHostPort(host, fmt.Sprint(a.Port))))
+		}
+	}
+	if len(eps) == 0 {
+		if lastErr != nil {
+			return nil, fmt.Errorf("can't discover the etcd endpoints of %s: %v", domain, lastErr)
+		}
+		return nil, ErrNoSRVRecords
+	}
+	sort.Strings(eps)
+	return eps, nil
+}
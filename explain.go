@@ -0,0 +1,70 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation describes a single request a backend issued.
+type Operation struct {
+	Op       string
+	Key      string
+	Duration time.Duration
+	Err      error
+}
+
+// Trace records the operations of a backend call.
+// A nil *Trace records nothing, so backends can always call Record.
+type Trace struct {
+	mu         sync.Mutex
+	operations []Operation
+}
+
+// Record adds the operation op on key which was started at start.
+func (t *Trace) Record(op, key string, start time.Time, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.operations = append(t.operations, Operation{op, key, time.Since(start), err})
+	t.mu.Unlock()
+}
+
+// Operations returns all recorded operations in the order they were recorded.
+func (t *Trace) Operations() []Operation {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Operation(nil), t.operations...)
+}
+
+// An Explainer can report the backend operations that were needed to get the values.
+type Explainer interface {
+	GetValuesExplain(keys []string) (map[string]string, []Operation, error)
+}
+
+// Explain looks up all keys with the given prefixes like GetValues
+// and returns the backend operations that were issued alongside the values.
+// For backends that don't implement Explainer, the whole GetValues call is reported as one operation.
+func Explain(rw ReadWatcher, keys []string) (map[string]string, []Operation, error) {
+	if e, ok := rw.(Explainer); ok {
+		return e.GetValuesExplain(keys)
+	}
+
+	var t Trace
+	start := time.Now()
+	vars, err := rw.GetValues(keys)
+	t.Record("GetValues", strings.Join(keys, ","), start, err)
+	return vars, t.Operations(), err
+}
@@ -0,0 +1,42 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestTrace(t *C) {
+	var tr Trace
+	tr.Record("LIST", "/app", time.Now(), nil)
+	tr.Record("READ", "/app/db", time.Now(), errors.New("permission denied"))
+
+	ops := tr.Operations()
+	t.Assert(ops, HasLen, 2)
+	t.Check(ops[0].Op, Equals, "LIST")
+	t.Check(ops[1].Key, Equals, "/app/db")
+	t.Check(ops[1].Err, ErrorMatches, "permission denied")
+
+	var nilTrace *Trace
+	nilTrace.Record("LIST", "/app", time.Now(), nil)
+	t.Check(nilTrace.Operations(), HasLen, 0)
+}
+
+func (s *FilterSuite) TestExplainFallback(t *C) {
+	c := newTestClient(map[string]string{"/app/name": "easykv"})
+	vars, ops, err := Explain(c, []string{"/app", "/other"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+	t.Assert(ops, HasLen, 1)
+	t.Check(ops[0].Op, Equals, "GetValues")
+	t.Check(ops[0].Key, Equals, "/app,/other")
+}
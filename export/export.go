@@ -0,0 +1,152 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package export renders GetValues results as JSON, YAML, dotenv files or shell statements.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format is an output format.
+type Format string
+
+// The supported output formats.
+const (
+	FormatJSON   Format = "json"
+	FormatYAML   Format = "yaml"
+	FormatDotenv Format = "dotenv"
+	FormatShell  Format = "shell"
+)
+
+// ValueKey is the key under which Nest stores the value of a key that also has children.
+const ValueKey = "_value"
+
+// ErrUnknownFormat is returned by Write if the format isn't supported.
+var ErrUnknownFormat = errors.New("unknown export format - must be json, yaml, dotenv or shell")
+
+// Write renders vars in the given format to w.
+func Write(w io.Writer, format Format, vars map[string]string) error {
+	switch format {
+	case FormatJSON:
+		return JSON(w, vars)
+	case FormatYAML:
+		return YAML(w, vars)
+	case FormatDotenv:
+		return Dotenv(w, vars)
+	case FormatShell:
+		return Shell(w, vars)
+	}
+	return ErrUnknownFormat
+}
+
+// Nest reconstructs the hierarchy of the flattened keys in vars.
+// /app/db/url=x becomes {"app": {"db": {"url": "x"}}}.
+func Nest(vars map[string]string) map[string]interface{} {
+	root := make(map[string]interface{})
+	for k, v := range vars {
+		parts := strings.Split(strings.Trim(k, "/"), "/")
+		node := root
+		for _, part := range parts[:len(parts)-1] {
+			switch child := node[part].(type) {
+			case map[string]interface{}:
+				node = child
+			case string:
+				m := map[string]interface{}{ValueKey: child}
+				node[part] = m
+				node = m
+			default:
+				m := make(map[string]interface{})
+				node[part] = m
+				node = m
+			}
+		}
+
+		last := parts[len(parts)-1]
+		if child, ok := node[last].(map[string]interface{}); ok {
+			child[ValueKey] = v
+		} else {
+			node[last] = v
+		}
+	}
+	return root
+}
+
+// JSON writes vars as nested, indented json to w.
+func JSON(w io.Writer, vars map[string]string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Nest(vars))
+}
+
+// YAML writes vars as nested yaml to w.
+func YAML(w io.Writer, vars map[string]string) error {
+	data, err := yaml.Marshal(Nest(vars))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// VariableName converts a key into an environment variable name.
+// /app/db-1/url becomes APP_DB_1_URL.
+func VariableName(key string) string {
+	name := invalidNameChars.ReplaceAllString(strings.Trim(key, "/"), "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return strings.ToUpper(name)
+}
+
+// sortedKeys returns the keys of vars sorted by their variable name.
+func sortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return VariableName(keys[i]) < VariableName(keys[j])
+	})
+	return keys
+}
+
+var dotenvReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+
+// Dotenv writes vars as a dotenv file to w.
+// Values are double quoted.
+func Dotenv(w io.Writer, vars map[string]string) error {
+	for _, k := range sortedKeys(vars) {
+		if _, err := fmt.Fprintf(w, "%s=\"%s\"\n", VariableName(k), dotenvReplacer.Replace(vars[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shell writes vars as shell export statements to w.
+// Values are single quoted, so they are never expanded by the shell.
+func Shell(w io.Writer, vars map[string]string) error {
+	for _, k := range sortedKeys(vars) {
+		value := strings.Replace(vars[k], "'", `'\''`, -1)
+		if _, err := fmt.Fprintf(w, "export %s='%s'\n", VariableName(k), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+var vars = map[string]string{
+	"/premtest/database/url":  "www.google.de",
+	"/premtest/database/user": "Boris",
+	"/premtest/quote":         `it's "quoted" $HOME`,
+}
+
+func (s *FilterSuite) TestNest(t *C) {
+	t.Check(Nest(map[string]string{
+		"/app":    "root",
+		"/app/db": "x",
+	}), DeepEquals, map[string]interface{}{
+		"app": map[string]interface{}{ValueKey: "root", "db": "x"},
+	})
+}
+
+func (s *FilterSuite) TestJSON(t *C) {
+	var buf bytes.Buffer
+	t.Assert(Write(&buf, FormatJSON, vars), IsNil)
+	t.Check(buf.String(), Equals, `{
+  "premtest": {
+    "database": {
+      "url": "www.google.de",
+      "user": "Boris"
+    },
+    "quote": "it's \"quoted\" $HOME"
+  }
+}
+`)
+}
+
+func (s *FilterSuite) TestYAML(t *C) {
+	var buf bytes.Buffer
+	t.Assert(Write(&buf, FormatYAML, vars), IsNil)
+	t.Check(buf.String(), Equals, `premtest:
+  database:
+    url: www.google.de
+    user: Boris
+  quote: it's "quoted" $HOME
+`)
+}
+
+func (s *FilterSuite) TestDotenv(t *C) {
+	var buf bytes.Buffer
+	t.Assert(Write(&buf, FormatDotenv, vars), IsNil)
+	t.Check(buf.String(), Equals, `PREMTEST_DATABASE_URL="www.google.de"
+PREMTEST_DATABASE_USER="Boris"
+PREMTEST_QUOTE="it's \"quoted\" \$HOME"
+`)
+}
+
+func (s *FilterSuite) TestShell(t *C) {
+	var buf bytes.Buffer
+	t.Assert(Write(&buf, FormatShell, vars), IsNil)
+	t.Check(buf.String(), Equals, `export PREMTEST_DATABASE_URL='www.google.de'
+export PREMTEST_DATABASE_USER='Boris'
+export PREMTEST_QUOTE='it'\''s "quoted" $HOME'
+`)
+}
+
+func (s *FilterSuite) TestUnknownFormat(t *C) {
+	var buf bytes.Buffer
+	t.Check(Write(&buf, Format("xml"), vars), Equals, ErrUnknownFormat)
+	t.Check(VariableName("/app/db-1/url"), Equals, "APP_DB_1_URL")
+}
@@ -0,0 +1,118 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package factory
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/HeavyHorst/easykv"
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestNew(t *C) {
+	c, err := New("env://")
+	t.Assert(err, IsNil)
+	t.Check(fmt.Sprintf("%T", c), Equals, "*env.Client")
+
+	c, err = New("file:///tmp/easyKV_filetest.yml")
+	t.Assert(err, IsNil)
+	t.Check(fmt.Sprintf("%T", c), Equals, "*file.Client")
+
+	c, err = New("file+https://example.com/config.yml")
+	t.Assert(err, IsNil)
+	t.Check(fmt.Sprintf("%T", c), Equals, "*file.Client")
+
+	c, err = New("file+sftp://app@bastion/etc/app/config.yml?key=/etc/app/id_ed25519")
+	t.Assert(err, IsNil)
+	t.Check(fmt.Sprintf("%T", c), Equals, "*file.Client")
+}
+
+func (s *FilterSuite) TestUnknownBackend(t *C) {
+	_, err := New("dynamodb://table")
+	t.Check(err, ErrorMatches, `unknown backend: "dynamodb"`)
+	t.Check(errors.Is(err, ErrUnknownBackend), Equals, true)
+
+	_, err = New("redis://127.0.0.1:6379/notanumber")
+	t.Check(err, ErrorMatches, `invalid redis database "notanumber"`)
+	_, err = New("redis://127.0.0.1:6379?scan-count=0")
+	t.Check(err, ErrorMatches, `invalid scan-count "0"`)
+	_, err = New("redis://127.0.0.1:6379?pool-size=-1")
+	t.Check(err, ErrorMatches, `invalid pool-size "-1"`)
+	_, err = New("redis://127.0.0.1:6379?auth=iam")
+	t.Check(err, ErrorMatches, "redis iam auth needs a user")
+	_, err = New("zookeeper://127.0.0.1:2181?acl=world")
+	t.Check(err, ErrorMatches, `invalid zookeeper ACL "world"`)
+	_, err = New("zookeeper://127.0.0.1:2181?principal=app/host@EXAMPLE.COM")
+	t.Check(err, ErrorMatches, "zookeeper kerberos auth needs a keytab")
+	_, err = New("file:///tmp/easyKV_filetest.yml?poll=maybe")
+	t.Check(err, ErrorMatches, `invalid poll "maybe"`)
+	_, err = New("file:///tmp/easyKV_filetest.jsonnet?var=env")
+	t.Check(err, ErrorMatches, `invalid var "env"`)
+	_, err = New("file+sftp://app@bastion/etc/app/config.yml?insecure-ignore-host-key=maybe")
+	t.Check(err, ErrorMatches, `invalid insecure-ignore-host-key "maybe"`)
+	_, err = New("redis://127.0.0.1:6379?read-timeout=1")
+	t.Check(err, ErrorMatches, `invalid read-timeout "1"`)
+
+	_, err = New("vault://127.0.0.1:8200?auth=token&timeout=10")
+	t.Check(err, ErrorMatches, `invalid timeout "10"`)
+
+	_, err = New("vault://127.0.0.1:8200?auth=token&cache-max-ttl=forever")
+	t.Check(err, ErrorMatches, `invalid cache-max-ttl "forever"`)
+
+	_, err = New("etcd://127.0.0.1:2379?dial-timeout=fast")
+	t.Check(err, ErrorMatches, `invalid dial-timeout "fast"`)
+	_, err = New("etcd://127.0.0.1:2379?permit-without-stream=maybe")
+	t.Check(err, ErrorMatches, `invalid permit-without-stream "maybe"`)
+	_, err = New("consul://127.0.0.1:8500?consistency=eventual")
+	t.Check(err, ErrorMatches, `invalid consistency "eventual"`)
+	_, err = New("consul://127.0.0.1:8500?max-wait=long")
+	t.Check(err, ErrorMatches, `invalid max-wait "long"`)
+	_, err = New("etcd://127.0.0.1:2379?gateway=maybe")
+	t.Check(err, ErrorMatches, `invalid gateway "maybe"`)
+	_, err = New("etcd://127.0.0.1:2379?serializable=maybe")
+	t.Check(err, ErrorMatches, `invalid serializable "maybe"`)
+
+	_, err = New("vault://127.0.0.1:8200?auth=token&flavor=consul")
+	t.Check(err, ErrorMatches, `invalid flavor "consul"`)
+}
+
+func (s *FilterSuite) TestParseTLSOptions(t *C) {
+	u, _ := url.Parse("consul://127.0.0.1?ca=ca.pem&server-name=consul.local&insecure-skip-verify=true&tls-min-version=1.2")
+	o, err := parseTLSOptions(u.Query())
+	t.Assert(err, IsNil)
+	t.Check(o, DeepEquals, easykv.TLSOptions{
+		ClientCaKeys:       "ca.pem",
+		ServerName:         "consul.local",
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+	})
+
+	o, err = parseTLSOptions(url.Values{"tls-min-version": {"1.3"}})
+	t.Assert(err, IsNil)
+	t.Check(o.MinVersion, Equals, uint16(tls.VersionTLS13))
+
+	_, err = New("consul://127.0.0.1?tls-min-version=0.9")
+	t.Check(err, ErrorMatches, `invalid tls-min-version "0.9"`)
+
+	u, _ = url.Parse("zookeeper://127.0.0.1:2281?ca=ca.pem&skip-hostname-verification=true")
+	o, err = parseTLSOptions(u.Query())
+	t.Assert(err, IsNil)
+	t.Check(o, DeepEquals, easykv.TLSOptions{ClientCaKeys: "ca.pem", SkipHostnameVerification: true})
+}
@@ -9,16 +9,14 @@
 package file
 
 import (
-	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
-
+	"sync"
 	"time"
 
 	"github.com/HeavyHorst/easykv"
-	"github.com/fsnotify/fsnotify"
-	"gopkg.in/yaml.v2"
 )
 
 // Client is a wrapper around the file client
@@ -26,54 +24,72 @@ type Client struct {
 	filepath   string
 	isURL      bool
 	httpClient http.Client
+	logger     easykv.Logger
+	// format is the format of all files, they're parsed by their extension if it's empty.
+	format      string
+	poll        bool
+	includes    []string
+	importPaths []string
+	vars        map[string]string
+	ssh         SSHOptions
+
+	sftpMu    sync.Mutex
+	sftpConns map[string]*sftpConn
 }
 
 // New returns a new FileClient
-// The filepath can be a local path to a file or a remote http/https location.
-func New(filepath string) (*Client, error) {
-	c := &Client{filepath: filepath}
-	if strings.HasPrefix(filepath, "http://") || strings.HasPrefix(filepath, "https://") {
-		c.isURL = true
-		c.httpClient = http.Client{
-			Timeout: 5 * time.Second,
+// The filepath can be a local path to a file or a remote http/https or sftp location,
+// sftp://user@host/path is read with the ssh keys of WithSSH and polled by WatchPrefix.
+// A local directory or a glob pattern like conf.d/*.yaml selects several files, they're
+// merged in lexical order and the values of later files override the earlier ones.
+// The files of a directory include the files in its subdirectories.
+// The files are parsed as yaml, json, toml, hcl, ini, dotenv, java properties or xml,
+// or evaluated as jsonnet or cue, see WithFormat.
+// Files can include other files, see WithIncludes.
+func New(filepath string, opts ...Option) (*Client, error) {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Format != "" {
+		if _, err := decoder(options.Format); err != nil {
+			return nil, err
 		}
 	}
+
+	proxyFunc, err := easykv.ProxyFunc(options.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		filepath:    filepath,
+		isURL:       isRemote(filepath),
+		logger:      easykv.LoggerOrDiscard(options.Logger),
+		format:      options.Format,
+		poll:        options.Poll,
+		includes:    options.Includes,
+		importPaths: options.ImportPaths,
+		vars:        options.Vars,
+		ssh:         options.SSH,
+		// remote files can be included by local ones
+		httpClient: http.Client{
+			Transport: &http.Transport{Proxy: proxyFunc},
+			Timeout:   5 * time.Second,
+		},
+	}
 	return c, nil
 }
 
-// GetValues returns all key-value pairs from the yaml or json file where the
+// GetValues returns all key-value pairs from the files where the
 // keys begins with one of the prefixes specified in the keys array.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
-	yamlMap := make(map[interface{}]interface{})
 	vars := make(map[string]string)
 	kvs := make(map[string]string)
 
-	var data []byte
-	var err error
-	if c.isURL {
-		resp, err := c.httpClient.Get(c.filepath)
-		if err != nil {
-			return vars, err
-		}
-		defer resp.Body.Close()
-		data, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return vars, err
-		}
-	} else {
-		data, err = ioutil.ReadFile(c.filepath)
-		if err != nil {
-			return vars, err
-		}
-	}
-
-	err = yaml.Unmarshal(data, &yamlMap)
-	if err != nil {
+	if _, err := c.load(vars); err != nil {
 		return vars, err
 	}
 
-	nodeWalk(yamlMap, "", vars)
-
 	for _, k := range keys {
 		for key, val := range vars {
 			if strings.HasPrefix(key, k) {
@@ -85,65 +101,78 @@ func (c *Client) GetValues(keys []string) (map[string]string, error) {
 	return kvs, nil
 }
 
-// Close is only meant to fulfill the easykv.ReadWatcher interface.
-// Does nothing.
-func (c *Client) Close() {}
+// isRemote reports whether path is an http/https or sftp location.
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") || isSFTP(path)
+}
+
+// decodeFile reads and parses the file.
+func (c *Client) decodeFile(file string) (map[interface{}]interface{}, error) {
+	format := strings.ToLower(c.format)
+	if format == "" {
+		format = formatOf(file)
+	}
+	if _, ok := evalCommands[format]; ok {
+		return c.evaluate(format, file)
+	}
+	decode, err := decoder(format)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.read(file)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// read returns the contents of the local or remote file.
+func (c *Client) read(file string) ([]byte, error) {
+	if isSFTP(file) {
+		return c.readSFTP(file)
+	}
+	if !isRemote(file) {
+		return ioutil.ReadFile(file)
+	}
+	resp, err := c.httpClient.Get(file)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Close closes the connections to sftp servers.
+func (c *Client) Close() {
+	c.closeSFTP()
+}
 
 // nodeWalk recursively descends nodes, updating vars.
-func nodeWalk(node map[interface{}]interface{}, key string, vars map[string]string) error {
+// Values of unsupported types are skipped and reported to logger.
+func nodeWalk(node map[interface{}]interface{}, key string, vars map[string]string, logger easykv.Logger) error {
 	for k, v := range node {
 		key := key + "/" + k.(string)
 
 		switch v := v.(type) {
 		case map[interface{}]interface{}:
-			nodeWalk(v, key, vars)
+			nodeWalk(v, key, vars, logger)
 		case []interface{}:
 			for _, j := range v {
 				switch j := j.(type) {
 				case map[interface{}]interface{}:
-					nodeWalk(j, key, vars)
+					nodeWalk(j, key, vars, logger)
 				case string:
 					vars[key+"/"+j] = ""
+				default:
+					logger.Debug("skipping list item of unsupported type", "key", key, "type", fmt.Sprintf("%T", j))
 				}
 			}
 		case string:
 			vars[key] = v
+		default:
+			logger.Debug("skipping value of unsupported type", "key", key, "type", fmt.Sprintf("%T", v))
 		}
 	}
 	return nil
 }
-
-// WatchPrefix watches the file for changes with fsnotify.
-// Prefix, keys and waitIndex are only here to implement the StoreClient interface.
-// WatchPrefix is only supported for local files. Remote files over http/https arent supported.
-// Remote filesystems like nfs are also not supported.
-func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
-	if c.isURL {
-		// watch is not supported for urls
-		return 0, easykv.ErrWatchNotSupported
-	}
-
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return 0, err
-	}
-	defer watcher.Close()
-
-	err = watcher.Add(c.filepath)
-	if err != nil {
-		return 0, err
-	}
-
-	for {
-		select {
-		case event := <-watcher.Events:
-			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Remove == fsnotify.Remove {
-				return 1, nil
-			}
-		case err := <-watcher.Errors:
-			return 0, err
-		case <-ctx.Done():
-			return 0, easykv.ErrWatchCanceled
-		}
-	}
-}
@@ -9,14 +9,26 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/HeavyHorst/easykv"
 	"github.com/HeavyHorst/easykv/testutils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	. "gopkg.in/check.v1"
 )
@@ -62,6 +74,41 @@ const testfileJSON string = `
 }
 `
 
+const filepathTOML string = "/tmp/easyKV_filetest.toml"
+const testfileTOML string = `
+[remtest.database]
+hosts = [{ "192.168.0.1" = "test1", "192.168.0.2" = "test2" }]
+
+[premtest.database]
+url = "www.google.de"
+user = "Boris"
+`
+
+const filepathHCL string = "/tmp/easyKV_filetest.hcl"
+const testfileHCL string = `
+remtest database hosts {
+  "192.168.0.1" = "test1"
+  "192.168.0.2" = "test2"
+}
+
+premtest database {
+  url  = "www.google.de"
+  user = "Boris"
+}
+`
+
+const filepathINI string = "/tmp/easyKV_filetest.ini"
+const testfileINI string = `
+[remtest/database/hosts]
+192.168.0.1 = test1
+192.168.0.2 = test2
+
+; comment
+[premtest/database]
+url = www.google.de
+user = Boris
+`
+
 func testGetVal(file, data string, t *C) {
 	// write testfile
 	err := ioutil.WriteFile(file, []byte(data), 0666)
@@ -85,6 +132,504 @@ func (s *FilterSuite) TestGetValuesJSON(t *C) {
 	testGetVal(filepathJSON, testfileJSON, t)
 }
 
+func (s *FilterSuite) TestGetValuesTOML(t *C) {
+	testGetVal(filepathTOML, testfileTOML, t)
+}
+
+func (s *FilterSuite) TestGetValuesHCL(t *C) {
+	testGetVal(filepathHCL, testfileHCL, t)
+}
+
+func (s *FilterSuite) TestScalars(t *C) {
+	dir := t.MkDir()
+	expected := map[string]string{
+		"/app/port":  "8080",
+		"/app/ratio": "0.5",
+		"/app/debug": "true",
+	}
+	files := map[string]string{
+		"config.toml": "[app]\nport = 8080\nratio = 0.5\ndebug = true\n",
+		"config.hcl":  "app {\n  port  = 8080\n  ratio = 0.5\n  debug = true\n}\n",
+	}
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		t.Assert(ioutil.WriteFile(file, []byte(data), 0666), IsNil)
+		c, err := New(file)
+		t.Assert(err, IsNil)
+		vars, err := c.GetValues([]string{"/app"})
+		t.Assert(err, IsNil)
+		t.Check(vars, DeepEquals, expected, Commentf(name))
+	}
+}
+
+func (s *FilterSuite) TestGetValuesINI(t *C) {
+	testGetVal(filepathINI, testfileINI, t)
+}
+
+func (s *FilterSuite) TestGetValuesDotenv(t *C) {
+	file := filepath.Join(t.MkDir(), ".env")
+	data := `# database
+export DB_URL=postgres://db:5432/app # inline comment
+DB_USER='Boris # not a comment'
+DB_PASSWORD="se\"cret\n"
+CERT="-----BEGIN-----
+abc
+-----END-----"
+
+EMPTY=
+`
+	t.Assert(ioutil.WriteFile(file, []byte(data), 0666), IsNil)
+	c, err := New(file)
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/DB_URL":      "postgres://db:5432/app",
+		"/DB_USER":     "Boris # not a comment",
+		"/DB_PASSWORD": "se\"cret\n",
+		"/CERT":        "-----BEGIN-----\nabc\n-----END-----",
+		"/EMPTY":       "",
+	})
+
+	t.Assert(ioutil.WriteFile(file, []byte("A=1\nB=\"open\n"), 0666), IsNil)
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, "line 2: unterminated quoted value")
+	t.Assert(ioutil.WriteFile(file, []byte("A=1\nB\n"), 0666), IsNil)
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, "line 2: expected KEY=value")
+}
+
+func (s *FilterSuite) TestGetValuesProperties(t *C) {
+	file := filepath.Join(t.MkDir(), "app.properties")
+	data := `# comment
+! another comment
+database.url = jdbc:postgresql://db/app
+database.user: Boris
+database.password   se\=cret
+greeting = Gr\u00fc\u00dfe \
+           aus Berlin
+path\ with\ spaces=C:\\app
+empty
+`
+	t.Assert(ioutil.WriteFile(file, []byte(data), 0666), IsNil)
+	c, err := New(file)
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/database/url":      "jdbc:postgresql://db/app",
+		"/database/user":     "Boris",
+		"/database/password": "se=cret",
+		"/greeting":          "Grüße aus Berlin",
+		"/path with spaces":  `C:\app`,
+		"/empty":             "",
+	})
+
+	t.Assert(ioutil.WriteFile(file, []byte("a=1\nb=\\u12\n"), 0666), IsNil)
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, `line 2: invalid unicode escape "\\\\u12"`)
+}
+
+func (s *FilterSuite) TestGetValuesXML(t *C) {
+	file := filepath.Join(t.MkDir(), "app.xml")
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<config xmlns="urn:example">
+  <!-- comment -->
+  <database url="www.google.de">
+    <user>Boris</user>
+    <password><![CDATA[s<cret]]></password>
+  </database>
+  <hosts>
+    <host>192.168.0.1</host>
+    <host>192.168.0.2</host>
+  </hosts>
+</config>
+`
+	t.Assert(ioutil.WriteFile(file, []byte(data), 0666), IsNil)
+	c, err := New(file)
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/config"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/config/database/url":           "www.google.de",
+		"/config/database/user":          "Boris",
+		"/config/database/password":      "s<cret",
+		"/config/hosts/host/192.168.0.1": "",
+		"/config/hosts/host/192.168.0.2": "",
+	})
+
+	t.Assert(ioutil.WriteFile(file, []byte("<config><open></config>"), 0666), IsNil)
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, NotNil)
+}
+
+func (s *FilterSuite) TestEvaluate(t *C) {
+	dir := t.MkDir()
+	// the fake commands record their arguments and print the evaluated json, or the error to stderr
+	fake := func(name, output string, code int) string {
+		redirect := ""
+		if code != 0 {
+			redirect = ">&2"
+		}
+		script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s.args\nprintf '%%s' '%s' %s\nexit %d\n", filepath.Join(dir, name), output, redirect, code)
+		path := filepath.Join(dir, name)
+		t.Assert(ioutil.WriteFile(path, []byte(script), 0755), IsNil)
+		return path
+	}
+	defer func(commands map[string]string) { evalCommands = commands }(evalCommands)
+	evalCommands = map[string]string{
+		"jsonnet": fake("jsonnet", `{"app": {"name": "easykv", "env": "prod", "port": 8080, "ratio": 0.5, "debug": true}}`, 0),
+		"cue":     fake("cue", "tag env is not declared", 1),
+	}
+
+	file := filepath.Join(dir, "config.jsonnet")
+	t.Assert(ioutil.WriteFile(file, []byte("{}"), 0666), IsNil)
+	c, err := New(file, WithImportPaths("vendor"), WithVars(map[string]string{"region": "eu", "env": "prod"}))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv", "/app/env": "prod",
+		"/app/port": "8080", "/app/ratio": "0.5", "/app/debug": "true"})
+	args, err := ioutil.ReadFile(filepath.Join(dir, "jsonnet.args"))
+	t.Assert(err, IsNil)
+	vendor, err := filepath.Abs("vendor")
+	t.Assert(err, IsNil)
+	t.Check(string(args), Equals, "-J "+vendor+" --ext-str env=prod --ext-str region=eu "+file+"\n")
+
+	file = filepath.Join(dir, "config.cue")
+	t.Assert(ioutil.WriteFile(file, []byte("app: name: \"easykv\""), 0666), IsNil)
+	c, err = New(file, WithVars(map[string]string{"env": "prod"}))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/app"})
+	t.Check(err, ErrorMatches, "cue: tag env is not declared")
+	args, err = ioutil.ReadFile(filepath.Join(dir, "cue.args"))
+	t.Assert(err, IsNil)
+	t.Check(string(args), Equals, "export --out json -t env=prod "+file+"\n")
+
+	c, err = New(file, WithImportPaths("vendor"))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/app"})
+	t.Check(err, ErrorMatches, "cue imports are resolved by the cue module of .*, import paths are only used by jsonnet")
+
+	// hanging commands are killed
+	defer func(timeout time.Duration) { evalTimeout = timeout }(evalTimeout)
+	evalTimeout = 100 * time.Millisecond
+	hang := filepath.Join(dir, "hang")
+	t.Assert(ioutil.WriteFile(hang, []byte("#!/bin/sh\nexec sleep 10\n"), 0755), IsNil)
+	evalCommands["cue"] = hang
+	c, err = New(file)
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/app"})
+	t.Check(err, ErrorMatches, "cue evaluation of .* timed out after 100ms")
+
+	c, _ = New("https://example.com/config.jsonnet")
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, "jsonnet files can only be evaluated locally")
+}
+
+func (s *FilterSuite) TestFormat(t *C) {
+	file := filepath.Join(t.MkDir(), "config")
+	t.Assert(ioutil.WriteFile(file, []byte(testfileTOML), 0666), IsNil)
+
+	c, err := New(file, WithFormat("toml"))
+	t.Assert(err, IsNil)
+	t.Check(testutils.GetValues(t, c), IsNil)
+
+	_, err = New(file, WithFormat("xls"))
+	t.Check(err, ErrorMatches, `unknown file format "xls"`)
+
+	t.Check(formatOf("https://example.com/config.hcl?token=x"), Equals, "hcl")
+	t.Check(formatOf("/etc/app/config"), Equals, "yaml")
+	t.Check(formatOf("/srv/app/.env.local"), Equals, "dotenv")
+}
+
+func (s *FilterSuite) TestDirectory(t *C) {
+	dir := t.MkDir()
+	files := map[string]string{
+		"10-base.yaml":    "app:\n  name: easykv\n  env: dev\n",
+		"20-prod.toml":    "[app]\nenv = \"prod\"\n",
+		".20-backup.yaml": "app:\n  env: backup\n",
+		"README.md":       "# not a config file",
+	}
+	for name, data := range files {
+		t.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666), IsNil)
+	}
+	expected := map[string]string{"/app/name": "easykv", "/app/env": "prod"}
+
+	c, err := New(dir)
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, expected)
+
+	c, err = New(filepath.Join(dir, "*-*"))
+	t.Assert(err, IsNil)
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, expected)
+
+	c, err = New(filepath.Join(dir, "*.yaml"))
+	t.Assert(err, IsNil)
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv", "/app/env": "dev"})
+}
+
+func (s *FilterSuite) TestDirectoryTree(t *C) {
+	dir := t.MkDir()
+	for _, d := range []string{"services/db", ".git"} {
+		t.Assert(os.MkdirAll(filepath.Join(dir, d), 0755), IsNil)
+	}
+	files := map[string]string{
+		"app.yaml":               "app:\n  name: easykv\n  env: dev\n",
+		"services/db/prod.yaml":  "app:\n  env: prod\ndb:\n  url: postgres\n",
+		"services/cache.yaml":    "cache:\n  url: redis\n",
+		".git/config.yaml":       "app:\n  env: git\n",
+		"services/db/.swap.yaml": "db:\n  url: swap\n",
+	}
+	for name, data := range files {
+		t.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666), IsNil)
+	}
+
+	c, err := New(dir)
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/app/name":  "easykv",
+		"/app/env":   "prod",
+		"/db/url":    "postgres",
+		"/cache/url": "redis",
+	})
+}
+
+func (s *FilterSuite) TestWatchDirectoryTree(t *C) {
+	dir := t.MkDir()
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "app.yaml"), []byte("app:\n  name: easykv\n"), 0666), IsNil)
+	c, err := New(dir)
+	t.Assert(err, IsNil)
+
+	watch := func() chan error {
+		errc := make(chan error, 1)
+		go func() {
+			_, err := c.WatchPrefix(context.Background(), "/")
+			errc <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+		return errc
+	}
+	wait := func(errc chan error) {
+		select {
+		case err := <-errc:
+			t.Check(err, IsNil)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change")
+		}
+	}
+
+	// a new empty directory is watched, the files created in it are changes
+	errc := watch()
+	t.Assert(os.Mkdir(filepath.Join(dir, "services"), 0755), IsNil)
+	time.Sleep(100 * time.Millisecond)
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "services", "db.yaml"), []byte("db:\n  url: postgres\n"), 0666), IsNil)
+	wait(errc)
+
+	// existing subdirectories are watched
+	errc = watch()
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "services", "db.yaml"), []byte("db:\n  url: mysql\n"), 0666), IsNil)
+	wait(errc)
+
+	// a directory that is moved into the tree with its files
+	other := t.MkDir()
+	t.Assert(ioutil.WriteFile(filepath.Join(other, "cache.yaml"), []byte("cache:\n  url: redis\n"), 0666), IsNil)
+	errc = watch()
+	t.Assert(os.Rename(other, filepath.Join(dir, "cache")), IsNil)
+	wait(errc)
+
+	vars, err := c.GetValues([]string{"/"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv", "/db/url": "mysql", "/cache/url": "redis"})
+}
+
+func (s *FilterSuite) TestWatchDirectory(t *C) {
+	dir := t.MkDir()
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "10-base.yaml"), []byte("app:\n  name: easykv\n"), 0666), IsNil)
+	c, err := New(filepath.Join(dir, "*.yaml"))
+	t.Assert(err, IsNil)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.WatchPrefix(context.Background(), "/")
+		errc <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// files that don't match are ignored
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0666), IsNil)
+	select {
+	case err := <-errc:
+		t.Fatalf("unexpected change: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "20-prod.yaml"), []byte("app:\n  env: prod\n"), 0666), IsNil)
+	select {
+	case err := <-errc:
+		t.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change")
+	}
+}
+
+func (s *FilterSuite) TestWatchPolling(t *C) {
+	file := filepath.Join(t.MkDir(), "config.yaml")
+	t.Assert(ioutil.WriteFile(file, []byte("app:\n  name: easykv\n"), 0666), IsNil)
+	c, err := New(file, WithPolling())
+	t.Assert(err, IsNil)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.WatchPrefix(context.Background(), "/", easykv.WithInterval(10*time.Millisecond))
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// the same contents aren't a change
+	t.Assert(ioutil.WriteFile(file, []byte("app:\n  name: easykv\n"), 0666), IsNil)
+	select {
+	case err := <-errc:
+		t.Fatalf("unexpected change: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	t.Assert(ioutil.WriteFile(file, []byte("app:\n  name: changed\n"), 0666), IsNil)
+	select {
+	case err := <-errc:
+		t.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.WatchPrefix(ctx, "/")
+	t.Check(err, Equals, easykv.ErrWatchCanceled)
+}
+
+// k8sUpdate writes a new version of a kubernetes volume and swaps its data link like the kubelet.
+func k8sUpdate(t *C, volume, version, data string) {
+	t.Assert(os.Mkdir(filepath.Join(volume, version), 0755), IsNil)
+	t.Assert(ioutil.WriteFile(filepath.Join(volume, version, "config.yaml"), []byte(data), 0644), IsNil)
+	old, _ := os.Readlink(filepath.Join(volume, "..data"))
+	t.Assert(os.Symlink(version, filepath.Join(volume, "..data_tmp")), IsNil)
+	t.Assert(os.Rename(filepath.Join(volume, "..data_tmp"), filepath.Join(volume, "..data")), IsNil)
+	if old != "" {
+		t.Assert(os.RemoveAll(filepath.Join(volume, old)), IsNil)
+	}
+}
+
+func (s *FilterSuite) TestWatchKubernetesVolume(t *C) {
+	volume := t.MkDir()
+	k8sUpdate(t, volume, "..v1", "app:\n  name: easykv\n")
+	t.Assert(os.Symlink("..data/config.yaml", filepath.Join(volume, "config.yaml")), IsNil)
+
+	c, err := New(filepath.Join(volume, "config.yaml"))
+	t.Assert(err, IsNil)
+	errc := make(chan error, 2)
+	go func() {
+		_, err := c.WatchPrefix(context.Background(), "/")
+		errc <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	k8sUpdate(t, volume, "..v2", "app:\n  name: changed\n")
+
+	select {
+	case err := <-errc:
+		t.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change")
+	}
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "changed"})
+
+	// the removal of the old version isn't another change
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_, err := c.WatchPrefix(ctx, "/")
+		errc <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	t.Assert(os.Mkdir(filepath.Join(volume, "..v3"), 0755), IsNil)
+	t.Assert(os.RemoveAll(filepath.Join(volume, "..v3")), IsNil)
+	select {
+	case err := <-errc:
+		t.Fatalf("unexpected change: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *FilterSuite) TestIncludes(t *C) {
+	dir := t.MkDir()
+	files := map[string]string{
+		"defaults.yaml":    "app:\n  name: default\n  env: dev\n  region: eu\n",
+		"base.toml":        "[app]\nname = \"base\"\nlog = \"info\"\n",
+		"extra/db.yaml":    "$include: ../base.toml\ndb:\n  url: postgres\n",
+		"config.yaml":      "include:\n  - base.toml\n  - extra/*.yaml\napp:\n  env: prod\n",
+		"cycle-a.yaml":     "include: cycle-b.yaml\n",
+		"cycle-b.yaml":     "include: cycle-a.yaml\n",
+		"invalid.yaml":     "include:\n  path: base.toml\n",
+		"missing-inc.yaml": "include: missing.yaml\n",
+	}
+	t.Assert(os.Mkdir(filepath.Join(dir, "extra"), 0755), IsNil)
+	for name, data := range files {
+		t.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666), IsNil)
+	}
+
+	c, err := New(filepath.Join(dir, "config.yaml"), WithIncludes(filepath.Join(dir, "defaults.yaml")))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/app/name":   "base",
+		"/app/env":    "prod",
+		"/app/region": "eu",
+		"/app/log":    "info",
+		"/db/url":     "postgres",
+	})
+
+	c, _ = New(filepath.Join(dir, "cycle-a.yaml"))
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, "include cycle: .*cycle-a.yaml -> .*cycle-b.yaml -> .*cycle-a.yaml")
+	c, _ = New(filepath.Join(dir, "invalid.yaml"))
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, "include must be a path or a list of paths")
+	c, _ = New(filepath.Join(dir, "missing-inc.yaml"))
+	_, err = c.GetValues([]string{"/"})
+	t.Check(err, ErrorMatches, ".*missing.yaml: .* no such file or directory")
+}
+
+func (s *FilterSuite) TestWatchIncludes(t *C) {
+	dir := t.MkDir()
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "base.yaml"), []byte("app:\n  name: easykv\n"), 0666), IsNil)
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte("include: base.yaml\n"), 0666), IsNil)
+	c, err := New(filepath.Join(dir, "config.yaml"))
+	t.Assert(err, IsNil)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.WatchPrefix(context.Background(), "/")
+		errc <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	t.Assert(ioutil.WriteFile(filepath.Join(dir, "base.yaml"), []byte("app:\n  name: changed\n"), 0666), IsNil)
+	select {
+	case err := <-errc:
+		t.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change")
+	}
+}
+
 func (s *FilterSuite) TestWatchPrefix(t *C) {
 	err := ioutil.WriteFile(filepathYML, []byte(testfileYML), 0666)
 	if err != nil {
@@ -127,3 +672,137 @@ func (s *FilterSuite) TestWatchPrefixCancel(t *C) {
 	cancel()
 	wg.Wait()
 }
+
+// testLogger records all debug messages.
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.messages = append(l.messages, strings.TrimSpace(fmt.Sprintln(append([]interface{}{msg}, args...)...)))
+}
+func (l *testLogger) Info(msg string, args ...interface{})  {}
+func (l *testLogger) Warn(msg string, args ...interface{})  {}
+func (l *testLogger) Error(msg string, args ...interface{}) {}
+
+func (s *FilterSuite) TestLogger(t *C) {
+	err := ioutil.WriteFile(filepathYML, []byte("app:\n  name: easykv\n  port: 8080\n"), 0666)
+	t.Assert(err, IsNil)
+	defer os.Remove(filepathYML)
+
+	l := &testLogger{}
+	c, _ := New(filepathYML, WithLogger(l))
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+	t.Check(l.messages, DeepEquals, []string{"skipping value of unsupported type key /app/port type int"})
+}
+
+// sftpServer serves the local filesystem over sftp to the client key.
+// It returns the address, the client key file and a known_hosts file.
+func sftpServer(t *C) (string, string, string) {
+	dir := t.MkDir()
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	t.Assert(err, IsNil)
+	clientPub, clientKey, _ := ed25519.GenerateKey(rand.Reader)
+	sshPub, err := ssh.NewPublicKey(clientPub)
+	t.Assert(err, IsNil)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	t.Assert(err, IsNil)
+	keyFile := filepath.Join(dir, "id_ed25519")
+	t.Assert(ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600), IsNil)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "app" && bytes.Equal(key.Marshal(), sshPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Assert(err, IsNil)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					ch, reqs, err := nc.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						for req := range reqs {
+							req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+						}
+					}()
+					server, err := sftp.NewServer(ch)
+					if err != nil {
+						return
+					}
+					go func() {
+						server.Serve()
+						ch.Close()
+					}()
+				}
+			}()
+		}
+	}()
+
+	addr := l.Addr().String()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostSigner.PublicKey())
+	t.Assert(ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600), IsNil)
+	return addr, keyFile, knownHosts
+}
+
+func (s *FilterSuite) TestSFTP(t *C) {
+	addr, keyFile, knownHosts := sftpServer(t)
+	file := filepath.Join(t.MkDir(), "config.yaml")
+	t.Assert(ioutil.WriteFile(file, []byte("app:\n  name: easykv\n"), 0666), IsNil)
+
+	c, err := New("sftp://app@"+addr+file, WithSSH(SSHOptions{KeyFile: keyFile, KnownHostsFile: knownHosts}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.WatchPrefix(context.Background(), "/", easykv.WithInterval(10*time.Millisecond))
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	t.Assert(ioutil.WriteFile(file, []byte("app:\n  name: changed\n"), 0666), IsNil)
+	select {
+	case err := <-errc:
+		t.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change")
+	}
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "changed"})
+
+	c, _ = New("sftp://nobody@"+addr+file, WithSSH(SSHOptions{KeyFile: keyFile, KnownHostsFile: knownHosts}))
+	_, err = c.GetValues([]string{"/app"})
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrAuthentication)
+
+	empty := filepath.Join(t.MkDir(), "known_hosts")
+	t.Assert(ioutil.WriteFile(empty, nil, 0600), IsNil)
+	c, _ = New("sftp://app@"+addr+file, WithSSH(SSHOptions{KeyFile: keyFile, KnownHostsFile: empty}))
+	_, err = c.GetValues([]string{"/app"})
+	t.Check(err, ErrorMatches, ".*knownhosts: key is unknown")
+}
@@ -0,0 +1,103 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// decodeDotenv parses the KEY=value lines of a .env file, KEY becomes /KEY.
+// Single quoted values are taken literally, double quoted values support the escapes
+// \n, \r, \t, \" and \\ and can span lines. Export prefixes and comments are ignored.
+func decodeDotenv(data []byte) (map[interface{}]interface{}, error) {
+	m := make(map[interface{}]interface{})
+	rest := strings.Replace(string(data), "\r\n", "\n", -1)
+	for n := 1; rest != ""; n++ {
+		var line string
+		line, rest = cutLine(rest)
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimSpace(line[len("export"):])
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", n)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			var lines int
+			var err error
+			value, rest, lines, err = unquoteDouble(value[1:], rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			n += lines
+		default:
+			if c := strings.Index(value, " #"); c >= 0 {
+				value = strings.TrimSpace(value[:c])
+			}
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// unquoteDouble returns the double quoted value that starts with v and continues
+// on the following lines in rest if necessary, and the lines that were consumed.
+func unquoteDouble(v, rest string) (string, string, int, error) {
+	var b strings.Builder
+	for lines := 0; ; lines++ {
+		for i := 0; i < len(v); i++ {
+			switch c := v[i]; {
+			case c == '"':
+				return b.String(), rest, lines, nil
+			case c == '\\' && i+1 < len(v):
+				i++
+				switch v[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(v[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		if rest == "" {
+			return "", "", lines, errors.New("unterminated quoted value")
+		}
+		b.WriteByte('\n')
+		v, rest = cutLine(rest)
+	}
+}
+
+// cutLine returns the first line of s and the lines after it.
+func cutLine(s string) (string, string) {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
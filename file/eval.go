@@ -0,0 +1,94 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// evalCommands are the commands that evaluate jsonnet and cue files to json.
+// They're optional, the formats fail if the command is not installed.
+var evalCommands = map[string]string{
+	"jsonnet": "jsonnet",
+	"cue":     "cue",
+}
+
+// evalTimeout limits the evaluation of a file.
+var evalTimeout = 30 * time.Second
+
+// evaluate evaluates the local jsonnet or cue file and parses the resulting json.
+// Jsonnet libraries are searched in the import paths, the vars are the external variables of
+// jsonnet and the tags of cue. Cue imports are resolved by the module of the file, import paths
+// are rejected. The commands run in the directory of the file and are killed after evalTimeout.
+func (c *Client) evaluate(format, file string) (map[interface{}]interface{}, error) {
+	if isRemote(file) {
+		return nil, fmt.Errorf("%s files can only be evaluated locally", format)
+	}
+	if format == "cue" && len(c.importPaths) > 0 {
+		return nil, fmt.Errorf("cue imports are resolved by the cue module of %s, import paths are only used by jsonnet", file)
+	}
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(c.vars))
+	for k := range c.vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var args []string
+	switch format {
+	case "jsonnet":
+		for _, p := range c.importPaths {
+			// relative to the working directory, not to the directory of the file
+			p, err := filepath.Abs(p)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-J", p)
+		}
+		for _, k := range names {
+			args = append(args, "--ext-str", k+"="+c.vars[k])
+		}
+	case "cue":
+		args = []string{"export", "--out", "json"}
+		for _, k := range names {
+			args = append(args, "-t", k+"="+c.vars[k])
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), evalTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, evalCommands[format], append(args, file)...)
+	cmd.Dir = filepath.Dir(file)
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s evaluation of %s timed out after %v", format, file, evalTimeout)
+		}
+		if e, ok := err.(*exec.ExitError); ok && len(e.Stderr) > 0 {
+			return nil, fmt.Errorf("%s: %s", format, strings.TrimSpace(string(e.Stderr)))
+		}
+		return nil, fmt.Errorf("can't evaluate %s: %v", format, err)
+	}
+	m, err := decodeYAML(out)
+	if err != nil {
+		return nil, err
+	}
+	// unlike in yaml files, numbers and booleans are kept as strings
+	return normalize(m).(map[interface{}]interface{}), nil
+}
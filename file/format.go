@@ -0,0 +1,156 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// decoders parse the supported formats into the nested maps that are flattened by nodeWalk.
+var decoders = map[string]func([]byte) (map[interface{}]interface{}, error){
+	"yaml":       decodeYAML,
+	"json":       decodeYAML,
+	"toml":       decodeTOML,
+	"hcl":        decodeHCL,
+	"ini":        decodeINI,
+	"dotenv":     decodeDotenv,
+	"properties": decodeProperties,
+	"xml":        decodeXML,
+}
+
+// extensions maps the file extensions to their formats.
+var extensions = map[string]string{
+	".yml":        "yaml",
+	".yaml":       "yaml",
+	".json":       "json",
+	".toml":       "toml",
+	".hcl":        "hcl",
+	".ini":        "ini",
+	".env":        "dotenv",
+	".jsonnet":    "jsonnet",
+	".cue":        "cue",
+	".properties": "properties",
+	".xml":        "xml",
+}
+
+// formatOf returns the format of the file or URL path by its extension.
+// Files with an unknown extension are read as yaml, which includes json.
+func formatOf(path string) string {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		path = u.Path
+	}
+	if f, ok := knownFormat(path); ok {
+		return f
+	}
+	return "yaml"
+}
+
+// knownFormat returns the format of the file name. .env.local and the like are dotenv files.
+func knownFormat(path string) (string, bool) {
+	if base := filepath.Base(path); base == ".env" || strings.HasPrefix(base, ".env.") {
+		return "dotenv", true
+	}
+	f, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	return f, ok
+}
+
+// decoder returns the decoder of format. The evaluated formats have none, see evaluate.
+func decoder(format string) (func([]byte) (map[interface{}]interface{}, error), error) {
+	d, ok := decoders[strings.ToLower(format)]
+	if _, eval := evalCommands[strings.ToLower(format)]; !ok && !eval {
+		return nil, fmt.Errorf("unknown file format %q", format)
+	}
+	return d, nil
+}
+
+func decodeYAML(data []byte) (map[interface{}]interface{}, error) {
+	m := make(map[interface{}]interface{})
+	err := yaml.Unmarshal(data, &m)
+	return m, err
+}
+
+func decodeTOML(data []byte) (map[interface{}]interface{}, error) {
+	var m map[string]interface{}
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return normalize(m).(map[interface{}]interface{}), nil
+}
+
+// decodeHCL decodes HCL, blocks are lists of maps like the lists of maps of yaml.
+func decodeHCL(data []byte) (map[interface{}]interface{}, error) {
+	var m map[string]interface{}
+	if err := hcl.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return normalize(m).(map[interface{}]interface{}), nil
+}
+
+// decodeINI maps the keys of a section to /section/key, the keys before the first section to /key.
+func decodeINI(data []byte) (map[interface{}]interface{}, error) {
+	f, err := ini.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[interface{}]interface{})
+	for _, s := range f.Sections() {
+		node := m
+		if s.Name() != ini.DefaultSection {
+			node = make(map[interface{}]interface{})
+			m[s.Name()] = node
+		}
+		for _, k := range s.Keys() {
+			node[k.Name()] = k.Value()
+		}
+	}
+	return m, nil
+}
+
+// normalize converts the maps and lists of the decoders to the types of the yaml decoder,
+// numbers and booleans are converted to strings.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalize(e)
+		}
+		return l
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalize(e)
+		}
+		return l
+	case bool, int, int64, float64:
+		return fmt.Sprint(v)
+	default:
+		return v
+	}
+}
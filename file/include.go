@@ -0,0 +1,160 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// includeKeys are the top-level keys that include other files.
+var includeKeys = []string{"include", "$include"}
+
+// loader reads files and the files they include.
+type loader struct {
+	c    *Client
+	vars map[string]string
+	// stack are the files that are being read, an include of one of them is a cycle.
+	stack []string
+	files []string
+	seen  map[string]bool
+}
+
+// load reads the includes and the files of the source into vars.
+// It returns all files that were read, also if it fails.
+func (c *Client) load(vars map[string]string) ([]string, error) {
+	l := &loader{c: c, vars: vars, seen: make(map[string]bool)}
+	files, err := c.files()
+	if err != nil {
+		return l.files, err
+	}
+	for _, f := range append(append([]string{}, c.includes...), files...) {
+		if err := l.read(f); err != nil {
+			return l.files, err
+		}
+	}
+	return l.files, nil
+}
+
+// read reads the includes of file and then file into l.vars, its values override the included ones.
+func (l *loader) read(file string) error {
+	for i, f := range l.stack {
+		if f == file {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(l.stack[i:], file), " -> "))
+		}
+	}
+	l.stack = append(l.stack, file)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	if !l.seen[file] {
+		l.seen[file] = true
+		l.files = append(l.files, file)
+	}
+
+	values, err := l.c.decodeFile(file)
+	var includes []string
+	if err == nil {
+		includes, err = includesOf(values)
+	}
+	if err != nil {
+		if file != l.c.filepath {
+			err = fmt.Errorf("%s: %v", file, err)
+		}
+		return err
+	}
+
+	for _, inc := range includes {
+		paths, err := resolveInclude(file, inc)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		for _, p := range paths {
+			if err := l.read(p); err != nil {
+				return err
+			}
+		}
+	}
+	nodeWalk(values, "", l.vars, l.c.logger)
+	return nil
+}
+
+// includesOf removes the include keys from values and returns their paths.
+func includesOf(values map[interface{}]interface{}) ([]string, error) {
+	var includes []string
+	for _, key := range includeKeys {
+		v, ok := values[key]
+		if !ok {
+			continue
+		}
+		delete(values, key)
+		switch v := v.(type) {
+		case string:
+			includes = append(includes, v)
+		case []interface{}:
+			for _, p := range v {
+				s, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s must be a path or a list of paths", key)
+				}
+				includes = append(includes, s)
+			}
+		default:
+			return nil, fmt.Errorf("%s must be a path or a list of paths", key)
+		}
+	}
+	return includes, nil
+}
+
+// resolveInclude returns the files of an include of file. Relative paths are relative to file,
+// local glob patterns are expanded.
+func resolveInclude(file, include string) ([]string, error) {
+	if isRemote(include) {
+		return []string{include}, nil
+	}
+	if isRemote(file) {
+		u, err := url.Parse(file)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := u.Parse(include)
+		if err != nil {
+			return nil, err
+		}
+		return []string{ref.String()}, nil
+	}
+
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(filepath.Dir(file), include)
+	}
+	if !isGlob(include) {
+		return []string{include}, nil
+	}
+	matches, err := filepath.Glob(include)
+	sort.Strings(matches)
+	return matches, err
+}
+
+// includedFiles returns the local files that are included by the source.
+func (c *Client) includedFiles() []string {
+	files, _ := c.load(make(map[string]string))
+	sources, _ := c.files()
+	source := make(map[string]bool)
+	for _, f := range sources {
+		source[f] = true
+	}
+	var included []string
+	for _, f := range files {
+		if !source[f] && !isRemote(f) {
+			included = append(included, filepath.Clean(f))
+		}
+	}
+	return included
+}
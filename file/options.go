@@ -0,0 +1,98 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import "github.com/HeavyHorst/easykv"
+
+// Options contains all values that are needed to configure the file client.
+type Options struct {
+	Logger easykv.Logger
+	Proxy  string
+	// Format is yaml, json, toml, hcl, ini, dotenv, properties, xml, jsonnet or cue.
+	// It defaults to the format of the file extension.
+	Format string
+	// Poll watches the files by polling instead of fsnotify.
+	Poll bool
+	// Includes are read before the files of the source.
+	Includes []string
+	// ImportPaths are searched for imported jsonnet libraries, cue files reject them.
+	ImportPaths []string
+	// Vars are the external variables of jsonnet files and the tags of cue files.
+	Vars map[string]string
+	SSH  SSHOptions
+}
+
+// Option configures the file client.
+type Option func(*Options)
+
+// WithLogger sets the Logger that reports skipped values and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithProxy sets the URL of the http, https or socks5 proxy that is used for remote files.
+// Defaults to the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables,
+// easykv.NoProxy disables proxies.
+func WithProxy(rawurl string) Option {
+	return func(o *Options) {
+		o.Proxy = rawurl
+	}
+}
+
+// WithFormat sets the format of the file, e.g. for files without a known extension.
+func WithFormat(format string) Option {
+	return func(o *Options) {
+		o.Format = format
+	}
+}
+
+// WithPolling watches the files by comparing their contents every WatchOptions.Interval
+// instead of with fsnotify, e.g. on nfs or fuse mounts that don't report changes.
+// Polling is used automatically if fsnotify can't watch the files.
+func WithPolling() Option {
+	return func(o *Options) {
+		o.Poll = true
+	}
+}
+
+// WithIncludes reads the files before the files of the source, whose values override them.
+// A file can include other files itself with a top-level include or $include key, a path
+// or a list of paths relative to the file. Included files are read before the including
+// file and watched as well.
+func WithIncludes(files ...string) Option {
+	return func(o *Options) {
+		o.Includes = append(o.Includes, files...)
+	}
+}
+
+// WithImportPaths adds library paths that are searched for jsonnet imports, relative paths are
+// resolved against the working directory. Jsonnet files are evaluated with the jsonnet command,
+// cue files with the cue command, which resolves imports by the cue module and fails with import paths.
+func WithImportPaths(paths ...string) Option {
+	return func(o *Options) {
+		o.ImportPaths = append(o.ImportPaths, paths...)
+	}
+}
+
+// WithVars sets the external variables of jsonnet files (std.extVar) and the tags
+// of cue files (@tag), cue fails if a tag is not declared.
+func WithVars(vars map[string]string) Option {
+	return func(o *Options) {
+		o.Vars = vars
+	}
+}
+
+// WithSSH configures the ssh connections of sftp sources.
+func WithSSH(ssh SSHOptions) Option {
+	return func(o *Options) {
+		o.SSH = ssh
+	}
+}
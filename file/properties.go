@@ -0,0 +1,119 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeProperties parses a java .properties file. The dots of a key separate its
+// path, database.url becomes /database/url.
+// Keys and values support the escapes of java, including \uXXXX, and lines that end
+// with a backslash continue on the next line.
+func decodeProperties(data []byte) (map[interface{}]interface{}, error) {
+	m := make(map[interface{}]interface{})
+	rest := strings.Replace(strings.Replace(string(data), "\r\n", "\n", -1), "\r", "\n", -1)
+	for n := 1; rest != ""; n++ {
+		var line string
+		line, rest = cutLine(rest)
+		line = strings.TrimLeft(line, " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for continues(line) && rest != "" {
+			var next string
+			next, rest = cutLine(rest)
+			line = line[:len(line)-1] + strings.TrimLeft(next, " \t\f")
+			n++
+		}
+
+		key, value := splitProperty(line)
+		k, err := unescapeProperty(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		v, err := unescapeProperty(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		// nodeWalk joins the keys with slashes
+		m[strings.Replace(k, ".", "/", -1)] = v
+	}
+	return m, nil
+}
+
+// continues reports whether the line ends with an unescaped backslash.
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitProperty splits the line at the first unescaped =, : or whitespace.
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			key, value := line[:i], strings.TrimLeft(line[i:], " \t\f")
+			if line[i] == ' ' || line[i] == '\t' || line[i] == '\f' {
+				if value != "" && (value[0] == '=' || value[0] == ':') {
+					value = value[1:]
+				}
+			} else {
+				value = line[i+1:]
+			}
+			return key, strings.TrimLeft(value, " \t\f")
+		}
+	}
+	return line, ""
+}
+
+// unescapeProperty replaces the escapes of a key or value.
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("invalid unicode escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape %q", s[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,220 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTimeout limits the connection to an sftp server.
+const sshTimeout = 10 * time.Second
+
+// defaultKeyFiles are the private keys below ~/.ssh that are used without SSHOptions.KeyFile.
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// SSHOptions configures the connections to sftp://user@host/path sources.
+type SSHOptions struct {
+	// KeyFile is the private key, ~/.ssh/id_ed25519, id_ecdsa and id_rsa are tried by default.
+	KeyFile    string
+	Passphrase string
+	// KnownHostsFile verifies the host keys, ~/.ssh/known_hosts by default.
+	KnownHostsFile        string
+	InsecureIgnoreHostKey bool
+}
+
+// sftpConn is a connection to an sftp server.
+type sftpConn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+// close closes the ssh connection first, the sftp client would wait for the server otherwise.
+func (c *sftpConn) close() {
+	c.ssh.Close()
+	c.sftp.Close()
+}
+
+// isSFTP reports whether path is an sftp location.
+func isSFTP(path string) bool {
+	return strings.HasPrefix(path, "sftp://")
+}
+
+// readSFTP reads the file of an sftp URL. The connections to the servers are kept
+// open and established again if a read fails.
+func (c *Client) readSFTP(rawurl string) ([]byte, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	c.sftpMu.Lock()
+	defer c.sftpMu.Unlock()
+
+	for retry := 0; ; retry++ {
+		conn, err := c.sftpConn(u)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readSFTPFile(conn.sftp, u.Path)
+		if err == nil || os.IsNotExist(err) || os.IsPermission(err) || retry > 0 {
+			if os.IsPermission(err) {
+				err = &easykv.BackendError{Kind: easykv.ErrPermissionDenied, Err: err}
+			}
+			return data, err
+		}
+		c.logger.Debug("sftp read failed, connecting again", "host", u.Host, "err", err)
+		conn.close()
+		delete(c.sftpConns, sftpKey(u))
+	}
+}
+
+func readSFTPFile(client *sftp.Client, path string) ([]byte, error) {
+	f, err := client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// sftpKey identifies the connection of u.
+func sftpKey(u *url.URL) string {
+	return u.User.Username() + "@" + u.Host
+}
+
+// sftpConn returns the connection to the server of u and connects if necessary. c.sftpMu must be held.
+func (c *Client) sftpConn(u *url.URL) (*sftpConn, error) {
+	if conn, ok := c.sftpConns[sftpKey(u)]; ok {
+		return conn, nil
+	}
+	config, err := c.sshConfig(u)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	sshClient, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return nil, &easykv.BackendError{Kind: easykv.ErrAuthentication, Err: err}
+		}
+		if _, ok := err.(net.Error); ok {
+			return nil, &easykv.BackendError{Kind: easykv.ErrConnection, Err: err}
+		}
+		return nil, err
+	}
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	conn := &sftpConn{ssh: sshClient, sftp: sftpClient}
+	if c.sftpConns == nil {
+		c.sftpConns = make(map[string]*sftpConn)
+	}
+	c.sftpConns[sftpKey(u)] = conn
+	c.logger.Debug("connected to sftp server", "host", addr)
+	return conn, nil
+}
+
+// sshConfig authenticates with the private keys and the password of u, if it has one.
+func (c *Client) sshConfig(u *url.URL) (*ssh.ClientConfig, error) {
+	user := u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	signers, err := c.ssh.signers()
+	if err != nil {
+		return nil, err
+	}
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if pw, ok := u.User.Password(); ok {
+		auth = append(auth, ssh.Password(pw))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("no ssh private key found")
+	}
+
+	hostKey := ssh.InsecureIgnoreHostKey()
+	if !c.ssh.InsecureIgnoreHostKey {
+		file := c.ssh.KnownHostsFile
+		if file == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			file = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		if hostKey, err = knownhosts.New(file); err != nil {
+			return nil, err
+		}
+	}
+	return &ssh.ClientConfig{User: user, Auth: auth, HostKeyCallback: hostKey, Timeout: sshTimeout}, nil
+}
+
+// signers returns the signers of the private key, or of the default keys that exist.
+func (o SSHOptions) signers() ([]ssh.Signer, error) {
+	files := []string{o.KeyFile}
+	if o.KeyFile == "" {
+		home, _ := os.UserHomeDir()
+		files = nil
+		for _, f := range defaultKeyFiles {
+			files = append(files, filepath.Join(home, ".ssh", f))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, f := range files {
+		key, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) && o.KeyFile == "" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if o.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(o.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// closeSFTP closes the connections to the sftp servers.
+func (c *Client) closeSFTP() {
+	c.sftpMu.Lock()
+	defer c.sftpMu.Unlock()
+	for k, conn := range c.sftpConns {
+		conn.close()
+		delete(c.sftpConns, k)
+	}
+}
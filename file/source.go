@@ -0,0 +1,141 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isGlob reports whether path is a glob pattern.
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// files returns the files of the source in the order they're merged.
+func (c *Client) files() ([]string, error) {
+	if c.isURL {
+		return []string{c.filepath}, nil
+	}
+	if isGlob(c.filepath) {
+		matches, err := filepath.Glob(c.filepath)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err == nil && fi.Mode().IsRegular() {
+				files = append(files, m)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	fi, err := os.Stat(c.filepath)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{c.filepath}, nil
+	}
+	var files []string
+	err = filepath.Walk(c.filepath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != c.filepath && hidden(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !c.member(path) {
+			return nil
+		}
+		// entries can be symlinks
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// hidden reports whether the file or directory name is hidden.
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// subdirs returns dir and the directories below it that aren't hidden.
+func subdirs(dir string) []string {
+	var dirs []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path != dir && hidden(info.Name()) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs
+}
+
+// member reports whether the file belongs to the source. The files of a directory tree
+// need a known extension unless the format is set, hidden files other than .env and the
+// files in hidden directories are ignored.
+func (c *Client) member(file string) bool {
+	if isGlob(c.filepath) {
+		ok, _ := filepath.Match(c.filepath, file)
+		return ok
+	}
+	rel, err := filepath.Rel(filepath.Clean(c.filepath), filepath.Clean(file))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	if rel == "." {
+		return true
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, p := range parts[:len(parts)-1] {
+		if hidden(p) {
+			return false
+		}
+	}
+	format, known := knownFormat(file)
+	if hidden(parts[len(parts)-1]) && format != "dotenv" {
+		return false
+	}
+	return known || c.format != ""
+}
+
+// watchDirs returns the directories that contain the files of a directory or glob source.
+func (c *Client) watchDirs() []string {
+	if !isGlob(c.filepath) {
+		return subdirs(c.filepath)
+	}
+	dir := filepath.Dir(c.filepath)
+	if !isGlob(dir) {
+		return []string{dir}
+	}
+	// the directories are a pattern as well, watch the ones that exist
+	matches, _ := filepath.Glob(dir)
+	var dirs []string
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+			dirs = append(dirs, m)
+		}
+	}
+	return dirs
+}
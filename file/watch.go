@@ -0,0 +1,224 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/fsnotify/fsnotify"
+)
+
+// k8sDataLink links to the current version of the files of a kubernetes ConfigMap or Secret volume.
+// The files are symlinks below it, kubernetes updates them at once by replacing the link.
+const k8sDataLink = "..data"
+
+// defaultPollInterval is the poll interval if no Interval is set in the WatchOptions.
+const defaultPollInterval = 5 * time.Second
+
+// WatchPrefix watches the file for changes with fsnotify.
+// Prefix, keys and waitIndex are only here to implement the StoreClient interface.
+// The directories of a directory tree or glob source are watched, every file that is
+// created, changed or removed in them and belongs to the source is a change. New
+// subdirectories of a tree are watched as soon as they're created.
+// In a kubernetes ConfigMap or Secret volume, the swap of its data link is a single change.
+// Every local file that is included is watched as well.
+// If fsnotify can't watch the files or WithPolling is set, the files are polled every
+// Interval of the WatchOptions instead.
+// Files on sftp servers are always polled.
+// Remote files over http/https arent supported.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if isSFTP(c.filepath) {
+		return c.pollFiles(ctx, options.Interval)
+	}
+	if c.isURL {
+		// watch is not supported for urls
+		return 0, easykv.ErrWatchNotSupported
+	}
+	if c.poll {
+		return c.pollFiles(ctx, options.Interval)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.logger.Info("can't watch the files with fsnotify, polling instead", "file", c.filepath, "err", err)
+		return c.pollFiles(ctx, options.Interval)
+	}
+	defer watcher.Close()
+
+	watched, changed, tree := c.watchTargets()
+	// the watched directories of a directory tree
+	dirs := make(map[string]bool)
+	if tree {
+		for _, d := range watched {
+			dirs[filepath.Clean(d)] = true
+		}
+	}
+	included := make(map[string]bool)
+	for _, f := range c.includedFiles() {
+		included[f] = true
+		watched = append(watched, f)
+	}
+	for _, w := range watched {
+		if err := watcher.Add(w); err != nil {
+			if os.IsNotExist(err) {
+				return 0, err
+			}
+			// e.g. the inotify limits are reached
+			c.logger.Info("can't watch the files with fsnotify, polling instead", "file", w, "err", err)
+			return c.pollFiles(ctx, options.Interval)
+		}
+	}
+
+	for {
+		select {
+		case event := <-watcher.Events:
+			name := filepath.Clean(event.Name)
+			if tree && event.Op&fsnotify.Create == fsnotify.Create && !hidden(filepath.Base(name)) {
+				if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+					// a new directory is watched as well, it might have been moved here with its files
+					added, err := c.watchTree(watcher, name, dirs)
+					if err != nil || added {
+						c.logger.Debug("file changed", "file", name, "op", event.Op.String(), "err", err)
+						return 1, nil
+					}
+					continue
+				}
+			}
+			if dirs[name] && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				// the directory is gone with its files
+				c.logger.Debug("file changed", "file", name, "op", event.Op.String())
+				return 1, nil
+			}
+			if changed(event) || (included[filepath.Clean(event.Name)] && event.Op&(fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0) {
+				c.logger.Debug("file changed", "file", event.Name, "op", event.Op.String())
+				return 1, nil
+			}
+		case err := <-watcher.Errors:
+			c.logger.Warn("file watch failed", "file", c.filepath, "err", err)
+			return 0, err
+		case <-ctx.Done():
+			return 0, easykv.ErrWatchCanceled
+		}
+	}
+}
+
+// watchTree watches the directory and its subdirectories and reports whether they contain files of the source.
+func (c *Client) watchTree(watcher *fsnotify.Watcher, dir string, dirs map[string]bool) (bool, error) {
+	for _, d := range subdirs(dir) {
+		if err := watcher.Add(d); err != nil {
+			return false, err
+		}
+		dirs[filepath.Clean(d)] = true
+	}
+	files := false
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && c.member(path) {
+			files = true
+		}
+		return nil
+	})
+	return files, nil
+}
+
+// watchTargets returns the paths that fsnotify watches and the filter of the events that are a change.
+// tree is set for a directory source, the watched paths are the directories of the tree.
+func (c *Client) watchTargets() (watched []string, changed func(fsnotify.Event) bool, tree bool) {
+	dir := isGlob(c.filepath)
+	if !dir {
+		fi, err := os.Stat(c.filepath)
+		dir = err == nil && fi.IsDir()
+	}
+
+	if volume, ok := c.k8sVolume(dir); ok {
+		// the files are replaced by swapping the data link, which is a single create event
+		return []string{volume}, func(e fsnotify.Event) bool {
+			return filepath.Base(e.Name) == k8sDataLink && e.Op&fsnotify.Create == fsnotify.Create
+		}, false
+	}
+	if dir {
+		return c.watchDirs(), func(e fsnotify.Event) bool {
+			return c.member(e.Name) && e.Op&(fsnotify.Write|fsnotify.Remove|fsnotify.Create|fsnotify.Rename) != 0
+		}, !isGlob(c.filepath)
+	}
+	return []string{c.filepath}, func(e fsnotify.Event) bool {
+		return e.Op&(fsnotify.Write|fsnotify.Remove) != 0
+	}, false
+}
+
+// k8sVolume returns the directory of the source files if it's a kubernetes ConfigMap or Secret volume.
+func (c *Client) k8sVolume(dir bool) (string, bool) {
+	volume := c.filepath
+	if !dir || isGlob(c.filepath) {
+		volume = filepath.Dir(c.filepath)
+	}
+	if isGlob(volume) {
+		return "", false
+	}
+	fi, err := os.Lstat(filepath.Join(volume, k8sDataLink))
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return "", false
+	}
+	return volume, true
+}
+
+// pollFiles compares the fingerprint of the files every interval until it changes.
+func (c *Client) pollFiles(ctx context.Context, interval time.Duration) (uint64, error) {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	current := c.fingerprint()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, easykv.ErrWatchCanceled
+		case <-ticker.C:
+		}
+		if c.fingerprint() != current {
+			c.logger.Debug("file changed", "file", c.filepath)
+			return 1, nil
+		}
+	}
+}
+
+// fingerprint returns a hash of the names and contents of the files and their includes.
+// The contents are compared rather than the modification times, they're not reliable on
+// network filesystems. Files that can't be read are part of the hash with their error.
+func (c *Client) fingerprint() uint64 {
+	h := fnv.New64a()
+	files, err := c.load(make(map[string]string))
+	if err != nil {
+		fmt.Fprintf(h, "%v\x00", err)
+	}
+	for _, f := range files {
+		if isRemote(f) && !isSFTP(f) {
+			continue
+		}
+		data, err := c.read(f)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00%v\x00", f, err)
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", f, len(data))
+		h.Write(data)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,81 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package file
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// xmlElement is an element that is being decoded.
+type xmlElement struct {
+	name     string
+	children map[interface{}]interface{}
+	text     strings.Builder
+}
+
+// decodeXML parses a simple xml document. The elements become keys below their parent,
+// starting with the root element, and their text the value. Attributes are keys below
+// their element, repeated elements a list like a yaml list.
+func decodeXML(data []byte) (map[interface{}]interface{}, error) {
+	root := &xmlElement{children: make(map[interface{}]interface{})}
+	stack := []*xmlElement{root}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		top := stack[len(stack)-1]
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			e := &xmlElement{name: tok.Name.Local, children: make(map[interface{}]interface{})}
+			for _, a := range tok.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+					e.children[a.Name.Local] = a.Value
+				}
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			top.text.Write(tok)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			var value interface{} = top.children
+			if len(top.children) == 0 {
+				value = strings.TrimSpace(top.text.String())
+			}
+			addXMLChild(stack[len(stack)-1].children, top.name, value)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, errors.New("xml document has no root element")
+	}
+	return root.children, nil
+}
+
+// addXMLChild adds a child element to children, repeated elements are collected in a list.
+func addXMLChild(children map[interface{}]interface{}, name string, value interface{}) {
+	existing, ok := children[name]
+	if !ok {
+		children[name] = value
+		return
+	}
+	if l, ok := existing.([]interface{}); ok {
+		children[name] = append(l, value)
+		return
+	}
+	children[name] = []interface{}{existing, value}
+}
@@ -0,0 +1,38 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+// An IndexStore persists the last seen index of a watched prefix,
+// so that a watch can be resumed after a restart.
+type IndexStore interface {
+	LoadIndex(prefix string) (uint64, error)
+	SaveIndex(prefix string, index uint64) error
+}
+
+// ResumeIndex sets the WaitIndex to the persisted index of prefix
+// if an IndexStore is configured and no WaitIndex was given.
+func (o *WatchOptions) ResumeIndex(prefix string) error {
+	if o.IndexStore == nil || o.WaitIndex != 0 {
+		return nil
+	}
+	index, err := o.IndexStore.LoadIndex(prefix)
+	if err != nil {
+		return err
+	}
+	o.WaitIndex = index
+	return nil
+}
+
+// SaveIndex persists the index of prefix if an IndexStore is configured.
+func (o *WatchOptions) SaveIndex(prefix string, index uint64) error {
+	if o.IndexStore == nil {
+		return nil
+	}
+	return o.IndexStore.SaveIndex(prefix, index)
+}
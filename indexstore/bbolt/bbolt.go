@@ -0,0 +1,64 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package bbolt provides an easykv.IndexStore backed by a bbolt database.
+package bbolt
+
+import (
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBucket is the bucket that is used if no bucket name is given.
+const DefaultBucket = "easykv-index"
+
+// Store is an easykv.IndexStore that keeps the indexes in a bbolt bucket.
+type Store struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// New returns a new Store that persists the indexes in the given bucket of db.
+// The bucket is created if it doesn't exist.
+func New(db *bolt.DB, bucket string) (*Store, error) {
+	if bucket == "" {
+		bucket = DefaultBucket
+	}
+	s := &Store{db: db, bucket: []byte(bucket)}
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(s.bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadIndex returns the persisted index of prefix or 0 if there is none.
+func (s *Store) LoadIndex(prefix string) (uint64, error) {
+	var index uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(s.bucket).Get([]byte(prefix))
+		if len(v) == 8 {
+			index = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return index, err
+}
+
+// SaveIndex persists the index of prefix.
+func (s *Store) SaveIndex(prefix string, index uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, index)
+		return tx.Bucket(s.bucket).Put([]byte(prefix), v)
+	})
+}
@@ -0,0 +1,49 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package bbolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestStore(t *C) {
+	dir, err := ioutil.TempDir("", "easykv")
+	t.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "index.db"), 0600, nil)
+	t.Assert(err, IsNil)
+	defer db.Close()
+
+	store, err := New(db, "")
+	t.Assert(err, IsNil)
+
+	index, err := store.LoadIndex("/app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(0))
+
+	t.Assert(store.SaveIndex("/app", 42), IsNil)
+	index, err = store.LoadIndex("/app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+}
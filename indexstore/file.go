@@ -0,0 +1,88 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package indexstore provides easykv.IndexStore implementations.
+package indexstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is an easykv.IndexStore that keeps the indexes of all prefixes in a json file.
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a new File store that persists the indexes in the file at path.
+// The file is created on the first save.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) read() (map[string]uint64, error) {
+	indexes := make(map[string]uint64)
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return indexes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return indexes, nil
+	}
+	err = json.Unmarshal(data, &indexes)
+	return indexes, err
+}
+
+// LoadIndex returns the persisted index of prefix or 0 if there is none.
+func (f *File) LoadIndex(prefix string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	indexes, err := f.read()
+	if err != nil {
+		return 0, err
+	}
+	return indexes[prefix], nil
+}
+
+// SaveIndex persists the index of prefix.
+// The file is replaced atomically, so a crash never leaves a partially written file behind.
+func (f *File) SaveIndex(prefix string, index uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	indexes, err := f.read()
+	if err != nil {
+		return err
+	}
+	indexes[prefix] = index
+
+	data, err := json.Marshal(indexes)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
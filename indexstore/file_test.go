@@ -0,0 +1,47 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package indexstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestFile(t *C) {
+	dir, err := ioutil.TempDir("", "easykv")
+	t.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	f := NewFile(filepath.Join(dir, "index.json"))
+	index, err := f.LoadIndex("/app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(0))
+
+	t.Assert(f.SaveIndex("/app", 42), IsNil)
+	t.Assert(f.SaveIndex("/other", 7), IsNil)
+
+	f = NewFile(filepath.Join(dir, "index.json"))
+	index, err = f.LoadIndex("/app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(42))
+	index, err = f.LoadIndex("/other")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(7))
+}
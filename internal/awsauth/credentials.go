@@ -0,0 +1,192 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package awsauth finds AWS credentials and signs requests with them,
+// for the vault aws auth method and the IAM auth tokens of redis.
+package awsauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Credentials sign AWS requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// A CredentialsProvider returns Credentials.
+type CredentialsProvider interface {
+	Retrieve() (Credentials, error)
+}
+
+// ProviderFunc is an adapter to allow the use of ordinary functions as CredentialsProvider.
+type ProviderFunc func() (Credentials, error)
+
+// Retrieve calls f().
+func (f ProviderFunc) Retrieve() (Credentials, error) {
+	return f()
+}
+
+// Static returns the given credentials.
+func Static(accessKeyID, secretAccessKey, sessionToken string) CredentialsProvider {
+	return ProviderFunc(func() (Credentials, error) {
+		return Credentials{accessKeyID, secretAccessKey, sessionToken}, nil
+	})
+}
+
+// Env reads the credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+var Env CredentialsProvider = ProviderFunc(func() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, errors.New("AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY is not set")
+	}
+	return creds, nil
+})
+
+// metadataURL is the address of the ec2 instance metadata service.
+var metadataURL = "http://169.254.169.254"
+
+// containerURL is the address of the ecs container credentials endpoint.
+var containerURL = "http://169.254.170.2"
+
+// metadataClient is used for all requests to the metadata services.
+var metadataClient = &http.Client{Timeout: 5 * time.Second}
+
+// temporaryCredentials is the json format of the container and instance credentials.
+type temporaryCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+}
+
+// get sends a request with the given headers and returns the body.
+func get(method, u string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", method, u, resp.Status)
+	}
+	return body, nil
+}
+
+// temporary decodes container or instance credentials.
+func temporary(body []byte) (Credentials, error) {
+	var creds temporaryCredentials
+	if err := json.Unmarshal(body, &creds); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{creds.AccessKeyID, creds.SecretAccessKey, creds.Token}, nil
+}
+
+// Container reads the credentials of an ecs task role or an eks pod identity
+// from the container credentials endpoint.
+var Container CredentialsProvider = ProviderFunc(func() (Credentials, error) {
+	u := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		u = containerURL + rel
+	}
+	if u == "" {
+		return Credentials{}, errors.New("no container credentials endpoint set")
+	}
+	header := http.Header{}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if f := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); f != "" {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return Credentials{}, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		header.Set("Authorization", token)
+	}
+	body, err := get(http.MethodGet, u, header)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return temporary(body)
+})
+
+// imdsToken returns a session token of the instance metadata service (IMDSv2).
+func imdsToken() (string, error) {
+	token, err := get(http.MethodPut, metadataURL+"/latest/api/token",
+		http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"60"}})
+	if err != nil {
+		return "", fmt.Errorf("can't get instance metadata token: %v", err)
+	}
+	return string(token), nil
+}
+
+// IMDSGet reads a path of the instance metadata service.
+func IMDSGet(p string) ([]byte, error) {
+	token, err := imdsToken()
+	if err != nil {
+		return nil, err
+	}
+	return get(http.MethodGet, metadataURL+p, http.Header{"X-Aws-Ec2-Metadata-Token": {token}})
+}
+
+// Instance reads the credentials of the ec2 instance profile from the instance metadata service.
+var Instance CredentialsProvider = ProviderFunc(func() (Credentials, error) {
+	const credsPath = "/latest/meta-data/iam/security-credentials/"
+	role, err := IMDSGet(credsPath)
+	if err != nil {
+		return Credentials{}, err
+	}
+	body, err := IMDSGet(credsPath + strings.TrimSpace(string(role)))
+	if err != nil {
+		return Credentials{}, err
+	}
+	return temporary(body)
+})
+
+// Chain returns the credentials of the first provider that succeeds.
+func Chain(providers ...CredentialsProvider) CredentialsProvider {
+	return ProviderFunc(func() (Credentials, error) {
+		errs := make([]string, 0, len(providers))
+		for _, p := range providers {
+			creds, err := p.Retrieve()
+			if err == nil {
+				return creds, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return Credentials{}, fmt.Errorf("no aws credentials found: %s", strings.Join(errs, "; "))
+	})
+}
+
+// Default tries the environment, the container credentials and the instance profile.
+var Default = Chain(Env, Container, Instance)
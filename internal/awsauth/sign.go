@@ -0,0 +1,136 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package awsauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmptyPayloadHash is the hex encoded SHA-256 hash of an empty payload.
+const EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// signature derives the signing key of the scope and signs stringToSign with it.
+func signature(creds Credentials, date, region, service, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalPath returns the escaped path of u, / if it is empty.
+func canonicalPath(u *url.URL) string {
+	if p := u.EscapedPath(); p != "" {
+		return p
+	}
+	return "/"
+}
+
+// Sign signs a request to endpoint with aws signature version 4. It sets the
+// X-Amz-Date, X-Amz-Security-Token and Authorization headers, all headers of
+// header are signed.
+func Sign(method, endpoint, service, region, body string, header http.Header, creds Credentials, now time.Time) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := now.UTC().Format("20060102")
+	header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signed := map[string]string{"host": u.Host}
+	for k := range header {
+		signed[strings.ToLower(k)] = strings.TrimSpace(header.Get(k))
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + signed[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalPath(u),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature(creds, date, region, service, stringToSign)))
+	return nil
+}
+
+// Presign returns u as a GET request presigned with aws signature version 4,
+// which is valid for expires.
+func Presign(u *url.URL, service, region, payloadHash string, creds Credentials, now time.Time, expires time.Duration) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := now.UTC().Format("20060102")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	// aws encodes spaces as %20
+	query := strings.Replace(q.Encode(), "+", "%20", -1)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		canonicalPath(u),
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	signed := *u
+	signed.RawQuery = query + "&X-Amz-Signature=" + signature(creds, date, region, service, stringToSign)
+	return signed.String()
+}
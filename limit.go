@@ -0,0 +1,182 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// LimitPolicy decides what happens to values that exceed a size limit.
+type LimitPolicy int
+
+const (
+	// LimitFail makes GetValues fail with a *LimitError.
+	LimitFail LimitPolicy = iota
+	// LimitTruncate cuts the value to the limit.
+	LimitTruncate
+	// LimitSkip leaves the key out.
+	LimitSkip
+)
+
+// LimitError reports a value or a snapshot that exceeds a size limit.
+// Key is empty if the total size of the snapshot exceeds the limit.
+type LimitError struct {
+	Key   string
+	Size  int
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("snapshot size %d exceeds the limit of %d bytes", e.Size, e.Limit)
+	}
+	return fmt.Sprintf("value size %d of %s exceeds the limit of %d bytes", e.Size, e.Key, e.Limit)
+}
+
+// LimitOptions represents options for NewLimited.
+// A zero limit disables the check.
+type LimitOptions struct {
+	MaxValueSize int
+	MaxTotalSize int
+	Policy       LimitPolicy
+	OnLimit      func(err *LimitError)
+}
+
+// LimitOption configures a Limited backend.
+type LimitOption func(*LimitOptions)
+
+// WithMaxValueSize limits the size of every value to n bytes.
+func WithMaxValueSize(n int) LimitOption {
+	return func(o *LimitOptions) {
+		o.MaxValueSize = n
+	}
+}
+
+// WithMaxTotalSize limits the size of all keys and values of a snapshot to n bytes.
+func WithMaxTotalSize(n int) LimitOption {
+	return func(o *LimitOptions) {
+		o.MaxTotalSize = n
+	}
+}
+
+// WithLimitPolicy sets the LimitPolicy. Defaults to LimitFail.
+func WithLimitPolicy(p LimitPolicy) LimitOption {
+	return func(o *LimitOptions) {
+		o.Policy = p
+	}
+}
+
+// WithLimitHandler sets a function that is called for every exceeded limit,
+// e.g. to log truncated or skipped keys.
+func WithLimitHandler(fn func(err *LimitError)) LimitOption {
+	return func(o *LimitOptions) {
+		o.OnLimit = fn
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a utf-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Apply enforces the limits on vars.
+// The total size is counted in sorted key order, so the same keys are kept in every snapshot.
+// If the total size is exceeded, all following keys are left out, even with LimitTruncate.
+func (o *LimitOptions) Apply(vars map[string]string) (map[string]string, error) {
+	if o.MaxValueSize <= 0 && o.MaxTotalSize <= 0 {
+		return vars, nil
+	}
+
+	exceeded := func(err *LimitError) error {
+		if o.OnLimit != nil {
+			o.OnLimit(err)
+		}
+		if o.Policy == LimitFail {
+			return err
+		}
+		return nil
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	limited := make(map[string]string, len(vars))
+	total := 0
+	for i, k := range keys {
+		v := vars[k]
+		if o.MaxValueSize > 0 && len(v) > o.MaxValueSize {
+			if err := exceeded(&LimitError{Key: k, Size: len(v), Limit: o.MaxValueSize}); err != nil {
+				return nil, err
+			}
+			if o.Policy == LimitSkip {
+				continue
+			}
+			v = truncate(v, o.MaxValueSize)
+		}
+
+		if o.MaxTotalSize > 0 && total+len(k)+len(v) > o.MaxTotalSize {
+			size := total
+			for _, k := range keys[i:] {
+				size += len(k) + len(vars[k])
+			}
+			if err := exceeded(&LimitError{Size: size, Limit: o.MaxTotalSize}); err != nil {
+				return nil, err
+			}
+			if o.Policy == LimitTruncate && total+len(k) < o.MaxTotalSize {
+				limited[k] = truncate(v, o.MaxTotalSize-total-len(k))
+			}
+			break
+		}
+
+		limited[k] = v
+		total += len(k) + len(v)
+	}
+	return limited, nil
+}
+
+// Limited enforces size limits on the results of GetValues, so that an oversized value
+// that was written by accident doesn't end up in caches and rendered templates.
+// The wrapped backend still reads the whole value.
+type Limited struct {
+	ReadWatcher
+
+	options LimitOptions
+}
+
+// NewLimited returns a new Limited for the given backend.
+func NewLimited(rw ReadWatcher, opts ...LimitOption) *Limited {
+	var options LimitOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	return &Limited{
+		ReadWatcher: rw,
+		options:     options,
+	}
+}
+
+// GetValues returns the values of keys with the limits enforced.
+func (l *Limited) GetValues(keys []string) (map[string]string, error) {
+	vars, err := l.ReadWatcher.GetValues(keys)
+	if err != nil {
+		return vars, err
+	}
+	return l.options.Apply(vars)
+}
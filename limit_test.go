@@ -0,0 +1,52 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestLimited(t *C) {
+	c := newTestClient(map[string]string{
+		"/app/a":    "small",
+		"/app/blob": "0123456789",
+		"/app/name": "äöü",
+	})
+
+	var limits []string
+	handler := WithLimitHandler(func(err *LimitError) { limits = append(limits, err.Error()) })
+
+	_, err := NewLimited(c, WithMaxValueSize(8), handler).GetValues([]string{"/app"})
+	t.Check(err, ErrorMatches, "value size 10 of /app/blob exceeds the limit of 8 bytes")
+
+	vars, err := NewLimited(c, WithMaxValueSize(5), WithLimitPolicy(LimitTruncate)).GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "small", "/app/blob": "01234", "/app/name": "äö"})
+
+	vars, err = NewLimited(c, WithMaxValueSize(8), WithLimitPolicy(LimitSkip)).GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "small", "/app/name": "äöü"})
+
+	limits = nil
+	_, err = NewLimited(c, WithMaxTotalSize(20), handler).GetValues([]string{"/app"})
+	t.Check(err, ErrorMatches, "snapshot size 45 exceeds the limit of 20 bytes")
+	t.Check(limits, DeepEquals, []string{"snapshot size 45 exceeds the limit of 20 bytes"})
+
+	vars, err = NewLimited(c, WithMaxTotalSize(25), WithLimitPolicy(LimitTruncate)).GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "small", "/app/blob": "01234"})
+
+	vars, err = NewLimited(c, WithMaxTotalSize(25), WithLimitPolicy(LimitSkip)).GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "small"})
+
+	vars, err = NewLimited(c).GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, HasLen, 3)
+}
@@ -0,0 +1,187 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package loader builds a composed ReadWatcher from a declarative YAML or JSON spec.
+//
+// Example spec:
+//
+//	sources:
+//	  - name: defaults
+//	    backend: file:///etc/app/defaults.yml
+//	    watch: false
+//	  - name: consul
+//	    backend: consul://127.0.0.1:8500
+//	    prefix: /app
+//	    transforms:
+//	      - type: strip_prefix
+//	        value: /app
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/factory"
+	"gopkg.in/yaml.v2"
+)
+
+// Load reads a YAML or JSON spec from r and opens all its backends.
+func Load(r io.Reader) (*Composite, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var spec Spec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, err
+	}
+	return New(spec)
+}
+
+// LoadFile reads a YAML or JSON spec from the file at path and opens all its backends.
+func LoadFile(path string) (*Composite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// New opens all backends of spec.
+func New(spec Spec) (*Composite, error) {
+	c := &Composite{}
+	for i, s := range spec.Sources {
+		if err := s.validate(); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: %v", s.name(i), err)
+		}
+		rw, err := factory.New(s.Backend)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: %v", s.name(i), err)
+		}
+		c.sources = append(c.sources, &source{Source: s, rw: rw})
+	}
+	return c, nil
+}
+
+// Composite is a ReadWatcher that merges the values of several backends.
+type Composite struct {
+	sources []*source
+
+	mu    sync.Mutex
+	index uint64
+}
+
+type source struct {
+	Source
+	rw        easykv.ReadWatcher
+	waitIndex uint64
+}
+
+// GetValues returns the merged values of all sources with the given key prefixes.
+// The keys are matched after the transforms are applied.
+func (c *Composite) GetValues(keys []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for i, s := range c.sources {
+		values, err := s.rw.GetValues([]string{s.prefix()})
+		if err != nil {
+			return vars, fmt.Errorf("%s: %v", s.name(i), err)
+		}
+		for k, v := range values {
+			k = s.key(k)
+			if hasPrefix(k, keys) {
+				vars[k] = v
+			}
+		}
+	}
+	return vars, nil
+}
+
+func hasPrefix(key string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, path.Join("/", p)) {
+			return true
+		}
+	}
+	return false
+}
+
+type watchResponse struct {
+	source *source
+	index  uint64
+	err    error
+}
+
+// WatchPrefix watches all sources that have watching enabled
+// and returns as soon as one of them reports a change.
+// The prefix is ignored, every source is watched on its own prefix.
+func (c *Composite) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	respChan := make(chan watchResponse, len(c.sources))
+	watched := 0
+	for _, s := range c.sources {
+		if !s.watch() {
+			continue
+		}
+		watched++
+		go func(s *source) {
+			c.mu.Lock()
+			waitIndex := s.waitIndex
+			c.mu.Unlock()
+			index, err := s.rw.WatchPrefix(ctx, s.prefix(), easykv.WithWaitIndex(waitIndex))
+			respChan <- watchResponse{s, index, err}
+		}(s)
+	}
+	if watched == 0 {
+		return options.WaitIndex, easykv.ErrWatchNotSupported
+	}
+
+	for i := 0; i < watched; i++ {
+		select {
+		case r := <-respChan:
+			if r.err == easykv.ErrWatchNotSupported {
+				continue
+			}
+			if r.err != nil {
+				return options.WaitIndex, r.err
+			}
+			c.mu.Lock()
+			r.source.waitIndex = r.index
+			c.index++
+			index := c.index
+			c.mu.Unlock()
+			return index, nil
+		case <-ctx.Done():
+			return options.WaitIndex, easykv.ErrWatchCanceled
+		}
+	}
+	return options.WaitIndex, easykv.ErrWatchNotSupported
+}
+
+// Close closes all backends.
+func (c *Composite) Close() {
+	for _, s := range c.sources {
+		s.rw.Close()
+	}
+}
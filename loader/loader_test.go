@@ -0,0 +1,121 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package loader
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct {
+	dir string
+}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) SetUpTest(t *C) {
+	s.dir = t.MkDir()
+	s.write(t, "defaults.yml", "app:\n  name: default\n  port: \"80\"\n")
+	s.write(t, "override.yml", "service:\n  PORT: \"8080\"\n")
+}
+
+func (s *FilterSuite) write(t *C, name, data string) string {
+	p := filepath.Join(s.dir, name)
+	t.Assert(ioutil.WriteFile(p, []byte(data), 0644), IsNil)
+	return p
+}
+
+func (s *FilterSuite) spec(watch string) string {
+	return `
+sources:
+  - name: defaults
+    backend: file://` + filepath.Join(s.dir, "defaults.yml") + `
+    watch: ` + watch + `
+  - name: override
+    backend: file://` + filepath.Join(s.dir, "override.yml") + `
+    prefix: /service
+    transforms:
+      - type: strip_prefix
+        value: /service
+      - type: add_prefix
+        value: /app
+      - type: lowercase
+`
+}
+
+func (s *FilterSuite) TestGetValues(t *C) {
+	c, err := Load(strings.NewReader(s.spec("false")))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/app/name": "default",
+		"/app/port": "8080",
+	})
+}
+
+func (s *FilterSuite) TestLoadFileJSON(t *C) {
+	p := s.write(t, "spec.json", `{"sources": [{"backend": "file://`+filepath.Join(s.dir, "defaults.yml")+`"}]}`)
+	c, err := LoadFile(p)
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/app/name"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "default"})
+}
+
+func (s *FilterSuite) TestInvalidSpec(t *C) {
+	_, err := Load(strings.NewReader("sources:\n  - backend: env://\n    transforms:\n      - type: reverse\n"))
+	t.Check(err, ErrorMatches, `source 0: unknown transform "reverse"`)
+
+	_, err = Load(strings.NewReader("sources:\n  - name: x\n    backend: nope://\n"))
+	t.Check(err, ErrorMatches, `x: unknown backend: "nope"`)
+
+	_, err = Load(strings.NewReader("sources:\n  - backend: env://\n    prefixx: /app\n"))
+	t.Check(err, NotNil)
+}
+
+func (s *FilterSuite) TestWatchPrefix(t *C) {
+	c, err := Load(strings.NewReader(s.spec("false")))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.write(t, "override.yml", "service:\n  PORT: \"9090\"\n")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	index, err := c.WatchPrefix(ctx, "/app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(1))
+}
+
+func (s *FilterSuite) TestWatchDisabled(t *C) {
+	c, err := Load(strings.NewReader("sources:\n  - backend: env://\n    watch: false\n"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	_, err = c.WatchPrefix(context.Background(), "/")
+	t.Check(err, Equals, easykv.ErrWatchNotSupported)
+}
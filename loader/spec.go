@@ -0,0 +1,92 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package loader
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Spec describes a composed backend.
+// Sources are merged in order, values of later sources override values of earlier ones.
+type Spec struct {
+	Sources []Source `yaml:"sources" json:"sources"`
+}
+
+// Source describes a single backend of a Spec.
+type Source struct {
+	// Name is used in error messages.
+	Name string `yaml:"name" json:"name"`
+	// Backend is a backend uri as accepted by factory.New.
+	Backend string `yaml:"backend" json:"backend"`
+	// Prefix limits the source to the keys below prefix. Defaults to "/".
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// Transforms are applied in order to every key of the source.
+	Transforms []Transform `yaml:"transforms" json:"transforms"`
+	// Watch disables watching the source if set to false. Defaults to true.
+	Watch *bool `yaml:"watch" json:"watch"`
+}
+
+// Transform describes a key transformation.
+// Type is one of strip_prefix, add_prefix, lowercase and uppercase.
+type Transform struct {
+	Type  string `yaml:"type" json:"type"`
+	Value string `yaml:"value" json:"value"`
+}
+
+func (s Source) name(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("source %d", i)
+}
+
+func (s Source) prefix() string {
+	if s.Prefix == "" {
+		return "/"
+	}
+	return s.Prefix
+}
+
+func (s Source) watch() bool {
+	return s.Watch == nil || *s.Watch
+}
+
+// validate checks the transforms of the source.
+func (s Source) validate() error {
+	if s.Backend == "" {
+		return fmt.Errorf("no backend given")
+	}
+	for _, t := range s.Transforms {
+		switch t.Type {
+		case "strip_prefix", "add_prefix", "lowercase", "uppercase":
+		default:
+			return fmt.Errorf("unknown transform %q", t.Type)
+		}
+	}
+	return nil
+}
+
+// key applies all transforms to key.
+func (s Source) key(key string) string {
+	for _, t := range s.Transforms {
+		switch t.Type {
+		case "strip_prefix":
+			key = path.Join("/", strings.TrimPrefix(path.Join("/", key), path.Join("/", t.Value)))
+		case "add_prefix":
+			key = path.Join("/", t.Value, key)
+		case "lowercase":
+			key = strings.ToLower(key)
+		case "uppercase":
+			key = strings.ToUpper(key)
+		}
+	}
+	return key
+}
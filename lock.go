@@ -0,0 +1,48 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"time"
+)
+
+// A Session is a held lock.
+type Session interface {
+	// Done is closed when the lock is lost, e.g. because its lease expired
+	// or the connection to the backend was lost.
+	Done() <-chan struct{}
+	// Unlock releases the lock.
+	Unlock() error
+}
+
+// A SessionWriter is a Session that can write values bound to the lock.
+// The values are deleted when the lock is unlocked or lost,
+// the sessions of all backends that implement Locker are SessionWriters.
+type SessionWriter interface {
+	Session
+	SetValue(ctx context.Context, key, value string) error
+}
+
+// A Locker can acquire distributed locks.
+// Lock blocks until the lock on key is acquired or ctx is canceled.
+// The lock is kept alive until it is unlocked, ttl is the time after which
+// the lock is released if its holder dies.
+type Locker interface {
+	Lock(ctx context.Context, key string, ttl time.Duration) (Session, error)
+}
+
+// Lock acquires the lock on key if the backend implements Locker.
+// Otherwise ErrLockNotSupported is returned.
+func Lock(ctx context.Context, rw ReadWatcher, key string, ttl time.Duration) (Session, error) {
+	if l, ok := rw.(Locker); ok {
+		return l.Lock(ctx, key, ttl)
+	}
+	return nil, ErrLockNotSupported
+}
@@ -0,0 +1,44 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type testSession struct {
+	done chan struct{}
+}
+
+func (s *testSession) Done() <-chan struct{} { return s.done }
+func (s *testSession) Unlock() error         { close(s.done); return nil }
+
+type testLockClient struct {
+	*testClient
+	locked string
+}
+
+func (c *testLockClient) Lock(ctx context.Context, key string, ttl time.Duration) (Session, error) {
+	c.locked = key
+	return &testSession{make(chan struct{})}, nil
+}
+
+func (s *FilterSuite) TestLock(t *C) {
+	c := &testLockClient{testClient: newTestClient(nil)}
+	session, err := Lock(context.Background(), c, "/locks/app", time.Second)
+	t.Assert(err, IsNil)
+	t.Check(c.locked, Equals, "/locks/app")
+	t.Check(session.Unlock(), IsNil)
+
+	_, err = Lock(context.Background(), newTestClient(nil), "/locks/app", time.Second)
+	t.Check(err, Equals, ErrLockNotSupported)
+}
@@ -0,0 +1,37 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+// Logger is used by the backends to report auth events, retries, watch
+// reconnects and skipped keys. The arguments are alternating key-value pairs.
+// *slog.Logger implements this interface.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// DiscardLogger discards all messages. It is the default Logger of all backends.
+var DiscardLogger Logger = discardLogger{}
+
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string, args ...interface{}) {}
+func (discardLogger) Info(msg string, args ...interface{})  {}
+func (discardLogger) Warn(msg string, args ...interface{})  {}
+func (discardLogger) Error(msg string, args ...interface{}) {}
+
+// LoggerOrDiscard returns l, or DiscardLogger if l is nil.
+func LoggerOrDiscard(l Logger) Logger {
+	if l == nil {
+		return DiscardLogger
+	}
+	return l
+}
@@ -0,0 +1,24 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type testLogger struct {
+	discardLogger
+}
+
+func (s *FilterSuite) TestLoggerOrDiscard(t *C) {
+	t.Check(LoggerOrDiscard(nil), Equals, DiscardLogger)
+
+	l := &testLogger{}
+	t.Check(LoggerOrDiscard(l), Equals, Logger(l))
+}
@@ -0,0 +1,58 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import "time"
+
+// KeyMetadata is the value of a key together with what the backend knows about it.
+// Fields a backend doesn't support are left empty.
+type KeyMetadata struct {
+	Value       string
+	Version     uint64
+	CreateIndex uint64
+	ModifyIndex uint64
+
+	// Flags are the opaque flags of the key (consul), e.g. feature data of the application.
+	Flags uint64
+
+	// LeaseID is the lease the key is attached to (etcd), 0 for durable keys.
+	LeaseID int64
+	// TTL is the remaining time to live of the lease.
+	TTL time.Duration
+}
+
+// Ephemeral reports whether the key is removed when its lease expires,
+// e.g. a service registration.
+func (m KeyMetadata) Ephemeral() bool {
+	return m.LeaseID != 0
+}
+
+// A MetadataGetter can return the metadata of the keys alongside their values.
+type MetadataGetter interface {
+	GetValuesWithMetadata(keys []string) (map[string]KeyMetadata, error)
+}
+
+// GetValuesWithMetadata looks up all keys with the given prefixes like GetValues
+// and returns their metadata. For backends that don't implement MetadataGetter,
+// only the values are set.
+func GetValuesWithMetadata(rw ReadWatcher, keys []string) (map[string]KeyMetadata, error) {
+	if mg, ok := rw.(MetadataGetter); ok {
+		return mg.GetValuesWithMetadata(keys)
+	}
+
+	vars, err := rw.GetValues(keys)
+	if err != nil {
+		return nil, err
+	}
+	md := make(map[string]KeyMetadata, len(vars))
+	for k, v := range vars {
+		md[k] = KeyMetadata{Value: v}
+	}
+	return md, nil
+}
@@ -0,0 +1,37 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type metadataClient struct {
+	*testClient
+}
+
+func (c metadataClient) GetValuesWithMetadata(keys []string) (map[string]KeyMetadata, error) {
+	return map[string]KeyMetadata{"/service/a": {Value: "10.0.0.1", LeaseID: 7, TTL: 10 * time.Second}}, nil
+}
+
+func (s *FilterSuite) TestGetValuesWithMetadata(t *C) {
+	c := newTestClient(map[string]string{"/app/name": "easykv"})
+
+	md, err := GetValuesWithMetadata(c, []string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(md, DeepEquals, map[string]KeyMetadata{"/app/name": {Value: "easykv"}})
+	t.Check(md["/app/name"].Ephemeral(), Equals, false)
+
+	md, err = GetValuesWithMetadata(metadataClient{c}, []string{"/service"})
+	t.Assert(err, IsNil)
+	t.Check(md["/service/a"].Ephemeral(), Equals, true)
+	t.Check(md["/service/a"].TTL, Equals, 10*time.Second)
+}
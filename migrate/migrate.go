@@ -0,0 +1,113 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package migrate copies and mirrors keys from one backend to another.
+package migrate
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// Changes describes what a copy operation changed in the destination.
+type Changes struct {
+	// Set contains all keys that were written, with their new values.
+	Set map[string]string
+	// Deleted contains all keys that were deleted.
+	Deleted []string
+}
+
+// Copy copies all keys with the given prefix from src to dst.
+// Keys whose value is already up to date in dst are skipped.
+func Copy(src easykv.ReadWatcher, dst easykv.ReadWriter, prefix string, opts ...Option) (*Changes, error) {
+	options := Options{Rewrite: func(key string) string { return key }}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	srcValues, err := src.GetValues([]string{prefix})
+	if err != nil {
+		return nil, err
+	}
+
+	dstPrefix := options.Rewrite(prefix)
+	dstValues, err := dst.GetValues([]string{dstPrefix})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Changes{Set: make(map[string]string)}
+	wanted := make(map[string]bool, len(srcValues))
+	for k, v := range srcValues {
+		key := options.Rewrite(k)
+		wanted[key] = true
+		if old, ok := dstValues[key]; !ok || old != v {
+			result.Set[key] = v
+		}
+	}
+
+	if options.Delete {
+		for k := range dstValues {
+			if strings.HasPrefix(k, dstPrefix) && !wanted[k] {
+				result.Deleted = append(result.Deleted, k)
+			}
+		}
+		sort.Strings(result.Deleted)
+	}
+
+	if options.DryRun {
+		return result, nil
+	}
+
+	if len(result.Set) > 0 {
+		if err := dst.SetValues(result.Set); err != nil {
+			return result, err
+		}
+	}
+	if len(result.Deleted) > 0 {
+		if err := dst.DeleteValues(result.Deleted); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Mirror copies all keys with the given prefix from src to dst
+// and copies them again whenever src reports a change.
+// The callback fn is called with the changes of every copy operation, it may be nil.
+// Mirror returns if ctx is canceled or an error occurs.
+// It returns nil if ctx was canceled. The watches are configured with WithWatchOptions.
+func Mirror(ctx context.Context, src easykv.ReadWatcher, dst easykv.ReadWriter, prefix string, fn func(*Changes), opts ...Option) error {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var waitIndex uint64
+	for {
+		result, err := Copy(src, dst, prefix, opts...)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			fn(result)
+		}
+
+		watch := append(options.Watch[:len(options.Watch):len(options.Watch)], easykv.WithKeys([]string{prefix}), easykv.WithWaitIndex(waitIndex))
+		waitIndex, err = src.WatchPrefix(ctx, prefix, watch...)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package migrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv/mock"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestCopy(t *C) {
+	src, _ := mock.New(nil, map[string]string{
+		"/app/db/url":  "www.google.de",
+		"/app/db/user": "Boris",
+	})
+	dst, _ := mock.New(nil, map[string]string{
+		"/new/db/user": "Boris",
+		"/new/db/old":  "gone",
+	})
+
+	result, err := Copy(src, dst, "/app", WithPrefixRewrite("/app", "/new"), WithDelete(true), WithDryRun(true))
+	t.Assert(err, IsNil)
+	t.Check(result.Set, DeepEquals, map[string]string{"/new/db/url": "www.google.de"})
+	t.Check(result.Deleted, DeepEquals, []string{"/new/db/old"})
+	t.Check(dst.Data, HasLen, 2)
+
+	_, err = Copy(src, dst, "/app", WithPrefixRewrite("/app", "/new"), WithDelete(true))
+	t.Assert(err, IsNil)
+	t.Check(dst.Data, DeepEquals, map[string]string{
+		"/new/db/url":  "www.google.de",
+		"/new/db/user": "Boris",
+	})
+}
+
+func (s *FilterSuite) TestMirrorCancel(t *C) {
+	src, _ := mock.New(nil, map[string]string{"/app/name": "easykv"})
+	dst, _ := mock.New(nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	copies := 0
+	err := Mirror(ctx, src, dst, "/app", func(*Changes) { copies++ })
+	t.Check(err, IsNil)
+	t.Check(copies, Equals, 1)
+	t.Check(dst.Data, DeepEquals, map[string]string{"/app/name": "easykv"})
+}
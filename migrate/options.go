@@ -0,0 +1,65 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package migrate
+
+import (
+	"strings"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// Options contains all values that configure a copy or mirror operation.
+type Options struct {
+	Rewrite func(key string) string
+	DryRun  bool
+	Delete  bool
+	// Watch are the options of the watches of Mirror.
+	Watch []easykv.WatchOption
+}
+
+// Option configures a copy or mirror operation.
+type Option func(*Options)
+
+// WithRewrite sets the function that maps source keys to destination keys.
+func WithRewrite(rewrite func(key string) string) Option {
+	return func(o *Options) {
+		o.Rewrite = rewrite
+	}
+}
+
+// WithPrefixRewrite replaces the prefix from of all source keys with to.
+func WithPrefixRewrite(from, to string) Option {
+	return WithRewrite(func(key string) string {
+		if strings.HasPrefix(key, from) {
+			return to + strings.TrimPrefix(key, from)
+		}
+		return key
+	})
+}
+
+// WithDryRun only reports the changes without writing them to the destination.
+func WithDryRun(dryRun bool) Option {
+	return func(o *Options) {
+		o.DryRun = dryRun
+	}
+}
+
+// WithDelete deletes keys from the destination prefix that don't exist in the source.
+func WithDelete(del bool) Option {
+	return func(o *Options) {
+		o.Delete = del
+	}
+}
+
+// WithWatchOptions passes opts to the watches of Mirror, e.g. the poll interval.
+func WithWatchOptions(opts ...easykv.WatchOption) Option {
+	return func(o *Options) {
+		o.Watch = append(o.Watch, opts...)
+	}
+}
@@ -42,3 +42,40 @@ func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.
 	time.Sleep(2 * time.Second)
 	return 0, c.Err
 }
+
+// SetValues mock
+func (c *Client) SetValues(values map[string]string) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.Data == nil {
+		c.Data = make(map[string]string)
+	}
+	for k, v := range values {
+		c.Data[k] = v
+	}
+	return nil
+}
+
+// DeleteValues mock
+func (c *Client) DeleteValues(keys []string) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	for _, k := range keys {
+		delete(c.Data, k)
+	}
+	return nil
+}
+
+// GetValue mock
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	if c.Err != nil {
+		return "", c.Err
+	}
+	value, ok := c.Data[key]
+	if !ok {
+		return "", easykv.ErrKeyNotFound
+	}
+	return value, nil
+}
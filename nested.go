@@ -0,0 +1,118 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A Decoder decodes a stored value into a nested value.
+type Decoder interface {
+	Decode(data []byte) (interface{}, error)
+}
+
+// DecoderFunc is an adapter to allow the use of ordinary functions as Decoder.
+type DecoderFunc func(data []byte) (interface{}, error)
+
+// Decode calls f(data).
+func (f DecoderFunc) Decode(data []byte) (interface{}, error) {
+	return f(data)
+}
+
+// JSONDecoder decodes json encoded values. It is the default Decoder of GetValuesNested.
+var JSONDecoder Decoder = DecoderFunc(func(data []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	return v, err
+})
+
+// StringDecoder returns values unchanged as strings, e.g. for prefixes with plain text values.
+var StringDecoder Decoder = DecoderFunc(func(data []byte) (interface{}, error) {
+	return string(data), nil
+})
+
+// DecodeError is returned by GetValuesNested if a value can't be decoded.
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("can't decode the value of %s: %v", e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NestedOptions represents options for GetValuesNested.
+type NestedOptions struct {
+	Decoder        Decoder
+	PrefixDecoders map[string]Decoder
+}
+
+// NestedOption configures the GetValuesNested operation.
+type NestedOption func(*NestedOptions)
+
+// WithDecoder sets the Decoder that is used for all values.
+func WithDecoder(d Decoder) NestedOption {
+	return func(o *NestedOptions) {
+		o.Decoder = d
+	}
+}
+
+// WithPrefixDecoder sets the Decoder that is used for all values with the given key prefix.
+// If several prefixes match a key, the longest one wins.
+func WithPrefixDecoder(prefix string, d Decoder) NestedOption {
+	return func(o *NestedOptions) {
+		if o.PrefixDecoders == nil {
+			o.PrefixDecoders = make(map[string]Decoder)
+		}
+		o.PrefixDecoders[prefix] = d
+	}
+}
+
+// decoder returns the Decoder for the given key.
+func (o *NestedOptions) decoder(key string) Decoder {
+	d, match := o.Decoder, ""
+	for prefix, pd := range o.PrefixDecoders {
+		if strings.HasPrefix(key, prefix) && len(prefix) >= len(match) {
+			d, match = pd, prefix
+		}
+	}
+	return d
+}
+
+// GetValuesNested looks up all keys with the given prefixes like GetValues,
+// but decodes every value into a nested value (maps, slices, numbers, ...).
+// A value that can't be decoded fails the call with a DecodeError,
+// use StringDecoder for prefixes with plain text values.
+func GetValuesNested(rw ReadWatcher, keys []string, opts ...NestedOption) (map[string]interface{}, error) {
+	options := NestedOptions{Decoder: JSONDecoder}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	vars, err := rw.GetValues(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	nested := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		d, err := options.decoder(k).Decode([]byte(v))
+		if err != nil {
+			return nil, &DecodeError{k, err}
+		}
+		nested[k] = d
+	}
+	return nested, nil
+}
@@ -0,0 +1,48 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestGetValuesNested(t *C) {
+	c := newTestClient(map[string]string{
+		"/app/db":    `{"url": "www.google.de", "user": "Boris"}`,
+		"/app/name":  "easykv",
+		"/bin/hosts": "a,b",
+	})
+
+	split := DecoderFunc(func(data []byte) (interface{}, error) {
+		return strings.Split(string(data), ","), nil
+	})
+
+	vars, err := GetValuesNested(c, []string{"/app", "/bin"},
+		WithPrefixDecoder("/bin", split), WithPrefixDecoder("/app/name", StringDecoder))
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]interface{}{
+		"/app/db":    map[string]interface{}{"url": "www.google.de", "user": "Boris"},
+		"/app/name":  "easykv",
+		"/bin/hosts": []string{"a", "b"},
+	})
+}
+
+func (s *FilterSuite) TestGetValuesNestedDecodeError(t *C) {
+	c := newTestClient(map[string]string{
+		"/app/db":   `{"url": "www.google.de"}`,
+		"/app/name": "easykv",
+	})
+
+	_, err := GetValuesNested(c, []string{"/app"})
+	t.Assert(err, FitsTypeOf, &DecodeError{})
+	t.Check(err.(*DecodeError).Key, Equals, "/app/name")
+	t.Check(err, ErrorMatches, "can't decode the value of /app/name: .*")
+}
@@ -0,0 +1,38 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+// A PartialGetter can return the values it could read along with the errors of unreadable keys.
+type PartialGetter interface {
+	GetValuesPartial(keys []string) (map[string]string, map[string]error)
+}
+
+// GetValuesPartial looks up all keys with the given prefixes and returns the values
+// that could be read plus a map of the keys that failed and their errors.
+// Backends that implement PartialGetter report errors per key, all other backends
+// are queried prefix by prefix and errors are reported per prefix.
+func GetValuesPartial(rw ReadWatcher, keys []string) (map[string]string, map[string]error) {
+	if pg, ok := rw.(PartialGetter); ok {
+		return pg.GetValuesPartial(keys)
+	}
+
+	vars := make(map[string]string)
+	errs := make(map[string]error)
+	for _, key := range keys {
+		values, err := rw.GetValues([]string{key})
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		for k, v := range values {
+			vars[k] = v
+		}
+	}
+	return vars, errs
+}
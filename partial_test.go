@@ -0,0 +1,44 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+)
+
+type failingClient struct {
+	*testClient
+	failing string
+}
+
+func (c *failingClient) GetValues(keys []string) (map[string]string, error) {
+	for _, k := range keys {
+		if k == c.failing {
+			return nil, errors.New("permission denied")
+		}
+	}
+	return c.testClient.GetValues(keys)
+}
+
+func (s *FilterSuite) TestGetValuesPartial(t *C) {
+	c := &failingClient{
+		testClient: newTestClient(map[string]string{
+			"/app/name":   "easykv",
+			"/secret/key": "s3cret",
+		}),
+		failing: "/secret",
+	}
+
+	vars, errs := GetValuesPartial(c, []string{"/app", "/secret"})
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+	t.Check(errs, HasLen, 1)
+	t.Check(errs["/secret"], ErrorMatches, "permission denied")
+}
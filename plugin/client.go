@@ -0,0 +1,111 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/plugin/proto"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+)
+
+// client is the host side of a plugin.
+type client struct {
+	backend proto.BackendClient
+	plugin  *goplugin.Client
+	once    sync.Once
+}
+
+// Open starts the plugin executable at path and opens a backend with config.
+// The stderr of the plugin is passed through to os.Stderr.
+// Plugins are implemented with Serve.
+func Open(path string, config map[string]string) (easykv.ReadWatcher, error) {
+	pc := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          goplugin.PluginSet{pluginName: &backendPlugin{}},
+		Cmd:              exec.Command(path),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		Stderr:           os.Stderr,
+		Logger:           hclog.NewNullLogger(),
+	})
+
+	rpc, err := pc.Client()
+	if err != nil {
+		pc.Kill()
+		return nil, fmt.Errorf("can't start plugin %s: %v", path, err)
+	}
+	raw, err := rpc.Dispense(pluginName)
+	if err != nil {
+		pc.Kill()
+		return nil, fmt.Errorf("can't start plugin %s: %v", path, err)
+	}
+	c := raw.(*client)
+	c.plugin = pc
+
+	if _, err := c.backend.Open(context.Background(), &proto.OpenRequest{Config: config}); err != nil {
+		pc.Kill()
+		return nil, fmt.Errorf("can't open plugin %s: %v", path, fromStatus(err))
+	}
+	return c, nil
+}
+
+// GetValues queries the plugin for all keys with the given prefixes.
+func (c *client) GetValues(keys []string) (map[string]string, error) {
+	resp, err := c.backend.GetValues(context.Background(), &proto.GetValuesRequest{Keys: keys})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if resp.Values == nil {
+		return map[string]string{}, nil
+	}
+	return resp.Values, nil
+}
+
+// WatchPrefix watches a prefix in the plugin.
+// If ctx is canceled, the call and with it the watch in the plugin is canceled as well.
+func (c *client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// the call is only canceled by the host, a deadline of ctx would end it in the plugin
+	// before ctx reports it
+	callCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	resp, err := c.backend.WatchPrefix(callCtx, &proto.WatchPrefixRequest{
+		Prefix:    prefix,
+		WaitIndex: options.WaitIndex,
+		Keys:      options.Keys,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return options.WaitIndex, easykv.ErrWatchCanceled
+		}
+		return options.WaitIndex, fromStatus(err)
+	}
+	return resp.Index, nil
+}
+
+// Close closes the backend in the plugin and stops the plugin process.
+func (c *client) Close() {
+	c.once.Do(func() {
+		c.backend.Close(context.Background(), &proto.Empty{})
+		c.plugin.Kill()
+	})
+}
@@ -0,0 +1,106 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package plugin runs easyKV backends in separate processes with hashicorp/go-plugin.
+// The host opens a plugin executable with Open, the plugin serves its backend with Serve.
+// Host and plugin talk gRPC, see proto/backend.proto.
+package plugin
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/backend.proto
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/plugin/proto"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Handshake is the handshake between host and plugin. The magic cookie is set in the
+// environment of every plugin process and guards against executing a plugin directly.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "EASYKV_PLUGIN",
+	MagicCookieValue: "a3c1c7f4d2e04e1b",
+}
+
+// ErrNotPlugin is returned by Serve if the process wasn't started by Open.
+var ErrNotPlugin = errors.New("this binary is an easyKV plugin and can't be executed directly")
+
+// pluginName is the name of the backend in the plugin set.
+const pluginName = "backend"
+
+// backendPlugin is the go-plugin definition of a backend.
+// open is only set on the plugin side.
+type backendPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	open func(config map[string]string) (easykv.ReadWatcher, error)
+}
+
+func (p *backendPlugin) GRPCServer(_ *goplugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterBackendServer(s, &server{open: p.open})
+	return nil
+}
+
+func (p *backendPlugin) GRPCClient(_ context.Context, _ *goplugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return &client{backend: proto.NewBackendClient(conn)}, nil
+}
+
+// errorCodes are the gRPC codes of the well known easyKV errors,
+// errors don't keep their identity over gRPC.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{easykv.ErrWatchCanceled, codes.Canceled},
+	{easykv.ErrWatchNotSupported, codes.Unimplemented},
+	{easykv.ErrKeyNotFound, codes.NotFound},
+	{easykv.ErrAuthentication, codes.Unauthenticated},
+	{easykv.ErrPermissionDenied, codes.PermissionDenied},
+	{easykv.ErrConnection, codes.Unavailable},
+}
+
+// toStatus returns the gRPC status of a backend error.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	kind := easykv.ErrorKind(err)
+	for _, e := range errorCodes {
+		if err == e.err || kind == e.err {
+			return status.Error(e.code, err.Error())
+		}
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// fromStatus restores the well known easyKV errors of a gRPC error.
+// Classified backend errors become a *easykv.BackendError of the same kind.
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, e := range errorCodes {
+		if st.Code() != e.code {
+			continue
+		}
+		if st.Message() == e.err.Error() {
+			return e.err
+		}
+		if prefix := e.err.Error() + ": "; strings.HasPrefix(st.Message(), prefix) {
+			return &easykv.BackendError{Kind: e.err, Err: errors.New(strings.TrimPrefix(st.Message(), prefix))}
+		}
+	}
+	return errors.New(st.Message())
+}
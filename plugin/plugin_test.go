@@ -0,0 +1,122 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+
+	. "gopkg.in/check.v1"
+)
+
+// TestMain turns the test binary into a plugin if it's started by Open.
+func TestMain(m *testing.M) {
+	if os.Getenv(Handshake.MagicCookieKey) != "" {
+		err := Serve(func(config map[string]string) (easykv.ReadWatcher, error) {
+			if config["fail"] != "" {
+				return nil, errors.New(config["fail"])
+			}
+			return &testBackend{config: config}, nil
+		})
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+// testBackend is the backend of the test plugin.
+// A watch without a WaitIndex reports a change with index 7 if config has change set,
+// other watches wait until they are canceled.
+type testBackend struct {
+	config map[string]string
+}
+
+func (b *testBackend) GetValues(keys []string) (map[string]string, error) {
+	if b.config["denied"] != "" {
+		return nil, &easykv.BackendError{Kind: easykv.ErrPermissionDenied, Err: errors.New(b.config["denied"])}
+	}
+	return map[string]string{"/app/a": b.config["a"]}, nil
+}
+
+func (b *testBackend) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if b.config["change"] != "" && options.WaitIndex == 0 {
+		return 7, nil
+	}
+	<-ctx.Done()
+	return options.WaitIndex, easykv.ErrWatchCanceled
+}
+
+func (b *testBackend) Close() {}
+
+func (s *FilterSuite) TestGetValues(t *C) {
+	rw, err := Open(os.Args[0], map[string]string{"a": "1"})
+	t.Assert(err, IsNil)
+	defer rw.Close()
+
+	vars, err := rw.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "1"})
+}
+
+func (s *FilterSuite) TestErrors(t *C) {
+	_, err := Open(os.Args[0], map[string]string{"fail": "bad config"})
+	t.Check(err, ErrorMatches, ".*bad config")
+
+	rw, err := Open(os.Args[0], map[string]string{"denied": "no access to /app"})
+	t.Assert(err, IsNil)
+	defer rw.Close()
+	_, err = rw.GetValues([]string{"/app"})
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrPermissionDenied)
+	t.Check(err, ErrorMatches, "permission denied: no access to /app")
+}
+
+func (s *FilterSuite) TestWatchPrefix(t *C) {
+	rw, err := Open(os.Args[0], map[string]string{"change": "1"})
+	t.Assert(err, IsNil)
+	defer rw.Close()
+
+	index, err := rw.WatchPrefix(context.Background(), "/app")
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(7))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = rw.WatchPrefix(ctx, "/app", easykv.WithWaitIndex(7))
+	t.Check(err, Equals, easykv.ErrWatchCanceled)
+
+	// a watch canceled before it started doesn't reach the plugin
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	index, err = rw.WatchPrefix(ctx, "/app", easykv.WithWaitIndex(7))
+	t.Check(err, Equals, easykv.ErrWatchCanceled)
+	t.Check(index, Equals, uint64(7))
+}
+
+func (s *FilterSuite) TestServeDirectly(t *C) {
+	err := Serve(nil)
+	t.Check(err, Equals, ErrNotPlugin)
+}
@@ -0,0 +1,110 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/plugin/proto"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+)
+
+// Serve serves the backend returned by open to the host that started the process with Open.
+// It blocks until the host stops the plugin.
+// A plugin must not write to os.Stdout, it's used for the plugin protocol.
+func Serve(open func(config map[string]string) (easykv.ReadWatcher, error)) error {
+	if os.Getenv(Handshake.MagicCookieKey) != Handshake.MagicCookieValue {
+		return ErrNotPlugin
+	}
+
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         goplugin.PluginSet{pluginName: &backendPlugin{open: open}},
+		GRPCServer:      goplugin.DefaultGRPCServer,
+		// the log of the plugin ends up on the stderr of the host
+		Logger: hclog.New(&hclog.LoggerOptions{Level: hclog.Warn, Output: os.Stderr, JSONFormat: true}),
+	})
+	return nil
+}
+
+// server is the plugin side of a plugin.
+type server struct {
+	proto.UnimplementedBackendServer
+	open func(config map[string]string) (easykv.ReadWatcher, error)
+
+	mu sync.Mutex
+	rw easykv.ReadWatcher
+}
+
+func (s *server) backend() (easykv.ReadWatcher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rw == nil {
+		return nil, errors.New("plugin backend isn't open")
+	}
+	return s.rw, nil
+}
+
+// Open opens the backend with its config.
+func (s *server) Open(_ context.Context, req *proto.OpenRequest) (*proto.Empty, error) {
+	rw, err := s.open(req.Config)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rw != nil {
+		s.rw.Close()
+	}
+	s.rw = rw
+	return &proto.Empty{}, nil
+}
+
+// GetValues calls GetValues on the backend.
+func (s *server) GetValues(_ context.Context, req *proto.GetValuesRequest) (*proto.GetValuesResponse, error) {
+	rw, err := s.backend()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	vars, err := rw.GetValues(req.Keys)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.GetValuesResponse{Values: vars}, nil
+}
+
+// WatchPrefix calls WatchPrefix on the backend. The watch ends with the call,
+// gRPC cancels ctx if the host cancels the call or goes away.
+func (s *server) WatchPrefix(ctx context.Context, req *proto.WatchPrefixRequest) (*proto.WatchPrefixResponse, error) {
+	rw, err := s.backend()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	index, err := rw.WatchPrefix(ctx, req.Prefix, easykv.WithWaitIndex(req.WaitIndex), easykv.WithKeys(req.Keys))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.WatchPrefixResponse{Index: index}, nil
+}
+
+// Close closes the backend.
+func (s *server) Close(context.Context, *proto.Empty) (*proto.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rw != nil {
+		s.rw.Close()
+		s.rw = nil
+	}
+	return &proto.Empty{}, nil
+}
@@ -0,0 +1,43 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// NoProxy disables proxies when it is passed to ProxyFunc.
+const NoProxy = "none"
+
+// ProxyFunc returns the proxy function of an http.Transport for the proxy URL rawurl.
+// Supported schemes are http, https and socks5. Requests to https endpoints
+// are tunneled through the proxy with CONNECT.
+// If rawurl is empty, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables. If rawurl is NoProxy, no proxy is used.
+func ProxyFunc(rawurl string) (func(*http.Request) (*url.URL, error), error) {
+	switch rawurl {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case NoProxy:
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
@@ -0,0 +1,39 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"net/http"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestProxyFunc(t *C) {
+	req, err := http.NewRequest("GET", "https://vault.example.com/v1/secret/app", nil)
+	t.Assert(err, IsNil)
+
+	for _, rawurl := range []string{"http://proxy:3128", "https://proxy:3129", "socks5://user:pw@proxy:1080"} {
+		proxy, err := ProxyFunc(rawurl)
+		t.Assert(err, IsNil)
+		u, err := proxy(req)
+		t.Assert(err, IsNil)
+		t.Check(u.String(), Equals, rawurl)
+	}
+
+	proxy, err := ProxyFunc("")
+	t.Assert(err, IsNil)
+	t.Check(proxy, NotNil)
+
+	proxy, err = ProxyFunc(NoProxy)
+	t.Assert(err, IsNil)
+	t.Check(proxy, IsNil)
+
+	_, err = ProxyFunc("ftp://proxy")
+	t.Check(err, ErrorMatches, `unsupported proxy scheme "ftp"`)
+}
@@ -0,0 +1,212 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces secrets in redacted strings.
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the length below which values aren't registered as secrets,
+// since redacting short values like "true", "8080" or "admin" would make log lines unreadable.
+const minSecretLength = 8
+
+// RedactOptions configures a Redactor.
+type RedactOptions struct {
+	MaxSecrets int
+	TTL        time.Duration
+}
+
+// RedactOption configures a Redactor.
+type RedactOption func(*RedactOptions)
+
+// WithMaxSecrets sets the maximum number of registered secrets,
+// the least recently added secrets are removed first. Defaults to 1000.
+func WithMaxSecrets(n int) RedactOption {
+	return func(o *RedactOptions) {
+		o.MaxSecrets = n
+	}
+}
+
+// WithSecretTTL sets the time after which a secret that wasn't added again is removed.
+// Defaults to 0, secrets don't expire.
+func WithSecretTTL(ttl time.Duration) RedactOption {
+	return func(o *RedactOptions) {
+		o.TTL = ttl
+	}
+}
+
+// A Redactor removes registered secrets from strings, errors and log lines.
+// Backends create one Redactor per client, so the secrets of a client
+// are only redacted in the log lines and errors of this client.
+type Redactor struct {
+	options RedactOptions
+
+	mu sync.RWMutex
+	// secrets maps the secrets to the time they were added last
+	secrets map[string]time.Time
+	// expires is the time the oldest secret expires, zero without a ttl
+	expires  time.Time
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a new empty Redactor.
+func NewRedactor(opts ...RedactOption) *Redactor {
+	options := RedactOptions{MaxSecrets: 1000}
+	for _, o := range opts {
+		o(&options)
+	}
+	return &Redactor{options: options, secrets: make(map[string]time.Time)}
+}
+
+// Add registers secret values. Values shorter than 8 characters are ignored.
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, v := range values {
+		if len(v) < minSecretLength {
+			continue
+		}
+		if _, ok := r.secrets[v]; !ok {
+			r.replacer = nil
+		}
+		r.secrets[v] = now
+		if r.options.TTL > 0 && r.expires.IsZero() {
+			r.expires = now.Add(r.options.TTL)
+		}
+	}
+
+	for r.options.MaxSecrets > 0 && len(r.secrets) > r.options.MaxSecrets {
+		oldest, added := "", now
+		for v, t := range r.secrets {
+			if oldest == "" || t.Before(added) {
+				oldest, added = v, t
+			}
+		}
+		delete(r.secrets, oldest)
+		r.replacer = nil
+	}
+}
+
+// expire removes the secrets whose ttl is over.
+func (r *Redactor) expire(now time.Time) {
+	if r.expires.IsZero() || now.Before(r.expires) {
+		return
+	}
+	r.expires = time.Time{}
+	for v, t := range r.secrets {
+		expires := t.Add(r.options.TTL)
+		if !now.Before(expires) {
+			delete(r.secrets, v)
+			r.replacer = nil
+		} else if r.expires.IsZero() || expires.Before(r.expires) {
+			r.expires = expires
+		}
+	}
+}
+
+// Reset removes all registered secrets.
+func (r *Redactor) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = make(map[string]time.Time)
+	r.expires = time.Time{}
+	r.replacer = nil
+}
+
+// Redact replaces all registered secrets in s.
+func (r *Redactor) Redact(s string) string {
+	now := time.Now()
+	r.mu.RLock()
+	replacer, expires := r.replacer, r.expires
+	r.mu.RUnlock()
+
+	if replacer == nil || (!expires.IsZero() && !now.Before(expires)) {
+		r.mu.Lock()
+		r.expire(now)
+		if r.replacer == nil {
+			secrets := make([]string, 0, len(r.secrets))
+			for v := range r.secrets {
+				secrets = append(secrets, v)
+			}
+			// longer secrets first, so that a secret containing another one is replaced as a whole
+			sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+			pairs := make([]string, 0, 2*len(secrets))
+			for _, v := range secrets {
+				pairs = append(pairs, v, Redacted)
+			}
+			r.replacer = strings.NewReplacer(pairs...)
+		}
+		replacer = r.replacer
+		r.mu.Unlock()
+	}
+	return replacer.Replace(s)
+}
+
+// RedactError returns err with all registered secrets replaced in its message.
+// It returns nil if err is nil and err itself if it contains no secret.
+func (r *Redactor) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if redacted := r.Redact(msg); redacted != msg {
+		return errors.New(redacted)
+	}
+	return err
+}
+
+// Logger returns a Logger that redacts the message and all string and error arguments
+// before passing them to l.
+func (r *Redactor) Logger(l Logger) Logger {
+	return redactLogger{r, LoggerOrDiscard(l)}
+}
+
+type redactLogger struct {
+	r *Redactor
+	l Logger
+}
+
+func (l redactLogger) args(args []interface{}) []interface{} {
+	redacted := make([]interface{}, len(args))
+	for i, a := range args {
+		switch a := a.(type) {
+		case string:
+			redacted[i] = l.r.Redact(a)
+		case error:
+			redacted[i] = l.r.RedactError(a)
+		default:
+			redacted[i] = a
+		}
+	}
+	return redacted
+}
+
+func (l redactLogger) Debug(msg string, args ...interface{}) {
+	l.l.Debug(l.r.Redact(msg), l.args(args)...)
+}
+
+func (l redactLogger) Info(msg string, args ...interface{}) {
+	l.l.Info(l.r.Redact(msg), l.args(args)...)
+}
+
+func (l redactLogger) Warn(msg string, args ...interface{}) {
+	l.l.Warn(l.r.Redact(msg), l.args(args)...)
+}
+
+func (l redactLogger) Error(msg string, args ...interface{}) {
+	l.l.Error(l.r.Redact(msg), l.args(args)...)
+}
@@ -0,0 +1,65 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type recordLogger struct {
+	discardLogger
+	lines []string
+}
+
+func (l *recordLogger) Warn(msg string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintln(append([]interface{}{msg}, args...)...))
+}
+
+func (s *FilterSuite) TestRedactor(t *C) {
+	r := NewRedactor()
+	r.Add("hunter222", "hunter2222", "admin")
+
+	t.Check(r.Redact("password hunter2222, old hunter222, admin"), Equals, "password [REDACTED], old [REDACTED], admin")
+
+	err := errors.New("login with hunter222 failed")
+	t.Check(r.RedactError(err), ErrorMatches, `login with \[REDACTED\] failed`)
+	plain := errors.New("no secret")
+	t.Check(r.RedactError(plain), Equals, plain)
+	t.Check(r.RedactError(nil), IsNil)
+
+	l := &recordLogger{}
+	r.Logger(l).Warn("auth hunter222", "err", err, "n", 1)
+	t.Check(l.lines, DeepEquals, []string{"auth [REDACTED] err login with [REDACTED] failed n 1\n"})
+
+	r.Reset()
+	t.Check(r.Redact("hunter222"), Equals, "hunter222")
+}
+
+func (s *FilterSuite) TestRedactorLimits(t *C) {
+	r := NewRedactor(WithMaxSecrets(2))
+	r.Add("secret-1")
+	r.Add("secret-2")
+	r.Add("secret-1")
+	r.Add("secret-3")
+	t.Check(r.Redact("secret-1 secret-2 secret-3"), Equals, "[REDACTED] secret-2 [REDACTED]")
+
+	r = NewRedactor(WithSecretTTL(time.Hour))
+	r.Add("secret-1")
+	t.Check(r.Redact("secret-1"), Equals, Redacted)
+	r.mu.Lock()
+	r.secrets["secret-1"] = time.Now().Add(-2 * time.Hour)
+	r.expires = time.Now().Add(-time.Hour)
+	r.mu.Unlock()
+	r.Add("secret-2")
+	t.Check(r.Redact("secret-1 secret-2"), Equals, "secret-1 [REDACTED]")
+}
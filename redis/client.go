@@ -17,155 +17,697 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
 	"github.com/garyburd/redigo/redis"
 )
 
+// defaultMaxIdle is the number of idle connections kept by a pool without a Size.
+const defaultMaxIdle = 3
+
+// defaultTimeout is the default connect, read and write timeout.
+const defaultTimeout = time.Second
+
+var errConnectionLost = errors.New("redis connection lost")
+
 // Client is a wrapper around the redis client
 type Client struct {
-	client   redis.Conn
-	machines []string
-	password string
-	db       int
+	mu          sync.Mutex
+	pool        *redis.Pool
+	poolOptions PoolOptions
+	// clusterConn is shared in cluster mode instead of the pool, it holds a connection to every master.
+	clusterConn *clusterConn
+	machines    []string
+	username    string
+	password    string
+	passwordRef credentials.Ref
+	db          int
+	tls         easykv.TLSOptions
+	useTLS      bool
+	logger      easykv.Logger
+	stopWatch   context.CancelFunc
+
+	// sentinelMaster is the name of the master, the machines are sentinels if it is set.
+	sentinelMaster string
+	// cluster makes the machines seed nodes of a Redis Cluster.
+	cluster bool
+	// scanCount is the COUNT of the SCAN commands.
+	scanCount int
+	// batchSize is the number of keys read in a single round trip.
+	batchSize int
+	// iam generates the passwords of the IAM authentication.
+	iam *iamAuth
+	// replicaReads sends GetValues to the replicas, replicas are their addresses.
+	// replicaPool and replicaCluster are the counterparts of pool and clusterConn for these reads.
+	replicaReads   bool
+	replicas       []string
+	replicaPool    *redis.Pool
+	replicaCluster *clusterConn
+	// clientCache enables the tracker, the client-side cache of GetValues.
+	clientCache bool
+	tracker     *tracker
 }
 
 // Iterate through `machines`, trying to connect to each in turn.
 // Returns the first successful connection or the last error encountered.
 // Assumes that `machines` is non-empty.
-func tryConnect(machines []string, db int, password string) (redis.Conn, error) {
-	var err error
-	for _, address := range machines {
-		var conn redis.Conn
+// With a sentinel master, the sentinels are asked for the address of the master instead.
+// In cluster mode, the machines are the seed nodes of a cluster connection.
+func (c *Client) tryConnect() (redis.Conn, error) {
+	return c.connectTo(false)
+}
+
+// tryConnectReplica connects to a replica, see WithReplicaReads.
+func (c *Client) tryConnectReplica() (redis.Conn, error) {
+	return c.connectTo(true)
+}
+
+// connectTo connects to the master, or to a replica if replica is set. The replicas
+// are tried in a random order, so the connections are spread across them.
+func (c *Client) connectTo(replica bool) (redis.Conn, error) {
+	machines, db, logger := c.machines, c.db, c.logger
+
+	username := c.username
+	password, err := c.passwordRef.Resolve(c.password)
+	if err != nil {
+		return nil, err
+	}
+	if c.iam != nil {
+		username = c.iam.options.UserID
+		if password, err = c.iam.password(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	var tlsops []redis.DialOption
+	if c.tls.Enabled() || c.useTLS || c.iam != nil {
+		tlsConfig, err := c.tls.Config()
+		if err != nil {
+			return nil, err
+		}
+		tlsops = append(tlsops,
+			redis.DialUseTLS(true),
+			redis.DialTLSConfig(tlsConfig),
+			redis.DialTLSSkipVerify(c.tls.InsecureSkipVerify),
+		)
+	}
+
+	switch {
+	case c.sentinelMaster != "" && replica:
+		if machines, err = c.replicaAddrs(tlsops); err != nil {
+			return nil, err
+		}
+	case c.sentinelMaster != "":
+		master, err := c.masterAddr(tlsops)
+		if err != nil {
+			return nil, err
+		}
+		machines = []string{master}
+	case replica && !c.cluster:
+		machines = c.replicas
+	}
+	if replica && !c.cluster {
+		machines = shuffled(machines)
+	}
+
+	// dial connects to a single server, address is a unix socket if it exists as a file
+	dial := func(address string) (redis.Conn, error) {
 		network := "tcp"
-		if _, err = os.Stat(address); err == nil {
+		if _, err := os.Stat(address); err == nil {
 			network = "unix"
 		}
 
-		dialops := []redis.DialOption{
-			redis.DialConnectTimeout(time.Second),
-			redis.DialReadTimeout(time.Second),
-			redis.DialWriteTimeout(time.Second),
-			redis.DialDatabase(db),
+		dialops := c.poolOptions.timeouts()
+
+		// an ACL user is authenticated after the dial, before the database is selected
+		if username == "" {
+			dialops = append(dialops, redis.DialDatabase(db))
+			if password != "" {
+				dialops = append(dialops, redis.DialPassword(password))
+			}
+		}
+
+		if network == "tcp" {
+			dialops = append(dialops, tlsops...)
+		}
+
+		conn, err := redis.Dial(network, address, dialops...)
+		if err != nil {
+			return nil, classify(err)
+		}
+		if username != "" {
+			if err := auth(conn, username, password, db); err != nil {
+				conn.Close()
+				return nil, err
+			}
 		}
+		return conn, nil
+	}
 
-		if password != "" {
-			dialops = append(dialops, redis.DialPassword(password))
+	if c.cluster {
+		conn, err := newClusterConn(machines, dial, replica, logger)
+		if err != nil {
+			return nil, err
 		}
+		logger.Debug("connected to redis cluster", "seeds", machines)
+		return conn, nil
+	}
 
-		conn, err = redis.Dial(network, address, dialops...)
+	for _, address := range machines {
+		var conn redis.Conn
+		conn, err = dial(address)
 
 		if err != nil {
+			logger.Warn("can't connect to redis", "address", address, "err", err)
 			continue
 		}
+		if c.sentinelMaster != "" && !replica && !isMaster(conn) {
+			// the sentinels haven't noticed a failover yet
+			conn.Close()
+			err = fmt.Errorf("redis %s is not the master %s", address, c.sentinelMaster)
+			logger.Warn("can't connect to redis", "address", address, "err", err)
+			continue
+		}
+		logger.Debug("connected to redis", "address", address, "db", db, "replica", replica)
 		return conn, nil
 	}
+	if err == nil {
+		err = errors.New("no redis addresses given")
+	}
 	return nil, err
 }
 
-// Retrieves a connected redis client from the client wrapper.
-// Existing connections will be tested with a PING command before being returned. Tries to reconnect once if necessary.
-// Returns the established redis connection or the error encountered.
-func (c *Client) connectedClient() (redis.Conn, error) {
-	if c.client != nil {
-		resp, err := c.client.Do("PING")
-		if (err != nil && err == redis.ErrNil) || resp != "PONG" {
-			c.client = nil
+// shuffled returns the addresses in a random order.
+func shuffled(addrs []string) []string {
+	s := make([]string, len(addrs))
+	for i, j := range rand.Perm(len(addrs)) {
+		s[i] = addrs[j]
+	}
+	return s
+}
+
+// auth authenticates the ACL user of conn with AUTH <username> <password>,
+// which redigo doesn't support, and selects the database.
+func auth(conn redis.Conn, username, password string, db int) error {
+	if _, err := conn.Do("AUTH", username, password); err != nil {
+		return classify(err)
+	}
+	if db != 0 {
+		if _, err := conn.Do("SELECT", db); err != nil {
+			return classify(err)
+		}
+	}
+	return nil
+}
+
+// classify wraps rejected credentials and missing ACL permissions in an *easykv.BackendError.
+func classify(err error) error {
+	e, ok := err.(redis.Error)
+	if !ok {
+		return err
+	}
+	msg := string(e)
+	switch {
+	case strings.HasPrefix(msg, "WRONGPASS"), strings.HasPrefix(msg, "NOAUTH"), strings.Contains(msg, "invalid password"):
+		return &easykv.BackendError{Kind: easykv.ErrAuthentication, Err: err}
+	case strings.HasPrefix(msg, "NOPERM"):
+		return &easykv.BackendError{Kind: easykv.ErrPermissionDenied, Err: err}
+	}
+	return err
+}
+
+// masterAddr asks the sentinels in turn for the address of the current master.
+func (c *Client) masterAddr(tlsops []redis.DialOption) (string, error) {
+	addrs, err := c.askSentinels(tlsops, "the master "+c.sentinelMaster, func(conn redis.Conn) ([]string, error) {
+		addr, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", c.sentinelMaster))
+		if err != nil || len(addr) != 2 {
+			return nil, err
 		}
+		return []string{net.JoinHostPort(addr[0], addr[1])}, nil
+	})
+	if err != nil {
+		return "", err
 	}
+	return addrs[0], nil
+}
 
-	// Existing client could have been deleted by previous block
-	if c.client == nil {
-		var err error
-		c.client, err = tryConnect(c.machines, c.db, c.password)
+// replicaAddrs asks the sentinels in turn for the addresses of the replicas that are up.
+func (c *Client) replicaAddrs(tlsops []redis.DialOption) ([]string, error) {
+	return c.askSentinels(tlsops, "replicas of the master "+c.sentinelMaster, func(conn redis.Conn) ([]string, error) {
+		replicas, err := redis.Values(conn.Do("SENTINEL", "replicas", c.sentinelMaster))
 		if err != nil {
 			return nil, err
 		}
+		var addrs []string
+		for _, r := range replicas {
+			info, err := redis.StringMap(r, nil)
+			if err != nil {
+				return nil, err
+			}
+			if strings.Contains(info["flags"], "down") || strings.Contains(info["flags"], "disconnected") {
+				continue
+			}
+			addrs = append(addrs, net.JoinHostPort(info["ip"], info["port"]))
+		}
+		return addrs, nil
+	})
+}
+
+// askSentinels calls ask with a connection to every sentinel in turn and returns the
+// first addresses it finds. what describes the addresses in the errors.
+func (c *Client) askSentinels(tlsops []redis.DialOption, what string, ask func(redis.Conn) ([]string, error)) ([]string, error) {
+	var err error
+	for _, sentinel := range c.machines {
+		dialops := append(c.poolOptions.timeouts(), tlsops...)
+
+		var conn redis.Conn
+		if conn, err = redis.Dial("tcp", sentinel, dialops...); err != nil {
+			c.logger.Warn("can't connect to redis sentinel", "address", sentinel, "err", err)
+			continue
+		}
+		var addrs []string
+		addrs, err = ask(conn)
+		conn.Close()
+		if err == nil && len(addrs) == 0 {
+			err = redis.ErrNil
+		}
+		if err == redis.ErrNil {
+			err = fmt.Errorf("redis sentinel %s doesn't know %s", sentinel, what)
+		}
+		if err != nil {
+			c.logger.Warn("can't get the redis addresses from the sentinel", "address", sentinel, "err", err)
+			continue
+		}
+		return addrs, nil
+	}
+	return nil, err
+}
+
+// isMaster checks the ROLE of the redis server of conn.
+func isMaster(conn redis.Conn) bool {
+	role, err := redis.Values(conn.Do("ROLE"))
+	if err != nil || len(role) == 0 {
+		return false
+	}
+	name, _ := redis.String(role[0], nil)
+	return name == "master"
+}
+
+// newPool returns a connection pool to the master or the replicas for the current options. c.mu must be held.
+// Connections are tested with a PING before they're borrowed, with a sentinel
+// the role of the master is checked, too.
+func (c *Client) newPool(replica bool) *redis.Pool {
+	o, sentinel, logger := c.poolOptions, c.sentinelMaster, c.logger
+	maxIdle := o.Size
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdle
+	}
+	if maxIdle < o.MinIdle {
+		maxIdle = o.MinIdle
 	}
 
-	return c.client, nil
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.connectTo(replica)
+		},
+		TestOnBorrow: func(conn redis.Conn, _ time.Time) error {
+			resp, err := redis.String(conn.Do("PING"))
+			if err != nil || resp != "PONG" {
+				logger.Info("redis connection lost, reconnecting", "err", err)
+				return errConnectionLost
+			}
+			if sentinel != "" && !replica && !isMaster(conn) {
+				logger.Info("redis master failed over, reconnecting")
+				return errConnectionLost
+			}
+			return nil
+		},
+		MaxIdle:     maxIdle,
+		MaxActive:   o.Size,
+		IdleTimeout: o.IdleTimeout,
+		Wait:        o.Size > 0,
+	}
+}
+
+// connect opens the MinIdle connections of the pool, or the cluster connection in cluster mode.
+// The first error is returned, so it also checks that redis can be reached.
+func (c *Client) connect() error {
+	c.mu.Lock()
+	minIdle, cluster := c.poolOptions.MinIdle, c.cluster
+	c.mu.Unlock()
+
+	if cluster || minIdle < 1 {
+		minIdle = 1
+	}
+	conns := make([]redis.Conn, 0, minIdle)
+	defer func() {
+		for _, conn := range conns {
+			release(conn)
+		}
+	}()
+	for i := 0; i < minIdle; i++ {
+		conn, err := c.connectedClient()
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+	}
+	return nil
+}
+
+// connectedClient returns a connection to the master, the caller has to release it.
+func (c *Client) connectedClient() (redis.Conn, error) {
+	return c.get(false)
+}
+
+// readClient returns a connection for reads, the caller has to release it.
+// With WithReplicaReads, it's a connection to a replica, or to the master if no replica can be reached.
+func (c *Client) readClient() (redis.Conn, error) {
+	c.mu.Lock()
+	replicaReads := c.replicaReads
+	c.mu.Unlock()
+	if !replicaReads {
+		return c.connectedClient()
+	}
+
+	conn, err := c.get(true)
+	if err != nil {
+		c.logger.Warn("can't connect to a redis replica, reading from the master", "err", err)
+		return c.connectedClient()
+	}
+	return conn, nil
+}
+
+// get returns a connection from the pool of the master or the replicas.
+func (c *Client) get(replica bool) (redis.Conn, error) {
+	c.mu.Lock()
+	if c.cluster {
+		defer c.mu.Unlock()
+		shared := &c.clusterConn
+		if replica {
+			shared = &c.replicaCluster
+		}
+		if *shared == nil {
+			conn, err := c.connectTo(replica)
+			if err != nil {
+				return nil, err
+			}
+			*shared = conn.(*clusterConn)
+		}
+		return *shared, nil
+	}
+	pool := c.pool
+	if replica {
+		pool = c.replicaPool
+	}
+	c.mu.Unlock()
+
+	conn := pool.Get()
+	if err := conn.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// release returns conn to the pool. The shared cluster connection is kept open.
+func release(conn redis.Conn) {
+	if _, ok := conn.(*clusterConn); !ok {
+		conn.Close()
+	}
+}
+
+// closeConns closes the pools and the cluster connections. c.mu must be held.
+func (c *Client) closeConns() {
+	for _, pool := range []*redis.Pool{c.pool, c.replicaPool} {
+		if pool != nil {
+			pool.Close()
+		}
+	}
+	for _, conn := range []*clusterConn{c.clusterConn, c.replicaCluster} {
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	c.clusterConn, c.replicaCluster = nil, nil
 }
 
 // New returns an *redis.Client with a connection to named machines.
 // It returns an error if a connection to the cluster cannot be made.
 func New(machines []string, opts ...Option) (*Client, error) {
-	var err error
-	var c Client
+	c := Client{logger: easykv.DiscardLogger}
 	for _, o := range opts {
 		o(&c)
 	}
 	c.machines = machines
+	c.pool, c.replicaPool = c.newPool(false), c.newPool(true)
+	if err := c.connect(); err != nil {
+		c.closeConns()
+		return &c, err
+	}
+	if c.clientCache {
+		if c.cluster {
+			c.logger.Warn("the redis client-side cache isn't supported in cluster mode")
+		} else {
+			c.tracker = newTracker(func() (redis.Conn, error) {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				return c.tryConnect()
+			}, c.logger)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopWatch = cancel
+	go credentials.WatchRefs(ctx, func() error { return c.Reload() }, []credentials.Ref{c.passwordRef},
+		credentials.WithErrorHandler(func(err error) {
+			c.logger.Warn("can't reload redis credentials", "err", err)
+		}),
+	)
+	return &c, nil
+}
+
+// Reload applies opts to the client and replaces its connections.
+// This allows to rotate the password of a running client.
+// If the reconnect fails, the next command tries to connect again.
+func (c *Client) Reload(opts ...Option) error {
+	c.mu.Lock()
+	for _, o := range opts {
+		o(c)
+	}
+	c.closeConns()
+	c.pool, c.replicaPool = c.newPool(false), c.newPool(true)
+	logger := c.logger
+	c.mu.Unlock()
+	if c.tracker != nil {
+		// reconnects with the new options on the next read
+		c.tracker.close()
+	}
 
-	c.client, err = tryConnect(c.machines, c.db, c.password)
-	return &c, err
+	err := c.connect()
+	if err == nil {
+		logger.Info("reloaded redis client")
+	}
+	return err
 }
 
-// Close closes the redis client connection.
+// Close closes the redis connections and stops watching the referenced password file.
 func (c *Client) Close() {
-	if c.client != nil {
-		c.client.Close()
+	if c.stopWatch != nil {
+		c.stopWatch()
 	}
+	if c.tracker != nil {
+		c.tracker.close()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeConns()
 }
 
 // GetValues is used to lookup all keys with a prefix.
 // Several prefixes can be specified in the keys array.
-// The redis SCAN operation is, for performance reasons, limited to 1000 results.
+// The fields of hashes are returned as nested keys, e.g. /app/config/port, the
+// elements of lists, sets and sorted sets with their index, e.g. /app/hosts/0.
+// The keys below a prefix are enumerated with SCAN, see WithScanCount,
+// and read in batches, see WithBatchSize. With WithClientCache, the values are cached.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
-	// Ensure we have a connected redis client
-	rClient, err := c.connectedClient()
-	if err != nil && err != redis.ErrNil {
+	return c.getValues(keys, nil, true)
+}
+
+// GetValuesExplain is used to lookup all keys with a prefix.
+// It additionally returns all commands that were sent to redis.
+func (c *Client) GetValuesExplain(keys []string) (map[string]string, []easykv.Operation, error) {
+	var tr easykv.Trace
+	vars, err := c.getValues(keys, &tr, true)
+	return vars, tr.Operations(), err
+}
+
+// do sends a command to redis and records it in tr.
+func do(conn redis.Conn, tr *easykv.Trace, key string, cmd string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	reply, err := conn.Do(cmd, args...)
+	tr.Record(cmd, key, start, err)
+	return reply, err
+}
+
+// defaultScanCount is the default COUNT of the SCAN commands.
+const defaultScanCount = 1000
+
+// scan returns all keys matching pattern. It iterates with SCAN, so the server isn't blocked
+// like with KEYS, count is the amount of work of a single SCAN. In a cluster, every master is scanned.
+func scan(conn redis.Conn, tr *easykv.Trace, pattern string, count int) ([]string, error) {
+	if cluster, ok := conn.(*clusterConn); ok {
+		var found []string
+		err := cluster.eachNode(func(conn redis.Conn) error {
+			keys, err := scan(conn, tr, pattern, count)
+			found = append(found, keys...)
+			return err
+		})
+		return found, err
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	var found []string
+	cursor := "0"
+	for {
+		values, err := redis.Values(do(conn, tr, pattern, "SCAN", cursor, "MATCH", pattern, "COUNT", count))
+		if err != nil {
+			return found, err
+		}
+		if len(values) != 2 {
+			return found, fmt.Errorf("invalid SCAN reply for %s", pattern)
+		}
+		// the cursor is an unsigned 64 bit integer, it's passed on as it is
+		if cursor, err = redis.String(values[0], nil); err != nil {
+			return found, err
+		}
+		items, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return found, err
+		}
+		found = append(found, items...)
+		if cursor == "0" {
+			return found, nil
+		}
+	}
+}
+
+// getValues reads the prefixes of keys. If replica is set, they're read from the replicas,
+// unless the client-side cache is used, its invalidations are reported by the master.
+func (c *Client) getValues(keys []string, tr *easykv.Trace, replica bool) (map[string]string, error) {
+	connect := c.connectedClient
+	if replica && c.tracker == nil {
+		connect = c.readClient
+	}
+	rClient, err := connect()
+	if err != nil {
 		return nil, err
 	}
+	defer release(rClient)
 
 	vars := make(map[string]string)
 	for _, key := range keys {
 		key = strings.Replace(key, "/*", "", -1)
-		value, err := redis.String(rClient.Do("GET", key))
-		if err == nil {
-			vars[key] = value
+		if c.tracker == nil {
+			if err := c.readPrefix(rClient, tr, key, vars); err != nil {
+				return vars, err
+			}
 			continue
 		}
 
-		if err != redis.ErrNil {
+		values, err := c.tracker.get(key, func() (map[string]string, error) {
+			values := make(map[string]string)
+			return values, c.readPrefix(rClient, tr, key, values)
+		})
+		if err != nil {
 			return vars, err
 		}
+		for k, v := range values {
+			vars[k] = v
+		}
+	}
+	return vars, nil
+}
 
-		if key == "/" {
-			key = "/*"
-		} else {
-			key = fmt.Sprintf("%s/*", key)
+// readPrefix adds the value of key and the values of the keys below it to vars.
+func (c *Client) readPrefix(conn redis.Conn, tr *easykv.Trace, key string, vars map[string]string) error {
+	err := readKey(conn, tr, key, vars)
+	if err != redis.ErrNil {
+		return err
+	}
+
+	if key == "/" {
+		key = "/*"
+	} else {
+		key = fmt.Sprintf("%s/*", key)
+	}
+
+	found, err := scan(conn, tr, key, c.scanCount)
+	if err != nil {
+		return err
+	}
+	return c.readKeys(conn, tr, key, found, vars)
+}
+
+// SetValues writes all key-value pairs to redis.
+func (c *Client) SetValues(values map[string]string) error {
+	rClient, err := c.connectedClient()
+	if err != nil {
+		return err
+	}
+	defer release(rClient)
+	for k, v := range values {
+		if _, err := rClient.Do("SET", k, v); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		idx := 0
-		for {
-			values, err := redis.Values(rClient.Do("SCAN", idx, "MATCH", key, "COUNT", "1000"))
-			if err != nil && err != redis.ErrNil {
-				return vars, err
-			}
-			idx, _ = redis.Int(values[0], nil)
-			items, _ := redis.Strings(values[1], nil)
-			for _, item := range items {
-				var newKey string
-				if newKey, err = redis.String(item, nil); err != nil {
-					return vars, err
-				}
-				if value, err = redis.String(rClient.Do("GET", newKey)); err == nil {
-					vars[newKey] = value
-				}
-			}
-			if idx == 0 {
-				break
-			}
+// DeleteValues deletes all given keys from redis.
+func (c *Client) DeleteValues(keys []string) error {
+	rClient, err := c.connectedClient()
+	if err != nil {
+		return err
+	}
+	defer release(rClient)
+	for _, k := range keys {
+		if _, err := rClient.Do("DEL", k); err != nil {
+			return err
 		}
 	}
-	return vars, nil
+	return nil
 }
 
-// WatchPrefix is not yet implemented.
-func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
-	return 0, easykv.ErrWatchNotSupported
+// GetValue returns the value of a single key or of a field of a hash, e.g. /app/config/port.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	rClient, err := c.connectedClient()
+	if err != nil {
+		return "", err
+	}
+	defer release(rClient)
+	value, err := redis.String(rClient.Do("GET", key))
+	if err == redis.ErrNil {
+		// the key may be the field of a hash
+		if i := strings.LastIndex(key, "/"); i > 0 {
+			value, err = redis.String(rClient.Do("HGET", key[:i], key[i+1:]))
+		}
+	}
+	if err == redis.ErrNil || isWrongType(err) {
+		return "", easykv.ErrKeyNotFound
+	}
+	return value, err
 }
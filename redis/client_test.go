@@ -9,9 +9,20 @@
 package redis
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/conformance"
 	"github.com/HeavyHorst/easykv/testutils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/garyburd/redigo/redis"
 
 	. "gopkg.in/check.v1"
 )
@@ -29,18 +40,722 @@ func (s *FilterSuite) TestGetValues(t *C) {
 		t.Error(err)
 	}
 
-	c.client.Do("SET", "/premtest/database/url", "www.google.de")
-	c.client.Do("SET", "/premtest/database/user", "Boris")
-	c.client.Do("SET", "/remtest/database/hosts/192.168.0.1", "test1")
-	c.client.Do("SET", "/remtest/database/hosts/192.168.0.2", "test2")
+	c.SetValues(map[string]string{
+		"/premtest/database/url":              "www.google.de",
+		"/premtest/database/user":             "Boris",
+		"/remtest/database/hosts/192.168.0.1": "test1",
+		"/remtest/database/hosts/192.168.0.2": "test2",
+	})
 
 	testutils.GetValues(t, c)
 }
 
 func (s *FilterSuite) TestWatchPrefix(t *C) {
 	c, err := New([]string{"localhost:6379"})
-	if err != nil {
-		t.Error(err)
+	t.Assert(err, IsNil)
+	defer c.Close()
+	c.SetValues(map[string]string{"/watchtest/app/name": "easykv"})
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		c.SetValues(map[string]string{"/watchtest/app/name": "changed"})
+	}()
+	index := testutils.WatchPrefix(context.Background(), t, c, "/watchtest", []string{"/watchtest/app"})
+	t.Check(index, Not(Equals), uint64(0))
+}
+
+func (s *FilterSuite) TestWatchPrefixPolling(t *C) {
+	// miniredis has no CONFIG command, the watch falls back to polling
+	m, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer m.Close()
+	m.Set("/app/name", "easykv")
+	m.Set("/other/name", "easykv")
+
+	c, err := New([]string{m.Addr()})
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		m.Set("/app/other", "ignored")
+		time.Sleep(100 * time.Millisecond)
+		m.Set("/app/name", "changed")
+	}()
+	index, err := c.WatchPrefix(context.Background(), "/app", easykv.WithKeys([]string{"/app/name"}),
+		easykv.WithInterval(20*time.Millisecond))
+	t.Assert(err, IsNil)
+	t.Check(m.Exists("/app/other"), Equals, true)
+	want, err := c.fingerprint("/app", []string{"/app/name"})
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, want)
+
+	// an outdated WaitIndex returns immediately
+	index, err = c.WatchPrefix(context.Background(), "/app", easykv.WithWaitIndex(1))
+	t.Assert(err, IsNil)
+	t.Check(index, Not(Equals), uint64(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.WatchPrefix(ctx, "/app", easykv.WithInterval(20*time.Millisecond))
+	t.Check(err, Equals, easykv.ErrWatchCanceled)
+}
+
+func (s *FilterSuite) TestEnableNotifications(t *C) {
+	srv, err := server.NewServer("127.0.0.1:0")
+	t.Assert(err, IsNil)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	events := "Ex"
+	srv.Register("CONFIG", func(p *server.Peer, cmd string, args []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.ToUpper(args[0]) == "SET" {
+			events = args[2]
+			p.WriteOK()
+			return
+		}
+		p.WriteStrings([]string{"notify-keyspace-events", events})
+	})
+
+	conn, err := redis.Dial("tcp", srv.Addr().String())
+	t.Assert(err, IsNil)
+	defer conn.Close()
+	t.Assert(enableNotifications(conn), IsNil)
+	t.Check(events, Equals, "ExKg$lshze")
+
+	events = "AK"
+	t.Assert(enableNotifications(conn), IsNil)
+	t.Check(events, Equals, "AK")
+
+	t.Check(notified("/app/db", []string{"/app/db/port"}), Equals, true)
+	t.Check(notified("/app/dbx", []string{"/app/db/port"}), Equals, false)
+	t.Check(escapePattern(`/app/*[a]?\`), Equals, `/app/\*\[a\]\?\\`)
+}
+
+func (s *FilterSuite) TestReload(t *C) {
+	c, err := New([]string{"localhost:6379"})
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	c.SetValues(map[string]string{"/reloadtest": "db0"})
+	t.Assert(c.Reload(WithDatabase(1)), IsNil)
+	c.SetValues(map[string]string{"/reloadtest": "db1"})
+
+	vars, err := c.GetValues([]string{"/reloadtest"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/reloadtest": "db1"})
+}
+
+func (s *FilterSuite) TestPool(t *C) {
+	m, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer m.Close()
+	m.Select(2)
+	m.Set("/app/name", "easykv")
+
+	c, err := New([]string{m.Addr()}, WithDatabase(2), WithPool(PoolOptions{
+		Size:        2,
+		MinIdle:     2,
+		ReadTimeout: 500 * time.Millisecond,
+	}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Check(c.pool.IdleCount(), Equals, 2)
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "easykv"})
+
+	// the pool is full, a third connection waits for a free one
+	c1, err := c.connectedClient()
+	t.Assert(err, IsNil)
+	c2, err := c.connectedClient()
+	t.Assert(err, IsNil)
+	t.Check(c.pool.ActiveCount(), Equals, 2)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		c1.Close()
+	}()
+	v, err := c.GetValue(context.Background(), "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "easykv")
+	c2.Close()
+
+	// connections that were closed by the server are replaced
+	m.Restart()
+	v, err = c.GetValue(context.Background(), "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "easykv")
+}
+
+// fakeSentinel returns a miniredis that answers SENTINEL get-master-addr-by-name
+// with the address of master.
+func fakeSentinel(t *C, master func() *miniredis.Miniredis) *miniredis.Miniredis {
+	sentinel, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	sentinel.Server().Register("SENTINEL", func(p *server.Peer, cmd string, args []string) {
+		if len(args) != 2 || args[1] != "mymaster" {
+			p.WriteNull()
+			return
+		}
+		host, port, _ := net.SplitHostPort(master().Addr())
+		p.WriteStrings([]string{host, port})
+	})
+	return sentinel
+}
+
+// withRole makes m answer the ROLE command with role.
+func withRole(t *C, m *miniredis.Miniredis, role *string, mu *sync.Mutex) {
+	t.Assert(m.Server().Register("ROLE", func(p *server.Peer, cmd string, args []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		p.WriteLen(1)
+		p.WriteBulk(*role)
+	}), IsNil)
+}
+
+func (s *FilterSuite) TestSentinel(t *C) {
+	var mu sync.Mutex
+	roleA, roleB := "master", "slave"
+	a, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer a.Close()
+	b, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer b.Close()
+	withRole(t, a, &roleA, &mu)
+	withRole(t, b, &roleB, &mu)
+	a.Set("/app/name", "a")
+	b.Set("/app/name", "b")
+
+	current := a
+	sentinel := fakeSentinel(t, func() *miniredis.Miniredis {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	})
+	defer sentinel.Close()
+
+	_, err = New([]string{sentinel.Addr()}, WithSentinel("unknown"))
+	t.Check(err, ErrorMatches, ".*doesn't know the master unknown")
+
+	c, err := New([]string{"127.0.0.1:1", sentinel.Addr()}, WithSentinel("mymaster"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	v, err := c.GetValue(context.Background(), "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "a")
+
+	// failover to b
+	mu.Lock()
+	roleA, roleB, current = "slave", "master", b
+	mu.Unlock()
+	v, err = c.GetValue(context.Background(), "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "b")
+}
+
+// fakeCluster is a redis cluster of two masters that split the slots in half.
+type fakeCluster struct {
+	mu    sync.Mutex
+	nodes []*server.Server
+	data  []map[string]string
+	split int // the first slot of the second node
+}
+
+func newFakeCluster(t *C) *fakeCluster {
+	f := &fakeCluster{split: clusterSlots / 2}
+	for i := 0; i < 2; i++ {
+		srv, err := server.NewServer("127.0.0.1:0")
+		t.Assert(err, IsNil)
+		f.nodes = append(f.nodes, srv)
+		f.data = append(f.data, make(map[string]string))
+		f.register(i)
+	}
+	return f
+}
+
+func (f *fakeCluster) close() {
+	for _, n := range f.nodes {
+		n.Close()
+	}
+}
+
+// owner returns the node that serves key.
+func (f *fakeCluster) owner(key string) int {
+	if hashSlot(key) < f.split {
+		return 0
+	}
+	return 1
+}
+
+func (f *fakeCluster) register(i int) {
+	srv := f.nodes[i]
+	srv.Register("PING", func(p *server.Peer, cmd string, args []string) {
+		p.WriteInline("PONG")
+	})
+	srv.Register("CLUSTER", func(p *server.Peer, cmd string, args []string) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		ranges := [][2]int{{0, f.split - 1}, {f.split, clusterSlots - 1}}
+		n := 0
+		for _, r := range ranges {
+			if r[0] <= r[1] {
+				n++
+			}
+		}
+		p.WriteLen(n)
+		for j, r := range ranges {
+			if r[0] > r[1] {
+				continue
+			}
+			p.WriteLen(3)
+			p.WriteInt(r[0])
+			p.WriteInt(r[1])
+			p.WriteLen(2)
+			p.WriteBulk("127.0.0.1")
+			p.WriteInt(f.nodes[j].Addr().Port)
+		}
+	})
+	// moved answers with a MOVED redirect if key isn't served by this node
+	moved := func(p *server.Peer, key string) bool {
+		if o := f.owner(key); o != i {
+			p.WriteError(fmt.Sprintf("MOVED %d %s", hashSlot(key), f.nodes[o].Addr()))
+			return true
+		}
+		return false
+	}
+	srv.Register("GET", func(p *server.Peer, cmd string, args []string) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if moved(p, args[0]) {
+			return
+		}
+		if v, ok := f.data[i][args[0]]; ok {
+			p.WriteBulk(v)
+		} else {
+			p.WriteNull()
+		}
+	})
+	srv.Register("SET", func(p *server.Peer, cmd string, args []string) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if moved(p, args[0]) {
+			return
+		}
+		f.data[i][args[0]] = args[1]
+		p.WriteOK()
+	})
+	srv.Register("SCAN", func(p *server.Peer, cmd string, args []string) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var keys []string
+		for k := range f.data[i] {
+			if strings.HasPrefix(k, strings.TrimSuffix(args[2], "*")) {
+				keys = append(keys, k)
+			}
+		}
+		p.WriteLen(2)
+		p.WriteBulk("0")
+		p.WriteStrings(keys)
+	})
+}
+
+func (s *FilterSuite) TestCluster(t *C) {
+	f := newFakeCluster(t)
+	defer f.close()
+
+	c, err := New([]string{"127.0.0.1:1", f.nodes[1].Addr().String()}, WithCluster())
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	data := map[string]string{"/app/a": "1", "/app/b": "2", "/app/c": "3", "/app/d": "4"}
+	t.Assert(c.SetValues(data), IsNil)
+	f.mu.Lock()
+	t.Check(len(f.data[0]) > 0 && len(f.data[1]) > 0, Equals, true)
+	f.mu.Unlock()
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, data)
+
+	// all slots move to the second node
+	f.mu.Lock()
+	f.split = 0
+	for k, v := range f.data[0] {
+		f.data[1][k] = v
+	}
+	f.data[0] = make(map[string]string)
+	f.mu.Unlock()
+	for k, v := range data {
+		got, err := c.GetValue(context.Background(), k)
+		t.Assert(err, IsNil)
+		t.Check(got, Equals, v)
+	}
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, data)
+}
+
+func (s *FilterSuite) TestScan(t *C) {
+	srv, err := server.NewServer("127.0.0.1:0")
+	t.Assert(err, IsNil)
+	defer srv.Close()
+
+	// the first page ends with the largest cursor redis can return
+	pages := map[string][]string{"0": {"18446744073709551615", "/app/a"}, "18446744073709551615": {"0", "/app/b"}}
+	var counts []string
+	srv.Register("PING", func(p *server.Peer, cmd string, args []string) { p.WriteInline("PONG") })
+	srv.Register("SCAN", func(p *server.Peer, cmd string, args []string) {
+		counts = append(counts, args[4])
+		page := pages[args[0]]
+		p.WriteLen(2)
+		p.WriteBulk(page[0])
+		p.WriteStrings(page[1:])
+	})
+	srv.Register("GET", func(p *server.Peer, cmd string, args []string) {
+		p.WriteNull()
+	})
+	srv.Register("MGET", func(p *server.Peer, cmd string, args []string) {
+		p.WriteLen(len(args))
+		for _, k := range args {
+			p.WriteBulk(strings.TrimPrefix(k, "/app/"))
+		}
+	})
+
+	c, err := New([]string{srv.Addr().String()}, WithScanCount(10))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/a": "a", "/app/b": "b"})
+	t.Check(counts, DeepEquals, []string{"10", "10"})
+}
+
+func (s *FilterSuite) TestHashSlot(t *C) {
+	t.Check(crc16("123456789"), Equals, uint16(0x31c3))
+	t.Check(hashSlot("{user1000}.following"), Equals, hashSlot("{user1000}.followers"))
+	t.Check(hashSlot("foo{}{bar}"), Equals, hashSlot("foo{}{bar}"))
+	t.Check(hashSlot("{}foo"), Not(Equals), hashSlot("foo"))
+}
+
+func (s *FilterSuite) TestTypes(t *C) {
+	m, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer m.Close()
+	m.Set("/app/name", "easykv")
+	m.HSet("/app/db", "host", "localhost", "port", "5432")
+	m.Push("/app/hosts", "b", "a")
+	m.SetAdd("/app/tags", "web", "api")
+	m.ZAdd("/app/ranks", 2, "second")
+	m.ZAdd("/app/ranks", 1, "first")
+
+	c, err := New([]string{m.Addr()})
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/app/name":    "easykv",
+		"/app/db/host": "localhost",
+		"/app/db/port": "5432",
+		"/app/hosts/0": "b",
+		"/app/hosts/1": "a",
+		"/app/tags/0":  "api",
+		"/app/tags/1":  "web",
+		"/app/ranks/0": "first",
+		"/app/ranks/1": "second",
+	})
+
+	vars, err = c.GetValues([]string{"/app/db"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/db/host": "localhost", "/app/db/port": "5432"})
+
+	v, err := c.GetValue(context.Background(), "/app/db/port")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "5432")
+	_, err = c.GetValue(context.Background(), "/app/db/user")
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+	_, err = c.GetValue(context.Background(), "/app/name/x")
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+}
+
+func (s *FilterSuite) TestBatches(t *C) {
+	m, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer m.Close()
+	want := make(map[string]string)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		m.Set("/app/"+k, k)
+		want["/app/"+k] = k
 	}
-	testutils.WatchPrefixError(t, c)
+	m.HSet("/app/h", "field", "value")
+	m.Push("/app/l", "first")
+	want["/app/h/field"] = "value"
+	want["/app/l/0"] = "first"
+
+	c, err := New([]string{m.Addr()}, WithBatchSize(2))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, ops, err := c.GetValuesExplain([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, want)
+	count := make(map[string]int)
+	for _, op := range ops {
+		count[op.Op]++
+	}
+	// 7 keys in batches of 2, the hash and the list are read with a TYPE and a read pipeline each
+	t.Check(count["MGET"], Equals, 4)
+	t.Check(count["PIPELINE"], Equals, 4)
+}
+
+func (s *FilterSuite) TestACLUser(t *C) {
+	m, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer m.Close()
+	m.RequireUserAuth("app", "secret")
+	m.DB(2).Set("/app/name", "easykv")
+
+	c, err := New([]string{m.Addr()}, WithUsername("app"), WithPassword("secret"), WithDatabase(2))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	v, err := c.GetValue(context.Background(), "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "easykv")
+
+	_, err = New([]string{m.Addr()}, WithUsername("app"), WithPassword("wrong"))
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrAuthentication)
+	_, err = New([]string{m.Addr()}, WithPassword("secret"))
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrAuthentication)
+}
+
+func (s *FilterSuite) TestLock(t *C) {
+	c, err := New([]string{"localhost:6379"})
+	t.Assert(err, IsNil)
+	defer c.Close()
+	testutils.Lock(t, c, "/locktest")
+	testutils.LockValues(t, c, "/lockvaluetest")
+}
+
+func (s *FilterSuite) TestConformance(t *C) {
+	// redis matches prefixes as directories
+	conformance.Run(t, func(data map[string]string) (easykv.ReadWatcher, error) {
+		c, err := New([]string{"localhost:6379"})
+		if err != nil {
+			return nil, err
+		}
+		if err := c.SetValues(data); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}, conformance.WithSkip("plain string prefix", "trailing slash"))
+}
+
+// fakeTracking adds CLIENT ID and CLIENT TRACKING to m. The returned function sends an
+// invalidation message for the tracked keys to the connection they're redirected to.
+func fakeTracking(t *C, m *miniredis.Miniredis, prefixes *[]string) func(keys ...string) {
+	var mu sync.Mutex
+	ids := make(map[int]*server.Peer)
+	var redirect *server.Peer
+	t.Assert(m.Server().Register("CLIENT", func(p *server.Peer, cmd string, args []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case len(args) == 1 && strings.ToUpper(args[0]) == "ID":
+			ids[len(ids)+1] = p
+			p.WriteInt(len(ids))
+		case len(args) == 2 && strings.ToUpper(args[1]) == "OFF":
+			*prefixes = nil
+			p.WriteOK()
+		case len(args) > 4 && strings.ToUpper(args[2]) == "REDIRECT":
+			id := 0
+			fmt.Sscan(args[3], &id)
+			redirect = ids[id]
+			for i := 5; i+1 < len(args); i += 2 {
+				*prefixes = append(*prefixes, args[i+1])
+			}
+			p.WriteOK()
+		default:
+			p.WriteError("ERR unsupported CLIENT command")
+		}
+	}), IsNil)
+
+	return func(keys ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		t.Assert(redirect, NotNil)
+		redirect.Block(func(w *server.Writer) {
+			w.WriteLen(3)
+			w.WriteBulk("message")
+			w.WriteBulk(invalidateChannel)
+			w.WriteStrings(keys)
+			w.Flush()
+		})
+	}
+}
+
+func (s *FilterSuite) TestClientCache(t *C) {
+	m, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer m.Close()
+	var prefixes []string
+	invalidate := fakeTracking(t, m, &prefixes)
+	m.Set("/app/db/host", "db1")
+	m.Set("/app/name", "easykv")
+
+	c, err := New([]string{m.Addr()}, WithClientCache())
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/app/db"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/db/host": "db1"})
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/db/host": "db1", "/app/name": "easykv"})
+	// the overlapping prefix is replaced
+	t.Check(prefixes, DeepEquals, []string{"/app"})
+
+	// the values are served from the cache until redis reports a change
+	m.Set("/app/name", "changed")
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/app/name"], Equals, "easykv")
+
+	invalidate("/app/name")
+	for i := 0; i < 50 && vars["/app/name"] != "changed"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		vars, err = c.GetValues([]string{"/app"})
+		t.Assert(err, IsNil)
+	}
+	t.Check(vars["/app/name"], Equals, "changed")
+
+	// the watch waits for an invalidation of the watched keys
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.Set("/app/db/host", "db2")
+		invalidate("/app/name")
+		invalidate("/app/db/host")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	index, err := c.WatchPrefix(ctx, "/app", easykv.WithKeys([]string{"/app/db"}))
+	t.Assert(err, IsNil)
+	t.Check(index, Not(Equals), uint64(0))
+	v, err := c.GetValues([]string{"/app/db"})
+	t.Assert(err, IsNil)
+	t.Check(v, DeepEquals, map[string]string{"/app/db/host": "db2"})
+}
+
+func (s *FilterSuite) TestIAMToken(t *C) {
+	now := time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	a := &iamAuth{options: IAMOptions{
+		UserID:     "app",
+		CacheName:  "mycache",
+		Region:     "eu-west-1",
+		Serverless: true,
+		Credentials: AWSCredentialsProviderFunc(func() (AWSCredentials, error) {
+			calls++
+			return AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}, nil
+		}),
+	}}
+	token, err := a.password(now)
+	t.Assert(err, IsNil)
+	t.Check(strings.HasPrefix(token, "mycache/?Action=connect&ResourceType=ServerlessCache&User=app&X-Amz-Algorithm="), Equals, true)
+	t.Check(strings.Contains(token, "X-Amz-Credential=AKID%2F20130524%2Feu-west-1%2Felasticache%2Faws4_request"), Equals, true)
+	t.Check(strings.Contains(token, "X-Amz-Expires=900&X-Amz-Security-Token=session&"), Equals, true)
+
+	// the token is replaced before it expires
+	again, err := a.password(now.Add(5 * time.Minute))
+	t.Assert(err, IsNil)
+	t.Check(again, Equals, token)
+	again, err = a.password(now.Add(iamTokenRefresh))
+	t.Assert(err, IsNil)
+	t.Check(again, Not(Equals), token)
+	t.Check(calls, Equals, 2)
+}
+
+func (s *FilterSuite) TestReplicaReads(t *C) {
+	master, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer master.Close()
+	replica, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer replica.Close()
+	master.Set("/app/name", "master")
+	replica.Set("/app/name", "replica")
+
+	c, err := New([]string{master.Addr()}, WithReplicaReads(replica.Addr()))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "replica"})
+	// watches read from the master
+	index, err := c.fingerprint("/app", nil)
+	t.Assert(err, IsNil)
+	master.Set("/app/name", "changed")
+	changed, err := c.fingerprint("/app", nil)
+	t.Assert(err, IsNil)
+	t.Check(changed, Not(Equals), index)
+
+	// the master is read if the replica is down
+	replica.Close()
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "changed"})
+
+	// the sentinels know the replicas
+	replica.Restart()
+	sentinel, err := miniredis.Run()
+	t.Assert(err, IsNil)
+	defer sentinel.Close()
+	t.Assert(sentinel.Server().Register("SENTINEL", func(p *server.Peer, cmd string, args []string) {
+		m := master
+		if strings.ToLower(args[0]) == "replicas" {
+			m = replica
+			p.WriteLen(1)
+		}
+		host, port, _ := net.SplitHostPort(m.Addr())
+		if m == replica {
+			p.WriteStrings([]string{"ip", host, "port", port, "flags", "slave"})
+			return
+		}
+		p.WriteStrings([]string{host, port})
+	}), IsNil)
+	var mu sync.Mutex
+	role := "master"
+	withRole(t, master, &role, &mu)
+
+	c, err = New([]string{sentinel.Addr()}, WithSentinel("mymaster"), WithReplicaReads())
+	t.Assert(err, IsNil)
+	defer c.Close()
+	vars, err = c.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/name": "replica"})
+}
+
+func (s *FilterSuite) TestClusterReplicaRoute(t *C) {
+	c := &clusterConn{readonly: true, slots: []slotRange{
+		{0, 8191, "10.0.0.1:6379", []string{"10.0.0.2:6379"}},
+		{8192, 16383, "10.0.0.3:6379", nil},
+	}}
+	for _, key := range []string{"foo", "bar"} {
+		want := "10.0.0.2:6379"
+		if hashSlot(key) > 8191 {
+			want = "10.0.0.3:6379"
+		}
+		t.Check(c.route("GET", []interface{}{key}), Equals, want)
+	}
+	t.Check(c.nodes(), DeepEquals, []string{"10.0.0.2:6379", "10.0.0.3:6379"})
+
+	c.readonly = false
+	t.Check(c.nodes(), DeepEquals, []string{"10.0.0.1:6379", "10.0.0.3:6379"})
 }
@@ -0,0 +1,381 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/garyburd/redigo/redis"
+)
+
+// clusterSlots is the number of hash slots of a Redis Cluster.
+const clusterSlots = 16384
+
+// maxRedirects limits how often a command follows MOVED and ASK redirects.
+const maxRedirects = 5
+
+var errPipelining = errors.New("redis cluster connections don't support pipelining")
+
+// slotRange is a range of hash slots served by a master and its replicas.
+type slotRange struct {
+	start, end int
+	addr       string
+	replicas   []string
+}
+
+// clusterConn is a redis.Conn to a Redis Cluster.
+// Commands are sent to the master of the hash slot of their key and follow
+// MOVED and ASK redirects, commands without a key are sent to any master.
+// Send, Flush and Receive aren't supported.
+// A readonly connection sends the commands to a replica of the hash slot instead.
+type clusterConn struct {
+	seeds    []string
+	dial     func(address string) (redis.Conn, error)
+	readonly bool
+	logger   easykv.Logger
+
+	mu    sync.Mutex
+	slots []slotRange
+	conns map[string]redis.Conn
+}
+
+// newClusterConn reads the slot map from the first seed node that answers.
+func newClusterConn(seeds []string, dial func(string) (redis.Conn, error), readonly bool, logger easykv.Logger) (*clusterConn, error) {
+	c := &clusterConn{seeds: seeds, dial: dial, readonly: readonly, logger: logger, conns: make(map[string]redis.Conn)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refresh(); err != nil {
+		c.closeAll()
+		return nil, err
+	}
+	return c, nil
+}
+
+// refresh reads the slot map again. The known masters are asked before the seeds.
+// c.mu must be held.
+func (c *clusterConn) refresh() error {
+	err := errors.New("no redis cluster nodes given")
+	for _, addr := range append(c.masters(), c.seeds...) {
+		var conn redis.Conn
+		if conn, err = c.conn(addr); err != nil {
+			c.logger.Warn("can't connect to redis cluster node", "address", addr, "err", err)
+			continue
+		}
+		var slots []slotRange
+		if slots, err = readSlots(conn, addr); err != nil {
+			c.drop(addr)
+			c.logger.Warn("can't read the redis cluster slots", "address", addr, "err", err)
+			continue
+		}
+		c.slots = slots
+
+		// close the connections to nodes that aren't used anymore
+		used := make(map[string]bool)
+		for _, n := range c.nodes() {
+			used[n] = true
+		}
+		for a := range c.conns {
+			if !used[a] {
+				c.drop(a)
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// readSlots returns the slot map of CLUSTER SLOTS.
+// Nodes without a host are reachable at the host of addr.
+// The first node of a range is the master, the others are its replicas.
+func readSlots(conn redis.Conn, addr string) ([]slotRange, error) {
+	reply, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+	var slots []slotRange
+	for _, r := range reply {
+		v, err := redis.Values(r, nil)
+		if err != nil || len(v) < 3 {
+			return nil, fmt.Errorf("invalid CLUSTER SLOTS reply from %s", addr)
+		}
+		var nodes []string
+		for _, n := range v[2:] {
+			node, err := redis.Values(n, nil)
+			if err != nil || len(node) < 2 {
+				return nil, fmt.Errorf("invalid CLUSTER SLOTS reply from %s", addr)
+			}
+			host, _ := redis.String(node[0], nil)
+			port, _ := redis.Int(node[1], nil)
+			if host == "" {
+				host, _, _ = net.SplitHostPort(addr)
+			}
+			nodes = append(nodes, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+		start, _ := redis.Int(v[0], nil)
+		end, _ := redis.Int(v[1], nil)
+		slots = append(slots, slotRange{start, end, nodes[0], nodes[1:]})
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("redis cluster node %s has no slots assigned", addr)
+	}
+	return slots, nil
+}
+
+// masters returns the addresses of all masters of the slot map.
+func (c *clusterConn) masters() []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, s := range c.slots {
+		if !seen[s.addr] {
+			seen[s.addr] = true
+			addrs = append(addrs, s.addr)
+		}
+	}
+	return addrs
+}
+
+// node returns the address of the node that serves the range s, a replica if c is readonly.
+func (c *clusterConn) node(s slotRange) string {
+	if c.readonly && len(s.replicas) > 0 {
+		return s.replicas[0]
+	}
+	return s.addr
+}
+
+// nodes returns the addresses of the nodes that serve the slot map, one per range of a master.
+func (c *clusterConn) nodes() []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, s := range c.slots {
+		if !seen[s.addr] {
+			seen[s.addr] = true
+			addrs = append(addrs, c.node(s))
+		}
+	}
+	return addrs
+}
+
+// conn returns the connection to addr and connects if necessary.
+// The connections of a readonly clusterConn allow reads from replicas.
+func (c *clusterConn) conn(addr string) (redis.Conn, error) {
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := c.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.readonly {
+		if _, err := conn.Do("READONLY"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+// drop closes the connection to addr.
+func (c *clusterConn) drop(addr string) {
+	if conn, ok := c.conns[addr]; ok {
+		conn.Close()
+		delete(c.conns, addr)
+	}
+}
+
+func (c *clusterConn) closeAll() {
+	for addr := range c.conns {
+		c.drop(addr)
+	}
+}
+
+// route returns the address of the master that serves the key of the command.
+func (c *clusterConn) route(cmd string, args []interface{}) string {
+	if key, ok := commandKey(cmd, args); ok {
+		slot := hashSlot(key)
+		for _, s := range c.slots {
+			if slot >= s.start && slot <= s.end {
+				return c.node(s)
+			}
+		}
+	}
+	if len(c.slots) == 0 {
+		return ""
+	}
+	return c.node(c.slots[0])
+}
+
+// commandKey returns the key the command operates on.
+func commandKey(cmd string, args []interface{}) (string, bool) {
+	var key interface{}
+	switch strings.ToUpper(cmd) {
+	case "PING", "ROLE", "SCAN", "INFO", "CLUSTER", "ASKING", "READONLY":
+		return "", false
+	case "EVAL", "EVALSHA":
+		if n, _ := redis.Int(argAt(args, 1), nil); n > 0 {
+			key = argAt(args, 2)
+		}
+	default:
+		key = argAt(args, 0)
+	}
+
+	switch k := key.(type) {
+	case nil:
+		return "", false
+	case []byte:
+		return string(k), true
+	default:
+		return fmt.Sprint(k), true
+	}
+}
+
+func argAt(args []interface{}, i int) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// hashSlot returns the hash slot of key. Only the hash tag in braces is hashed if key has one.
+func hashSlot(key string) int {
+	if s := strings.IndexByte(key, '{'); s >= 0 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			key = key[s+1 : s+1+e]
+		}
+	}
+	return int(crc16(key)) % clusterSlots
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum of the Redis Cluster key distribution.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// redirect parses a MOVED or ASK error and returns its kind and target address.
+func redirect(err error) (string, string) {
+	e, ok := err.(redis.Error)
+	if !ok {
+		return "", ""
+	}
+	parts := strings.Fields(string(e))
+	if len(parts) != 3 || (parts[0] != "MOVED" && parts[0] != "ASK") {
+		return "", ""
+	}
+	return parts[0], parts[2]
+}
+
+// Do sends the command to the master of its key. Redirects are followed, the slot
+// map is read again after a MOVED redirect or if the master can't be reached.
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	addr := c.route(cmd, args)
+	asking, refreshed := false, false
+	for i := 0; ; i++ {
+		conn, err := c.conn(addr)
+		if err == nil && asking {
+			_, err = conn.Do("ASKING")
+		}
+		var reply interface{}
+		if err == nil {
+			reply, err = conn.Do(cmd, args...)
+		}
+		if err == nil {
+			return reply, nil
+		}
+
+		if _, ok := err.(redis.Error); !ok {
+			// the master is unreachable, ask the cluster for the new one once
+			c.drop(addr)
+			if refreshed {
+				return nil, err
+			}
+			refreshed = true
+			if rerr := c.refresh(); rerr != nil {
+				return nil, err
+			}
+			addr, asking = c.route(cmd, args), false
+			continue
+		}
+
+		kind, target := redirect(err)
+		if kind == "" || i >= maxRedirects {
+			return reply, err
+		}
+		c.logger.Debug("following redis cluster redirect", "redirect", kind, "from", addr, "to", target)
+		if kind == "MOVED" {
+			if rerr := c.refresh(); rerr != nil {
+				c.logger.Warn("can't read the redis cluster slots", "err", rerr)
+			}
+		}
+		addr, asking = target, kind == "ASK"
+	}
+}
+
+// eachNode calls f with the connection to every master, or to a replica of it if c is readonly.
+func (c *clusterConn) eachNode(f func(redis.Conn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, addr := range c.nodes() {
+		conn, err := c.conn(addr)
+		if err != nil {
+			return err
+		}
+		if err := f(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connections to all nodes.
+func (c *clusterConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeAll()
+	return nil
+}
+
+// Err is always nil, broken node connections are replaced on the next command.
+func (c *clusterConn) Err() error {
+	return nil
+}
+
+// Send is not supported.
+func (c *clusterConn) Send(cmd string, args ...interface{}) error {
+	return errPipelining
+}
+
+// Flush is not supported.
+func (c *clusterConn) Flush() error {
+	return errPipelining
+}
+
+// Receive is not supported.
+func (c *clusterConn) Receive() (interface{}, error) {
+	return nil, errPipelining
+}
@@ -0,0 +1,91 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package redis
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv/internal/awsauth"
+)
+
+// iamTokenLifetime is how long an IAM auth token is accepted by ElastiCache and MemoryDB.
+const iamTokenLifetime = 15 * time.Minute
+
+// iamTokenRefresh is the age after which a new token is generated, before the old one expires.
+const iamTokenRefresh = 10 * time.Minute
+
+// AWSCredentials sign the IAM auth tokens.
+type AWSCredentials = awsauth.Credentials
+
+// An AWSCredentialsProvider returns AWSCredentials.
+type AWSCredentialsProvider = awsauth.CredentialsProvider
+
+// AWSCredentialsProviderFunc is an adapter to allow the use of ordinary functions as AWSCredentialsProvider.
+type AWSCredentialsProviderFunc = awsauth.ProviderFunc
+
+// DefaultAWSCredentials tries the environment, the container credentials of ecs and eks
+// and the instance profile of ec2, like the vault aws auth method.
+var DefaultAWSCredentials = awsauth.Default
+
+// IAMOptions configures the IAM authentication of AWS ElastiCache and MemoryDB.
+type IAMOptions struct {
+	// UserID is the id of the redis user that has IAM authentication enabled.
+	UserID string
+	// CacheName is the replication group id or serverless cache name of ElastiCache,
+	// or the cluster name of MemoryDB.
+	CacheName string
+	Region    string
+	// Service is elasticache, the default, or memorydb.
+	Service string
+	// Serverless has to be set for ElastiCache serverless caches.
+	Serverless bool
+	// Credentials returns the credentials that sign the tokens, DefaultAWSCredentials if it is nil.
+	Credentials AWSCredentialsProvider
+}
+
+// iamAuth generates the IAM auth tokens, a token is reused until it's iamTokenRefresh old.
+type iamAuth struct {
+	options   IAMOptions
+	token     string
+	generated time.Time
+}
+
+// password returns a valid auth token. It isn't safe for concurrent use, the client calls it with c.mu held.
+func (a *iamAuth) password(now time.Time) (string, error) {
+	if a.token != "" && now.Sub(a.generated) < iamTokenRefresh {
+		return a.token, nil
+	}
+	provider := a.options.Credentials
+	if provider == nil {
+		provider = DefaultAWSCredentials
+	}
+	creds, err := provider.Retrieve()
+	if err != nil {
+		return "", err
+	}
+	a.token, a.generated = iamToken(a.options, creds, now), now
+	return a.token, nil
+}
+
+// iamToken returns an auth token, the presigned connect request of the cache without the scheme.
+func iamToken(o IAMOptions, creds AWSCredentials, now time.Time) string {
+	service := o.Service
+	if service == "" {
+		service = "elasticache"
+	}
+	q := url.Values{"Action": {"connect"}, "User": {o.UserID}}
+	if o.Serverless {
+		q.Set("ResourceType", "ServerlessCache")
+	}
+	u := &url.URL{Scheme: "https", Host: o.CacheName, Path: "/", RawQuery: q.Encode()}
+	signed := awsauth.Presign(u, service, o.Region, awsauth.EmptyPayloadHash, creds, now, iamTokenLifetime)
+	return strings.TrimPrefix(signed, "https://")
+}
@@ -0,0 +1,181 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/garyburd/redigo/redis"
+)
+
+// lockRetryInterval is the time between two attempts to acquire a lock.
+var lockRetryInterval = 100 * time.Millisecond
+
+// refreshScript extends the ttl of the lock if it's still held with the token.
+var refreshScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// unlockScript deletes the lock if it's still held with the token.
+var unlockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// session is a lock held with SET NX PX. The ttl is refreshed in the background.
+type session struct {
+	mu    sync.Mutex
+	conn  redis.Conn
+	key   string
+	token string
+	ttl   time.Duration
+	// values are the keys written with SetValue, they share the ttl of the lock
+	values []string
+	once   sync.Once
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func (s *session) Done() <-chan struct{} {
+	return s.done
+}
+
+// SetValue writes value to key with the ttl of the lock,
+// the key is refreshed with the lock and deleted by Unlock.
+func (s *session) SetValue(ctx context.Context, key, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return easykv.ErrLockLost
+	default:
+	}
+	if _, err := s.conn.Do("SET", key, value, "PX", int64(s.ttl/time.Millisecond)); err != nil {
+		return err
+	}
+	s.values = append(s.values, key)
+	return nil
+}
+
+func (s *session) Unlock() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+		return nil
+	default:
+	}
+	close(s.stop)
+	_, err := unlockScript.Do(s.conn, s.key, s.token)
+	for _, k := range s.values {
+		if _, derr := s.conn.Do("DEL", k); err == nil {
+			err = derr
+		}
+	}
+	s.conn.Close()
+	s.lost()
+	return err
+}
+
+func (s *session) lost() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// refresh extends the ttl of the lock and its values every third of the ttl
+// until the lock is unlocked or lost.
+func (s *session) refresh(logger easykv.Logger) {
+	ttl := s.ttl
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		ok, err := redis.Bool(refreshScript.Do(s.conn, s.key, s.token, int64(ttl/time.Millisecond)))
+		if err == nil && ok {
+			for _, k := range s.values {
+				if _, err = s.conn.Do("PEXPIRE", k, int64(ttl/time.Millisecond)); err != nil {
+					break
+				}
+			}
+		}
+		s.mu.Unlock()
+		if err != nil || !ok {
+			logger.Warn("lost redis lock", "key", s.key, "err", err)
+			s.lost()
+			return
+		}
+	}
+}
+
+// Lock acquires the lock on key with SET NX PX on a dedicated connection.
+// The ttl is refreshed in the background until the lock is unlocked.
+func (c *Client) Lock(ctx context.Context, key string, ttl time.Duration) (easykv.Session, error) {
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	conn, err := c.tryConnect()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		_, err := redis.String(conn.Do("SET", key, token, "NX", "PX", int64(ttl/time.Millisecond)))
+		if err == nil {
+			break
+		}
+		if err != redis.ErrNil {
+			conn.Close()
+			return nil, err
+		}
+
+		select {
+		case <-time.After(lockRetryInterval):
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	c.logger.Debug("acquired redis lock", "key", key)
+	s := &session{
+		conn:  conn,
+		key:   key,
+		token: token,
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go s.refresh(c.logger)
+	return s, nil
+}
@@ -8,6 +8,14 @@
 
 package redis
 
+import (
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+	"github.com/garyburd/redigo/redis"
+)
+
 // Option configures the redis client.
 type Option func(*Client)
 
@@ -18,9 +26,143 @@ func WithPassword(pw string) Option {
 	}
 }
 
+// WithUsername sets the ACL user of redis 6 and newer, the password is the password of the user.
+func WithUsername(name string) Option {
+	return func(o *Client) {
+		o.username = name
+	}
+}
+
+// WithIAM authenticates with the IAM auth tokens of AWS ElastiCache and MemoryDB instead of a password.
+// The tokens are signed with the AWS credentials and replaced before they expire, TLS is always used.
+func WithIAM(iam IAMOptions) Option {
+	return func(o *Client) {
+		o.iam = &iamAuth{options: iam}
+	}
+}
+
+// WithPasswordFrom reads the redis password from a file or an environment variable.
+func WithPasswordFrom(ref credentials.Ref) Option {
+	return func(o *Client) {
+		o.passwordRef = ref
+	}
+}
+
 // WithDatabase configures the redis database.
 func WithDatabase(db int) Option {
 	return func(o *Client) {
 		o.db = db
 	}
 }
+
+// WithLogger sets the Logger that reports reconnects and skipped keys.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Client) {
+		o.logger = easykv.LoggerOrDiscard(l)
+	}
+}
+
+// WithTLS enables TLS for tcp connections.
+func WithTLS(tls easykv.TLSOptions) Option {
+	return func(o *Client) {
+		o.tls = tls
+	}
+}
+
+// WithUseTLS enables TLS for tcp connections even without TLSOptions,
+// the certificate of the server is verified with the system roots then.
+func WithUseTLS() Option {
+	return func(o *Client) {
+		o.useTLS = true
+	}
+}
+
+// WithSentinel discovers the master with the given name from Redis Sentinel.
+// The machines passed to New are the addresses of the sentinels, the client
+// reconnects to the new master after a failover.
+func WithSentinel(master string) Option {
+	return func(o *Client) {
+		o.sentinelMaster = master
+	}
+}
+
+// WithCluster connects to a Redis Cluster. The machines passed to New are seed nodes,
+// the slot map is read from the first one that answers. Only database 0 is available.
+func WithCluster() Option {
+	return func(o *Client) {
+		o.cluster = true
+	}
+}
+
+// WithScanCount sets the COUNT hint of the SCAN commands that enumerate the keys of a prefix, 1000 by default.
+// Smaller batches keep every single command short on large datasets at the cost of more round trips.
+func WithScanCount(n int) Option {
+	return func(o *Client) {
+		o.scanCount = n
+	}
+}
+
+// WithBatchSize sets the number of keys that GetValues reads in a single round trip, 100 by default.
+func WithBatchSize(n int) Option {
+	return func(o *Client) {
+		o.batchSize = n
+	}
+}
+
+// WithReplicaReads sends the reads of GetValues to replicas, the watches and writes stay on the master.
+// The replicas are the given addresses, with a sentinel they are discovered from the sentinels,
+// in cluster mode the replicas of the hash slots are read after a READONLY.
+// The master is read if no replica can be reached. Replicas may lag behind the master.
+func WithReplicaReads(replicas ...string) Option {
+	return func(o *Client) {
+		o.replicaReads = true
+		o.replicas = replicas
+	}
+}
+
+// WithClientCache caches the values of GetValues in the client. Redis reports the changes
+// of the cached prefixes with CLIENT TRACKING, which also replaces the keyspace notifications
+// of WatchPrefix. It needs redis 6 or newer and isn't supported in cluster mode.
+func WithClientCache() Option {
+	return func(o *Client) {
+		o.clientCache = true
+	}
+}
+
+// PoolOptions tunes the connection pool of the client.
+type PoolOptions struct {
+	// Size limits the number of open connections, commands wait for a free one.
+	// The number is unlimited if Size is 0.
+	Size int
+	// MinIdle connections are opened when the client connects.
+	MinIdle int
+	// IdleTimeout closes connections that were idle for longer, they are kept if it is 0.
+	IdleTimeout time.Duration
+
+	// The timeouts of a connection, 1s by default.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+}
+
+// timeouts returns the dial options of the timeouts.
+func (o PoolOptions) timeouts() []redis.DialOption {
+	or := func(d time.Duration) time.Duration {
+		if d > 0 {
+			return d
+		}
+		return defaultTimeout
+	}
+	return []redis.DialOption{
+		redis.DialConnectTimeout(or(o.ConnectTimeout)),
+		redis.DialReadTimeout(or(o.ReadTimeout)),
+		redis.DialWriteTimeout(or(o.WriteTimeout)),
+	}
+}
+
+// WithPool configures the connection pool and the timeouts of the connections.
+func WithPool(p PoolOptions) Option {
+	return func(o *Client) {
+		o.poolOptions = p
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/garyburd/redigo/redis"
+)
+
+// invalidateChannel receives the invalidation messages of CLIENT TRACKING.
+// redigo speaks RESP2, so the messages are redirected to a subscribed connection.
+const invalidateChannel = "__redis__:invalidate"
+
+// trackingInterval is the interval of the health checks of the tracking connections.
+var trackingInterval = 5 * time.Second
+
+var (
+	errTrackingLost = errors.New("redis client tracking connection lost")
+	errClientClosed = errors.New("redis client closed")
+)
+
+// tracker is the client-side cache of GetValues. The prefixes are tracked in the
+// broadcasting mode of CLIENT TRACKING, redis reports every change of a key below
+// them and the cached values are dropped. The cache is emptied if a connection
+// fails, the invalidation messages might have been lost.
+type tracker struct {
+	connect func() (redis.Conn, error)
+	logger  easykv.Logger
+
+	mu sync.Mutex
+	// sub is subscribed to the invalidateChannel, ctl has the tracking enabled.
+	sub, ctl redis.Conn
+	id       int64
+	prefixes []string
+	values   map[string]map[string]string
+	// gen is incremented by every invalidation, a read is only cached if it didn't change.
+	gen      uint64
+	watchers map[*watcher]bool
+	stop     chan struct{}
+}
+
+// watcher is a WatchPrefix waiting for an invalidation of the keys below prefix.
+type watcher struct {
+	prefix string
+	keys   []string
+	notify chan struct{}
+	errc   chan error
+}
+
+func newTracker(connect func() (redis.Conn, error), logger easykv.Logger) *tracker {
+	return &tracker{connect: connect, logger: logger, watchers: make(map[*watcher]bool)}
+}
+
+// start connects the tracking connections. t.mu must be held.
+func (t *tracker) start() error {
+	if t.sub != nil {
+		return nil
+	}
+	sub, err := t.connect()
+	if err != nil {
+		return err
+	}
+	id, err := redis.Int64(sub.Do("CLIENT", "ID"))
+	if err == nil {
+		err = sub.Send("SUBSCRIBE", invalidateChannel)
+	}
+	if err == nil {
+		err = sub.Flush()
+	}
+	if err == nil {
+		// wait for the confirmation
+		_, err = sub.Receive()
+	}
+	if err != nil {
+		sub.Close()
+		return err
+	}
+	ctl, err := t.connect()
+	if err != nil {
+		sub.Close()
+		return err
+	}
+
+	t.sub, t.ctl, t.id = sub, ctl, id
+	t.prefixes, t.values = nil, make(map[string]map[string]string)
+	t.stop = make(chan struct{})
+	go t.receive(sub)
+	go t.check(sub, ctl, t.stop)
+	t.logger.Debug("started redis client tracking", "redirect", id)
+	return nil
+}
+
+// track enables the tracking of prefix. t.mu must be held.
+// Redis rejects overlapping prefixes, so a prefix that covers tracked ones replaces them.
+func (t *tracker) track(prefix string) error {
+	if err := t.start(); err != nil {
+		return err
+	}
+	var kept []string
+	for _, p := range t.prefixes {
+		if strings.HasPrefix(prefix, p) {
+			return nil
+		}
+		if !strings.HasPrefix(p, prefix) {
+			kept = append(kept, p)
+		}
+	}
+	prefixes := append(kept, prefix)
+
+	args := []interface{}{"TRACKING", "ON", "REDIRECT", t.id, "BCAST"}
+	if len(kept) < len(t.prefixes) {
+		if _, err := t.ctl.Do("CLIENT", "TRACKING", "OFF"); err != nil {
+			t.fail(err)
+			return err
+		}
+		// changes are not reported until tracking is enabled again
+		t.invalidate(nil)
+		for _, p := range prefixes {
+			args = append(args, "PREFIX", p)
+		}
+	} else {
+		args = append(args, "PREFIX", prefix)
+	}
+	if _, err := t.ctl.Do("CLIENT", args...); err != nil {
+		t.fail(err)
+		return err
+	}
+	t.prefixes = prefixes
+	return nil
+}
+
+// get returns the cached values of prefix. Missing values are read with read and cached.
+// If the tracking can't be enabled, the values are read without the cache.
+func (t *tracker) get(prefix string, read func() (map[string]string, error)) (map[string]string, error) {
+	t.mu.Lock()
+	if values, ok := t.values[prefix]; ok {
+		t.mu.Unlock()
+		return values, nil
+	}
+	if err := t.track(prefix); err != nil {
+		t.mu.Unlock()
+		t.logger.Warn("can't enable redis client tracking, reading without the cache", "prefix", prefix, "err", err)
+		return read()
+	}
+	gen, sub := t.gen, t.sub
+	t.mu.Unlock()
+
+	values, err := read()
+	if err != nil {
+		return values, err
+	}
+	t.mu.Lock()
+	if t.gen == gen && t.sub == sub {
+		t.values[prefix] = values
+	}
+	t.mu.Unlock()
+	return values, nil
+}
+
+// watch registers a watcher for the keys below prefix, see subscribe.
+func (t *tracker) watch(prefix string, keys []string) (<-chan struct{}, <-chan error, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.track(prefix); err != nil {
+		return nil, nil, func() {}, err
+	}
+	w := &watcher{prefix: prefix, keys: keys, notify: make(chan struct{}, 1), errc: make(chan error, 1)}
+	t.watchers[w] = true
+	return w.notify, w.errc, func() {
+		t.mu.Lock()
+		delete(t.watchers, w)
+		t.mu.Unlock()
+	}, nil
+}
+
+// receive reads the invalidation messages from sub until it fails.
+// The health checks make sure that a message arrives at least every trackingInterval.
+func (t *tracker) receive(sub redis.Conn) {
+	for {
+		reply, err := redis.Values(redis.ReceiveWithTimeout(sub, 2*trackingInterval))
+		if err != nil {
+			t.mu.Lock()
+			if t.sub == sub {
+				t.logger.Warn("redis client tracking failed, dropping the cache", "err", err)
+				t.fail(errTrackingLost)
+			}
+			t.mu.Unlock()
+			return
+		}
+		if len(reply) != 3 || fmt.Sprintf("%s", reply[0]) != "message" {
+			// subscription confirmations and pongs
+			continue
+		}
+
+		// the keys are nil if the database was flushed
+		keys, _ := redis.Strings(reply[2], nil)
+		t.mu.Lock()
+		if t.sub == sub {
+			t.invalidate(keys)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// check pings the tracking connections every trackingInterval.
+func (t *tracker) check(sub, ctl redis.Conn, stop chan struct{}) {
+	ticker := time.NewTicker(trackingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		t.mu.Lock()
+		_, err := ctl.Do("PING")
+		if err == nil {
+			// the reply of a subscribed connection is read by receive
+			if err = sub.Send("PING"); err == nil {
+				err = sub.Flush()
+			}
+		}
+		if err != nil && t.sub == sub {
+			t.logger.Warn("redis client tracking failed, dropping the cache", "err", err)
+			t.fail(errTrackingLost)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// invalidate drops the cached prefixes of keys and notifies their watchers.
+// All prefixes are dropped if keys is nil. t.mu must be held.
+func (t *tracker) invalidate(keys []string) {
+	t.gen++
+	if keys == nil {
+		t.values = make(map[string]map[string]string)
+		for w := range t.watchers {
+			w.wake()
+		}
+		return
+	}
+
+	for _, k := range keys {
+		for p := range t.values {
+			if strings.HasPrefix(k, p) {
+				delete(t.values, p)
+			}
+		}
+		for w := range t.watchers {
+			if strings.HasPrefix(k, w.prefix) && notified(k, w.keys) {
+				w.wake()
+			}
+		}
+	}
+}
+
+func (w *watcher) wake() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// fail closes the tracking connections, empties the cache and ends the watches with err.
+// The next read connects again. t.mu must be held.
+func (t *tracker) fail(err error) {
+	if t.sub == nil {
+		return
+	}
+	close(t.stop)
+	t.sub.Close()
+	t.ctl.Close()
+	t.sub, t.ctl = nil, nil
+	t.values = nil
+	t.gen++
+	for w := range t.watchers {
+		w.errc <- err
+		delete(t.watchers, w)
+	}
+}
+
+// close closes the tracking connections.
+func (t *tracker) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fail(errClientClosed)
+}
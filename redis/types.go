@@ -0,0 +1,234 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package redis
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/garyburd/redigo/redis"
+)
+
+// defaultBatchSize is the default number of keys that are read in a single round trip.
+const defaultBatchSize = 100
+
+// command is a redis command of a pipeline.
+type command struct {
+	name string
+	args []interface{}
+}
+
+// isWrongType checks if err is the error of a command on a key of another type.
+func isWrongType(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(e), "WRONGTYPE")
+}
+
+// readCommand returns the command that reads a key of type typ.
+func readCommand(typ, key string) (command, bool) {
+	switch typ {
+	case "hash":
+		return command{"HGETALL", []interface{}{key}}, true
+	case "list":
+		return command{"LRANGE", []interface{}{key, 0, -1}}, true
+	case "set":
+		return command{"SMEMBERS", []interface{}{key}}, true
+	case "zset":
+		return command{"ZRANGE", []interface{}{key, 0, -1}}, true
+	}
+	return command{}, false
+}
+
+// setValues adds the reply of the readCommand of key to vars. A hash is mapped to nested
+// keys key/<field>, lists and sorted sets to key/<index> in their order and sets to
+// key/<index> in lexical order.
+func setValues(key, typ string, reply interface{}, vars map[string]string) error {
+	if typ == "hash" {
+		fields, err := redis.StringMap(reply, nil)
+		if err != nil {
+			return err
+		}
+		for f, v := range fields {
+			vars[key+"/"+f] = v
+		}
+		return nil
+	}
+
+	values, err := redis.Strings(reply, nil)
+	if err != nil {
+		return err
+	}
+	if typ == "set" {
+		sort.Strings(values)
+	}
+	for i, v := range values {
+		vars[key+"/"+strconv.Itoa(i)] = v
+	}
+	return nil
+}
+
+// readKey adds the value of key to vars, see setValues for the types other than strings.
+// It returns redis.ErrNil if key doesn't exist.
+func readKey(conn redis.Conn, tr *easykv.Trace, key string, vars map[string]string) error {
+	value, err := redis.String(do(conn, tr, key, "GET", key))
+	if err == nil {
+		vars[key] = value
+		return nil
+	}
+	if !isWrongType(err) {
+		return err
+	}
+
+	typ, err := redis.String(do(conn, tr, key, "TYPE", key))
+	if err != nil {
+		return err
+	}
+	cmd, ok := readCommand(typ, key)
+	if !ok {
+		return redis.Error("WRONGTYPE redis keys of type " + typ + " aren't supported")
+	}
+	reply, err := do(conn, tr, key, cmd.name, cmd.args...)
+	if err != nil {
+		return err
+	}
+	return setValues(key, typ, reply, vars)
+}
+
+// pipeline sends all commands in a single round trip and returns their replies.
+// The error replies of single commands are returned as replies.
+func pipeline(conn redis.Conn, tr *easykv.Trace, key string, cmds []command) ([]interface{}, error) {
+	start := time.Now()
+	replies := make([]interface{}, len(cmds))
+	err := func() error {
+		for _, cmd := range cmds {
+			if err := conn.Send(cmd.name, cmd.args...); err != nil {
+				return err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for i := range cmds {
+			reply, err := conn.Receive()
+			if e, ok := err.(redis.Error); ok {
+				replies[i] = e
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			replies[i] = reply
+		}
+		return nil
+	}()
+	tr.Record("PIPELINE", key, start, err)
+	return replies, err
+}
+
+// readKeys adds the values of keys to vars. The strings are read with an MGET per batch,
+// the other types with a pipeline of TYPE commands and one of the read commands.
+// Keys that are gone in the meantime or can't be read are skipped.
+func (c *Client) readKeys(conn redis.Conn, tr *easykv.Trace, pattern string, keys []string, vars map[string]string) error {
+	if _, ok := conn.(*clusterConn); ok {
+		// the keys may belong to different slots
+		for _, k := range keys {
+			if err := readKey(conn, tr, k, vars); err != nil {
+				c.logger.Debug("skipping redis key", "key", k, "err", err)
+			}
+		}
+		return nil
+	}
+
+	size := c.batchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > size {
+			n = size
+		}
+		batch := keys[:n]
+		keys = keys[n:]
+
+		args := make([]interface{}, len(batch))
+		for i, k := range batch {
+			args[i] = k
+		}
+		values, err := redis.Values(do(conn, tr, pattern, "MGET", args...))
+		if err != nil {
+			return err
+		}
+		var others []string
+		for i, v := range values {
+			if v == nil {
+				// MGET returns nil for keys of other types
+				others = append(others, batch[i])
+				continue
+			}
+			vars[batch[i]], _ = redis.String(v, nil)
+		}
+		if err := c.readOthers(conn, tr, pattern, others, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readOthers reads the keys that aren't strings with two pipelines.
+func (c *Client) readOthers(conn redis.Conn, tr *easykv.Trace, pattern string, keys []string, vars map[string]string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	cmds := make([]command, len(keys))
+	for i, k := range keys {
+		cmds[i] = command{"TYPE", []interface{}{k}}
+	}
+	types, err := pipeline(conn, tr, pattern, cmds)
+	if err != nil {
+		return err
+	}
+
+	var read, typs []string
+	cmds = cmds[:0]
+	for i, t := range types {
+		typ, _ := redis.String(t, nil)
+		cmd, ok := readCommand(typ, keys[i])
+		if !ok {
+			if typ != "none" {
+				c.logger.Debug("skipping redis key", "key", keys[i], "type", typ)
+			}
+			continue
+		}
+		cmds = append(cmds, cmd)
+		read = append(read, keys[i])
+		typs = append(typs, typ)
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	replies, err := pipeline(conn, tr, pattern, cmds)
+	if err != nil {
+		return err
+	}
+	for i, r := range replies {
+		if err, ok := r.(error); ok {
+			c.logger.Debug("skipping redis key", "key", read[i], "err", err)
+			continue
+		}
+		if err := setValues(read[i], typs[i], r, vars); err != nil {
+			c.logger.Debug("skipping redis key", "key", read[i], "err", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/garyburd/redigo/redis"
+)
+
+// defaultWatchInterval is the poll interval of WatchPrefix if keyspace notifications
+// can't be used and no Interval is set in the WatchOptions.
+const defaultWatchInterval = 5 * time.Second
+
+// keyspaceEvents are the notify-keyspace-events a watch needs: keyspace events
+// of generic, string, list, set, hash and sorted set commands, expired and evicted keys.
+const keyspaceEvents = "Kg$lshzxe"
+
+// WatchPrefix watches a specific prefix for changes.
+// It subscribes to the keyspace notifications of the keys below prefix and enables them
+// with CONFIG SET if necessary. If that isn't permitted, e.g. on managed services, or in
+// a cluster, the prefix is polled every Interval instead. With WithClientCache, the
+// invalidation messages of the client-side cache are used.
+// The index is a fingerprint of the watched keys and their values. A WaitIndex that doesn't
+// match the current fingerprint is answered immediately, without one the watch waits for the next change.
+// Failed watches are retried if a Backoff is set in the WatchOptions.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, options)
+	})
+}
+
+// watchPrefix runs a single watch.
+func (c *Client) watchPrefix(ctx context.Context, prefix string, options easykv.WatchOptions) (uint64, error) {
+	notify, errc, stop, err := c.subscribe(prefix, options.Keys)
+	if err != nil {
+		return options.WaitIndex, err
+	}
+	defer stop()
+
+	// the fingerprint is taken after subscribing, so no change is missed
+	current, err := c.fingerprint(prefix, options.Keys)
+	if err != nil {
+		return options.WaitIndex, err
+	}
+	if options.WaitIndex != 0 && current != options.WaitIndex {
+		return current, nil
+	}
+
+	var poll <-chan time.Time
+	if notify == nil {
+		interval := options.Interval
+		if interval <= 0 {
+			interval = defaultWatchInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	c.logger.Debug("watching redis prefix", "prefix", prefix, "notifications", notify != nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return options.WaitIndex, easykv.ErrWatchCanceled
+		case err := <-errc:
+			c.logger.Warn("redis keyspace subscription failed", "prefix", prefix, "err", err)
+			return options.WaitIndex, err
+		case <-notify:
+		case <-poll:
+		}
+
+		index, err := c.fingerprint(prefix, options.Keys)
+		if err != nil {
+			return options.WaitIndex, err
+		}
+		if index != current {
+			return index, nil
+		}
+	}
+}
+
+// subscribe subscribes to the keyspace notifications of the watched keys below prefix
+// on a dedicated connection. notify receives a value after every notification and errc
+// the error that ended the subscription. Both are nil if the notifications can't be used.
+// stop closes the connection.
+func (c *Client) subscribe(prefix string, keys []string) (notify <-chan struct{}, errc <-chan error, stop func(), err error) {
+	stop = func() {}
+	if c.tracker != nil {
+		if notify, errc, stop, err = c.tracker.watch(prefix, keys); err == nil {
+			return notify, errc, stop, nil
+		}
+		c.logger.Warn("can't enable redis client tracking, using keyspace notifications", "prefix", prefix, "err", err)
+	}
+
+	c.mu.Lock()
+	cluster, db, logger := c.cluster, c.db, c.logger
+	var conn redis.Conn
+	if !cluster {
+		conn, err = c.tryConnect()
+	}
+	c.mu.Unlock()
+	if cluster {
+		logger.Debug("redis cluster keyspace notifications aren't supported, polling instead")
+		return nil, nil, stop, nil
+	}
+	if err != nil {
+		return nil, nil, stop, err
+	}
+
+	if err := enableNotifications(conn); err != nil {
+		conn.Close()
+		logger.Info("can't enable redis keyspace notifications, polling instead", "err", err)
+		return nil, nil, stop, nil
+	}
+
+	channel := fmt.Sprintf("__keyspace@%d__:", db)
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.PSubscribe(channel + escapePattern(prefix) + "*"); err != nil {
+		conn.Close()
+		return nil, nil, stop, err
+	}
+	// wait for the confirmation, the subscription is active afterwards
+	switch v := psc.ReceiveWithTimeout(time.Second).(type) {
+	case redis.Subscription:
+	case error:
+		conn.Close()
+		return nil, nil, stop, v
+	default:
+		conn.Close()
+		return nil, nil, stop, fmt.Errorf("unexpected reply to PSUBSCRIBE: %v", v)
+	}
+
+	n := make(chan struct{}, 1)
+	e := make(chan error, 1)
+	go func() {
+		for {
+			switch v := psc.ReceiveWithTimeout(0).(type) {
+			case redis.PMessage:
+				if !notified(strings.TrimPrefix(v.Channel, channel), keys) {
+					continue
+				}
+				select {
+				case n <- struct{}{}:
+				default:
+				}
+			case error:
+				e <- v
+				return
+			}
+		}
+	}()
+	return n, e, func() { conn.Close() }, nil
+}
+
+// enableNotifications makes sure that redis publishes the keyspaceEvents.
+// The events that are enabled already are kept.
+func enableNotifications(conn redis.Conn) error {
+	reply, err := redis.Strings(conn.Do("CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil {
+		return err
+	}
+	current := ""
+	if len(reply) == 2 {
+		current = reply[1]
+	}
+
+	missing := ""
+	for _, f := range keyspaceEvents {
+		// A is an alias for all classes of events, but not for K
+		if strings.ContainsRune(current, f) || (f != 'K' && strings.ContainsRune(current, 'A')) {
+			continue
+		}
+		missing += string(f)
+	}
+	if missing == "" {
+		return nil
+	}
+	_, err = conn.Do("CONFIG", "SET", "notify-keyspace-events", current+missing)
+	return err
+}
+
+// escapePattern escapes the glob characters of a PSUBSCRIBE pattern.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// watched checks if key has one of the prefixes in keys. All keys are watched if keys is empty.
+func watched(key string, keys []string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// notified checks if a notification for key concerns the watched keys.
+// A hash, list or set contains the nested keys below key.
+func notified(key string, keys []string) bool {
+	if watched(key, keys) {
+		return true
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, key+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint returns a hash of the watched keys below prefix and their values.
+// They're read from the master, a replica might not have received a change yet.
+func (c *Client) fingerprint(prefix string, keys []string) (uint64, error) {
+	vars, err := c.getValues([]string{prefix}, nil, false)
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		if watched(k, keys) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, k := range names {
+		fmt.Fprintf(h, "%s\x00%s\x00", k, vars[k])
+	}
+	// 0 is reserved for "no index"
+	if index := h.Sum64(); index != 0 {
+		return index, nil
+	}
+	return 1, nil
+}
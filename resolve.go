@@ -0,0 +1,139 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// referenceRegexp matches references like ${vault:secret/db#password} or ${consul:app/flag}.
+var referenceRegexp = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+):([^}#]+)(?:#([^}]+))?\}`)
+
+// ReferenceError is returned if a reference can't be resolved.
+type ReferenceError struct {
+	Reference string
+	Err       error
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("can't resolve reference %s: %v", e.Reference, e.Err)
+}
+
+// Unwrap returns the error of the referenced backend.
+func (e *ReferenceError) Unwrap() error {
+	return e.Err
+}
+
+// Resolver resolves ${backend:path} and ${backend:path#field} references in the values
+// returned by the wrapped ReadWatcher through other registered backends.
+// References in resolved values are resolved as well.
+// WatchPrefix is passed through to the wrapped ReadWatcher, changes in referenced backends
+// don't trigger a watch.
+type Resolver struct {
+	ReadWatcher
+
+	mu       sync.RWMutex
+	backends map[string]ReadWatcher
+}
+
+// NewResolver returns a new Resolver for the given backend.
+func NewResolver(rw ReadWatcher) *Resolver {
+	return &Resolver{
+		ReadWatcher: rw,
+		backends:    make(map[string]ReadWatcher),
+	}
+}
+
+// Register registers a backend under the given name.
+// The name is used as the first part of a reference.
+func (r *Resolver) Register(name string, rw ReadWatcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[name] = rw
+}
+
+// GetValues returns the values of the wrapped ReadWatcher with all references resolved.
+func (r *Resolver) GetValues(keys []string) (map[string]string, error) {
+	vars, err := r.ReadWatcher.GetValues(keys)
+	if err != nil {
+		return vars, err
+	}
+	for k, v := range vars {
+		resolved, err := r.resolve(v, nil)
+		if err != nil {
+			return vars, err
+		}
+		vars[k] = resolved
+	}
+	return vars, nil
+}
+
+// resolve replaces all references in value.
+// seen holds the references that are currently being resolved.
+func (r *Resolver) resolve(value string, seen []string) (string, error) {
+	var rerr error
+	resolved := referenceRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+		if rerr != nil {
+			return ref
+		}
+		for _, s := range seen {
+			if s == ref {
+				rerr = &ReferenceError{ref, ErrReferenceCycle}
+				return ref
+			}
+		}
+
+		v, err := r.lookup(ref)
+		if err != nil {
+			rerr = &ReferenceError{ref, err}
+			return ref
+		}
+
+		v, err = r.resolve(v, append(seen, ref))
+		if err != nil {
+			rerr = err
+			return ref
+		}
+		return v
+	})
+	return resolved, rerr
+}
+
+// lookup reads the value of a single reference from its backend.
+func (r *Resolver) lookup(ref string) (string, error) {
+	m := referenceRegexp.FindStringSubmatch(ref)
+	name, p, field := m[1], strings.TrimSpace(m[2]), m[3]
+
+	r.mu.RLock()
+	rw, ok := r.backends[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown backend %q", name)
+	}
+
+	key := path.Join("/", p)
+	if field != "" {
+		key = path.Join(key, field)
+	}
+
+	vars, err := rw.GetValues([]string{p})
+	if err != nil {
+		return "", err
+	}
+	for k, v := range vars {
+		if path.Join("/", k) == key {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("key %s not found", key)
+}
@@ -0,0 +1,58 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestResolverGetValues(t *C) {
+	r := NewResolver(newTestClient(map[string]string{
+		"/app/db/url":  "postgres://app:${vault:secret/db#password}@db/app",
+		"/app/flag":    "${consul:app/flag}",
+		"/app/timeout": "10s",
+	}))
+	r.Register("vault", newTestClient(map[string]string{
+		"secret/db/password": "s3cret",
+	}))
+	r.Register("consul", newTestClient(map[string]string{
+		"/app/flag": "${vault:secret/db#password}",
+	}))
+
+	vars, err := r.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/app/db/url":  "postgres://app:s3cret@db/app",
+		"/app/flag":    "s3cret",
+		"/app/timeout": "10s",
+	})
+}
+
+func (s *FilterSuite) TestResolverCycle(t *C) {
+	r := NewResolver(newTestClient(map[string]string{
+		"/a": "${other:b}",
+	}))
+	r.Register("other", newTestClient(map[string]string{
+		"/b": "${other:c}",
+		"/c": "${other:b}",
+	}))
+
+	_, err := r.GetValues([]string{"/a"})
+	t.Assert(err, FitsTypeOf, &ReferenceError{})
+	t.Check(err.(*ReferenceError).Err, Equals, ErrReferenceCycle)
+}
+
+func (s *FilterSuite) TestResolverUnknownBackend(t *C) {
+	r := NewResolver(newTestClient(map[string]string{
+		"/a": "${missing:b}",
+	}))
+
+	_, err := r.GetValues([]string{"/a"})
+	t.Check(err, ErrorMatches, `can't resolve reference \$\{missing:b\}: unknown backend "missing"`)
+}
@@ -0,0 +1,82 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package testserver
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv/consul"
+)
+
+// Consul starts a consul dev agent and returns a consul client connected to it.
+// The consul binary has to be in the PATH, otherwise ErrNotInstalled is returned.
+func Consul(opts ...consul.Option) (*consul.Client, func(), error) {
+	bin, err := exec.LookPath("consul")
+	if err != nil {
+		return nil, nil, ErrNotInstalled
+	}
+
+	args := []string{"agent", "-dev", "-bind=127.0.0.1", "-client=127.0.0.1", "-dns-port=-1"}
+	ports := make(map[string]int)
+	for _, name := range []string{"http-port", "serf-lan-port", "serf-wan-port", "server-port"} {
+		if ports[name], err = freePort(); err != nil {
+			return nil, nil, err
+		}
+		args = append(args, "-"+name+"="+strconv.Itoa(ports[name]))
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(ports["http-port"])
+
+	cmd := exec.Command(bin, args...)
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	stop := func() {
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+	}
+
+	if err := waitForLeader("http://" + addr); err != nil {
+		stop()
+		return nil, nil, err
+	}
+
+	c, err := consul.New([]string{addr}, opts...)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return c, func() {
+		c.Close()
+		stop()
+	}, nil
+}
+
+// waitForLeader waits until the consul agent at addr has elected a leader.
+func waitForLeader(addr string) error {
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(addr + "/v1/status/leader")
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if leader := strings.Trim(strings.TrimSpace(string(body)), `"`); resp.StatusCode == http.StatusOK && leader != "" {
+				return nil
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("the consul agent didn't elect a leader")
+}
@@ -0,0 +1,79 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package testserver
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/etcd"
+	"github.com/coreos/etcd/embed"
+)
+
+// Etcd starts an embedded single member etcd in a temporary directory
+// and returns an etcd v3 client connected to it.
+func Etcd(opts ...etcd.Option) (easykv.ReadWatcher, func(), error) {
+	dir, err := ioutil.TempDir("", "easykv-etcd")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientAddr, err := localAddr()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	peerAddr, err := localAddr()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	clientURL := url.URL{Scheme: "http", Host: clientAddr}
+	peerURL := url.URL{Scheme: "http", Host: peerAddr}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LCUrls, cfg.ACUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	stop := func() {
+		e.Close()
+		os.RemoveAll(dir)
+	}
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case err := <-e.Err():
+		stop()
+		return nil, nil, err
+	case <-time.After(startTimeout):
+		stop()
+		return nil, nil, errors.New("the embedded etcd didn't become ready")
+	}
+
+	c, err := etcd.New([]string{clientURL.String()}, append([]etcd.Option{etcd.WithVersion(3)}, opts...)...)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return c, func() {
+		c.Close()
+		stop()
+	}, nil
+}
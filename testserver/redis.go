@@ -0,0 +1,32 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package testserver
+
+import (
+	"github.com/HeavyHorst/easykv/redis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Redis starts an in-memory miniredis server and returns a redis client connected to it.
+func Redis(opts ...redis.Option) (*redis.Client, func(), error) {
+	m, err := miniredis.Run()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := redis.New([]string{m.Addr()}, opts...)
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	return c, func() {
+		c.Close()
+		m.Close()
+	}, nil
+}
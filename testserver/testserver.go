@@ -0,0 +1,44 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package testserver starts throwaway backends for the integration tests of code built on easyKV.
+// Every server returns a ready-to-use client and a cleanup func that closes the client,
+// stops the server and removes its data.
+package testserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// startTimeout is the time a server has to become ready.
+const startTimeout = 30 * time.Second
+
+// ErrNotInstalled is returned if the binary of a server isn't in the PATH.
+var ErrNotInstalled = errors.New("the server binary isn't installed")
+
+// freePort returns a local tcp port that is currently unused.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// localAddr returns the address of a free local port.
+func localAddr() (string, error) {
+	port, err := freePort()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("127.0.0.1:%d", port), nil
+}
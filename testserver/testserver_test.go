@@ -0,0 +1,92 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package testserver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/etcd"
+	"github.com/HeavyHorst/easykv/testutils"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+var testValues = map[string]string{
+	"/premtest/database/url":              "www.google.de",
+	"/premtest/database/user":             "Boris",
+	"/remtest/database/hosts/192.168.0.1": "test1",
+	"/remtest/database/hosts/192.168.0.2": "test2",
+}
+
+// check runs the read and, if watch is set, the watch tests against a client of a test server.
+func check(t *C, c easykv.ReadWatcher, watch bool) {
+	t.Assert(c.(easykv.Writer).SetValues(testValues), IsNil)
+	t.Check(testutils.GetValues(t, c), IsNil)
+	if !watch {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := c.WatchPrefix(ctx, "/remtest", easykv.WithKeys([]string{"/remtest"}))
+		t.Check(err, IsNil)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	t.Assert(c.(easykv.Writer).SetValues(map[string]string{"/remtest/database/hosts/192.168.0.3": "test3"}), IsNil)
+	wg.Wait()
+}
+
+func (s *FilterSuite) TestEtcd(t *C) {
+	c, cleanup, err := Etcd(etcd.WithNamespace("/tenant"))
+	t.Assert(err, IsNil)
+	defer cleanup()
+	check(t, c, true)
+}
+
+func (s *FilterSuite) TestRedis(t *C) {
+	c, cleanup, err := Redis()
+	t.Assert(err, IsNil)
+	defer cleanup()
+	check(t, c, false)
+}
+
+func (s *FilterSuite) TestConsul(t *C) {
+	c, cleanup, err := Consul()
+	if err == ErrNotInstalled {
+		t.Skip("consul isn't installed")
+	}
+	t.Assert(err, IsNil)
+	defer cleanup()
+	check(t, c, true)
+}
+
+func (s *FilterSuite) TestZooKeeperKerberos(t *C) {
+	c, cleanup, err := ZooKeeperKerberos()
+	if err == ErrNotInstalled {
+		t.Skip("zookeeper or the mit kerberos kdc isn't installed")
+	}
+	t.Assert(err, IsNil)
+	defer cleanup()
+	check(t, c, true)
+}
@@ -0,0 +1,154 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package testserver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv/zookeeper"
+)
+
+// kerberosRealm is the realm of the KDC of ZooKeeperKerberos.
+const kerberosRealm = "EASYKV.TEST"
+
+// ZooKeeperKerberos starts an MIT Kerberos KDC and a zookeeper server that requires SASL
+// authentication with Kerberos, and returns a zookeeper client that authenticates with the
+// keytab of the principal app. The krb5kdc, kdb5_util, kadmin.local and zkServer.sh binaries
+// have to be in the PATH, otherwise ErrNotInstalled is returned.
+func ZooKeeperKerberos(opts ...zookeeper.Option) (*zookeeper.Client, func(), error) {
+	bins := make(map[string]string)
+	for _, name := range []string{"krb5kdc", "kdb5_util", "kadmin.local", "zkServer.sh"} {
+		bin, err := exec.LookPath(name)
+		if err != nil {
+			return nil, nil, ErrNotInstalled
+		}
+		bins[name] = bin
+	}
+
+	dir, err := ioutil.TempDir("", "easykv-zookeeper")
+	if err != nil {
+		return nil, nil, err
+	}
+	kdcPort, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	zkAddr, err := localAddr()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	_, zkPort, _ := net.SplitHostPort(zkAddr)
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	files := map[string]string{
+		"krb5.conf": fmt.Sprintf("[libdefaults]\n default_realm = %[1]s\n dns_lookup_kdc = false\n dns_lookup_realm = false\n"+
+			" udp_preference_limit = 1\n\n[realms]\n %[1]s = {\n  kdc = 127.0.0.1:%[2]d\n }\n", kerberosRealm, kdcPort),
+		"kdc.conf": fmt.Sprintf("[realms]\n %[1]s = {\n  database_name = %[2]s\n  key_stash_file = %[3]s\n"+
+			"  acl_file = %[4]s\n  kdc_ports = %[5]d\n  kdc_tcp_ports = %[5]d\n }\n\n[logging]\n kdc = FILE:%[6]s\n",
+			kerberosRealm, path("principal"), path("stash"), path("kadm5.acl"), kdcPort, path("kdc.log")),
+		"kadm5.acl": "",
+		"jaas.conf": fmt.Sprintf("Server {\n  com.sun.security.auth.module.Krb5LoginModule required\n  useKeyTab=true\n"+
+			"  keyTab=%q\n  storeKey=true\n  useTicketCache=false\n  principal=\"zookeeper/127.0.0.1@%s\";\n};\n",
+			path("zookeeper.keytab"), kerberosRealm),
+		"zoo.cfg": fmt.Sprintf("tickTime=2000\ndataDir=%s\nclientPort=%s\nclientPortAddress=127.0.0.1\n"+
+			"admin.enableServer=false\nauthProvider.1=org.apache.zookeeper.server.auth.SASLAuthenticationProvider\n"+
+			"sessionRequireClientSASLAuth=true\nenforce.auth.enabled=true\nenforce.auth.schemes=sasl\n",
+			path("data"), zkPort),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(path(name), []byte(content), 0600); err != nil {
+			os.RemoveAll(dir)
+			return nil, nil, err
+		}
+	}
+	env := append(os.Environ(), "KRB5_CONFIG="+path("krb5.conf"), "KRB5_KDC_PROFILE="+path("kdc.conf"))
+
+	// the realm with the principals of the server and the client and their keytabs
+	setup := [][]string{
+		{bins["kdb5_util"], "create", "-s", "-r", kerberosRealm, "-P", "easykv"},
+		{bins["kadmin.local"], "-r", kerberosRealm, "-q", "addprinc -randkey zookeeper/127.0.0.1"},
+		{bins["kadmin.local"], "-r", kerberosRealm, "-q", "addprinc -randkey app"},
+		{bins["kadmin.local"], "-r", kerberosRealm, "-q", "ktadd -k " + path("zookeeper.keytab") + " zookeeper/127.0.0.1"},
+		{bins["kadmin.local"], "-r", kerberosRealm, "-q", "ktadd -k " + path("app.keytab") + " app"},
+	}
+	for _, args := range setup {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = env
+		if out, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return nil, nil, fmt.Errorf("%s failed: %v: %s", filepath.Base(args[0]), err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	kdc := exec.Command(bins["krb5kdc"], "-n", "-r", kerberosRealm)
+	kdc.Env = env
+	if err := kdc.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	server := exec.Command(bins["zkServer.sh"], "start-foreground", path("zoo.cfg"))
+	server.Env = append(env[:len(env):len(env)], "ZOO_LOG_DIR="+dir, "SERVER_JVMFLAGS=-Djava.security.auth.login.config="+path("jaas.conf")+
+		" -Djava.security.krb5.conf="+path("krb5.conf"))
+	if err := server.Start(); err != nil {
+		kdc.Process.Kill()
+		kdc.Wait()
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	stop := func() {
+		server.Process.Kill()
+		server.Wait()
+		kdc.Process.Kill()
+		kdc.Wait()
+		os.RemoveAll(dir)
+	}
+
+	if err := waitForPort(zkAddr); err != nil {
+		stop()
+		return nil, nil, err
+	}
+
+	c, err := zookeeper.New([]string{zkAddr}, append(opts[:len(opts):len(opts)], zookeeper.WithKerberos(zookeeper.KerberosOptions{
+		Principal: "app@" + kerberosRealm,
+		Keytab:    path("app.keytab"),
+		Config:    path("krb5.conf"),
+	}))...)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return c, func() {
+		c.Close()
+		stop()
+	}, nil
+}
+
+// waitForPort waits until a server listens on addr.
+func waitForPort(addr string) error {
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("no server is listening on " + addr)
+}
@@ -10,6 +10,7 @@ package testutils
 
 import (
 	"context"
+	"time"
 
 	"github.com/HeavyHorst/easykv"
 	"gopkg.in/check.v1"
@@ -60,3 +61,42 @@ func WatchPrefixError(t *check.C, c easykv.ReadWatcher) {
 	t.Check(num, check.Equals, uint64(0))
 	t.Check(err, check.Equals, easykv.ErrWatchNotSupported)
 }
+
+// Lock is a util function to test the easykv.Locker.Lock Method.
+// The lock must be exclusive and released by Unlock.
+func Lock(t *check.C, l easykv.Locker, key string) {
+	session, err := l.Lock(context.Background(), key, time.Second)
+	t.Assert(err, check.IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err = l.Lock(ctx, key, time.Second)
+	t.Check(err, check.Equals, context.DeadlineExceeded)
+
+	t.Assert(session.Unlock(), check.IsNil)
+
+	session, err = l.Lock(context.Background(), key, time.Second)
+	t.Assert(err, check.IsNil)
+	t.Check(session.Unlock(), check.IsNil)
+}
+
+// LockValues checks that values written with the lock session are deleted on Unlock.
+func LockValues(t *check.C, c interface {
+	easykv.ReadWatcher
+	easykv.Locker
+}, key string) {
+	session, err := c.Lock(context.Background(), key, time.Second)
+	t.Assert(err, check.IsNil)
+	w, ok := session.(easykv.SessionWriter)
+	t.Assert(ok, check.Equals, true)
+
+	valueKey := key + "/value"
+	t.Assert(w.SetValue(context.Background(), valueKey, "held"), check.IsNil)
+	v, err := easykv.GetValue(context.Background(), c, valueKey)
+	t.Assert(err, check.IsNil)
+	t.Check(v, check.Equals, "held")
+
+	t.Assert(session.Unlock(), check.IsNil)
+	_, err = easykv.GetValue(context.Background(), c, valueKey)
+	t.Check(err, check.Equals, easykv.ErrKeyNotFound)
+}
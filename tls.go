@@ -0,0 +1,101 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSOptions contains the TLS settings that are shared by all backends.
+type TLSOptions struct {
+	// ClientCert and ClientKey are the paths of the client certificate and key.
+	ClientCert string
+	ClientKey  string
+	// ClientCaKeys is the path of the CA certificates. The system pool is used if it's empty.
+	ClientCaKeys string
+	// ServerName overrides the name the server certificate is verified against.
+	ServerName string
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool
+	// SkipHostnameVerification verifies the certificate chain of the server, but not
+	// that the certificate is issued for its name, e.g. for servers that are reached by IP.
+	SkipHostnameVerification bool
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS12.
+	MinVersion uint16
+}
+
+// Enabled reports whether any TLS setting is set.
+func (o TLSOptions) Enabled() bool {
+	return o != TLSOptions{}
+}
+
+// Config returns the tls.Config for the options.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		MinVersion:         o.MinVersion,
+	}
+
+	if o.ClientCert != "" && o.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.ClientCaKeys != "" {
+		ca, err := ioutil.ReadFile(o.ClientCaKeys)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", o.ClientCaKeys)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.SkipHostnameVerification && !o.InsecureSkipVerify {
+		// the default verification checks the name, the chain is verified by hand instead
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(config.RootCAs)
+	}
+
+	return config, nil
+}
+
+// verifyChain returns a tls.Config.VerifyPeerCertificate that verifies the certificate
+// chain against roots, the system pool if it's nil, without checking the server name.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("the server sent no certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
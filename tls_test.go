@@ -0,0 +1,67 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestTLSOptions(t *C) {
+	t.Check(TLSOptions{}.Enabled(), Equals, false)
+	t.Check(TLSOptions{InsecureSkipVerify: true}.Enabled(), Equals, true)
+
+	config, err := TLSOptions{ServerName: "kv.local", MinVersion: tls.VersionTLS12}.Config()
+	t.Assert(err, IsNil)
+	t.Check(config.ServerName, Equals, "kv.local")
+	t.Check(config.MinVersion, Equals, uint16(tls.VersionTLS12))
+	t.Check(config.RootCAs, IsNil)
+	t.Check(config.Certificates, HasLen, 0)
+
+	f, err := ioutil.TempFile("", "easykv-ca")
+	t.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+	f.WriteString("not a certificate")
+	f.Close()
+
+	_, err = TLSOptions{ClientCaKeys: f.Name()}.Config()
+	t.Check(err, ErrorMatches, "no certificates found in .*")
+
+	_, err = TLSOptions{ClientCert: "missing.pem", ClientKey: "missing-key.pem"}.Config()
+	t.Check(err, NotNil)
+}
+
+func (s *FilterSuite) TestSkipHostnameVerification(t *C) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	ca := filepath.Join(t.MkDir(), "ca.pem")
+	t.Assert(ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600), IsNil)
+
+	dial := func(o TLSOptions) error {
+		config, err := o.Config()
+		t.Assert(err, IsNil)
+		conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), config)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	// the certificate is issued for example.com
+	t.Check(dial(TLSOptions{ClientCaKeys: ca, ServerName: "kv.local"}), NotNil)
+	t.Check(dial(TLSOptions{ClientCaKeys: ca, ServerName: "kv.local", SkipHostnameVerification: true}), IsNil)
+	// the chain is still verified
+	t.Check(dial(TLSOptions{ServerName: "kv.local", SkipHostnameVerification: true}), NotNil)
+}
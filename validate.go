@@ -0,0 +1,186 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Validator checks a snapshot returned by GetValues.
+type Validator interface {
+	Validate(vars map[string]string) error
+}
+
+// ValidatorFunc is an adapter to allow the use of ordinary functions as Validator.
+type ValidatorFunc func(vars map[string]string) error
+
+// Validate calls f(vars).
+func (f ValidatorFunc) Validate(vars map[string]string) error {
+	return f(vars)
+}
+
+// ValidationError is returned if a snapshot fails validation and there is no previous good snapshot.
+type ValidationError struct {
+	Keys []string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validation of %s failed: %v", strings.Join(e.Keys, ", "), e.Err)
+}
+
+// RequiredKeys fails if one of keys is missing.
+func RequiredKeys(keys ...string) Validator {
+	return ValidatorFunc(func(vars map[string]string) error {
+		var missing []string
+		for _, k := range keys {
+			if _, ok := vars[k]; !ok {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing keys %s", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// inPrefix reports whether key is prefix or below it.
+func inPrefix(key, prefix string) bool {
+	key, prefix = path.Join("/", key), path.Join("/", prefix)
+	return prefix == "/" || key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+// eachValue calls fn for all keys below prefix in sorted order and returns the first error.
+func eachValue(vars map[string]string, prefix string, fn func(k, v string) error) error {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		if inPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, vars[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchRegexp fails if a value below prefix doesn't match re.
+func MatchRegexp(prefix string, re *regexp.Regexp) Validator {
+	return ValidatorFunc(func(vars map[string]string) error {
+		return eachValue(vars, prefix, func(k, v string) error {
+			if !re.MatchString(v) {
+				return fmt.Errorf("value of %s doesn't match %s", k, re)
+			}
+			return nil
+		})
+	})
+}
+
+// ValidJSON fails if a value below prefix isn't valid json.
+func ValidJSON(prefix string) Validator {
+	return ValidatorFunc(func(vars map[string]string) error {
+		return eachValue(vars, prefix, func(k, v string) error {
+			if !json.Valid([]byte(v)) {
+				return fmt.Errorf("value of %s isn't valid json", k)
+			}
+			return nil
+		})
+	})
+}
+
+// ValidateOptions represents options for NewValidated.
+type ValidateOptions struct {
+	Validators []Validator
+	OnReject   func(err *ValidationError)
+}
+
+// ValidateOption configures a Validated backend.
+type ValidateOption func(*ValidateOptions)
+
+// WithValidator adds a Validator. The validators run in the order they are added.
+func WithValidator(v Validator) ValidateOption {
+	return func(o *ValidateOptions) {
+		o.Validators = append(o.Validators, v)
+	}
+}
+
+// WithRejectHandler sets a function that is called for every rejected snapshot.
+func WithRejectHandler(fn func(err *ValidationError)) ValidateOption {
+	return func(o *ValidateOptions) {
+		o.OnReject = fn
+	}
+}
+
+// Validated validates the results of GetValues.
+// If a snapshot fails validation, the last good snapshot of the same keys is returned instead.
+// If there is no good snapshot yet, a *ValidationError is returned.
+// Since consumers read the values again after WatchPrefix returns,
+// watch-triggered reloads are validated as well.
+type Validated struct {
+	ReadWatcher
+
+	options ValidateOptions
+	mu      sync.Mutex
+	good    map[string]map[string]string
+}
+
+// NewValidated returns a new Validated for the given backend.
+func NewValidated(rw ReadWatcher, opts ...ValidateOption) *Validated {
+	var options ValidateOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	return &Validated{
+		ReadWatcher: rw,
+		options:     options,
+		good:        make(map[string]map[string]string),
+	}
+}
+
+// GetValues returns the values of keys if they pass all validators,
+// or the last good snapshot of keys otherwise.
+func (v *Validated) GetValues(keys []string) (map[string]string, error) {
+	vars, err := v.ReadWatcher.GetValues(keys)
+	if err != nil {
+		return vars, err
+	}
+
+	key := cacheKey(keys)
+	for _, validator := range v.options.Validators {
+		if err := validator.Validate(vars); err != nil {
+			verr := &ValidationError{Keys: keys, Err: err}
+			if v.options.OnReject != nil {
+				v.options.OnReject(verr)
+			}
+
+			v.mu.Lock()
+			good, ok := v.good[key]
+			v.mu.Unlock()
+			if !ok {
+				return nil, verr
+			}
+			return copyVars(good), nil
+		}
+	}
+
+	v.mu.Lock()
+	v.good[key] = copyVars(vars)
+	v.mu.Unlock()
+	return vars, nil
+}
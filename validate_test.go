@@ -0,0 +1,59 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"regexp"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestValidators(t *C) {
+	vars := map[string]string{
+		"/app/port":        "8080",
+		"/app/config":      `{"debug": true}`,
+		"/app/config/bad":  `{`,
+		"/app/configother": `{`,
+	}
+
+	t.Check(RequiredKeys("/app/port").Validate(vars), IsNil)
+	t.Check(RequiredKeys("/app/port", "/app/host", "/app/user").Validate(vars), ErrorMatches, "missing keys /app/host, /app/user")
+
+	t.Check(MatchRegexp("/app/port", regexp.MustCompile(`^\d+$`)).Validate(vars), IsNil)
+	t.Check(MatchRegexp("/app/config", regexp.MustCompile(`^\d+$`)).Validate(vars), ErrorMatches, "value of /app/config doesn't match .*")
+
+	t.Check(ValidJSON("/app/port").Validate(vars), IsNil)
+	t.Check(ValidJSON("/app/config").Validate(vars), ErrorMatches, "value of /app/config/bad isn't valid json")
+}
+
+func (s *FilterSuite) TestValidated(t *C) {
+	c := newTestClient(map[string]string{"/app/port": "8080"})
+	var rejected []*ValidationError
+	v := NewValidated(c,
+		WithValidator(RequiredKeys("/app/port")),
+		WithValidator(MatchRegexp("/app", regexp.MustCompile(`^\d+$`))),
+		WithRejectHandler(func(err *ValidationError) { rejected = append(rejected, err) }),
+	)
+
+	vars, err := v.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/port": "8080"})
+
+	c.mu.Lock()
+	c.data["/app/port"] = "eighty"
+	c.mu.Unlock()
+	vars, err = v.GetValues([]string{"/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/app/port": "8080"})
+	t.Assert(rejected, HasLen, 1)
+	t.Check(rejected[0], ErrorMatches, "validation of /app failed: value of /app/port doesn't match .*")
+
+	_, err = v.GetValues([]string{"/app/port"})
+	t.Check(err, FitsTypeOf, &ValidationError{})
+}
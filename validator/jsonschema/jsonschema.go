@@ -0,0 +1,73 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package jsonschema validates json values against a JSON schema.
+//
+// Example:
+//
+//	v, err := jsonschema.New("/app/config", `{"type": "object", "required": ["port"]}`)
+//	if err != nil {
+//		...
+//	}
+//	rw = easykv.NewValidated(rw, easykv.WithValidator(v))
+package jsonschema
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Validator validates all values below a prefix against a JSON schema.
+type Validator struct {
+	prefix string
+	schema *gojsonschema.Schema
+}
+
+// New compiles schema and returns a Validator for all values below prefix.
+func New(prefix, schema string) (*Validator, error) {
+	s, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{prefix: path.Join("/", prefix), schema: s}, nil
+}
+
+// Validate validates the values below the prefix in sorted key order
+// and returns the errors of the first invalid value.
+func (v *Validator) Validate(vars map[string]string) error {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		k2 := path.Join("/", k)
+		if v.prefix == "/" || k2 == v.prefix || strings.HasPrefix(k2, v.prefix+"/") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		result, err := v.schema.Validate(gojsonschema.NewStringLoader(vars[k]))
+		if err != nil {
+			return fmt.Errorf("value of %s: %v", k, err)
+		}
+		if !result.Valid() {
+			errs := make([]string, 0, len(result.Errors()))
+			for _, e := range result.Errors() {
+				errs = append(errs, e.String())
+			}
+			return fmt.Errorf("value of %s: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+var _ easykv.Validator = (*Validator)(nil)
@@ -0,0 +1,49 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package jsonschema
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+const schema = `{
+	"type": "object",
+	"properties": {"port": {"type": "integer"}},
+	"required": ["port"]
+}`
+
+func (s *FilterSuite) TestValidate(t *C) {
+	v, err := New("/app/config", schema)
+	t.Assert(err, IsNil)
+
+	t.Check(v.Validate(map[string]string{
+		"/app/config":  `{"port": 8080}`,
+		"/app/ignored": `not json`,
+	}), IsNil)
+
+	err = v.Validate(map[string]string{"/app/config": `{"port": "8080"}`})
+	t.Check(err, ErrorMatches, `value of /app/config: port: Invalid type.*`)
+
+	err = v.Validate(map[string]string{"/app/config": `not json`})
+	t.Check(err, ErrorMatches, `value of /app/config: .*`)
+}
+
+func (s *FilterSuite) TestInvalidSchema(t *C) {
+	_, err := New("/app", `{"type": 1}`)
+	t.Check(err, NotNil)
+}
@@ -0,0 +1,43 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"path"
+)
+
+// A ValueGetter can read a single key directly, without walking a prefix.
+// It returns ErrKeyNotFound if the key doesn't exist.
+type ValueGetter interface {
+	GetValue(ctx context.Context, key string) (string, error)
+}
+
+// GetValue returns the value of a single key.
+// Backends that don't implement ValueGetter are queried with GetValues
+// and the key is looked up in the result.
+func GetValue(ctx context.Context, rw ReadWatcher, key string) (string, error) {
+	if vg, ok := rw.(ValueGetter); ok {
+		return vg.GetValue(ctx, key)
+	}
+
+	vars, err := rw.GetValues([]string{key})
+	if err != nil {
+		return "", err
+	}
+	if v, ok := vars[key]; ok {
+		return v, nil
+	}
+	for k, v := range vars {
+		if path.Join("/", k) == path.Join("/", key) {
+			return v, nil
+		}
+	}
+	return "", ErrKeyNotFound
+}
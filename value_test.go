@@ -0,0 +1,29 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestGetValueFallback(t *C) {
+	c := newTestClient(map[string]string{
+		"/app/name":     "easykv",
+		"/app/name/sub": "other",
+	})
+
+	v, err := GetValue(context.Background(), c, "/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "easykv")
+
+	_, err = GetValue(context.Background(), c, "/app/missing")
+	t.Check(err, Equals, ErrKeyNotFound)
+}
@@ -0,0 +1,145 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv/internal/awsauth"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// AWSOptions configures the aws auth method.
+// With the default iam type a signed sts:GetCallerIdentity request is sent to vault,
+// the ec2 type logs in with the pkcs7 signed instance identity document.
+type AWSOptions struct {
+	Type        string
+	Role        string
+	Region      string
+	HeaderValue string
+	Nonce       string
+	Credentials AWSCredentialsProvider
+}
+
+// AWSCredentials sign the GetCallerIdentity request of the iam login.
+type AWSCredentials = awsauth.Credentials
+
+// An AWSCredentialsProvider returns AWSCredentials.
+type AWSCredentialsProvider = awsauth.CredentialsProvider
+
+// AWSCredentialsProviderFunc is an adapter to allow the use of ordinary functions as AWSCredentialsProvider.
+type AWSCredentialsProviderFunc = awsauth.ProviderFunc
+
+// StaticAWSCredentials returns the given credentials.
+func StaticAWSCredentials(accessKeyID, secretAccessKey, sessionToken string) AWSCredentialsProvider {
+	return awsauth.Static(accessKeyID, secretAccessKey, sessionToken)
+}
+
+// EnvAWSCredentials reads the credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+var EnvAWSCredentials = awsauth.Env
+
+// ContainerAWSCredentials reads the credentials of an ecs task role or an eks pod identity
+// from the container credentials endpoint.
+var ContainerAWSCredentials = awsauth.Container
+
+// InstanceAWSCredentials reads the credentials of the ec2 instance profile from the instance metadata service.
+var InstanceAWSCredentials = awsauth.Instance
+
+// AWSCredentialChain returns the credentials of the first provider that succeeds.
+func AWSCredentialChain(providers ...AWSCredentialsProvider) AWSCredentialsProvider {
+	return awsauth.Chain(providers...)
+}
+
+// DefaultAWSCredentials tries the environment, the container credentials and the instance profile.
+var DefaultAWSCredentials = awsauth.Default
+
+// stsEndpoint returns the sts endpoint of region.
+func stsEndpoint(region string) string {
+	if region == "" || region == "us-east-1" {
+		return "https://sts.amazonaws.com/"
+	}
+	return "https://sts." + region + ".amazonaws.com/"
+}
+
+// signSTSRequest signs a POST request to the sts endpoint with aws signature version 4
+// and returns its headers.
+func signSTSRequest(endpoint, region, body string, header http.Header, creds AWSCredentials, now time.Time) (http.Header, error) {
+	if region == "" {
+		region = "us-east-1"
+	}
+	if err := awsauth.Sign(http.MethodPost, endpoint, "sts", region, body, header, creds, now); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// awsLoginData returns the login request of the aws auth method.
+func awsLoginData(options AWSOptions) (map[string]interface{}, error) {
+	if options.Type == "ec2" {
+		pkcs7, err := awsauth.IMDSGet("/latest/dynamic/instance-identity/pkcs7")
+		if err != nil {
+			return nil, err
+		}
+		data := map[string]interface{}{
+			"role":  options.Role,
+			"pkcs7": strings.Replace(string(pkcs7), "\n", "", -1),
+		}
+		if options.Nonce != "" {
+			data["nonce"] = options.Nonce
+		}
+		return data, nil
+	}
+
+	provider := options.Credentials
+	if provider == nil {
+		provider = DefaultAWSCredentials
+	}
+	creds, err := provider.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := stsEndpoint(options.Region)
+	body := "Action=GetCallerIdentity&Version=2011-06-15"
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if options.HeaderValue != "" {
+		header.Set("X-Vault-AWS-IAM-Server-ID", options.HeaderValue)
+	}
+	header, err = signSTSRequest(endpoint, options.Region, body, header, creds, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	headers, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"role":                    options.Role,
+		"iam_http_request_method": http.MethodPost,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(endpoint)),
+		"iam_request_body":        base64.StdEncoding.EncodeToString([]byte(body)),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+	}, nil
+}
+
+// awsLogin logs in with the aws auth method.
+func awsLogin(c *vaultapi.Client, login string, options AWSOptions) (*vaultapi.Secret, error) {
+	data, err := awsLoginData(options)
+	if err != nil {
+		return nil, err
+	}
+	return c.Logical().Write(login, data)
+}
@@ -0,0 +1,124 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// AzureOptions configures the azure auth method.
+// The managed identity token is fetched from the instance metadata service.
+// SubscriptionID, ResourceGroupName, VMName and VMSSName default to the values
+// of the instance metadata. ClientID selects a user assigned identity.
+type AzureOptions struct {
+	Role              string
+	Resource          string
+	ClientID          string
+	SubscriptionID    string
+	ResourceGroupName string
+	VMName            string
+	VMSSName          string
+}
+
+// azureMetadataURL is the address of the azure instance metadata service.
+var azureMetadataURL = "http://169.254.169.254"
+
+// azureDefaultResource is the default resource of the managed identity token.
+const azureDefaultResource = "https://management.azure.com/"
+
+// azureGet reads a path of the instance metadata service.
+func azureGet(p string, q url.Values, v interface{}) error {
+	body, err := metadataGet(azureMetadataURL+p+"?"+q.Encode(), http.Header{"Metadata": {"true"}})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// azureToken returns a managed identity token.
+func azureToken(options AzureOptions) (string, error) {
+	q := url.Values{}
+	q.Set("api-version", "2018-02-01")
+	q.Set("resource", options.Resource)
+	if options.Resource == "" {
+		q.Set("resource", azureDefaultResource)
+	}
+	if options.ClientID != "" {
+		q.Set("client_id", options.ClientID)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := azureGet("/metadata/identity/oauth2/token", q, &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// azureInstance fills the unset instance values of options from the instance metadata.
+func azureInstance(options AzureOptions) (AzureOptions, error) {
+	if options.SubscriptionID != "" && options.ResourceGroupName != "" && (options.VMName != "" || options.VMSSName != "") {
+		return options, nil
+	}
+	var instance struct {
+		Compute struct {
+			SubscriptionID    string `json:"subscriptionId"`
+			ResourceGroupName string `json:"resourceGroupName"`
+			Name              string `json:"name"`
+			VMScaleSetName    string `json:"vmScaleSetName"`
+		} `json:"compute"`
+	}
+	if err := azureGet("/metadata/instance", url.Values{"api-version": {"2021-02-01"}}, &instance); err != nil {
+		return options, err
+	}
+	if options.SubscriptionID == "" {
+		options.SubscriptionID = instance.Compute.SubscriptionID
+	}
+	if options.ResourceGroupName == "" {
+		options.ResourceGroupName = instance.Compute.ResourceGroupName
+	}
+	if options.VMName == "" && options.VMSSName == "" {
+		// the name of a scale set instance is not accepted as vm_name
+		if instance.Compute.VMScaleSetName != "" {
+			options.VMSSName = instance.Compute.VMScaleSetName
+		} else {
+			options.VMName = instance.Compute.Name
+		}
+	}
+	return options, nil
+}
+
+// azureLogin logs in with the azure auth method.
+func azureLogin(c *vaultapi.Client, login string, options AzureOptions) (*vaultapi.Secret, error) {
+	jwt, err := azureToken(options)
+	if err != nil {
+		return nil, err
+	}
+	options, err = azureInstance(options)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"role":                options.Role,
+		"jwt":                 jwt,
+		"subscription_id":     options.SubscriptionID,
+		"resource_group_name": options.ResourceGroupName,
+	}
+	if options.VMSSName != "" {
+		data["vmss_name"] = options.VMSSName
+	} else {
+		data["vm_name"] = options.VMName
+	}
+	return c.Logical().Write(login, data)
+}
@@ -0,0 +1,88 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// valueCache caches the results of GetValues, see WithCacheMaxTTL.
+type valueCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedValues
+	// generation is incremented by invalidate, reads that started
+	// before don't store their results.
+	generation uint64
+}
+
+type cachedValues struct {
+	vars    map[string]string
+	expires time.Time
+}
+
+// valueCacheKey returns the same key for the same set of prefixes.
+func valueCacheKey(keys []string) string {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+// get returns a copy of the unexpired values of key and the generation to store a new read with.
+func (vc *valueCache) get(key string, now time.Time) (map[string]string, uint64, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	e, ok := vc.entries[key]
+	if !ok || !now.Before(e.expires) {
+		return nil, vc.generation, false
+	}
+	return copyValues(e.vars), vc.generation, true
+}
+
+// put stores the values of key unless the cache was invalidated after generation.
+func (vc *valueCache) put(key string, generation uint64, vars map[string]string, expires time.Time) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if vc.generation != generation {
+		return
+	}
+	if vc.entries == nil {
+		vc.entries = make(map[string]cachedValues)
+	}
+	vc.entries[key] = cachedValues{vars: copyValues(vars), expires: expires}
+}
+
+// invalidate removes all values.
+func (vc *valueCache) invalidate() {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	vc.entries = nil
+	vc.generation++
+}
+
+func copyValues(vars map[string]string) map[string]string {
+	c := make(map[string]string, len(vars))
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
+
+// cacheTTL returns the shortest lease duration of ttls, but at most maxTTL.
+func cacheTTL(maxTTL time.Duration, ttls map[string]time.Duration) time.Duration {
+	ttl := maxTTL
+	for _, t := range ttls {
+		if t > 0 && t < ttl {
+			ttl = t
+		}
+	}
+	return ttl
+}
@@ -15,132 +15,173 @@ package vault
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
 	vaultapi "github.com/hashicorp/vault/api"
 )
 
 // Client is a wrapper around the vault client
 type Client struct {
-	client *vaultapi.Client
-}
-
-// get a parameter from a map, panics if no value was found
-func getParameter(key string, parameters map[string]string) string {
-	value := parameters[key]
-	if value == "" {
-		// panic if a configuration is missing
-		panic(fmt.Sprintf("%s is missing from configuration", key))
-	}
-	return value
-}
-
-// panicToError converts a panic to an error
-func panicToError(err *error) {
-	if r := recover(); r != nil {
-		switch t := r.(type) {
-		case string:
-			*err = errors.New(t)
-		case error:
-			*err = t
-		default: // panic again if we don't know how to handle
-			panic(r)
-		}
-	}
+	address  string
+	authType string
+
+	mu      sync.RWMutex
+	client  *vaultapi.Client
+	options Options
+	mounts  mountCache
+	leases  leaseCache
+	values  valueCache
+
+	unwrapped unwrappedSecret
+
+	// ctx is canceled by Close, it cancels the reads without a context of their own.
+	ctx         context.Context
+	stopWatch   context.CancelFunc
+	stopRenewal context.CancelFunc
 }
 
 // authenticate with the remote client
-func authenticate(c *vaultapi.Client, authType string, params map[string]string) (err error) {
+// It returns the auth info of the token, or nil if it is unknown.
+func authenticate(c *vaultapi.Client, authType string, params map[string]string, options Options) (*vaultapi.SecretAuth, error) {
 	var secret *vaultapi.Secret
+	var err error
 
-	// handle panics gracefully by creating an error
-	// this would happen when we get a parameter that is missing
-	defer panicToError(&err)
+	mount := strings.Trim(options.AuthMount, "/")
+	if mount == "" {
+		mount = authType
+	}
+	login := path.Join("/auth", mount, "login")
 
 	switch authType {
 	case "approle":
-		secret, err = c.Logical().Write("/auth/approle/login", map[string]interface{}{
-			"role_id":   getParameter("role-id", params),
-			"secret_id": getParameter("secret-id", params),
-		})
+		if err = requireParams(params, "role-id", "secret-id"); err == nil {
+			secret, err = c.Logical().Write(login, map[string]interface{}{
+				"role_id":   params["role-id"],
+				"secret_id": params["secret-id"],
+			})
+		}
 	case "app-id":
-		secret, err = c.Logical().Write("/auth/app-id/login", map[string]interface{}{
-			"app_id":  getParameter("app-id", params),
-			"user_id": getParameter("user-id", params),
-		})
+		if err = requireParams(params, "app-id", "user-id"); err == nil {
+			secret, err = c.Logical().Write(login, map[string]interface{}{
+				"app_id":  params["app-id"],
+				"user_id": params["user-id"],
+			})
+		}
 	case "github":
-		secret, err = c.Logical().Write("/auth/github/login", map[string]interface{}{
-			"token": getParameter("token", params),
-		})
-	case "token":
-		c.SetToken(getParameter("token", params))
-		secret, err = c.Logical().Read("/auth/token/lookup-self")
-	case "userpass":
-		username, password := getParameter("username", params), getParameter("password", params)
-		secret, err = c.Logical().Write(fmt.Sprintf("/auth/userpass/login/%s", username), map[string]interface{}{
-			"password": password,
-		})
-	case "kubernetes":
-		jwt, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
-		if err != nil {
-			return err
+		if err = requireParams(params, "token"); err == nil {
+			secret, err = c.Logical().Write(login, map[string]interface{}{
+				"token": params["token"],
+			})
+		}
+	case "token", "token-file":
+		if err = requireParams(params, "token"); err == nil {
+			c.SetToken(params["token"])
+			secret, err = c.Logical().Read("/auth/token/lookup-self")
+		}
+	case "userpass", "ldap":
+		if err = requireParams(params, "username", "password"); err == nil {
+			secret, err = c.Logical().Write(path.Join(login, params["username"]), map[string]interface{}{
+				"password": params["password"],
+			})
 		}
-		secret, err = c.Logical().Write("/auth/kubernetes/login", map[string]interface{}{
-			"jwt":  string(jwt[:]),
-			"role": getParameter("role-id", params),
-		})
+	case "kubernetes":
+		secret, err = kubernetesLogin(c, login, params["role-id"], options.Kubernetes)
 	case "cert":
-		secret, err = c.Logical().Write("/auth/cert/login", nil)
+		var data map[string]interface{}
+		if options.CertRole != "" {
+			data = map[string]interface{}{"name": options.CertRole}
+		}
+		secret, err = c.Logical().Write(login, data)
+	case "aws":
+		secret, err = awsLogin(c, login, options.AWS)
+	case "gcp":
+		secret, err = gcpLogin(c, login, options.GCP)
+	case "azure":
+		secret, err = azureLogin(c, login, options.Azure)
+	case "jwt", "oidc":
+		secret, err = jwtLogin(c, login, options.JWT)
+	default:
+		return nil, fmt.Errorf("unsupported vault auth type %q", authType)
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// if the token has already been set
 	if c.Token() != "" {
-		return nil
+		if authType == "token" || authType == "token-file" {
+			return tokenAuth(c.Token(), secret), nil
+		}
+		return nil, nil
 	}
 
 	// the default place for a token is in the auth section
 	// otherwise, the backend will set the token itself
+	if secret == nil || secret.Auth == nil {
+		return nil, &easykv.BackendError{Kind: easykv.ErrAuthentication, Err: errors.New("the vault login returned no token")}
+	}
 	c.SetToken(secret.Auth.ClientToken)
-	return nil
+	return secret.Auth, nil
 }
 
-func getConfig(address, cert, key, caCert string) (*vaultapi.Config, error) {
+// tokenAuth returns the auth info of a token from its lookup-self response.
+func tokenAuth(token string, secret *vaultapi.Secret) *vaultapi.SecretAuth {
+	if secret == nil || secret.Data == nil {
+		return nil
+	}
+	auth := &vaultapi.SecretAuth{ClientToken: token}
+	auth.Renewable, _ = secret.Data["renewable"].(bool)
+	if ttl, err := secret.TokenTTL(); err == nil {
+		auth.LeaseDuration = int(ttl.Seconds())
+	}
+	return auth
+}
+
+func getConfig(address string, options Options) (*vaultapi.Config, error) {
 	conf := vaultapi.DefaultConfig()
-	conf.Address = address
+	if address != "" {
+		conf.Address = address
+	}
 
-	tlsConfig := &tls.Config{}
-	if cert != "" && key != "" {
-		clientCert, err := tls.LoadX509KeyPair(cert, key)
-		if err != nil {
-			return nil, err
+	if options.Timeout > 0 {
+		conf.Timeout = options.Timeout
+	}
+	if r := options.Retry; r.Disabled {
+		conf.MaxRetries = 0
+	} else {
+		if r.MaxRetries > 0 {
+			conf.MaxRetries = r.MaxRetries
+		}
+		if r.MinWait > 0 {
+			conf.MinRetryWait = r.MinWait
+		}
+		if r.MaxWait > 0 {
+			conf.MaxRetryWait = r.MaxWait
 		}
-		tlsConfig.Certificates = []tls.Certificate{clientCert}
-		tlsConfig.BuildNameToCertificate()
 	}
 
-	if caCert != "" {
-		ca, err := ioutil.ReadFile(caCert)
-		if err != nil {
-			return nil, err
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(ca)
-		tlsConfig.RootCAs = caCertPool
+	tlsConfig, err := options.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	proxyFunc, err := easykv.ProxyFunc(options.Proxy)
+	if err != nil {
+		return nil, err
 	}
 
 	conf.HttpClient.Transport = &http.Transport{
+		Proxy:           proxyFunc,
 		TLSClientConfig: tlsConfig,
 	}
 
@@ -149,87 +190,394 @@ func getConfig(address, cert, key, caCert string) (*vaultapi.Config, error) {
 
 // New returns an *vault.Client with a connection to named machines.
 // It returns an error if a connection to the cluster cannot be made.
+// The token-file auth method reads the token from the file set with WithTokenFrom,
+// ~/.vault-token by default, and authenticates again whenever the file changes,
+// e.g. when a vault agent writes a new token to its sink.
+// If address is empty, it is read from VAULT_ADDR (BAO_ADDR for OpenBao).
 func New(address, authType string, opts ...Option) (*Client, error) {
-	var options Options
+	options := Options{Redactor: easykv.NewRedactor()}
 	for _, o := range opts {
 		o(&options)
 	}
 
-	params := map[string]string{
-		"role-id":   options.RoleID,
-		"secret-id": options.SecretID,
-		"app-id":    options.AppID,
-		"user-id":   options.UserID,
-		"username":  options.Auth.Username,
-		"password":  options.Auth.Password,
-		"token":     options.Token,
-		"cert":      options.TLS.ClientCert,
-		"key":       options.TLS.ClientKey,
-		"caCert":    options.TLS.ClientCaKeys,
-	}
-
 	if authType == "" {
 		return nil, errors.New("you have to set the auth type when using the vault backend")
 	}
-	conf, err := getConfig(address, options.TLS.ClientCert, options.TLS.ClientKey, options.TLS.ClientCaKeys)
 
-	if err != nil {
+	address = flavorDefaults(address, &options)
+	if authType == "token-file" && options.TokenRef.IsZero() {
+		options.TokenRef = credentials.File(defaultTokenFile(options.Flavor))
+	}
+
+	c := &Client{address: address, authType: authType}
+	if err := c.connect(options); err != nil {
 		return nil, err
 	}
 
-	c, err := vaultapi.NewClient(conf)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx, c.stopWatch = ctx, cancel
+	go credentials.WatchRefs(ctx, func() error { return c.Reload() }, options.refs(),
+		credentials.WithErrorHandler(func(err error) {
+			c.logger().Warn("can't reload vault credentials", "err", err)
+		}),
+	)
+	return c, nil
+}
+
+// connect creates and authenticates a new vault client with the given options
+// and replaces the current one.
+// Credential references are read again on every call.
+func (c *Client) connect(options Options) error {
+	creds, err := options.resolve()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := authenticate(c, authType, params); err != nil {
-		return nil, err
+	conf, err := getConfig(c.address, options)
+	if err != nil {
+		return err
+	}
+
+	vc, err := vaultapi.NewClient(conf)
+	if err != nil {
+		return err
+	}
+	if options.Namespace != "" {
+		vc.SetNamespace(options.Namespace)
+	}
+
+	// credentials must never show up in errors or logs
+	if options.Redactor != nil {
+		options.Redactor.Add(creds.Token, creds.SecretID, creds.Auth.Password, creds.JWT.JWT, creds.WrappedToken)
+	}
+
+	creds, err = c.unwrap(vc, creds)
+	if err != nil {
+		return classifyError(err, options.Redactor, true)
+	}
+	if options.Redactor != nil {
+		options.Redactor.Add(creds.Token, creds.SecretID)
+	}
+
+	params := map[string]string{
+		"role-id":   creds.RoleID,
+		"secret-id": creds.SecretID,
+		"app-id":    creds.AppID,
+		"user-id":   creds.UserID,
+		"username":  creds.Auth.Username,
+		"password":  creds.Auth.Password,
+		"token":     creds.Token,
+		"cert":      creds.TLS.ClientCert,
+		"key":       creds.TLS.ClientKey,
+		"caCert":    creds.TLS.ClientCaKeys,
 	}
-	return &Client{c}, nil
+
+	logger := options.logger()
+	auth, err := authenticate(vc, c.authType, params, creds)
+	if err != nil {
+		err = classifyError(err, options.Redactor, true)
+		logger.Warn("vault authentication failed", "auth", c.authType, "err", err)
+		return err
+	}
+	logger.Info("vault authentication succeeded", "auth", c.authType)
+
+	c.mu.Lock()
+	c.client = vc
+	c.options = options
+	if c.stopRenewal != nil {
+		c.stopRenewal()
+		c.stopRenewal = nil
+	}
+	if !options.Renewal.Disabled && auth != nil && auth.LeaseDuration > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.stopRenewal = cancel
+		go c.keepAlive(ctx, vc, auth, options.Renewal)
+	}
+	c.mu.Unlock()
+	return nil
 }
 
-// Close is only meant to fulfill the easykv.ReadWatcher interface.
-// Does nothing.
-func (c *Client) Close() {}
+// Reload applies opts on top of the current options, authenticates again and
+// replaces the vault client. This allows to rotate tokens, auth credentials and
+// TLS certificates of a running client. Requests that are already running finish
+// with the old client. If the authentication fails, the old client is kept.
+// Otherwise the values cached with WithCacheMaxTTL are dropped.
+func (c *Client) Reload(opts ...Option) error {
+	c.mu.RLock()
+	options := c.options
+	c.mu.RUnlock()
+	for _, o := range opts {
+		o(&options)
+	}
+	if err := c.connect(options); err != nil {
+		return err
+	}
+	c.values.invalidate()
+	return nil
+}
+
+// logger returns the current Logger.
+func (c *Client) logger() easykv.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.options.logger()
+}
+
+// Redactor returns the Redactor the values read by the client are registered with,
+// e.g. to redact them in the logs of the application too. It returns nil if the
+// registration is disabled.
+func (c *Client) Redactor() *easykv.Redactor {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.options.Redactor
+}
+
+// redact registers the values with the Redactor.
+func (c *Client) redact(values ...string) {
+	c.mu.RLock()
+	r := c.options.Redactor
+	c.mu.RUnlock()
+	if r != nil {
+		r.Add(values...)
+	}
+}
+
+// api returns the current vault client.
+func (c *Client) api() *vaultapi.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+// Close stops watching the referenced credential files and renewing the token and leases.
+func (c *Client) Close() {
+	c.leases.clear()
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+	c.mu.Lock()
+	if c.stopRenewal != nil {
+		c.stopRenewal()
+		c.stopRenewal = nil
+	}
+	c.mu.Unlock()
+}
 
 // GetValues is used to lookup all keys with a prefix.
 // Several prefixes can be specified in the keys array.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
+	return c.GetValuesContext(c.readContext(), keys)
+}
+
+// GetValuesContext is GetValues with a context, which cancels e.g. waiting for control group approval.
+// With WithCacheMaxTTL, the values are cached.
+func (c *Client) GetValuesContext(ctx context.Context, keys []string) (map[string]string, error) {
+	c.mu.RLock()
+	maxTTL := c.options.CacheMaxTTL
+	c.mu.RUnlock()
+	if maxTTL <= 0 {
+		vars, _, errs := c.read(ctx, keys, nil)
+		if err := firstError(errs); err != nil {
+			return nil, err
+		}
+		return vars, nil
+	}
+
+	key, now := valueCacheKey(keys), time.Now()
+	vars, generation, ok := c.values.get(key, now)
+	if ok {
+		return vars, nil
+	}
+	vars, ttls, errs := c.read(ctx, keys, nil)
+	if err := firstError(errs); err != nil {
+		return nil, err
+	}
+	c.values.put(key, generation, vars, now.Add(cacheTTL(maxTTL, ttls)))
+	return vars, nil
+}
+
+// readContext returns the context of reads without one, which is canceled by Close.
+func (c *Client) readContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// GetValuesPartial is used to lookup all keys with a prefix.
+// Unlike GetValues it doesn't fail if a single secret can't be read,
+// but returns the readable values plus the errors of the unreadable secrets.
+func (c *Client) GetValuesPartial(keys []string) (map[string]string, map[string]error) {
+	vars, _, errs := c.read(c.readContext(), keys, nil)
+	return vars, errs
+}
+
+// GetValuesWithTTL is used to lookup all keys with a prefix.
+// It additionally returns the lease duration of the secret every key belongs to.
+func (c *Client) GetValuesWithTTL(keys []string) (map[string]string, map[string]time.Duration, error) {
+	vars, ttls, errs := c.read(c.readContext(), keys, nil)
+	if err := firstError(errs); err != nil {
+		return nil, nil, err
+	}
+	return vars, ttls, nil
+}
+
+// firstError returns the error of the lexically first key, or nil if errs is empty.
+func firstError(errs map[string]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	failed := make([]string, 0, len(errs))
+	for key := range errs {
+		failed = append(failed, key)
+	}
+	sort.Strings(failed)
+	return errs[failed[0]]
+}
+
+// GetValuesExplain is used to lookup all keys with a prefix.
+// It additionally returns all LIST and READ requests that were sent to vault.
+func (c *Client) GetValuesExplain(keys []string) (map[string]string, []easykv.Operation, error) {
+	var tr easykv.Trace
+	vars, _, errs := c.read(c.readContext(), keys, &tr)
+	if err := firstError(errs); err != nil {
+		return nil, tr.Operations(), err
+	}
+	return vars, tr.Operations(), nil
+}
+
+// read looks up all keys with the given prefixes and returns the values,
+// the lease durations of the keys and the errors of all unreadable secrets.
+// Keys with a version suffix (e.g. /secret/app?version=3) read the given version of a KV v2 secret.
+// The secrets are read concurrently, the results are merged in key order.
+// All requests are recorded in tr, reads that wait for control group approval stop if ctx is canceled.
+func (c *Client) read(ctx context.Context, keys []string, tr *easykv.Trace) (map[string]string, map[string]time.Duration, map[string]error) {
+	vc := c.api()
 	branches := make(map[string]bool)
+	errs := make(map[string]error)
+
+	c.mu.RLock()
+	versions := make(map[string]int, len(c.options.Versions))
+	for k, v := range c.options.Versions {
+		versions[normalizeKey(k)] = v
+	}
+	c.mu.RUnlock()
 
 	for _, key := range keys {
-		walkTree(c.client, key, branches)
+		key, version, err := splitVersion(key)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		if version > 0 {
+			versions[normalizeKey(key)] = version
+		}
+		c.walkTree(vc, key, branches, tr)
 	}
 
-	vars := make(map[string]string)
+	sorted := make([]string, 0, len(branches))
 	for key := range branches {
-		resp, err := c.client.Logical().Read(key)
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
 
-		if err != nil {
-			return nil, err
-		}
-		if resp == nil || resp.Data == nil {
+	c.mu.RLock()
+	workers := c.options.Concurrency
+	c.mu.RUnlock()
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+
+	type result struct {
+		vars map[string]string
+		ttl  time.Duration
+		err  error
+	}
+	results := make([]result, len(sorted))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(sorted); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := &results[i]
+				r.vars, r.ttl, r.err = c.readBranch(ctx, vc, sorted[i], versions[normalizeKey(sorted[i])], tr)
+			}
+		}()
+	}
+	for i := range sorted {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	vars := make(map[string]string)
+	ttls := make(map[string]time.Duration)
+	for i, key := range sorted {
+		r := results[i]
+		if r.err != nil {
+			errs[key] = c.classify(r.err)
 			continue
 		}
-
-		// if the key has only one string value
-		// treat it as a string and not a map of values
-		if val, ok := isKV(resp.Data); ok {
-			vars[key] = val
-		} else {
-			// save the json encoded response
-			// and flatten it to allow usage of gets & getvs
-			js, _ := json.Marshal(resp.Data)
-			vars[key] = string(js)
-			flatten(key, resp.Data, vars)
-			delete(vars, key)
+		for k, v := range r.vars {
+			vars[k] = v
+			ttls[k] = r.ttl
 		}
 	}
-	return vars, nil
+	return vars, ttls, errs
+}
+
+// readBranch reads the secret at key and returns its flattened values and lease duration.
+// If version isn't 0, the given version of the secret is read.
+func (c *Client) readBranch(ctx context.Context, vc *vaultapi.Client, key string, version int, tr *easykv.Trace) (map[string]string, time.Duration, error) {
+	kc := c.forKey(vc, key)
+	m := c.mountOf(context.Background(), kc, key)
+	start := time.Now()
+	var resp *vaultapi.Secret
+	var err error
+	if version > 0 {
+		resp, err = c.readVersion(kc, m, key, version)
+	} else {
+		resp, err = c.readSecret(context.Background(), kc, key, m.apiPath(key, "data"))
+	}
+	tr.Record("READ", key, start, err)
+	if err == nil && isControlGroupResponse(resp) {
+		resp, err = c.waitForApproval(ctx, kc, key, resp.WrapInfo)
+	}
+
+	if err != nil {
+		c.logger().Warn("can't read vault secret", "key", key, "err", err)
+		return nil, 0, err
+	}
+	data := unwrapData(m, resp)
+	if data == nil {
+		return nil, 0, nil
+	}
+
+	secretVars := make(map[string]string)
+	// if the key has only one string value
+	// treat it as a string and not a map of values
+	if val, ok := isKV(data); ok {
+		secretVars[key] = val
+	} else {
+		// flatten the response to allow usage of gets & getvs
+		flatten(key, data, secretVars)
+	}
+	if err := c.decrypt(context.Background(), vc, secretVars); err != nil {
+		c.logger().Warn("can't decrypt vault secret", "key", key, "err", err)
+		return nil, 0, err
+	}
+
+	for _, v := range secretVars {
+		c.redact(v)
+	}
+	return secretVars, time.Duration(resp.LeaseDuration) * time.Second, nil
 }
 
 // recursively walk the branches in the Vault, adding to branches map
-func walkTree(c *vaultapi.Client, key string, branches map[string]bool) error {
+// KV v2 mounts are listed through their metadata endpoint.
+func (c *Client) walkTree(vc *vaultapi.Client, key string, branches map[string]bool, tr *easykv.Trace) error {
 	// strip trailing slash as long as it's not the only character
 	if last := len(key) - 1; last > 0 && key[last] == '/' {
 		key = key[:last]
@@ -241,7 +589,14 @@ func walkTree(c *vaultapi.Client, key string, branches map[string]bool) error {
 	}
 	branches[key] = true
 
-	resp, err := c.Logical().List(key)
+	kc := c.forKey(vc, key)
+	start := time.Now()
+	resp, err := kc.Logical().List(c.mountOf(context.Background(), kc, key).apiPath(key, "metadata"))
+	tr.Record("LIST", key, start, err)
+	if re, ok := err.(*vaultapi.ResponseError); ok && re.StatusCode == http.StatusMethodNotAllowed {
+		// secret engines like database can't list their secrets
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -261,7 +616,7 @@ func walkTree(c *vaultapi.Client, key string, branches map[string]bool) error {
 		switch innerKey.(type) {
 		case string:
 			innerKey = path.Join(key, "/", innerKey.(string))
-			walkTree(c, innerKey.(string), branches)
+			c.walkTree(vc, innerKey.(string), branches, tr)
 		}
 	}
 	return nil
@@ -294,7 +649,77 @@ func flatten(key string, value interface{}, vars map[string]string) {
 	}
 }
 
-// WatchPrefix - not implemented at the moment
-func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
-	return 0, easykv.ErrWatchNotSupported
+// SetValues writes all key-value pairs to vault.
+// Every key is written as its own secret with the value stored in the "value" field.
+func (c *Client) SetValues(values map[string]string) error {
+	defer c.values.invalidate()
+	vc := c.api()
+	for k, v := range values {
+		kc := c.forKey(vc, k)
+		m := c.mountOf(context.Background(), kc, k)
+		data := map[string]interface{}{"value": v}
+		if m.version == 2 {
+			data = map[string]interface{}{"data": data}
+		}
+		if _, err := kc.Logical().Write(m.apiPath(k, "data"), data); err != nil {
+			return c.classify(err)
+		}
+	}
+	return nil
+}
+
+// DeleteValues deletes all given keys from vault.
+// On KV v2 mounts the latest version is deleted, older versions are kept.
+func (c *Client) DeleteValues(keys []string) error {
+	defer c.values.invalidate()
+	vc := c.api()
+	for _, k := range keys {
+		kc := c.forKey(vc, k)
+		if _, err := kc.Logical().Delete(c.mountOf(context.Background(), kc, k).apiPath(k, "data")); err != nil {
+			return c.classify(err)
+		}
+	}
+	return nil
+}
+
+// GetValue returns the value of a single key.
+// The key is either a secret with a single "value" field,
+// or a field of a secret (e.g. secret/db/password for the password field of secret/db).
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	vc := c.forKey(c.api(), key)
+	val, err := c.getValue(ctx, vc, key)
+	if err != nil {
+		return "", c.classify(err)
+	}
+	vars := map[string]string{key: val}
+	if err := c.decrypt(ctx, c.api(), vars); err != nil {
+		return "", c.classify(err)
+	}
+	c.redact(vars[key])
+	return vars[key], nil
+}
+
+// getValue reads the stored value of a single key.
+func (c *Client) getValue(ctx context.Context, vc *vaultapi.Client, key string) (string, error) {
+	m := c.mountOf(ctx, vc, key)
+	resp, err := c.readSecret(ctx, vc, key, m.apiPath(key, "data"))
+	if err != nil {
+		return "", err
+	}
+	if data := unwrapData(m, resp); data != nil {
+		if val, ok := isKV(data); ok {
+			return val, nil
+		}
+	}
+
+	resp, err = vc.Logical().ReadWithContext(ctx, m.apiPath(path.Dir(key), "data"))
+	if err != nil {
+		return "", err
+	}
+	if data := unwrapData(m, resp); data != nil {
+		if val, ok := data[path.Base(key)].(string); ok {
+			return val, nil
+		}
+	}
+	return "", easykv.ErrKeyNotFound
 }
@@ -9,13 +9,37 @@
 package vault
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
 	"github.com/HeavyHorst/easykv/testutils"
+	vaultapi "github.com/hashicorp/vault/api"
 
 	. "gopkg.in/check.v1"
 )
@@ -39,12 +63,54 @@ func init() {
 }
 
 func (s *FilterSuite) TestWatchPrefix(t *C) {
-	c, err := New("http://127.0.0.1:8200", "token", WithToken(string(token)))
-	if err != nil {
-		t.Error(err)
+	f, ts := newFakeVault(map[string]int{"secret/": 2, "kv/": 1})
+	defer ts.Close()
+	f.put("secret/app/name", map[string]interface{}{"value": "easykv"})
+	f.put("secret/other/name", map[string]interface{}{"value": "other"})
+	f.put("kv/app/name", map[string]interface{}{"value": "easykv"})
+
+	c, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	for _, prefix := range []string{"/secret/app", "/kv/app"} {
+		index, err := c.WatchPrefix(context.Background(), prefix)
+		t.Assert(err, IsNil)
+		t.Check(index, Not(Equals), uint64(0))
+
+		// unchanged secrets block until ctx is canceled
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		i, err := c.WatchPrefix(ctx, prefix, easykv.WithWaitIndex(index), easykv.WithInterval(10*time.Millisecond))
+		cancel()
+		t.Check(err, Equals, easykv.ErrWatchCanceled)
+		t.Check(i, Equals, index)
+
+		done := make(chan uint64)
+		go func() {
+			i, _ := c.WatchPrefix(context.Background(), prefix, easykv.WithWaitIndex(index), easykv.WithInterval(10*time.Millisecond))
+			done <- i
+		}()
+		time.Sleep(50 * time.Millisecond)
+		f.put("secret/other/name", map[string]interface{}{"value": "changed"})
+		f.put(strings.TrimPrefix(prefix, "/")+"/name", map[string]interface{}{"value": "changed"})
+		select {
+		case i := <-done:
+			t.Check(i, Not(Equals), index)
+		case <-time.After(5 * time.Second):
+			t.Fatal("change of " + prefix + " not detected")
+		}
 	}
 
-	testutils.WatchPrefixError(t, c)
+	// changes of other keys are ignored
+	f.put("secret/app/db", map[string]interface{}{"value": "db"})
+	index, err := c.WatchPrefix(context.Background(), "/secret/app", easykv.WithKeys([]string{"/secret/app/name"}))
+	t.Assert(err, IsNil)
+	f.put("secret/app/db", map[string]interface{}{"value": "changed"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.WatchPrefix(ctx, "/secret/app", easykv.WithWaitIndex(index), easykv.WithKeys([]string{"/secret/app/name"}),
+		easykv.WithInterval(10*time.Millisecond))
+	t.Check(err, Equals, easykv.ErrWatchCanceled)
 }
 
 func (s *FilterSuite) TestGetValues(t *C) {
@@ -60,16 +126,1585 @@ func (s *FilterSuite) TestGetValues(t *C) {
 	testutils.GetValues(t, c)
 }
 
-func (s *FilterSuite) TestGetParameterEmptyMap(t *C) {
-	var err error
-	m := map[string]string{}
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer panicToError(&err)
-		getParameter("test", m)
-	}()
-	wg.Wait()
+func (s *FilterSuite) TestRequireParamsEmptyMap(t *C) {
+	err := requireParams(map[string]string{}, "test")
 	t.Check(err.Error(), Equals, "test is missing from configuration")
+	t.Check(requireParams(map[string]string{"test": "value"}, "test"), IsNil)
+}
+
+func (s *FilterSuite) TestErrorClassification(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/userpass/login/alice":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["password"] != "correct-horse" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors": ["invalid password %s"]}`, body["password"])
+				return
+			}
+			fmt.Fprint(w, `{"auth": {"client_token": "alice-token"}}`)
+		case "/v1/secret/denied":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+		case "/v1/secret/sealed":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"errors": ["Vault is sealed"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	r := easykv.NewRedactor()
+	_, err := New(ts.URL, "userpass", WithRedactor(r), WithRetry(RetryOptions{Disabled: true}),
+		WithBasicAuth(BasicAuthOptions{Username: "alice", Password: "battery-staple"}))
+	t.Assert(err, NotNil)
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrAuthentication)
+	t.Check(strings.Contains(err.Error(), "battery-staple"), Equals, false)
+
+	_, err = New(ts.URL, "userpass", WithRedactor(r), WithBasicAuth(BasicAuthOptions{Username: "alice"}))
+	t.Check(err, ErrorMatches, "password is missing from configuration")
+	t.Check(easykv.ErrorKind(err), IsNil)
+
+	_, err = New(ts.URL, "kerberos", WithRedactor(r))
+	t.Check(err, ErrorMatches, `unsupported vault auth type "kerberos"`)
+
+	c, err := New(ts.URL, "userpass", WithRedactor(r), WithKVVersion(1), WithRetry(RetryOptions{Disabled: true}),
+		WithBasicAuth(BasicAuthOptions{Username: "alice", Password: "correct-horse"}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	_, err = c.GetValue(context.Background(), "/secret/denied")
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrPermissionDenied)
+	_, err = c.GetValue(context.Background(), "/secret/sealed")
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrConnection)
+	_, err = c.GetValue(context.Background(), "/secret/missing")
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+
+	addr := ts.URL
+	ts.Close()
+	_, err = New(addr, "token", WithToken("token"), WithRetry(RetryOptions{Disabled: true}))
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrConnection)
+}
+
+func (s *FilterSuite) TestControlGroup(t *C) {
+	var approved int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/secret/gated":
+			fmt.Fprint(w, `{"wrap_info": {"token": "wrapping-token", "accessor": "wrapping-accessor", "ttl": 60}}`)
+		case "/v1/sys/control-group/request":
+			fmt.Fprintf(w, `{"data": {"approved": %t}}`, atomic.LoadInt32(&approved) == 1)
+		case "/v1/sys/wrapping/unwrap":
+			fmt.Fprint(w, `{"data": {"value": "s3cret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	conf, err := getConfig(ts.URL, Options{})
+	t.Assert(err, IsNil)
+	vc, err := vaultapi.NewClient(conf)
+	t.Assert(err, IsNil)
+	vc.SetToken("token")
+
+	c := &Client{client: vc}
+	_, err = c.GetValues([]string{"/secret/gated"})
+	t.Assert(err, FitsTypeOf, &ApprovalRequiredError{})
+	t.Check(err.(*ApprovalRequiredError).Accessor, Equals, "wrapping-accessor")
+	t.Check(errors.Is(fmt.Errorf("read: %w", err), ErrApprovalRequired), Equals, true)
+
+	// waiting for approval stops with the context of the read
+	c.options.ControlGroup = ControlGroupOptions{Timeout: time.Minute, Interval: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.GetValuesContext(ctx, []string{"/secret/gated"})
+	t.Check(errors.Is(err, context.DeadlineExceeded), Equals, true, Commentf("%v", err))
+	t.Check(time.Since(start) < time.Second, Equals, true)
+
+	atomic.StoreInt32(&approved, 1)
+	c.options.ControlGroup = ControlGroupOptions{Timeout: time.Second, Interval: 10 * time.Millisecond}
+	vars, err := c.GetValues([]string{"/secret/gated"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/gated": "s3cret"})
+}
+
+func (s *FilterSuite) TestRedactor(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case "/v1/secret/db":
+			fmt.Fprint(w, `{"data": {"password": "hunter22", "port": "80"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	r := easykv.NewRedactor()
+	c, err := New(ts.URL, "token", WithToken("token"), WithRedactor(r))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/secret/db"})
+	t.Assert(err, IsNil)
+	t.Check(r.Redact("password=hunter22 port=80"), Equals, "password=[REDACTED] port=80")
+	t.Check(c.Redactor(), Equals, r)
+
+	// the secrets of a client aren't redacted by other clients
+	c2, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+	t.Check(c2.Redactor().Redact("password=hunter22"), Equals, "password=hunter22")
+}
+
+func (s *FilterSuite) TestReload(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "new-token" && r.URL.Path != "/v1/auth/token/lookup-self" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case "/v1/secret/app":
+			fmt.Fprint(w, `{"data": {"value": "ok"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "token", WithToken("old-token"))
+	t.Assert(err, IsNil)
+	_, err = c.GetValues([]string{"/secret/app"})
+	t.Check(err, NotNil)
+
+	t.Assert(c.Reload(WithToken("new-token")), IsNil)
+	vars, err := c.GetValues([]string{"/secret/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app": "ok"})
+}
+
+func (s *FilterSuite) TestTokenFrom(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case r.Header.Get("X-Vault-Token") != "new-token":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+		default:
+			fmt.Fprint(w, `{"data": {"value": "ok"}}`)
+		}
+	}))
+	defer ts.Close()
+
+	p := filepath.Join(t.MkDir(), "token")
+	t.Assert(ioutil.WriteFile(p, []byte("old-token\n"), 0600), IsNil)
+
+	c, err := New(ts.URL, "token", WithTokenFrom(credentials.File(p)))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	_, err = c.GetValues([]string{"/secret/app"})
+	t.Check(err, NotNil)
+
+	t.Assert(ioutil.WriteFile(p, []byte("new-token\n"), 0600), IsNil)
+	t.Assert(c.Reload(), IsNil)
+	vars, err := c.GetValues([]string{"/secret/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app": "ok"})
+
+	_, err = New(ts.URL, "token", WithTokenFrom(credentials.Env("EASYKV_MISSING_TOKEN")))
+	t.Check(err, ErrorMatches, "environment variable EASYKV_MISSING_TOKEN is not set")
+}
+
+func (s *FilterSuite) TestProxy(t *C) {
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		default:
+			fmt.Fprint(w, `{"data": {"value": "ok"}}`)
+		}
+	}))
+	defer proxy.Close()
+
+	c, err := New("http://vault.invalid:8200", "token", WithToken("token"), WithProxy(proxy.URL))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/secret/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app": "ok"})
+	t.Check(len(hosts) > 0, Equals, true)
+	for _, h := range hosts {
+		t.Check(h, Equals, "vault.invalid:8200")
+	}
+
+	_, err = New("http://vault.invalid:8200", "token", WithToken("token"), WithProxy("ftp://proxy"))
+	t.Check(err, ErrorMatches, `unsupported proxy scheme "ftp"`)
+}
+
+func (s *FilterSuite) TestAWSAuth(t *C) {
+	var login map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/aws/login":
+			login = nil
+			json.NewDecoder(r.Body).Decode(&login)
+			fmt.Fprint(w, `{"auth": {"client_token": "aws-token"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	decode := func(key string) string {
+		v, _ := login[key].(string)
+		b, err := base64.StdEncoding.DecodeString(v)
+		t.Assert(err, IsNil)
+		return string(b)
+	}
+
+	c, err := New(ts.URL, "aws", WithAWS(AWSOptions{
+		Role:        "app",
+		Region:      "eu-west-1",
+		HeaderValue: "vault.example.com",
+		Credentials: StaticAWSCredentials("AKID", "SECRET", "SESSION"),
+	}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Check(c.client.Token(), Equals, "aws-token")
+	t.Check(login["role"], Equals, "app")
+	t.Check(login["iam_http_request_method"], Equals, "POST")
+	t.Check(decode("iam_request_url"), Equals, "https://sts.eu-west-1.amazonaws.com/")
+	t.Check(decode("iam_request_body"), Equals, "Action=GetCallerIdentity&Version=2011-06-15")
+
+	var headers http.Header
+	t.Assert(json.Unmarshal([]byte(decode("iam_request_headers")), &headers), IsNil)
+	t.Check(headers.Get("X-Vault-AWS-IAM-Server-ID"), Equals, "vault.example.com")
+	t.Check(headers.Get("X-Amz-Security-Token"), Equals, "SESSION")
+	t.Check(headers.Get("Authorization"), Matches,
+		`AWS4-HMAC-SHA256 Credential=AKID/\d{8}/eu-west-1/sts/aws4_request, `+
+			`SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-vault-aws-iam-server-id, Signature=[0-9a-f]{64}`)
+
+	// the signature only depends on the request, the credentials and the time
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	h1, err := signSTSRequest(stsEndpoint(""), "", "body", http.Header{}, AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, now)
+	t.Assert(err, IsNil)
+	h2, err := signSTSRequest(stsEndpoint("us-east-1"), "us-east-1", "body", http.Header{}, AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, now)
+	t.Assert(err, IsNil)
+	t.Check(h1.Get("Authorization"), Equals, h2.Get("Authorization"))
+	t.Check(h1.Get("X-Amz-Date"), Equals, "20150830T123600Z")
+
+	_, err = New(ts.URL, "aws", WithAWS(AWSOptions{
+		Credentials: AWSCredentialChain(AWSCredentialsProviderFunc(func() (AWSCredentials, error) {
+			return AWSCredentials{}, errors.New("no credentials")
+		})),
+	}))
+	t.Check(err, ErrorMatches, "no aws credentials found: no credentials")
+}
+
+func (s *FilterSuite) TestGCPAuth(t *C) {
+	var login map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/gcp/login":
+			login = nil
+			json.NewDecoder(r.Body).Decode(&login)
+			fmt.Fprint(w, `{"auth": {"client_token": "gcp-token"}}`)
+		case "/computeMetadata/v1/instance/service-accounts/default/identity":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, "gce-jwt:"+r.URL.Query().Get("audience"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	t.Assert(err, IsNil)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	t.Assert(err, IsNil)
+	keyFile, err := json.Marshal(map[string]string{
+		"client_email":   "app@project.iam.gserviceaccount.com",
+		"private_key_id": "kid",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	t.Assert(err, IsNil)
+	p := filepath.Join(t.MkDir(), "key.json")
+	t.Assert(ioutil.WriteFile(p, keyFile, 0600), IsNil)
+
+	c, err := New(ts.URL, "gcp", WithGCP(GCPOptions{Role: "app", CredentialsFile: p}))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(login["role"], Equals, "app")
+
+	parts := strings.Split(login["jwt"].(string), ".")
+	t.Assert(parts, HasLen, 3)
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	t.Assert(err, IsNil)
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	t.Check(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig), IsNil)
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	t.Assert(err, IsNil)
+	var c1 map[string]interface{}
+	t.Assert(json.Unmarshal(claims, &c1), IsNil)
+	t.Check(c1["sub"], Equals, "app@project.iam.gserviceaccount.com")
+	t.Check(c1["aud"], Equals, "vault/app")
+
+	defer func(u string) { gcpMetadataURL = u }(gcpMetadataURL)
+	gcpMetadataURL = ts.URL
+	c, err = New(ts.URL, "gcp", WithGCP(GCPOptions{Role: "app"}))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(login["jwt"], Equals, "gce-jwt:http://vault/app")
+}
+
+func (s *FilterSuite) TestAzureAuth(t *C) {
+	var login map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/metadata/") && r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/azure/login":
+			login = nil
+			json.NewDecoder(r.Body).Decode(&login)
+			fmt.Fprint(w, `{"auth": {"client_token": "azure-token"}}`)
+		case "/metadata/identity/oauth2/token":
+			fmt.Fprintf(w, `{"access_token": "msi:%s"}`, r.URL.Query().Get("resource"))
+		case "/metadata/instance":
+			fmt.Fprint(w, `{"compute": {"subscriptionId": "sub", "resourceGroupName": "rg", "name": "vmss_0", "vmScaleSetName": "vmss"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	defer func(u string) { azureMetadataURL = u }(azureMetadataURL)
+	azureMetadataURL = ts.URL
+
+	c, err := New(ts.URL, "azure", WithAzure(AzureOptions{Role: "app"}))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(login, DeepEquals, map[string]interface{}{
+		"role":                "app",
+		"jwt":                 "msi:https://management.azure.com/",
+		"subscription_id":     "sub",
+		"resource_group_name": "rg",
+		"vmss_name":           "vmss",
+	})
+
+	c, err = New(ts.URL, "azure", WithAzure(AzureOptions{Role: "app", ResourceGroupName: "other", VMName: "vm"}))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(login["resource_group_name"], Equals, "other")
+	t.Check(login["vm_name"], Equals, "vm")
+}
+
+func (s *FilterSuite) TestLDAPAuth(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var login map[string]string
+		json.NewDecoder(r.Body).Decode(&login)
+		if r.URL.Path != "/v1/auth/ldap/login/alice" || login["password"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors": ["invalid credentials"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"auth": {"client_token": "ldap-token"}}`)
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "ldap", WithBasicAuth(BasicAuthOptions{Username: "alice", Password: "secret"}))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(c.client.Token(), Equals, "ldap-token")
+
+	_, err = New(ts.URL, "ldap", WithBasicAuth(BasicAuthOptions{Username: "alice", Password: "wrong"}))
+	t.Check(err, NotNil)
+	_, err = New(ts.URL, "ldap", WithBasicAuth(BasicAuthOptions{Username: "alice"}))
+	t.Check(err, ErrorMatches, "password is missing from configuration")
+}
+
+func (s *FilterSuite) TestJWTAuth(t *C) {
+	var mu sync.Mutex
+	var logins []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var login map[string]string
+		json.NewDecoder(r.Body).Decode(&login)
+		mu.Lock()
+		logins = append(logins, r.URL.Path+" "+login["role"]+" "+login["jwt"])
+		mu.Unlock()
+		fmt.Fprint(w, `{"auth": {"client_token": "jwt-token"}}`)
+	}))
+	defer ts.Close()
+
+	p := filepath.Join(t.MkDir(), "token")
+	t.Assert(ioutil.WriteFile(p, []byte("jwt-1\n"), 0600), IsNil)
+
+	c, err := New(ts.URL, "jwt", WithJWT(JWTOptions{Role: "ci", JWTRef: credentials.File(p)}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Assert(ioutil.WriteFile(p, []byte("jwt-2\n"), 0600), IsNil)
+	t.Assert(c.Reload(), IsNil)
+
+	c2, err := New(ts.URL, "oidc", WithJWT(JWTOptions{Role: "ci", Mount: "/github/", JWT: "jwt-3"}))
+	t.Assert(err, IsNil)
+	c2.Close()
+
+	mu.Lock()
+	t.Check(logins[:3], DeepEquals, []string{
+		"/v1/auth/jwt/login ci jwt-1",
+		"/v1/auth/jwt/login ci jwt-2",
+		"/v1/auth/github/login ci jwt-3",
+	})
+	mu.Unlock()
+
+	_, err = New(ts.URL, "jwt", WithJWT(JWTOptions{Role: "ci"}))
+	t.Check(err, ErrorMatches, "jwt is missing from configuration")
+}
+
+func (s *FilterSuite) TestTokenFile(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {}}`)
+	}))
+	defer ts.Close()
+
+	home := t.MkDir()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	t.Assert(ioutil.WriteFile(filepath.Join(home, ".vault-token"), []byte("cli-token\n"), 0600), IsNil)
+
+	c, err := New(ts.URL, "token-file")
+	t.Assert(err, IsNil)
+	t.Check(c.client.Token(), Equals, "cli-token")
+	c.Close()
+
+	p := filepath.Join(t.MkDir(), "sink")
+	t.Assert(ioutil.WriteFile(p, []byte("agent-token-1"), 0600), IsNil)
+	c, err = New(ts.URL, "token-file", WithTokenFrom(credentials.File(p)))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Assert(ioutil.WriteFile(p, []byte("agent-token-2"), 0600), IsNil)
+
+	t.Assert(c.Reload(), IsNil)
+	t.Check(c.client.Token(), Equals, "agent-token-2")
+}
+
+func (s *FilterSuite) TestOpenBao(t *C) {
+	var mu sync.Mutex
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Get("X-Vault-Namespace")+" "+r.Header.Get("X-Vault-Token"))
+		mu.Unlock()
+		fmt.Fprint(w, `{"data": {}}`)
+	}))
+	defer ts.Close()
+
+	for k, v := range map[string]string{"BAO_ADDR": ts.URL, "BAO_TOKEN": "bao-token", "BAO_NAMESPACE": "team"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+	c, err := New("", "token", WithFlavor(FlavorOpenBao))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(requests, DeepEquals, []string{"team bao-token"})
+
+	home := t.MkDir()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	t.Assert(ioutil.WriteFile(filepath.Join(home, ".bao-token"), []byte("cli-token\n"), 0600), IsNil)
+	c, err = New(ts.URL, "token-file", WithFlavor(FlavorOpenBao), WithNamespace("other"))
+	t.Assert(err, IsNil)
+	c.Close()
+	t.Check(requests[1], Equals, "other cli-token")
+}
+
+func (s *FilterSuite) TestHCP(t *C) {
+	var mu sync.Mutex
+	logins := 0
+	version := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/oauth2/token" {
+			r.ParseForm()
+			if r.PostForm.Get("client_secret") != "principal-secret" || r.PostForm.Get("grant_type") != "client_credentials" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"message": "invalid client"}`)
+				return
+			}
+			logins++
+			fmt.Fprint(w, `{"access_token": "hcp-token", "expires_in": 3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer hcp-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		base := "/secrets/2023-11-28/organizations/org/projects/proj"
+		switch r.URL.Path {
+		case base + "/apps":
+			fmt.Fprint(w, `{"apps": [{"name": "payments"}, {"name": "web"}, {"name": "locked"}]}`)
+		case base + "/apps/payments/secrets:open":
+			if r.URL.Query().Get("pagination.next_page_token") == "" {
+				fmt.Fprintf(w, `{"secrets": [{"name": "DB_PASSWORD", "latest_version": %d, "static_version": {"value": "pw-%d"}}],
+					"pagination": {"next_page_token": "page-2"}}`, version, version)
+				return
+			}
+			fmt.Fprint(w, `{"secrets": [{"name": "DB_USER", "latest_version": 1, "static_version": {"value": "payments"}}]}`)
+		case base + "/apps/web/secrets:open":
+			fmt.Fprint(w, `{"secrets": [{"name": "PORT", "latest_version": 1, "static_version": {"value": "8080"}}]}`)
+		case base + "/apps/locked/secrets:open":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "permission denied"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	_, err := NewHCP("org", "proj", WithHCP(HCPOptions{ClientID: "principal", ClientSecret: "wrong", APIURL: ts.URL, AuthURL: ts.URL + "/oauth2/token"}))
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrAuthentication)
+	_, err = NewHCP("org", "", WithHCP(HCPOptions{ClientID: "principal", ClientSecret: "principal-secret"}))
+	t.Check(err, NotNil)
+
+	c, err := NewHCP("org", "proj", WithHCP(HCPOptions{ClientID: "principal", ClientSecret: "principal-secret", APIURL: ts.URL, AuthURL: ts.URL + "/oauth2/token"}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/payments", "/web/PORT", "/missing"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/payments/DB_PASSWORD": "pw-1",
+		"/payments/DB_USER":     "payments",
+		"/web/PORT":             "8080",
+	})
+
+	// apps and secrets are matched by prefix
+	vars, err = c.GetValues([]string{"/pay", "/web/PO", "/payments/DB_U"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/payments/DB_PASSWORD": "pw-1",
+		"/payments/DB_USER":     "payments",
+		"/web/PORT":             "8080",
+	})
+	vars, err = c.GetValues([]string{"/payments/DB_U", "/we/"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/payments/DB_USER": "payments"})
+	_, err = c.GetValues([]string{"/"})
+	t.Check(easykv.ErrorKind(err), Equals, easykv.ErrPermissionDenied)
+	t.Check(logins, Equals, 1)
+
+	index, err := c.WatchPrefix(context.Background(), "/payments")
+	t.Assert(err, IsNil)
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+	index2, err := c.WatchPrefix(context.Background(), "/payments", easykv.WithWaitIndex(index), easykv.WithInterval(10*time.Millisecond))
+	t.Assert(err, IsNil)
+	t.Check(index2, Not(Equals), index)
+	vars, err = c.GetValues([]string{"/payments/DB_PASSWORD"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/payments/DB_PASSWORD": "pw-2"})
+}
+
+func (s *FilterSuite) TestNamespace(t *C) {
+	var mu sync.Mutex
+	var logins []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.Header.Get("X-Vault-Namespace")
+		switch {
+		case r.URL.Path == "/v1/auth/approle/login":
+			mu.Lock()
+			logins = append(logins, ns)
+			mu.Unlock()
+			fmt.Fprint(w, `{"auth": {"client_token": "token"}}`)
+		case r.URL.Query().Get("list") == "true":
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/"):
+			fmt.Fprintf(w, `{"data": {"value": %q}}`, ns)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "approle", WithRoleID("role"), WithSecretID("secret"),
+		WithNamespace("team-a"),
+		WithKeyNamespace("/secret/shared", "root"),
+		WithKeyNamespace("/secret/shared/b", "team-b"),
+	)
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/secret/app", "/secret/shared/a", "/secret/shared/b"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/secret/app":      "team-a",
+		"/secret/shared/a": "root",
+		"/secret/shared/b": "team-b",
+	})
+	v, err := c.GetValue(context.Background(), "/secret/shared/b")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "team-b")
+
+	mu.Lock()
+	t.Check(logins, DeepEquals, []string{"team-a"})
+	mu.Unlock()
+}
+
+func (s *FilterSuite) TestDynamicSecrets(t *C) {
+	var mu sync.Mutex
+	issued, renewals := 0, 0
+	renewable := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case r.URL.Path == "/v1/database/creds/app":
+			issued++
+			fmt.Fprintf(w, `{"lease_id": "database/creds/app/%d", "lease_duration": 1, "renewable": true,
+				"data": {"username": "user-%d", "password": "secret"}}`, issued, issued)
+		case r.URL.Path == "/v1/sys/leases/renew":
+			if !renewable {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors": ["lease not found"]}`)
+				return
+			}
+			renewals++
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprintf(w, `{"lease_id": %q, "lease_duration": 1, "renewable": true}`, body["lease_id"])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	count := func(n *int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return *n
+	}
+
+	c, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	// the credentials are read once and their lease is renewed
+	for i := 0; i < 2; i++ {
+		vars, err := c.GetValues([]string{"/database/creds/app"})
+		t.Assert(err, IsNil)
+		t.Check(vars["/database/creds/app/username"], Equals, "user-1")
+	}
+	index, err := c.WatchPrefix(context.Background(), "/database/creds/app")
+	t.Assert(err, IsNil)
+	time.Sleep(1500 * time.Millisecond)
+	t.Check(count(&renewals) > 0, Equals, true)
+	t.Check(count(&issued), Equals, 1)
+
+	// a lease that can't be renewed anymore triggers the watch
+	mu.Lock()
+	renewable = false
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	i, err := c.WatchPrefix(ctx, "/database/creds/app", easykv.WithWaitIndex(index), easykv.WithInterval(time.Hour))
+	t.Assert(err, IsNil)
+	t.Check(i, Not(Equals), index)
+	vars, err := c.GetValues([]string{"/database/creds/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/database/creds/app/username"], Not(Equals), "user-1")
+}
+
+func (s *FilterSuite) TestTransit(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			switch r.URL.Path {
+			case "/v1/secret/app":
+				fmt.Fprint(w, `{"data": {"keys": ["db", "name", "tls/"]}}`)
+			case "/v1/secret/app/tls":
+				fmt.Fprint(w, `{"data": {"keys": ["key"]}}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case r.URL.Path == "/v1/secret/app/db":
+			fmt.Fprint(w, `{"data": {"user": "admin", "password": "vault:v1:cGFzc3dvcmQ="}}`)
+		case r.URL.Path == "/v1/secret/app/name":
+			fmt.Fprint(w, `{"data": {"value": "easykv"}}`)
+		case r.URL.Path == "/v1/secret/app/tls/key":
+			fmt.Fprint(w, `{"data": {"value": "a2V5"}}`)
+		case r.URL.Path == "/v1/kms/decrypt/app":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			ciphertext := strings.TrimPrefix(body["ciphertext"], "vault:v1:")
+			plaintext, _ := base64.StdEncoding.DecodeString(ciphertext)
+			fmt.Fprintf(w, `{"data": {"plaintext": %q}}`, base64.StdEncoding.EncodeToString([]byte("decrypted-"+string(plaintext))))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "token", WithToken("token"),
+		WithTransit(TransitOptions{Key: "app", Mount: "kms", Prefixes: []string{"/secret/app/tls"}}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/secret/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/secret/app/db/user":     "admin",
+		"/secret/app/db/password": "decrypted-password",
+		"/secret/app/name":        "easykv",
+		"/secret/app/tls/key":     "decrypted-key",
+	})
+	v, err := c.GetValue(context.Background(), "/secret/app/db/password")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "decrypted-password")
+}
+
+func (s *FilterSuite) TestWrappedToken(t *C) {
+	var mu sync.Mutex
+	unwraps := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/sys/wrapping/unwrap":
+			unwraps++
+			switch r.Header.Get("X-Vault-Token") {
+			case "wrapped-secret-id":
+				fmt.Fprint(w, `{"data": {"secret_id": "secret", "secret_id_accessor": "accessor"}}`)
+			case "wrapped-token":
+				fmt.Fprint(w, `{"auth": {"client_token": "unwrapped-token"}}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors": ["wrapping token is not valid or does not exist"]}`)
+			}
+		case "/v1/auth/approle/login":
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"auth": {"client_token": "approle-token"}}`)
+		case "/v1/auth/token/lookup-self":
+			if r.Header.Get("X-Vault-Token") != "unwrapped-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"data": {}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "approle", WithRoleID("role"), WithWrappedToken("wrapped-secret-id"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Check(c.client.Token(), Equals, "approle-token")
+	// the wrapping token can only be used once
+	t.Assert(c.Reload(), IsNil)
+	mu.Lock()
+	t.Check(unwraps, Equals, 1)
+	mu.Unlock()
+
+	c2, err := New(ts.URL, "token", WithWrappedToken("wrapped-token"))
+	t.Assert(err, IsNil)
+	c2.Close()
+	t.Check(c2.client.Token(), Equals, "unwrapped-token")
+
+	_, err = New(ts.URL, "token", WithWrappedToken("invalid"))
+	t.Check(err, ErrorMatches, "can't unwrap the wrapped token: (.|\n)*wrapping token is not valid(.|\n)*")
+}
+
+func (s *FilterSuite) TestConcurrency(t *C) {
+	var mu sync.Mutex
+	running, max := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			if r.URL.Path != "/v1/secret/app" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			keys := make([]string, 20)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("%02d", i)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/app/"):
+			mu.Lock()
+			running++
+			if running > max {
+				max = running
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			name := path.Base(r.URL.Path)
+			if name == "13" || name == "07" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"data": {"value": %q}}`, name)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "token", WithToken("token"), WithConcurrency(4))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, errs := c.GetValuesPartial([]string{"/secret/app"})
+	t.Check(vars, HasLen, 18)
+	t.Check(vars["/secret/app/19"], Equals, "19")
+	t.Check(errs, HasLen, 2)
+	t.Check(errs["/secret/app/07"], NotNil)
+	t.Check(errs["/secret/app/13"], NotNil)
+	mu.Lock()
+	t.Check(max, Equals, 4)
+	mu.Unlock()
+
+	_, err = c.GetValues([]string{"/secret/app"})
+	t.Check(err, ErrorMatches, "(.|\n)*/secret/app/07(.|\n)*")
+}
+
+func (s *FilterSuite) TestAuthMount(t *C) {
+	var logins []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logins = append(logins, r.URL.Path)
+		fmt.Fprint(w, `{"auth": {"client_token": "token"}, "data": {}}`)
+	}))
+	defer ts.Close()
+
+	for _, opts := range [][]Option{
+		{WithRoleID("role"), WithSecretID("secret")},
+		{WithRoleID("role"), WithSecretID("secret"), WithAuthMount("/approle-ci/")},
+	} {
+		c, err := New(ts.URL, "approle", opts...)
+		t.Assert(err, IsNil)
+		c.Close()
+	}
+	c, err := New(ts.URL, "userpass", WithAuthMount("people"), WithBasicAuth(BasicAuthOptions{Username: "alice", Password: "secret"}))
+	t.Assert(err, IsNil)
+	c.Close()
+	c, err = New(ts.URL, "token", WithAuthMount("ignored"), WithToken("token"))
+	t.Assert(err, IsNil)
+	c.Close()
+
+	t.Check(logins, DeepEquals, []string{
+		"/v1/auth/approle/login",
+		"/v1/auth/approle-ci/login",
+		"/v1/auth/people/login/alice",
+		"/v1/auth/token/lookup-self",
+	})
+}
+
+func (s *FilterSuite) TestCertAuth(t *C) {
+	var logins []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		logins = append(logins, r.URL.Path+" "+body["name"])
+		fmt.Fprint(w, `{"auth": {"client_token": "cert-token"}}`)
+	}))
+	defer ts.Close()
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithCertRole("web")},
+		{WithCertRole("db"), WithAuthMount("tls-certs")},
+	} {
+		c, err := New(ts.URL, "cert", opts...)
+		t.Assert(err, IsNil)
+		t.Check(c.client.Token(), Equals, "cert-token")
+		c.Close()
+	}
+
+	t.Check(logins, DeepEquals, []string{
+		"/v1/auth/cert/login ",
+		"/v1/auth/cert/login web",
+		"/v1/auth/tls-certs/login db",
+	})
+}
+
+func (s *FilterSuite) TestKubernetesAuth(t *C) {
+	var login map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login = nil
+		json.NewDecoder(r.Body).Decode(&login)
+		fmt.Fprint(w, `{"auth": {"client_token": "k8s-token"}}`)
+	}))
+	defer ts.Close()
+
+	jwt := func(aud interface{}) string {
+		claims, _ := json.Marshal(map[string]interface{}{"aud": aud, "sub": "system:serviceaccount:default:app"})
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+	}
+	p := filepath.Join(t.MkDir(), "token")
+	t.Assert(ioutil.WriteFile(p, []byte(jwt([]string{"vault"})+"\n"), 0600), IsNil)
+
+	c, err := New(ts.URL, "kubernetes", WithKubernetes(KubernetesOptions{Role: "app", TokenPath: p, Audience: "vault"}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	t.Check(login, DeepEquals, map[string]string{"role": "app", "jwt": jwt([]string{"vault"})})
+
+	// a rotated token is read on the next login
+	t.Assert(ioutil.WriteFile(p, []byte(jwt("vault")), 0600), IsNil)
+	t.Assert(c.Reload(), IsNil)
+	t.Check(login["jwt"], Equals, jwt("vault"))
+
+	c2, err := New(ts.URL, "kubernetes", WithRoleID("legacy"), WithKubernetes(KubernetesOptions{TokenPath: p}))
+	t.Assert(err, IsNil)
+	c2.Close()
+	t.Check(login["role"], Equals, "legacy")
+
+	_, err = New(ts.URL, "kubernetes", WithKubernetes(KubernetesOptions{Role: "app", TokenPath: p, Audience: "other"}))
+	t.Check(err, ErrorMatches, `the kubernetes token isn't issued for the audience "other"`)
+}
+
+func (s *FilterSuite) TestTLS(t *C) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {}}`)
+	}))
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	// don't retry the failing handshakes
+	defer os.Setenv("VAULT_MAX_RETRIES", os.Getenv("VAULT_MAX_RETRIES"))
+	os.Setenv("VAULT_MAX_RETRIES", "0")
+
+	ca := filepath.Join(t.MkDir(), "ca.pem")
+	t.Assert(ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600), IsNil)
+	// the test certificate is valid for example.com, but not for localhost
+	addr := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	_, err := New(addr, "token", WithToken("token"))
+	t.Check(err, NotNil)
+	_, err = New(addr, "token", WithToken("token"), WithTLSOptions(TLSOptions{ClientCaKeys: ca}))
+	t.Check(err, NotNil)
+
+	c, err := New(addr, "token", WithToken("token"), WithTLSOptions(TLSOptions{InsecureSkipVerify: true}))
+	t.Assert(err, IsNil)
+	c.Close()
+	c, err = New(addr, "token", WithToken("token"), WithTLSOptions(TLSOptions{ClientCaKeys: ca, ServerName: "example.com"}))
+	t.Assert(err, IsNil)
+	c.Close()
+}
+
+func (s *FilterSuite) TestRetry(t *C) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			fmt.Fprint(w, `{"data": {}}`)
+			return
+		}
+		if r.URL.Path == "/v1/secret/slow" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := requests
+		requests = 0
+		return n
+	}
+
+	c, err := New(ts.URL, "token", WithToken("token"), WithRetry(RetryOptions{MaxRetries: 3, MinWait: time.Millisecond, MaxWait: time.Millisecond}))
+	t.Assert(err, IsNil)
+	count()
+	_, err = c.GetValue(context.Background(), "/secret/app")
+	t.Check(err, NotNil)
+	// the mount listing, the mount detection and the read of the key
+	t.Check(count(), Equals, 3*4)
+	c.Close()
+
+	c, err = New(ts.URL, "token", WithToken("token"), WithRetry(RetryOptions{Disabled: true}), WithTimeout(50*time.Millisecond))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	count()
+	_, err = c.GetValue(context.Background(), "/secret/app")
+	t.Check(err, NotNil)
+	t.Check(count(), Equals, 3)
+
+	start := time.Now()
+	_, err = c.GetValue(context.Background(), "/secret/slow")
+	t.Check(err, NotNil)
+	t.Check(time.Since(start) < 400*time.Millisecond, Equals, true)
+}
+
+func (s *FilterSuite) TestGetValueContext(t *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case "/v1/secret/slow":
+			// answer only after the client gave up
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "token", WithToken("token"), WithRetry(RetryOptions{Disabled: true}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.GetValue(ctx, "/secret/slow")
+	t.Check(err, NotNil)
+	t.Check(time.Since(start) < time.Second, Equals, true)
+}
+
+func (s *FilterSuite) TestPKI(t *C) {
+	var mu sync.Mutex
+	var requests []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {}}`)
+		case r.URL.Path == "/v1/pki/issue/web" && r.Method == http.MethodPut:
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			requests = append(requests, req)
+			serial := len(requests)
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"certificate":   "cert",
+				"private_key":   "key",
+				"ca_chain":      []string{"intermediate", "root"},
+				"serial_number": strconv.Itoa(serial),
+				"expiration":    time.Now().Add(1500 * time.Millisecond).Unix(),
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, "token", WithToken("token"), WithPKI("/pki/issue/web", PKIRequest{
+		CommonName: "web.example.com",
+		AltNames:   []string{"www.example.com", "example.com"},
+		TTL:        time.Hour,
+	}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	vars, err := c.GetValues([]string{"/pki/issue/web"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/pki/issue/web/certificate":   "cert",
+		"/pki/issue/web/private_key":   "key",
+		"/pki/issue/web/ca_chain":      "intermediate\nroot",
+		"/pki/issue/web/serial_number": "1",
+	})
+	mu.Lock()
+	t.Check(requests, DeepEquals, []map[string]interface{}{{
+		"common_name": "web.example.com",
+		"alt_names":   "www.example.com,example.com",
+		"ttl":         "1h0m0s",
+	}})
+	mu.Unlock()
+
+	// the certificate is reused until it expires soon
+	index, err := c.WatchPrefix(context.Background(), "/pki/issue/web")
+	t.Assert(err, IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.WatchPrefix(ctx, "/pki/issue/web", easykv.WithWaitIndex(index), easykv.WithInterval(time.Hour))
+	t.Assert(err, IsNil)
+	vars, err = c.GetValues([]string{"/pki/issue/web"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/pki/issue/web/serial_number"], Equals, "2")
+}
+
+func (s *FilterSuite) TestKVv2(t *C) {
+	f, ts := newFakeVault(map[string]int{"secret/": 2, "kv/": 1})
+	defer ts.Close()
+	f.put("secret/app/db", map[string]interface{}{"url": "db:5432", "user": "admin"})
+	f.put("secret/app/name", map[string]interface{}{"value": "old"})
+	f.put("secret/app/name", map[string]interface{}{"value": "easykv"})
+	f.put("kv/app/flag", map[string]interface{}{"value": "on"})
+
+	c, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+
+	vars, err := c.GetValues([]string{"/secret/app", "/kv/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/secret/app/db/url":  "db:5432",
+		"/secret/app/db/user": "admin",
+		"/secret/app/name":    "easykv",
+		"/kv/app/flag":        "on",
+	})
+
+	// the root of the mount
+	vars, err = c.GetValues([]string{"/secret"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{
+		"/secret/app/db/url":  "db:5432",
+		"/secret/app/db/user": "admin",
+		"/secret/app/name":    "easykv",
+	})
+
+	t.Assert(c.SetValues(map[string]string{"/secret/app/name": "new"}), IsNil)
+	v, err := c.GetValue(context.Background(), "/secret/app/name")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "new")
+	v, err = c.GetValue(context.Background(), "/secret/app/db/user")
+	t.Assert(err, IsNil)
+	t.Check(v, Equals, "admin")
+
+	t.Assert(c.DeleteValues([]string{"/secret/app/name"}), IsNil)
+	_, err = c.GetValue(context.Background(), "/secret/app/name")
+	t.Check(err, Equals, easykv.ErrKeyNotFound)
+
+	// the mounts are detected only once
+	mounts := 0
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, "GET sys/internal/ui/mounts/") {
+			mounts++
+		}
+	}
+	t.Check(mounts, Equals, 2)
+
+	c, err = New(ts.URL, "token", WithToken("token"), WithKVVersion(2))
+	t.Assert(err, IsNil)
+	vars, err = c.GetValues([]string{"/secret/app/db"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/db/url": "db:5432", "/secret/app/db/user": "admin"})
+	vars, err = c.GetValues([]string{"/secret"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/db/url": "db:5432", "/secret/app/db/user": "admin"})
+}
+
+func (s *FilterSuite) TestMountDetection(t *C) {
+	f, ts := newFakeVault(map[string]int{"secret/": 2, "kv/": 1, "team/kv/": 2})
+	defer ts.Close()
+	f.listMounts = true
+	f.put("secret/app/name", map[string]interface{}{"value": "easykv"})
+	f.put("kv/app/flag", map[string]interface{}{"value": "on"})
+	f.put("team/kv/app/port", map[string]interface{}{"value": "8080"})
+
+	c, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+	for i := 0; i < 2; i++ {
+		vars, err := c.GetValues([]string{"/secret/app", "/kv/app", "/team/kv/app"})
+		t.Assert(err, IsNil)
+		t.Check(vars, DeepEquals, map[string]string{
+			"/secret/app/name":  "easykv",
+			"/kv/app/flag":      "on",
+			"/team/kv/app/port": "8080",
+		})
+	}
+
+	// the mounts are listed once, no preflight requests are needed
+	lists, preflights := 0, 0
+	for _, r := range f.requests {
+		switch {
+		case r == "GET sys/mounts":
+			lists++
+		case strings.HasPrefix(r, "GET sys/internal/ui/mounts/"):
+			preflights++
+		}
+	}
+	t.Check(lists, Equals, 1)
+	t.Check(preflights, Equals, 0)
+
+	// without permission on sys/mounts the preflight requests are used
+	f.listMounts = false
+	c, err = New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+	vars, err := c.GetValues([]string{"/secret/app", "/kv/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "easykv", "/kv/app/flag": "on"})
+}
+
+func (s *FilterSuite) TestCache(t *C) {
+	f, ts := newFakeVault(map[string]int{"secret/": 2, "kv/": 1})
+	defer ts.Close()
+	f.put("secret/app/name", map[string]interface{}{"value": "v1"})
+	f.put("kv/app/flag", map[string]interface{}{"value": "on"})
+
+	reads := func() int {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		n := 0
+		for _, r := range f.requests {
+			if r == "GET secret/data/app/name" || r == "GET kv/app/flag" {
+				n++
+			}
+		}
+		f.requests = nil
+		return n
+	}
+
+	c, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	// the client is cached with the core cache, which uses the lease durations of GetValuesWithTTL
+	cache := easykv.NewCache(c, time.Hour)
+	for i := 0; i < 3; i++ {
+		vars, err := cache.GetValues([]string{"/secret/app", "/kv/app"})
+		t.Assert(err, IsNil)
+		t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "v1", "/kv/app/flag": "on"})
+	}
+	t.Check(reads(), Equals, 2)
+
+	// a reported change invalidates the cache
+	index, err := cache.WatchPrefix(context.Background(), "/secret/app")
+	t.Assert(err, IsNil)
+	f.put("secret/app/name", map[string]interface{}{"value": "v2"})
+	_, err = cache.WatchPrefix(context.Background(), "/secret/app", easykv.WithWaitIndex(index), easykv.WithInterval(10*time.Millisecond))
+	t.Assert(err, IsNil)
+	vars, err := cache.GetValues([]string{"/secret/app", "/kv/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "v2", "/kv/app/flag": "on"})
+
+	// the cache of the client keeps Reload, GetValue and the other methods of the client
+	c, err = New(ts.URL, "token", WithToken("token"), WithCacheMaxTTL(time.Hour))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	reads()
+	for i := 0; i < 3; i++ {
+		vars, err = c.GetValues([]string{"/secret/app", "/kv/app"})
+		t.Assert(err, IsNil)
+		t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "v2", "/kv/app/flag": "on"})
+	}
+	t.Check(reads(), Equals, 2)
+
+	t.Assert(c.SetValues(map[string]string{"/secret/app/name": "v3"}), IsNil)
+	vars, err = c.GetValues([]string{"/secret/app", "/kv/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/secret/app/name"], Equals, "v3")
+
+	index, err = c.WatchPrefix(context.Background(), "/secret/app")
+	t.Assert(err, IsNil)
+	f.put("secret/app/name", map[string]interface{}{"value": "v4"})
+	_, err = c.WatchPrefix(context.Background(), "/secret/app", easykv.WithWaitIndex(index), easykv.WithInterval(10*time.Millisecond))
+	t.Assert(err, IsNil)
+	vars, err = c.GetValues([]string{"/secret/app", "/kv/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/secret/app/name"], Equals, "v4")
+
+	f.put("secret/app/name", map[string]interface{}{"value": "v5"})
+	t.Assert(c.Reload(), IsNil)
+	vars, err = c.GetValues([]string{"/secret/app", "/kv/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars["/secret/app/name"], Equals, "v5")
+}
+
+func (s *FilterSuite) TestVersionPinning(t *C) {
+	f, ts := newFakeVault(map[string]int{"secret/": 2, "kv/": 1})
+	defer ts.Close()
+	f.put("secret/app/name", map[string]interface{}{"value": "v1"})
+	f.put("secret/app/name", map[string]interface{}{"value": "v2"})
+	f.put("secret/app/name", map[string]interface{}{"value": "v3"})
+	f.put("kv/app/flag", map[string]interface{}{"value": "on"})
+
+	c, err := New(ts.URL, "token", WithToken("token"))
+	t.Assert(err, IsNil)
+
+	vars, err := c.GetValues([]string{"/secret/app/name?version=2"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "v2"})
+
+	vars, err = c.GetValues([]string{"/secret/app/name"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "v3"})
+
+	_, err = c.GetValues([]string{"/secret/app/name?version=x"})
+	t.Check(err, NotNil)
+	_, err = c.GetValues([]string{"/kv/app/flag?version=1"})
+	t.Check(err, ErrorMatches, ".*kv v2.*")
+
+	md, err := c.Metadata("/secret/app/name")
+	t.Assert(err, IsNil)
+	t.Check(md.CurrentVersion, Equals, 3)
+	t.Check(md.OldestVersion, Equals, 1)
+	t.Check(md.UpdatedTime.IsZero(), Equals, false)
+	_, err = c.Metadata("/kv/app/flag")
+	t.Check(err, NotNil)
+
+	c, err = New(ts.URL, "token", WithToken("token"), WithSecretVersion("/secret/app/name", 1))
+	t.Assert(err, IsNil)
+	vars, err = c.GetValues([]string{"/secret/app"})
+	t.Assert(err, IsNil)
+	t.Check(vars, DeepEquals, map[string]string{"/secret/app/name": "v1"})
+}
+
+func (s *FilterSuite) TestRenewal(t *C) {
+	var mu sync.Mutex
+	logins, renewals := 0, 0
+	renewable := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			logins++
+			fmt.Fprintf(w, `{"auth": {"client_token": "token-%d", "lease_duration": 1, "renewable": %t}}`, logins, renewable)
+		case "/v1/auth/token/renew-self":
+			renewals++
+			fmt.Fprint(w, `{"auth": {"client_token": "token", "lease_duration": 1, "renewable": true}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	count := func(n *int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return *n
+	}
+	waitFor := func(n *int, min int) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			if count(n) >= min {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// a non-renewable token is replaced before it expires
+	c, err := New(ts.URL, "approle", WithRoleID("role"), WithSecretID("secret"))
+	t.Assert(err, IsNil)
+	t.Check(waitFor(&logins, 2), Equals, true)
+	c.Close()
+	n := count(&logins)
+	time.Sleep(1500 * time.Millisecond)
+	t.Check(count(&logins), Equals, n)
+
+	// a renewable token is renewed
+	mu.Lock()
+	renewable = true
+	mu.Unlock()
+	c, err = New(ts.URL, "approle", WithRoleID("role"), WithSecretID("secret"))
+	t.Assert(err, IsNil)
+	t.Check(waitFor(&renewals, 1), Equals, true)
+	c.Close()
+
+	c, err = New(ts.URL, "approle", WithRoleID("role"), WithSecretID("secret"), WithRenewal(RenewalOptions{Disabled: true}))
+	t.Assert(err, IsNil)
+	n = count(&renewals)
+	time.Sleep(1500 * time.Millisecond)
+	t.Check(count(&renewals), Equals, n)
+	c.Close()
+}
+
+// fakeVault is a minimal vault server with kv v1 and v2 mounts.
+// sys/mounts is only served if listMounts is set.
+type fakeVault struct {
+	mu         sync.Mutex
+	mounts     map[string]int
+	listMounts bool
+	secrets    map[string][]map[string]interface{}
+	requests   []string
+}
+
+func newFakeVault(mounts map[string]int) (*fakeVault, *httptest.Server) {
+	f := &fakeVault{mounts: mounts, secrets: make(map[string][]map[string]interface{})}
+	return f, httptest.NewServer(f)
+}
+
+// put writes a new version of the secret at p.
+func (f *fakeVault) put(p string, data map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[p] = append(f.secrets[p], data)
+}
+
+// mountOf returns the mount path and kv version of p.
+func (f *fakeVault) mountOf(p string) (string, int) {
+	for m, v := range f.mounts {
+		if strings.HasPrefix(p+"/", m) {
+			return m, v
+		}
+	}
+	return "", 0
+}
+
+// list returns the children of dir.
+func (f *fakeVault) list(dir string) []interface{} {
+	seen := make(map[string]bool)
+	for p, versions := range f.secrets {
+		if !strings.HasPrefix(p, dir+"/") || versions[len(versions)-1] == nil {
+			continue
+		}
+		child := strings.TrimPrefix(p, dir+"/")
+		if i := strings.Index(child, "/"); i >= 0 {
+			child = child[:i+1]
+		}
+		seen[child] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]interface{}, len(keys))
+	for i, k := range keys {
+		list[i] = k
+	}
+	return list
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := strings.TrimPrefix(path.Clean(r.URL.Path), "/v1/")
+	isList := r.URL.Query().Get("list") == "true" || r.Method == "LIST"
+	method := r.Method
+	if isList {
+		method = "LIST"
+	}
+	f.requests = append(f.requests, method+" "+p)
+
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+
+	if p == "auth/token/lookup-self" {
+		reply(map[string]interface{}{})
+		return
+	}
+	if p == "sys/mounts" {
+		if !f.listMounts {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			return
+		}
+		mounts := map[string]interface{}{"sys/": map[string]interface{}{"type": "system"}}
+		for m, v := range f.mounts {
+			mounts[m] = map[string]interface{}{"type": "kv", "options": map[string]interface{}{"version": strconv.Itoa(v)}}
+		}
+		reply(mounts)
+		return
+	}
+	if strings.HasPrefix(p, "sys/internal/ui/mounts/") {
+		m, v := f.mountOf(strings.TrimPrefix(p, "sys/internal/ui/mounts/"))
+		if m == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors": ["no mount"]}`)
+			return
+		}
+		reply(map[string]interface{}{"path": m, "type": "kv", "options": map[string]interface{}{"version": strconv.Itoa(v)}})
+		return
+	}
+
+	m, v := f.mountOf(p)
+	key := p
+	if v == 2 {
+		rel := strings.TrimPrefix(p, m)
+		op := rel
+		if i := strings.Index(rel, "/"); i >= 0 {
+			op = rel[:i]
+		}
+		key = strings.TrimSuffix(m+strings.TrimPrefix(strings.TrimPrefix(rel, op), "/"), "/")
+		if op == "metadata" && r.Method == http.MethodGet && !isList {
+			versions := f.secrets[key]
+			if len(versions) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			reply(map[string]interface{}{
+				"current_version": len(versions),
+				"oldest_version":  1,
+				"created_time":    "2018-03-22T02:24:06.945319214Z",
+				"updated_time":    "2018-03-22T02:36:43.986212308Z",
+			})
+			return
+		}
+		if (isList && op != "metadata") || (!isList && op != "data") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	switch {
+	case isList:
+		keys := f.list(key)
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(map[string]interface{}{"keys": keys})
+	case r.Method == http.MethodGet:
+		versions := f.secrets[key]
+		if len(versions) == 0 || versions[len(versions)-1] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		n := len(versions)
+		if q := r.URL.Query().Get("version"); q != "" && v == 2 {
+			n, _ = strconv.Atoi(q)
+			if n < 1 || n > len(versions) || versions[n-1] == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+		data := versions[n-1]
+		if v == 2 {
+			reply(map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": n}})
+			return
+		}
+		reply(data)
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if v == 2 {
+			body, _ = body["data"].(map[string]interface{})
+		}
+		f.secrets[key] = append(f.secrets[key], body)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		f.secrets[key] = append(f.secrets[key], nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
 }
@@ -0,0 +1,89 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const defaultControlGroupInterval = 5 * time.Second
+
+// ErrApprovalRequired is matched by errors.Is if a read wasn't approved by a control group.
+var ErrApprovalRequired = errors.New("vault control group approval required")
+
+// ApprovalRequiredError is returned if a read is gated by a Vault Enterprise control group
+// and the request wasn't approved (in time).
+// The request can be approved with the Accessor.
+type ApprovalRequiredError struct {
+	Path     string
+	Accessor string
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("reading %s requires control group approval (accessor %s)", e.Path, e.Accessor)
+}
+
+// Is reports whether target is ErrApprovalRequired.
+func (e *ApprovalRequiredError) Is(target error) bool {
+	return target == ErrApprovalRequired
+}
+
+// isControlGroupResponse checks if the response is a wrapped control group request
+// instead of the secret itself.
+func isControlGroupResponse(resp *vaultapi.Secret) bool {
+	return resp != nil && resp.Data == nil && resp.WrapInfo != nil && resp.WrapInfo.Accessor != ""
+}
+
+// waitForApproval polls the control group request until it is approved
+// or the timeout expires and unwraps the secret. It stops waiting if ctx is canceled.
+func (c *Client) waitForApproval(ctx context.Context, vc *vaultapi.Client, key string, wrapInfo *vaultapi.SecretWrapInfo) (*vaultapi.Secret, error) {
+	c.mu.RLock()
+	cg := c.options.ControlGroup
+	c.mu.RUnlock()
+
+	approvalErr := &ApprovalRequiredError{Path: key, Accessor: wrapInfo.Accessor}
+	if cg.Timeout <= 0 {
+		return nil, approvalErr
+	}
+
+	interval := cg.Interval
+	if interval <= 0 {
+		interval = defaultControlGroupInterval
+	}
+
+	c.logger().Info("waiting for vault control group approval", "key", key, "accessor", wrapInfo.Accessor)
+	deadline := time.Now().Add(cg.Timeout)
+	for {
+		resp, err := vc.Logical().WriteWithContext(ctx, "sys/control-group/request", map[string]interface{}{
+			"accessor": wrapInfo.Accessor,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil && resp.Data != nil {
+			if approved, ok := resp.Data["approved"].(bool); ok && approved {
+				return vc.Logical().UnwrapWithContext(ctx, wrapInfo.Token)
+			}
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return nil, approvalErr
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
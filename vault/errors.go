@@ -0,0 +1,90 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/HeavyHorst/easykv"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// errMissing returns the error of a missing configuration parameter.
+func errMissing(name string) error {
+	return fmt.Errorf("%s is missing from configuration", name)
+}
+
+// requireParams returns an error for the first of the named parameters that isn't set.
+func requireParams(params map[string]string, names ...string) error {
+	for _, name := range names {
+		if params[name] == "" {
+			return errMissing(name)
+		}
+	}
+	return nil
+}
+
+// classifyError removes the secrets registered with r from err and wraps it
+// in an easykv.BackendError if it is an authentication, permission or connection error.
+// If login is set, rejected requests are authentication errors.
+func classifyError(err error, r *easykv.Redactor, login bool) error {
+	if err == nil {
+		return nil
+	}
+	kind := errorKind(err, login)
+	if r != nil {
+		err = r.RedactError(err)
+	}
+	if kind == nil {
+		return err
+	}
+	return &easykv.BackendError{Kind: kind, Err: err}
+}
+
+// errorKind returns the easykv error kind of err, or nil if it has none.
+func errorKind(err error, login bool) error {
+	for err != nil {
+		switch e := err.(type) {
+		case *easykv.BackendError:
+			return e.Kind
+		case *vaultapi.ResponseError:
+			switch {
+			case login && e.StatusCode >= 400 && e.StatusCode < 500:
+				return easykv.ErrAuthentication
+			case e.StatusCode == http.StatusUnauthorized:
+				return easykv.ErrAuthentication
+			case e.StatusCode == http.StatusForbidden:
+				return easykv.ErrPermissionDenied
+			case e.StatusCode == http.StatusBadGateway, e.StatusCode == http.StatusServiceUnavailable, e.StatusCode == http.StatusGatewayTimeout:
+				return easykv.ErrConnection
+			}
+			return nil
+		case net.Error:
+			return easykv.ErrConnection
+		}
+		u, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return nil
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
+// classify removes all registered secrets from err and classifies it.
+func (c *Client) classify(err error) error {
+	c.mu.RLock()
+	r := c.options.Redactor
+	c.mu.RUnlock()
+	return classifyError(err, r, false)
+}
@@ -0,0 +1,49 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// The flavors of vault compatible servers.
+// OpenBao speaks the vault api, but its cli uses the BAO_* environment variables
+// and the ~/.bao-token token file.
+const (
+	FlavorVault   = "vault"
+	FlavorOpenBao = "openbao"
+)
+
+// defaultTokenFile returns the token file of the cli of the flavor.
+func defaultTokenFile(flavor string) string {
+	if flavor == FlavorOpenBao {
+		return filepath.Join(os.Getenv("HOME"), ".bao-token")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".vault-token")
+}
+
+// flavorDefaults sets the address, token and namespace from the environment
+// variables of the flavor if they aren't set. The vault variables are read
+// by the vault api itself.
+func flavorDefaults(address string, o *Options) string {
+	if o.Flavor != FlavorOpenBao {
+		return address
+	}
+	if address == "" {
+		address = os.Getenv("BAO_ADDR")
+	}
+	if o.Token == "" && o.TokenRef.IsZero() {
+		o.Token = os.Getenv("BAO_TOKEN")
+	}
+	if o.Namespace == "" {
+		o.Namespace = os.Getenv("BAO_NAMESPACE")
+	}
+	return address
+}
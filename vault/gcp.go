@@ -0,0 +1,151 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// GCPOptions configures the gcp auth method.
+// The iam type signs a jwt with the key of a service account, the gce type
+// uses an identity token of the instance from the metadata server.
+// Type defaults to iam if a CredentialsFile is set and to gce otherwise.
+type GCPOptions struct {
+	Type            string
+	Role            string
+	ServiceAccount  string
+	CredentialsFile string
+	JWTExpiration   time.Duration
+}
+
+// gcpMetadataURL is the address of the gce metadata server.
+var gcpMetadataURL = "http://metadata.google.internal"
+
+// gcpServiceAccountKey is the json key file of a service account.
+type gcpServiceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+}
+
+// gcpIAMJWT returns a jwt for role that is signed with the key of the service account.
+func gcpIAMJWT(options GCPOptions, now time.Time) (string, error) {
+	b, err := ioutil.ReadFile(options.CredentialsFile)
+	if err != nil {
+		return "", err
+	}
+	var key gcpServiceAccountKey
+	if err := json.Unmarshal(b, &key); err != nil {
+		return "", err
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", errors.New("can't decode the private key of the service account")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("the private key of the service account is no rsa key")
+	}
+
+	sub := options.ServiceAccount
+	if sub == "" {
+		sub = key.ClientEmail
+	}
+	exp := options.JWTExpiration
+	if exp == 0 {
+		exp = 15 * time.Minute
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": key.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"sub": sub,
+		"aud": "vault/" + options.Role,
+		"exp": now.Add(exp).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// gcpGCEJWT returns an identity token for role from the metadata server.
+func gcpGCEJWT(options GCPOptions) (string, error) {
+	sa := options.ServiceAccount
+	if sa == "" {
+		sa = "default"
+	}
+	q := url.Values{}
+	q.Set("audience", "http://vault/"+options.Role)
+	q.Set("format", "full")
+	u := gcpMetadataURL + "/computeMetadata/v1/instance/service-accounts/" + url.PathEscape(sa) + "/identity?" + q.Encode()
+	jwt, err := metadataGet(u, http.Header{"Metadata-Flavor": {"Google"}})
+	if err != nil {
+		return "", err
+	}
+	return string(jwt), nil
+}
+
+// gcpLogin logs in with the gcp auth method.
+func gcpLogin(c *vaultapi.Client, login string, options GCPOptions) (*vaultapi.Secret, error) {
+	var jwt string
+	var err error
+	switch options.Type {
+	case "iam":
+		jwt, err = gcpIAMJWT(options, time.Now())
+	case "gce":
+		jwt, err = gcpGCEJWT(options)
+	case "":
+		if options.CredentialsFile != "" {
+			jwt, err = gcpIAMJWT(options, time.Now())
+		} else {
+			jwt, err = gcpGCEJWT(options)
+		}
+	default:
+		err = errors.New("unknown gcp auth type " + options.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Logical().Write(login, map[string]interface{}{
+		"role": options.Role,
+		"jwt":  jwt,
+	})
+}
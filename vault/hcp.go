@@ -0,0 +1,414 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
+// HCPOptions configures the HCP Vault Secrets client.
+// ClientID and ClientSecret are the credentials of a service principal and default
+// to HCP_CLIENT_ID and HCP_CLIENT_SECRET. APIURL and AuthURL default to the HCP endpoints.
+type HCPOptions struct {
+	ClientID        string
+	ClientSecret    string
+	ClientSecretRef credentials.Ref
+	APIURL          string
+	AuthURL         string
+}
+
+// The default HCP endpoints.
+const (
+	hcpAPIURL     = "https://api.cloud.hashicorp.com"
+	hcpAuthURL    = "https://auth.idp.hashicorp.com/oauth2/token"
+	hcpAudience   = "https://api.hashicorp.cloud"
+	hcpAPIVersion = "2023-11-28"
+)
+
+// HCPClient reads the secrets of HCP Vault Secrets apps.
+// The first path segment of a key is the app, the second one the secret,
+// e.g. /payments/DB_PASSWORD. HCP Vault Secrets is read-only for easyKV.
+type HCPClient struct {
+	base    string
+	options Options
+	http    *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewHCP returns a client for the apps of an HCP Vault Secrets project.
+// The service principal is configured with WithHCP, TLS, proxy and timeout
+// with the same options as the vault client.
+func NewHCP(organization, project string, opts ...Option) (*HCPClient, error) {
+	options := Options{Redactor: easykv.NewRedactor()}
+	for _, o := range opts {
+		o(&options)
+	}
+	if organization == "" || project == "" {
+		return nil, errors.New("you have to set the organization and project when using hcp vault secrets")
+	}
+
+	h := &options.HCP
+	if h.ClientID == "" {
+		h.ClientID = os.Getenv("HCP_CLIENT_ID")
+	}
+	if h.ClientSecret == "" && h.ClientSecretRef.IsZero() {
+		h.ClientSecret = os.Getenv("HCP_CLIENT_SECRET")
+	}
+	var err error
+	if h.ClientSecret, err = h.ClientSecretRef.Resolve(h.ClientSecret); err != nil {
+		return nil, err
+	}
+	if err := requireParams(map[string]string{"client-id": h.ClientID, "client-secret": h.ClientSecret}, "client-id", "client-secret"); err != nil {
+		return nil, err
+	}
+	if h.APIURL == "" {
+		h.APIURL = hcpAPIURL
+	}
+	if h.AuthURL == "" {
+		h.AuthURL = hcpAuthURL
+	}
+	if options.Redactor != nil {
+		options.Redactor.Add(h.ClientSecret)
+	}
+
+	conf, err := getConfig("", options)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &HCPClient{
+		base: strings.TrimSuffix(h.APIURL, "/") + path.Join("/secrets", hcpAPIVersion,
+			"organizations", url.PathEscape(organization), "projects", url.PathEscape(project)),
+		options: options,
+		http:    conf.HttpClient,
+	}
+	if _, err := c.accessToken(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// accessToken returns the current access token of the service principal
+// and requests a new one shortly before it expires.
+func (c *HCPClient) accessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	h := c.options.HCP
+	resp, err := c.http.PostForm(h.AuthURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {h.ClientID},
+		"client_secret": {h.ClientSecret},
+		"audience":      {hcpAudience},
+	})
+	if err != nil {
+		return "", c.classify(err, true)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", c.statusError(resp, true)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", &easykv.BackendError{Kind: easykv.ErrAuthentication, Err: errors.New("the hcp login returned no token")}
+	}
+	c.token = token.AccessToken
+	// renew the token a minute before it expires
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// get reads the api path p into v. All pages are requested if next is set,
+// it returns the token of the next page of a response.
+func (c *HCPClient) get(p string, v interface{}, next func() string) error {
+	token, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+
+	pageToken := ""
+	for {
+		u := c.base + p
+		if pageToken != "" {
+			u += "?pagination.next_page_token=" + url.QueryEscape(pageToken)
+		}
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return c.classify(err, false)
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := c.statusError(resp, false)
+			resp.Body.Close()
+			return err
+		}
+		err = json.NewDecoder(resp.Body).Decode(v)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if pageToken = next(); pageToken == "" {
+			return nil
+		}
+	}
+}
+
+// statusError returns the classified error of a failed response.
+func (c *HCPClient) statusError(resp *http.Response, login bool) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &msg)
+	err := fmt.Errorf("hcp request failed with %s: %s", resp.Status, msg.Message)
+
+	var kind error
+	switch {
+	case login && resp.StatusCode >= 400 && resp.StatusCode < 500:
+		kind = easykv.ErrAuthentication
+	case resp.StatusCode == http.StatusUnauthorized:
+		kind = easykv.ErrAuthentication
+	case resp.StatusCode == http.StatusForbidden:
+		kind = easykv.ErrPermissionDenied
+	case resp.StatusCode == http.StatusNotFound:
+		return easykv.ErrKeyNotFound
+	case resp.StatusCode >= 500:
+		kind = easykv.ErrConnection
+	}
+	if kind == nil {
+		return c.classify(err, login)
+	}
+	return c.classify(&easykv.BackendError{Kind: kind, Err: err}, login)
+}
+
+func (c *HCPClient) classify(err error, login bool) error {
+	return classifyError(err, c.options.Redactor, login)
+}
+
+// apps returns the names of all apps of the project.
+func (c *HCPClient) apps() ([]string, error) {
+	var names []string
+	var resp struct {
+		Apps []struct {
+			Name string `json:"name"`
+		} `json:"apps"`
+		Pagination struct {
+			NextPageToken string `json:"next_page_token"`
+		} `json:"pagination"`
+	}
+	err := c.get("/apps", &resp, func() string {
+		for _, a := range resp.Apps {
+			names = append(names, a.Name)
+		}
+		next := resp.Pagination.NextPageToken
+		resp.Apps, resp.Pagination.NextPageToken = nil, ""
+		return next
+	})
+	return names, err
+}
+
+// hcpSecret is an opened secret of an app.
+type hcpSecret struct {
+	name    string
+	version int
+	value   string
+}
+
+// secrets returns the opened secrets of app.
+func (c *HCPClient) secrets(app string) ([]hcpSecret, error) {
+	var secrets []hcpSecret
+	var resp struct {
+		Secrets []struct {
+			Name          string `json:"name"`
+			LatestVersion int    `json:"latest_version"`
+			StaticVersion struct {
+				Value string `json:"value"`
+			} `json:"static_version"`
+		} `json:"secrets"`
+		Pagination struct {
+			NextPageToken string `json:"next_page_token"`
+		} `json:"pagination"`
+	}
+	err := c.get("/apps/"+url.PathEscape(app)+"/secrets:open", &resp, func() string {
+		for _, s := range resp.Secrets {
+			secrets = append(secrets, hcpSecret{s.Name, s.LatestVersion, s.StaticVersion.Value})
+		}
+		next := resp.Pagination.NextPageToken
+		resp.Secrets, resp.Pagination.NextPageToken = nil, ""
+		return next
+	})
+	return secrets, err
+}
+
+// read returns the secrets of all apps below the given prefixes.
+// Like the keys of the other backends, the prefixes are plain string prefixes:
+// /pay matches all secrets of the app payments, /payments/DB_ the secrets
+// DB_PASSWORD and DB_USER. With a trailing slash, /payments/ only matches the app payments.
+func (c *HCPClient) read(keys []string) (map[string]hcpSecret, error) {
+	found := make(map[string]hcpSecret)
+	opened := make(map[string][]hcpSecret)
+	var all []string
+	for _, key := range keys {
+		key = strings.TrimPrefix(key, "/")
+		var apps []string
+		app, name := key, ""
+		if i := strings.Index(key, "/"); i >= 0 {
+			app, name = key[:i], key[i+1:]
+			apps = []string{app}
+		} else {
+			if all == nil {
+				var err error
+				if all, err = c.apps(); err != nil {
+					return nil, err
+				}
+			}
+			for _, a := range all {
+				if strings.HasPrefix(a, app) {
+					apps = append(apps, a)
+				}
+			}
+		}
+
+		for _, a := range apps {
+			secrets, ok := opened[a]
+			if !ok {
+				var err error
+				secrets, err = c.secrets(a)
+				if err == easykv.ErrKeyNotFound {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				opened[a] = secrets
+			}
+			for _, s := range secrets {
+				if strings.HasPrefix(s.name, name) {
+					found["/"+a+"/"+s.name] = s
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+// GetValues returns the secrets of all apps and secrets with the given prefixes.
+// The prefix / returns the secrets of all apps.
+func (c *HCPClient) GetValues(keys []string) (map[string]string, error) {
+	found, err := c.read(keys)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(found))
+	values := make([]string, 0, len(found))
+	for k, s := range found {
+		vars[k] = s.value
+		values = append(values, s.value)
+	}
+	if r := c.options.Redactor; r != nil {
+		r.Add(values...)
+	}
+	return vars, nil
+}
+
+// WatchPrefix polls the prefix every Interval until the versions of its secrets change.
+// A WaitIndex of 0 returns the current index immediately.
+func (c *HCPClient) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options.Retry(ctx, func() (uint64, error) {
+		interval := options.Interval
+		if interval <= 0 {
+			interval = defaultWatchInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			index, err := c.fingerprint(prefix, options.Keys)
+			if err != nil {
+				return options.WaitIndex, err
+			}
+			if index != options.WaitIndex {
+				return index, nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return options.WaitIndex, easykv.ErrWatchCanceled
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// fingerprint returns a hash of the versions of all secrets below prefix.
+// If keys is set, only secrets with one of the given prefixes are included.
+func (c *HCPClient) fingerprint(prefix string, keys []string) (uint64, error) {
+	found, err := c.read([]string{prefix})
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0, len(found))
+	for k := range found {
+		if watched(k, keys) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, k := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", k, found[k].version)
+	}
+	// 0 is reserved for "no index"
+	if index := h.Sum64(); index != 0 {
+		return index, nil
+	}
+	return 1, nil
+}
+
+// Close is only meant to fulfill the easykv.ReadWatcher interface.
+// Does nothing.
+func (c *HCPClient) Close() {}
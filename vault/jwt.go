@@ -0,0 +1,42 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"path"
+	"strings"
+
+	"github.com/HeavyHorst/easykv/credentials"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// JWTOptions configures the jwt and oidc auth methods.
+// The JWT is usually read from a file with JWTRef, e.g. a projected service account token,
+// and is read again on every login. Mount defaults to the auth type.
+type JWTOptions struct {
+	Role   string
+	Mount  string
+	JWT    string
+	JWTRef credentials.Ref
+}
+
+// jwtLogin logs in with the jwt auth method.
+// The Mount of the options replaces the mount of the login path.
+func jwtLogin(c *vaultapi.Client, login string, options JWTOptions) (*vaultapi.Secret, error) {
+	if options.JWT == "" {
+		return nil, errMissing("jwt")
+	}
+	if options.Mount != "" {
+		login = path.Join("/auth", strings.Trim(options.Mount, "/"), "login")
+	}
+	return c.Logical().Write(login, map[string]interface{}{
+		"role": options.Role,
+		"jwt":  options.JWT,
+	})
+}
@@ -0,0 +1,94 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// defaultKubernetesTokenPath is the token of the pod's service account.
+const defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// KubernetesOptions configures the kubernetes auth method.
+// The token is read from TokenPath on every login, so rotated (projected) tokens are picked up
+// when the client authenticates again. If an Audience is set, the token must be issued for it.
+// Role defaults to the RoleID.
+type KubernetesOptions struct {
+	Role      string
+	TokenPath string
+	Audience  string
+}
+
+// checkAudience checks if the jwt is issued for audience.
+func checkAudience(jwt, audience string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return errors.New("the kubernetes token is no jwt")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fmt.Errorf("can't decode the kubernetes token: %v", err)
+	}
+	var claims struct {
+		Aud interface{} `json:"aud"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("can't decode the kubernetes token: %v", err)
+	}
+
+	var auds []interface{}
+	switch aud := claims.Aud.(type) {
+	case string:
+		auds = []interface{}{aud}
+	case []interface{}:
+		auds = aud
+	}
+	for _, a := range auds {
+		if a == audience {
+			return nil
+		}
+	}
+	return fmt.Errorf("the kubernetes token isn't issued for the audience %q", audience)
+}
+
+// kubernetesLogin logs in with the kubernetes auth method.
+func kubernetesLogin(c *vaultapi.Client, login string, role string, options KubernetesOptions) (*vaultapi.Secret, error) {
+	p := options.TokenPath
+	if p == "" {
+		p = defaultKubernetesTokenPath
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	jwt := strings.TrimSpace(string(b))
+	if options.Audience != "" {
+		if err := checkAudience(jwt, options.Audience); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Role != "" {
+		role = options.Role
+	}
+	if role == "" {
+		return nil, errMissing("role-id")
+	}
+	return c.Logical().Write(login, map[string]interface{}{
+		"jwt":  jwt,
+		"role": role,
+	})
+}
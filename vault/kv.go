@@ -0,0 +1,169 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// mount is the secret engine mount a key belongs to.
+type mount struct {
+	path    string
+	version int
+}
+
+// apiPath translates key into the path of the KV v2 endpoint op ("data" or "metadata").
+// Keys of other mounts are returned unchanged.
+func (m mount) apiPath(key, op string) string {
+	if m.version != 2 {
+		return key
+	}
+	// the slash makes the mount root itself, e.g. /secret, match the mount path secret/
+	rel := strings.TrimPrefix(strings.TrimPrefix(key, "/")+"/", m.path)
+	return path.Join("/", m.path, op, rel)
+}
+
+// mountCache caches the mounts of keys per namespace.
+type mountCache struct {
+	mu     sync.Mutex
+	mounts map[string]map[string]mount
+	listed map[string]bool
+}
+
+// list reports whether the mounts of namespace ns still have to be listed
+// and marks them as listed.
+func (mc *mountCache) list(ns string) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.listed[ns] {
+		return false
+	}
+	if mc.listed == nil {
+		mc.listed = make(map[string]bool)
+	}
+	mc.listed[ns] = true
+	return true
+}
+
+// lookup returns the cached mount of namespace ns with the longest path that contains key.
+// The root of a mount, e.g. secret, is contained in its path secret/.
+func (mc *mountCache) lookup(ns, key string) (mount, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	key += "/"
+	var match mount
+	found := false
+	for p, m := range mc.mounts[ns] {
+		if strings.HasPrefix(key, p) && (!found || len(p) > len(match.path)) {
+			match, found = m, true
+		}
+	}
+	return match, found
+}
+
+func (mc *mountCache) store(ns string, m mount) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.mounts == nil {
+		mc.mounts = make(map[string]map[string]mount)
+	}
+	if mc.mounts[ns] == nil {
+		mc.mounts[ns] = make(map[string]mount)
+	}
+	mc.mounts[ns][m.path] = m
+}
+
+// firstSegment returns the first path segment of key with a trailing slash.
+func firstSegment(key string) string {
+	key = strings.TrimPrefix(key, "/")
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return key + "/"
+}
+
+// listMounts stores all mounts of sys/mounts in the mount cache.
+// The mounts are listed only once per namespace. If the token isn't allowed
+// to list them, the mounts are detected per key by mountOf.
+func (c *Client) listMounts(ctx context.Context, vc *vaultapi.Client) {
+	ns := vc.Namespace()
+	if !c.mounts.list(ns) {
+		return
+	}
+	mounts, err := vc.Sys().ListMountsWithContext(ctx)
+	if err != nil {
+		c.logger().Debug("can't list vault mounts, detecting them per key", "err", err)
+		return
+	}
+	for p, mo := range mounts {
+		if mo == nil || mo.Type == "" {
+			continue
+		}
+		m := mount{path: strings.TrimPrefix(p, "/"), version: 1}
+		if !strings.HasSuffix(m.path, "/") {
+			m.path += "/"
+		}
+		if (mo.Type == "kv" || mo.Type == "generic") && mo.Options["version"] == "2" {
+			m.version = 2
+		}
+		c.mounts.store(ns, m)
+	}
+}
+
+// mountOf returns the mount of key.
+// If the KV version isn't set in the options, the mounts are listed with sys/mounts.
+// Keys outside of the listed mounts are detected with the same preflight request
+// the vault cli uses. Keys whose mount can't be detected are treated as KV v1
+// and their first path segment is remembered as a v1 mount.
+func (c *Client) mountOf(ctx context.Context, vc *vaultapi.Client, key string) mount {
+	c.mu.RLock()
+	version := c.options.KVVersion
+	c.mu.RUnlock()
+	if version != 0 {
+		return mount{path: firstSegment(key), version: version}
+	}
+
+	c.listMounts(ctx, vc)
+	rel := strings.TrimPrefix(key, "/")
+	if m, ok := c.mounts.lookup(vc.Namespace(), rel); ok {
+		return m
+	}
+
+	m := mount{path: firstSegment(key), version: 1}
+	resp, err := vc.Logical().ReadWithContext(ctx, path.Join("sys/internal/ui/mounts", rel))
+	if err == nil && resp != nil && resp.Data != nil {
+		if p, ok := resp.Data["path"].(string); ok && p != "" {
+			m.path = p
+		}
+		if options, ok := resp.Data["options"].(map[string]interface{}); ok && options["version"] == "2" {
+			m.version = 2
+		}
+	} else if err != nil {
+		c.logger().Debug("can't detect vault mount, assuming kv v1", "key", key, "err", err)
+	}
+	c.mounts.store(vc.Namespace(), m)
+	return m
+}
+
+// unwrapData returns the secret of a KV v2 read without the version envelope.
+func unwrapData(m mount, resp *vaultapi.Secret) map[string]interface{} {
+	if resp == nil || resp.Data == nil {
+		return nil
+	}
+	if m.version != 2 {
+		return resp.Data
+	}
+	data, _ := resp.Data["data"].(map[string]interface{})
+	return data
+}
@@ -0,0 +1,153 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// leaseCache keeps the dynamic secrets (database/creds/*, aws/creds/*, ...) of the client,
+// so the same credentials are returned until their lease ends.
+type leaseCache struct {
+	mu      sync.Mutex
+	secrets map[string]*vaultapi.Secret
+	cancels map[string]context.CancelFunc
+	changed chan struct{}
+}
+
+func (lc *leaseCache) get(key string) (*vaultapi.Secret, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	s, ok := lc.secrets[key]
+	return s, ok
+}
+
+// put stores the secret of key and stops the renewal of the previous secret.
+func (lc *leaseCache) put(key string, secret *vaultapi.Secret, cancel context.CancelFunc) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.secrets == nil {
+		lc.secrets = make(map[string]*vaultapi.Secret)
+		lc.cancels = make(map[string]context.CancelFunc)
+	}
+	if c, ok := lc.cancels[key]; ok {
+		c()
+	}
+	lc.secrets[key] = secret
+	lc.cancels[key] = cancel
+}
+
+// expire removes the secret of key if it wasn't replaced yet and notifies the watchers.
+func (lc *leaseCache) expire(key string, secret *vaultapi.Secret) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.secrets[key] != secret {
+		return
+	}
+	lc.cancels[key]()
+	delete(lc.secrets, key)
+	delete(lc.cancels, key)
+	if lc.changed != nil {
+		close(lc.changed)
+		lc.changed = nil
+	}
+}
+
+// wait returns a channel that is closed when the next lease ends.
+func (lc *leaseCache) wait() <-chan struct{} {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.changed == nil {
+		lc.changed = make(chan struct{})
+	}
+	return lc.changed
+}
+
+// clear removes all secrets and stops their renewal.
+func (lc *leaseCache) clear() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	for _, c := range lc.cancels {
+		c()
+	}
+	lc.secrets = nil
+	lc.cancels = nil
+}
+
+// readSecret reads the secret at p.
+// Secrets with a lease are cached under key until the lease ends and are renewed in the background.
+// Keys with a PKIRequest issue a new certificate instead.
+func (c *Client) readSecret(ctx context.Context, vc *vaultapi.Client, key, p string) (*vaultapi.Secret, error) {
+	if s, ok := c.leases.get(key); ok {
+		return s, nil
+	}
+	if req, ok := c.pkiRequest(key); ok {
+		return c.issue(ctx, vc, key, p, req)
+	}
+	resp, err := vc.Logical().ReadWithContext(ctx, p)
+	if err != nil || resp == nil || resp.LeaseID == "" {
+		return resp, err
+	}
+
+	c.mu.RLock()
+	options := c.options.LeaseRenewal
+	c.mu.RUnlock()
+	// the lease is renewed independently of the read
+	renewCtx, cancel := context.WithCancel(context.Background())
+	c.leases.put(key, resp, cancel)
+	go c.renewLease(renewCtx, vc, key, resp, options)
+	return resp, nil
+}
+
+// renewLease renews the lease of a dynamic secret until it can't be renewed anymore.
+// Non-renewable leases end after two thirds of their duration.
+// Afterwards the secret is removed from the cache, so the next read returns new credentials,
+// and running watches are notified.
+func (c *Client) renewLease(ctx context.Context, vc *vaultapi.Client, key string, secret *vaultapi.Secret, options RenewalOptions) {
+	logger := c.logger()
+
+	if secret.Renewable && !options.Disabled {
+		renewer, err := vc.NewRenewer(&vaultapi.RenewerInput{
+			Secret:    secret,
+			Increment: options.Increment,
+		})
+		if err != nil {
+			logger.Warn("can't renew vault lease", "key", key, "err", err)
+			c.leases.expire(key, secret)
+			return
+		}
+		go renewer.Renew()
+		defer renewer.Stop()
+
+	renew:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-renewer.RenewCh():
+				logger.Debug("renewed vault lease", "key", key)
+			case err := <-renewer.DoneCh():
+				logger.Info("vault lease can't be renewed anymore", "key", key, "err", err)
+				break renew
+			}
+		}
+	} else {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(secret.LeaseDuration) * time.Second * 2 / 3):
+		}
+	}
+
+	c.leases.expire(key, secret)
+}
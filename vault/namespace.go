@@ -0,0 +1,37 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// forKey returns the vault client for key.
+// Keys with a namespace override are read through a copy of vc with that namespace,
+// all other keys use the namespace of vc.
+func (c *Client) forKey(vc *vaultapi.Client, key string) *vaultapi.Client {
+	c.mu.RLock()
+	overrides := c.options.KeyNamespaces
+	c.mu.RUnlock()
+
+	key = strings.TrimPrefix(key, "/")
+	ns, match, found := "", "", false
+	for prefix, n := range overrides {
+		prefix = strings.TrimPrefix(prefix, "/")
+		if strings.HasPrefix(key, prefix) && (!found || len(prefix) > len(match)) {
+			ns, match, found = n, prefix, true
+		}
+	}
+	if !found || ns == vc.Namespace() {
+		return vc
+	}
+	return vc.WithNamespace(ns)
+}
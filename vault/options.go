@@ -8,15 +8,59 @@
 
 package vault
 
+import (
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/credentials"
+)
+
 // Options contains all values that are needed to connect to vault.
 type Options struct {
-	RoleID   string
-	SecretID string
-	AppID    string
-	UserID   string
-	Token    string
-	TLS      TLSOptions
-	Auth     BasicAuthOptions
+	RoleID       string
+	SecretID     string
+	AppID        string
+	UserID       string
+	Token        string
+	AuthMount    string
+	CertRole     string
+	Flavor       string
+	WrappedToken string
+	TLS          TLSOptions
+	Proxy        string
+	Auth         BasicAuthOptions
+	ControlGroup ControlGroupOptions
+	Timeout      time.Duration
+	CacheMaxTTL  time.Duration
+	Retry        RetryOptions
+	Logger       easykv.Logger
+	Redactor     *easykv.Redactor
+	KVVersion    int
+	Versions     map[string]int
+	Concurrency  int
+	Renewal      RenewalOptions
+	LeaseRenewal RenewalOptions
+	AWS          AWSOptions
+	GCP          GCPOptions
+	Azure        AzureOptions
+	JWT          JWTOptions
+	Kubernetes   KubernetesOptions
+	HCP          HCPOptions
+	Transit      TransitOptions
+	PKI          map[string]PKIRequest
+
+	// Namespace is the namespace of the client, KeyNamespaces
+	// overrides it for all keys with the given prefixes.
+	Namespace     string
+	KeyNamespaces map[string]string
+
+	// The Refs replace the inline credentials if they are set.
+	TokenRef    credentials.Ref
+	RoleIDRef   credentials.Ref
+	SecretIDRef credentials.Ref
+	PasswordRef credentials.Ref
+
+	WrappedTokenRef credentials.Ref
 }
 
 // BasicAuthOptions contains options regarding to basic authentication.
@@ -26,10 +70,27 @@ type BasicAuthOptions struct {
 }
 
 // TLSOptions contains all certificates and keys.
-type TLSOptions struct {
-	ClientCert   string
-	ClientKey    string
-	ClientCaKeys string
+type TLSOptions = easykv.TLSOptions
+
+// ControlGroupOptions configures how reads gated by Vault Enterprise control groups are handled.
+// If Timeout is zero, such reads fail immediately with an *ApprovalRequiredError, which matches ErrApprovalRequired.
+// Otherwise the request is polled every Interval until it is approved or the Timeout expires.
+type ControlGroupOptions struct {
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+// defaultConcurrency is the number of secrets that are read concurrently if no Concurrency is set.
+const defaultConcurrency = 8
+
+// RetryOptions configures the retries of requests that failed with a connection error or a 5xx response.
+// Zero values keep the defaults of the vault client (2 retries waiting between 1s and 1.5s),
+// Disabled turns the retries off.
+type RetryOptions struct {
+	MaxRetries int
+	MinWait    time.Duration
+	MaxWait    time.Duration
+	Disabled   bool
 }
 
 // Option configures the vault client.
@@ -70,16 +131,295 @@ func WithToken(token string) Option {
 	}
 }
 
-// WithTLSOptions sets the TLSOptions.
+// WithWrappedToken sets a response wrapping token, which is unwrapped before the login.
+// It can wrap the token (token auth method) or the SecretID (approle auth method).
+func WithWrappedToken(token string) Option {
+	return func(o *Options) {
+		o.WrappedToken = token
+	}
+}
+
+// WithWrappedTokenFrom reads the response wrapping token from a file or an environment variable.
+func WithWrappedTokenFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.WrappedTokenRef = ref
+	}
+}
+
+// WithAuthMount sets the path the auth method is mounted at (e.g. approle-ci for auth/approle-ci).
+// Defaults to the name of the auth type.
+func WithAuthMount(mount string) Option {
+	return func(o *Options) {
+		o.AuthMount = mount
+	}
+}
+
+// WithFlavor sets the flavor of the server, FlavorVault (the default) or FlavorOpenBao.
+func WithFlavor(flavor string) Option {
+	return func(o *Options) {
+		o.Flavor = flavor
+	}
+}
+
+// WithHCP sets the HCPOptions of the HCP Vault Secrets client.
+func WithHCP(hcp HCPOptions) Option {
+	return func(o *Options) {
+		o.HCP = hcp
+	}
+}
+
+// WithCertRole sets the name of the certificate role the cert auth method logs in with.
+// Without a role, vault tries all roles of the mount. The mount is set with WithAuthMount.
+func WithCertRole(name string) Option {
+	return func(o *Options) {
+		o.CertRole = name
+	}
+}
+
+// WithTokenFrom reads the token from a file or an environment variable.
+func WithTokenFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.TokenRef = ref
+	}
+}
+
+// WithRoleIDFrom reads the RoleID from a file or an environment variable.
+func WithRoleIDFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.RoleIDRef = ref
+	}
+}
+
+// WithSecretIDFrom reads the SecretID from a file or an environment variable.
+func WithSecretIDFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.SecretIDRef = ref
+	}
+}
+
+// WithPasswordFrom reads the basic auth password from a file or an environment variable.
+func WithPasswordFrom(ref credentials.Ref) Option {
+	return func(o *Options) {
+		o.PasswordRef = ref
+	}
+}
+
+// WithTLSOptions sets the TLSOptions: the client certificate, the CA certificates,
+// the expected server name and whether the server certificate is verified at all.
 func WithTLSOptions(tls TLSOptions) Option {
 	return func(o *Options) {
 		o.TLS = tls
 	}
 }
 
-// WithBasicAuth enables the basic authentication and sets the username and password.
+// WithProxy sets the URL of the http, https or socks5 proxy.
+// Defaults to the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables,
+// easykv.NoProxy disables proxies.
+func WithProxy(rawurl string) Option {
+	return func(o *Options) {
+		o.Proxy = rawurl
+	}
+}
+
+// WithBasicAuth enables the basic authentication and sets the username and password
+// (userpass and ldap auth methods).
 func WithBasicAuth(b BasicAuthOptions) Option {
 	return func(o *Options) {
 		o.Auth = b
 	}
 }
+
+// WithControlGroup sets the ControlGroupOptions.
+func WithControlGroup(cg ControlGroupOptions) Option {
+	return func(o *Options) {
+		o.ControlGroup = cg
+	}
+}
+
+// WithKVVersion sets the version (1 or 2) of the KV secret engines.
+// The first path segment of a key is used as mount path.
+// By default the version and mount path are detected for every mount.
+func WithKVVersion(v int) Option {
+	return func(o *Options) {
+		o.KVVersion = v
+	}
+}
+
+// WithSecretVersion pins the KV v2 secret at key to the given version.
+// The same can be done with a version suffix of the key, e.g. /secret/app?version=3.
+func WithSecretVersion(key string, version int) Option {
+	return func(o *Options) {
+		if o.Versions == nil {
+			o.Versions = make(map[string]int)
+		}
+		o.Versions[key] = version
+	}
+}
+
+// WithRenewal sets the RenewalOptions.
+func WithRenewal(r RenewalOptions) Option {
+	return func(o *Options) {
+		o.Renewal = r
+	}
+}
+
+// WithAWS sets the AWSOptions (aws auth method).
+func WithAWS(aws AWSOptions) Option {
+	return func(o *Options) {
+		o.AWS = aws
+	}
+}
+
+// WithGCP sets the GCPOptions (gcp auth method).
+func WithGCP(gcp GCPOptions) Option {
+	return func(o *Options) {
+		o.GCP = gcp
+	}
+}
+
+// WithAzure sets the AzureOptions (azure auth method).
+func WithAzure(azure AzureOptions) Option {
+	return func(o *Options) {
+		o.Azure = azure
+	}
+}
+
+// WithJWT sets the JWTOptions (jwt and oidc auth methods).
+func WithJWT(jwt JWTOptions) Option {
+	return func(o *Options) {
+		o.JWT = jwt
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace of the client.
+// Authentication and all requests are sent to this namespace.
+func WithNamespace(ns string) Option {
+	return func(o *Options) {
+		o.Namespace = ns
+	}
+}
+
+// WithKeyNamespace reads all keys with the given prefix from the namespace ns.
+// If several prefixes match a key, the longest one wins.
+func WithKeyNamespace(prefix, ns string) Option {
+	return func(o *Options) {
+		if o.KeyNamespaces == nil {
+			o.KeyNamespaces = make(map[string]string)
+		}
+		o.KeyNamespaces[prefix] = ns
+	}
+}
+
+// WithLeaseRenewal sets the RenewalOptions of the leases of dynamic secrets.
+func WithLeaseRenewal(r RenewalOptions) Option {
+	return func(o *Options) {
+		o.LeaseRenewal = r
+	}
+}
+
+// WithTransit decrypts stored values with the transit secret engine.
+func WithTransit(t TransitOptions) Option {
+	return func(o *Options) {
+		o.Transit = t
+	}
+}
+
+// WithConcurrency sets the number of secrets that are read concurrently by GetValues.
+// Defaults to 8.
+func WithConcurrency(n int) Option {
+	return func(o *Options) {
+		o.Concurrency = n
+	}
+}
+
+// WithKubernetes sets the KubernetesOptions (kubernetes auth method).
+func WithKubernetes(k KubernetesOptions) Option {
+	return func(o *Options) {
+		o.Kubernetes = k
+	}
+}
+
+// WithTimeout sets the timeout of a single request to vault. Defaults to 60 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// WithCacheMaxTTL caches the values of GetValues for the lease duration of their secrets,
+// but at most ttl. Changes reported by WatchPrefix, SetValues, DeleteValues and Reload
+// drop the cached values.
+func WithCacheMaxTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.CacheMaxTTL = ttl
+	}
+}
+
+// WithRetry sets the RetryOptions.
+func WithRetry(r RetryOptions) Option {
+	return func(o *Options) {
+		o.Retry = r
+	}
+}
+
+// WithPKI issues a certificate whenever the key (e.g. /pki/issue/web) is read.
+// The certificate, private_key, ca_chain and serial_number are returned as values below the key.
+// The certificate is reused until two thirds of its lifetime have passed, then a new one is issued
+// and running watches are notified.
+func WithPKI(key string, req PKIRequest) Option {
+	return func(o *Options) {
+		if o.PKI == nil {
+			o.PKI = make(map[string]PKIRequest)
+		}
+		o.PKI[key] = req
+	}
+}
+
+// WithLogger sets the Logger that reports auth events and unreadable secrets.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithRedactor sets the Redactor all values read from vault are registered with.
+// Defaults to a new Redactor per client, nil disables the registration.
+func WithRedactor(r *easykv.Redactor) Option {
+	return func(o *Options) {
+		o.Redactor = r
+	}
+}
+
+// logger returns the Logger of the options, which redacts the registered secrets.
+func (o Options) logger() easykv.Logger {
+	if o.Redactor == nil {
+		return easykv.LoggerOrDiscard(o.Logger)
+	}
+	return o.Redactor.Logger(o.Logger)
+}
+
+// refs returns the credential references of the options.
+func (o Options) refs() []credentials.Ref {
+	return []credentials.Ref{o.TokenRef, o.RoleIDRef, o.SecretIDRef, o.PasswordRef, o.JWT.JWTRef, o.WrappedTokenRef}
+}
+
+// resolve returns a copy of the options with all credential references resolved.
+func (o Options) resolve() (Options, error) {
+	var err error
+	for _, c := range []struct {
+		ref   credentials.Ref
+		value *string
+	}{
+		{o.TokenRef, &o.Token},
+		{o.RoleIDRef, &o.RoleID},
+		{o.SecretIDRef, &o.SecretID},
+		{o.PasswordRef, &o.Auth.Password},
+		{o.JWT.JWTRef, &o.JWT.JWT},
+		{o.WrappedTokenRef, &o.WrappedToken},
+	} {
+		if *c.value, err = c.ref.Resolve(*c.value); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,94 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// PKIRequest configures the certificate that is issued for a pki/issue/<role> key.
+type PKIRequest struct {
+	CommonName string
+	AltNames   []string
+	IPSANs     []string
+	TTL        time.Duration
+}
+
+// pkiRequest returns the PKIRequest of key.
+func (c *Client) pkiRequest(key string) (PKIRequest, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for k, req := range c.options.PKI {
+		if strings.Trim(k, "/") == strings.Trim(key, "/") {
+			return req, true
+		}
+	}
+	return PKIRequest{}, false
+}
+
+// issue issues a certificate and caches it under key until two thirds of its lifetime have passed.
+// Afterwards a new certificate is issued on the next read and running watches are notified.
+// The ca_chain is returned as a single PEM encoded value.
+func (c *Client) issue(ctx context.Context, vc *vaultapi.Client, key, p string, req PKIRequest) (*vaultapi.Secret, error) {
+	data := map[string]interface{}{"common_name": req.CommonName}
+	if len(req.AltNames) > 0 {
+		data["alt_names"] = strings.Join(req.AltNames, ",")
+	}
+	if len(req.IPSANs) > 0 {
+		data["ip_sans"] = strings.Join(req.IPSANs, ",")
+	}
+	if req.TTL > 0 {
+		data["ttl"] = req.TTL.String()
+	}
+
+	resp, err := vc.Logical().WriteWithContext(ctx, p, data)
+	if err != nil || resp == nil || resp.Data == nil {
+		return resp, err
+	}
+
+	if chain, ok := resp.Data["ca_chain"].([]interface{}); ok {
+		certs := make([]string, 0, len(chain))
+		for _, cert := range chain {
+			if s, ok := cert.(string); ok {
+				certs = append(certs, s)
+			}
+		}
+		resp.Data["ca_chain"] = strings.Join(certs, "\n")
+	}
+
+	var expiration int64
+	switch e := resp.Data["expiration"].(type) {
+	case json.Number:
+		expiration, _ = e.Int64()
+	case float64:
+		expiration = int64(e)
+	}
+	if expiration == 0 {
+		return resp, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.leases.put(key, resp, cancel)
+	go func() {
+		lifetime := time.Until(time.Unix(expiration, 0))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(lifetime * 2 / 3):
+		}
+		c.logger().Info("vault certificate expires soon, issuing a new one", "key", key)
+		c.leases.expire(key, resp)
+	}()
+	return resp, nil
+}
@@ -0,0 +1,86 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// RenewalOptions configures how the token or the leases of dynamic secrets are kept alive.
+// Renewable tokens and leases are renewed with the given Increment (in seconds, zero keeps the current ttl).
+// Tokens that can't be renewed anymore are replaced by authenticating again,
+// except for the token auth method. Dynamic secrets are read again.
+type RenewalOptions struct {
+	Disabled  bool
+	Increment int
+}
+
+// reauthBackoff is used to retry a failed authentication.
+var reauthBackoff = easykv.BackoffOptions{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+// keepAlive renews the token of vc until it can't be renewed anymore and authenticates again.
+// Non-renewable tokens are replaced after two thirds of their ttl.
+// It returns when ctx is canceled, which happens when the client is reconnected or closed.
+func (c *Client) keepAlive(ctx context.Context, vc *vaultapi.Client, auth *vaultapi.SecretAuth, options RenewalOptions) {
+	logger := c.logger()
+
+	if auth.Renewable {
+		renewer, err := vc.NewRenewer(&vaultapi.RenewerInput{
+			Secret:    &vaultapi.Secret{Auth: auth},
+			Increment: options.Increment,
+		})
+		if err != nil {
+			logger.Warn("can't renew vault token", "err", err)
+			return
+		}
+		go renewer.Renew()
+		defer renewer.Stop()
+
+	renew:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-renewer.RenewCh():
+				logger.Debug("renewed vault token")
+			case err := <-renewer.DoneCh():
+				logger.Info("vault token can't be renewed anymore", "err", err)
+				break renew
+			}
+		}
+	} else {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(auth.LeaseDuration) * time.Second * 2 / 3):
+		}
+	}
+
+	if c.authType == "token" {
+		logger.Warn("vault token expires and the token auth method can't authenticate again")
+		return
+	}
+
+	b := easykv.NewBackoff(reauthBackoff)
+	for {
+		// Reload cancels ctx and starts a new keepAlive on success
+		err := c.Reload()
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		logger.Warn("vault re-authentication failed", "auth", c.authType, "err", err)
+		if !b.Wait(ctx) {
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// transitPrefix is the prefix of all transit ciphertexts (vault:v1:...).
+const transitPrefix = "vault:v"
+
+// TransitOptions configures the decryption of stored values with the transit secret engine.
+// Values with a vault:v<n>: ciphertext prefix and all values below the given Prefixes
+// are decrypted with the named Key. Mount defaults to transit.
+type TransitOptions struct {
+	Key      string
+	Mount    string
+	Prefixes []string
+}
+
+// encrypted checks if the value of key has to be decrypted.
+func (o TransitOptions) encrypted(key, value string) bool {
+	if o.Key == "" {
+		return false
+	}
+	if strings.HasPrefix(value, transitPrefix) {
+		return true
+	}
+	for _, p := range o.Prefixes {
+		if strings.HasPrefix(strings.TrimPrefix(key, "/"), strings.TrimPrefix(p, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// decrypt decrypts all values of vars that are encrypted with transit.
+func (c *Client) decrypt(ctx context.Context, vc *vaultapi.Client, vars map[string]string) error {
+	c.mu.RLock()
+	options := c.options.Transit
+	c.mu.RUnlock()
+
+	mount := options.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+	for k, v := range vars {
+		if !options.encrypted(k, v) {
+			continue
+		}
+		resp, err := vc.Logical().WriteWithContext(ctx, path.Join(mount, "decrypt", options.Key), map[string]interface{}{
+			"ciphertext": v,
+		})
+		if err != nil {
+			return fmt.Errorf("can't decrypt %s: %v", k, err)
+		}
+		if resp == nil || resp.Data == nil {
+			return fmt.Errorf("can't decrypt %s: empty response", k)
+		}
+		encoded, _ := resp.Data["plaintext"].(string)
+		plaintext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("can't decrypt %s: %v", k, err)
+		}
+		vars[k] = string(plaintext)
+	}
+	return nil
+}
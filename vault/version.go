@@ -0,0 +1,94 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// versionSuffix pins a key to a version of a KV v2 secret, e.g. /secret/app?version=3.
+const versionSuffix = "?version="
+
+// splitVersion splits a key with a version suffix into the key and the version.
+// Keys without a suffix have the version 0.
+func splitVersion(key string) (string, int, error) {
+	i := strings.Index(key, versionSuffix)
+	if i < 0 {
+		return key, 0, nil
+	}
+	v, err := strconv.Atoi(key[i+len(versionSuffix):])
+	if err != nil || v <= 0 {
+		return key[:i], 0, fmt.Errorf("invalid version of %s", key[:i])
+	}
+	return key[:i], v, nil
+}
+
+// normalizeKey returns key with a leading and without a trailing slash.
+func normalizeKey(key string) string {
+	return "/" + strings.Trim(key, "/")
+}
+
+// readVersion reads the given version of the KV v2 secret at key.
+func (c *Client) readVersion(vc *vaultapi.Client, m mount, key string, version int) (*vaultapi.Secret, error) {
+	if m.version != 2 {
+		return nil, fmt.Errorf("can't read version %d of %s: versions require a kv v2 mount", version, key)
+	}
+	return vc.Logical().ReadWithData(m.apiPath(key, "data"), map[string][]string{
+		"version": {strconv.Itoa(version)},
+	})
+}
+
+// Metadata is the metadata of a KV v2 secret.
+type Metadata struct {
+	CurrentVersion int
+	OldestVersion  int
+	CreatedTime    time.Time
+	UpdatedTime    time.Time
+}
+
+// Metadata returns the metadata of the KV v2 secret at key.
+func (c *Client) Metadata(key string) (*Metadata, error) {
+	vc := c.forKey(c.api(), key)
+	m := c.mountOf(context.Background(), vc, key)
+	if m.version != 2 {
+		return nil, fmt.Errorf("can't read the metadata of %s: metadata requires a kv v2 mount", key)
+	}
+	resp, err := vc.Logical().Read(m.apiPath(key, "metadata"))
+	if err != nil {
+		return nil, c.classify(err)
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, easykv.ErrKeyNotFound
+	}
+
+	var md Metadata
+	if v, ok := resp.Data["current_version"].(json.Number); ok {
+		n, _ := v.Int64()
+		md.CurrentVersion = int(n)
+	}
+	if v, ok := resp.Data["oldest_version"].(json.Number); ok {
+		n, _ := v.Int64()
+		md.OldestVersion = int(n)
+	}
+	if v, ok := resp.Data["created_time"].(string); ok {
+		md.CreatedTime, _ = time.Parse(time.RFC3339Nano, v)
+	}
+	if v, ok := resp.Data["updated_time"].(string); ok {
+		md.UpdatedTime, _ = time.Parse(time.RFC3339Nano, v)
+	}
+	return &md, nil
+}
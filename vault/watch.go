@@ -0,0 +1,150 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+)
+
+// defaultWatchInterval is the poll interval of WatchPrefix if no Interval is set in the WatchOptions.
+const defaultWatchInterval = 15 * time.Second
+
+// WatchPrefix polls the prefix every Interval until its secrets change.
+// The index is a fingerprint of the secrets: the version and update time of
+// KV v2 secrets and a hash of the values of all other secrets.
+// The end of the lease of a dynamic secret is reported immediately.
+// A WaitIndex of 0 returns the current index immediately.
+// Failed polls are retried if a Backoff is set in the WatchOptions.
+// A change drops the values cached with WithCacheMaxTTL.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	index, err := options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, options)
+	})
+	if err == nil && index != options.WaitIndex {
+		c.values.invalidate()
+	}
+	return index, err
+}
+
+// watchPrefix runs a single watch.
+func (c *Client) watchPrefix(ctx context.Context, prefix string, options easykv.WatchOptions) (uint64, error) {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	logger := c.logger()
+	logger.Debug("watching vault prefix", "prefix", prefix, "waitIndex", options.WaitIndex)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		leaseEnded := c.leases.wait()
+		index, err := c.fingerprint(prefix, options.Keys)
+		if err != nil {
+			err = c.classify(err)
+			logger.Warn("vault watch failed", "prefix", prefix, "err", err)
+			return options.WaitIndex, err
+		}
+		if index != options.WaitIndex {
+			return index, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return options.WaitIndex, easykv.ErrWatchCanceled
+		case <-ticker.C:
+		case <-leaseEnded:
+		}
+	}
+}
+
+// fingerprint returns a hash of the current state of all secrets below prefix.
+// If keys is set, only secrets with one of the given prefixes are included.
+func (c *Client) fingerprint(prefix string, keys []string) (uint64, error) {
+	vc := c.api()
+	branches := make(map[string]bool)
+	if err := c.walkTree(vc, prefix, branches, nil); err != nil {
+		return 0, err
+	}
+
+	secrets := make([]string, 0, len(branches))
+	for key := range branches {
+		if watched(key, keys) {
+			secrets = append(secrets, key)
+		}
+	}
+	sort.Strings(secrets)
+
+	h := fnv.New64a()
+	for _, key := range secrets {
+		kc := c.forKey(vc, key)
+		m := c.mountOf(context.Background(), kc, key)
+		if m.version == 2 {
+			resp, err := kc.Logical().Read(m.apiPath(key, "metadata"))
+			if err != nil {
+				return 0, err
+			}
+			if resp == nil || resp.Data == nil {
+				continue
+			}
+			fmt.Fprintf(h, "%s\x00%v\x00%v\x00", key, resp.Data["current_version"], resp.Data["updated_time"])
+			continue
+		}
+
+		resp, err := c.readSecret(context.Background(), kc, key, key)
+		if err != nil {
+			return 0, err
+		}
+		if resp == nil || resp.Data == nil {
+			continue
+		}
+		vars := make(map[string]string)
+		flatten(key, resp.Data, vars)
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Fprintf(h, "%s\x00%s\x00", k, vars[k])
+		}
+	}
+
+	// 0 is reserved for "no index"
+	if index := h.Sum64(); index != 0 {
+		return index, nil
+	}
+	return 1, nil
+}
+
+// watched checks if key has one of the given prefixes, or if no prefixes are given.
+func watched(key string, keys []string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(strings.TrimPrefix(key, "/"), strings.TrimPrefix(k, "/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package vault
+
+import (
+	"errors"
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// unwrappedSecret is the secret of a wrapping token.
+type unwrappedSecret struct {
+	wrappingToken string
+	secret        *vaultapi.Secret
+}
+
+// unwrap replaces the wrapped token of creds with the token or secret id it wraps.
+// A wrapping token can be used only once, so the secret is kept for later logins
+// with the same wrapping token.
+func (c *Client) unwrap(vc *vaultapi.Client, creds Options) (Options, error) {
+	if creds.WrappedToken == "" {
+		return creds, nil
+	}
+
+	c.mu.RLock()
+	u := c.unwrapped
+	c.mu.RUnlock()
+	if u.wrappingToken != creds.WrappedToken {
+		token := vc.Token()
+		secret, err := vc.Logical().Unwrap(creds.WrappedToken)
+		vc.SetToken(token)
+		if err != nil {
+			return creds, fmt.Errorf("can't unwrap the wrapped token: %v", err)
+		}
+		if secret == nil {
+			return creds, errors.New("can't unwrap the wrapped token: empty response")
+		}
+		u = unwrappedSecret{creds.WrappedToken, secret}
+		c.mu.Lock()
+		c.unwrapped = u
+		c.mu.Unlock()
+	}
+
+	if u.secret.Auth != nil && u.secret.Auth.ClientToken != "" {
+		creds.Token = u.secret.Auth.ClientToken
+	}
+	if id, ok := u.secret.Data["secret_id"].(string); ok {
+		creds.SecretID = id
+	}
+	if token, ok := u.secret.Data["token"].(string); ok {
+		creds.Token = token
+	}
+	if creds.Redactor != nil {
+		creds.Redactor.Add(creds.Token, creds.SecretID)
+	}
+	return creds, nil
+}
@@ -0,0 +1,79 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import "context"
+
+// WatchChan watches prefix in a loop and sends the index of every change on the first channel.
+// Watch errors are sent on the second channel and the watch is retried with the Backoff
+// of the options (DefaultBackoff if unset), unless the backend doesn't support watches at all.
+// The backoff is reset after every successful watch. If its MaxRetries are exceeded,
+// both channels are closed.
+// Both channels are closed when ctx is canceled or the backend returns ErrWatchNotSupported.
+// All options are passed on to the backend, the WaitIndex is the index of the last change.
+// The AfterIndex, e.g. the revision of a read (etcd), is the index of the last change as well
+// after the first one, so no change between two watches is missed.
+// The caller must receive from both channels until they are closed.
+func WatchChan(ctx context.Context, rw ReadWatcher, prefix string, opts ...WatchOption) (<-chan uint64, <-chan error) {
+	var options WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Keys == nil {
+		options.Keys = []string{prefix}
+	}
+
+	backoff := DefaultBackoff
+	if options.Backoff != nil {
+		backoff = *options.Backoff
+	}
+	b := NewBackoff(backoff)
+
+	indexc := make(chan uint64)
+	errc := make(chan error)
+
+	go func() {
+		defer close(indexc)
+		defer close(errc)
+
+		index, after := options.WaitIndex, options.AfterIndex
+		for {
+			i, err := rw.WatchPrefix(ctx, prefix, append(opts[:len(opts):len(opts)],
+				WithKeys(options.Keys), WithWaitIndex(index), WithAfterIndex(after))...)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				select {
+				case errc <- err:
+				case <-ctx.Done():
+					return
+				}
+				if err == ErrWatchNotSupported {
+					return
+				}
+				if !b.Wait(ctx) {
+					return
+				}
+				continue
+			}
+
+			b.Reset()
+			index, after = i, i
+			select {
+			case indexc <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return indexc, errc
+}
@@ -0,0 +1,98 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package easykv
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type unsupportedClient struct {
+	*testClient
+}
+
+func (c *unsupportedClient) WatchPrefix(ctx context.Context, prefix string, opts ...WatchOption) (uint64, error) {
+	return 0, ErrWatchNotSupported
+}
+
+func (s *FilterSuite) TestWatchChan(t *C) {
+	c := newTestClient(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	indexc, errc := WatchChan(ctx, c, "/app")
+	go func() {
+		c.changes <- 1
+		c.changes <- 2
+	}()
+	t.Check(<-indexc, Equals, uint64(1))
+	t.Check(<-indexc, Equals, uint64(2))
+
+	cancel()
+	_, ok := <-indexc
+	t.Check(ok, Equals, false)
+	_, ok = <-errc
+	t.Check(ok, Equals, false)
+}
+
+func (s *FilterSuite) TestWatchChanOptions(t *C) {
+	c := newTestClient(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	indexc, _ := WatchChan(ctx, c, "/app", WithInterval(time.Minute), WithWaitIndex(5), WithAfterIndex(4))
+	c.changes <- 6
+	c.mu.Lock()
+	t.Check(c.options.AfterIndex, Equals, uint64(4))
+	c.mu.Unlock()
+	t.Check(<-indexc, Equals, uint64(6))
+	// the next watch has started once it receives the next change
+	c.changes <- 7
+	c.mu.Lock()
+	t.Check(c.options.Interval, Equals, time.Minute)
+	t.Check(c.options.WaitIndex, Equals, uint64(6))
+	// the next watch continues after the change
+	t.Check(c.options.AfterIndex, Equals, uint64(6))
+	t.Check(c.options.Keys, DeepEquals, []string{"/app"})
+	c.mu.Unlock()
+	t.Check(<-indexc, Equals, uint64(7))
+}
+
+func (s *FilterSuite) TestWatchChanBlockingQuery(t *C) {
+	c := newTestClient(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	indexc, _ := WatchChan(ctx, c, "/app", WithMaxWait(30*time.Second), WithConsistency(ConsistencyStale), WithUseCache())
+	c.changes <- 1
+	t.Check(<-indexc, Equals, uint64(1))
+	c.mu.Lock()
+	t.Check(c.options.MaxWait, Equals, 30*time.Second)
+	t.Check(c.options.Consistency, Equals, ConsistencyStale)
+	t.Check(c.options.UseCache, Equals, true)
+	c.mu.Unlock()
+
+	// WatchDiff passes them on as well
+	c2 := newTestClient(map[string]string{"/app/a": "1"})
+	go func() { c2.changes <- 3 }()
+	_, _, index, err := WatchDiff(ctx, c2, "/app", nil, WithMaxWait(time.Minute))
+	t.Assert(err, IsNil)
+	t.Check(index, Equals, uint64(3))
+	c2.mu.Lock()
+	t.Check(c2.options.MaxWait, Equals, time.Minute)
+	c2.mu.Unlock()
+}
+
+func (s *FilterSuite) TestWatchChanNotSupported(t *C) {
+	indexc, errc := WatchChan(context.Background(), &unsupportedClient{newTestClient(nil)}, "/app")
+	t.Check(<-errc, Equals, ErrWatchNotSupported)
+	_, ok := <-indexc
+	t.Check(ok, Equals, false)
+}
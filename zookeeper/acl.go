@@ -0,0 +1,60 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package zookeeper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HeavyHorst/easykv"
+	zk "github.com/tevino/go-zookeeper/zk"
+)
+
+// perms maps the permission letters of the zookeeper cli to permissions.
+var perms = map[rune]int32{
+	'c': zk.PermCreate,
+	'd': zk.PermDelete,
+	'r': zk.PermRead,
+	'w': zk.PermWrite,
+	'a': zk.PermAdmin,
+}
+
+// ParseACL parses a comma separated list of ACL entries in the scheme:id:permissions
+// format of the zookeeper cli, e.g. "digest:app:NFZ5...=:cdrwa,world:anyone:r".
+// The id may contain colons, the permissions are letters of cdrwa.
+func ParseACL(s string) ([]zk.ACL, error) {
+	var acl []zk.ACL
+	for _, entry := range strings.Split(s, ",") {
+		first, last := strings.Index(entry, ":"), strings.LastIndex(entry, ":")
+		if first < 1 || first == last {
+			return nil, fmt.Errorf("invalid zookeeper ACL %q", entry)
+		}
+		a := zk.ACL{Scheme: entry[:first], ID: entry[first+1 : last]}
+		for _, p := range entry[last+1:] {
+			perm, ok := perms[p]
+			if !ok {
+				return nil, fmt.Errorf("invalid zookeeper ACL permission %q in %q", p, entry)
+			}
+			a.Perms |= perm
+		}
+		acl = append(acl, a)
+	}
+	return acl, nil
+}
+
+// classify wraps rejected credentials and missing permissions in an *easykv.BackendError.
+func classify(err error) error {
+	switch err {
+	case zk.ErrAuthFailed:
+		return &easykv.BackendError{Kind: easykv.ErrAuthentication, Err: err}
+	case zk.ErrNoAuth:
+		return &easykv.BackendError{Kind: easykv.ErrPermissionDenied, Err: err}
+	}
+	return err
+}
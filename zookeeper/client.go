@@ -17,9 +17,9 @@ package zookeeper
 
 import (
 	"context"
-	"path/filepath"
+	"crypto/tls"
+	"net"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/HeavyHorst/easykv"
@@ -29,16 +29,114 @@ import (
 // Client provides a wrapper around the zookeeper client
 type Client struct {
 	client *zk.Conn
+	logger easykv.Logger
+	acl    []zk.ACL
+	chroot string
+	krb    *kerberos
 }
 
 // New returns an *zookeeper.Client with a connection to named machines.
+// A chroot suffix of the last machine (127.0.0.1:2181/app) is used like WithChroot.
 // It returns an error if a connection to the cluster cannot be made.
-func New(machines []string) (*Client, error) {
-	c, _, err := zk.Connect(machines, time.Second)
+func New(machines []string, opts ...Option) (*Client, error) {
+	machines, chroot := splitChroot(machines)
+	options := Options{Chroot: chroot}
+	for _, o := range opts {
+		o(&options)
+	}
+	logger := easykv.LoggerOrDiscard(options.Logger)
+
+	dialer := zk.Dialer(net.DialTimeout)
+	if options.TLS.Enabled() || options.UseTLS {
+		tlsConfig, err := options.TLS.Config()
+		if err != nil {
+			return nil, err
+		}
+		dialer = func(network, address string, timeout time.Duration) (net.Conn, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, tlsConfig)
+		}
+	}
+
+	var krb *kerberos
+	if options.Kerberos.Principal != "" {
+		k, err := newKerberos(options.Kerberos, logger)
+		if err != nil {
+			return nil, err
+		}
+		krb = k
+		dialer = k.dialer(dialer)
+	}
+
+	c, _, err := zk.Connect(machines, time.Second, zk.WithLogger(zkLogger{logger}), zk.WithDialer(dialer))
 	if err != nil {
 		panic(err)
 	}
-	return &Client{c}, nil
+	if krb != nil {
+		if err := krb.wait(); err != nil {
+			c.Close()
+			krb.client.Destroy()
+			return nil, classify(err)
+		}
+	}
+
+	acl := options.ACL
+	if len(acl) == 0 {
+		acl = zk.WorldACL(zk.PermAll)
+	}
+	for _, a := range options.Auth {
+		if err := c.AddAuth(a.Scheme, []byte(a.Credentials)); err != nil {
+			c.Close()
+			if krb != nil {
+				krb.client.Destroy()
+			}
+			logger.Warn("can't authenticate the zookeeper session", "scheme", a.Scheme, "err", err)
+			return nil, classify(err)
+		}
+	}
+	chroot = strings.TrimRight(options.Chroot, "/")
+	if chroot != "" && !strings.HasPrefix(chroot, "/") {
+		chroot = "/" + chroot
+	}
+	return &Client{c, logger, acl, chroot, krb}, nil
+}
+
+// splitChroot removes the chroot suffix from the last machine of a connection string.
+func splitChroot(machines []string) ([]string, string) {
+	if len(machines) == 0 {
+		return machines, ""
+	}
+	last := machines[len(machines)-1]
+	i := strings.Index(last, "/")
+	if i < 0 {
+		return machines, ""
+	}
+	m := append([]string{}, machines[:len(machines)-1]...)
+	return append(m, last[:i]), last[i:]
+}
+
+// path returns the zookeeper path of key below the chroot.
+func (c *Client) path(key string) string {
+	if c.chroot == "" {
+		return key
+	}
+	if key == "" || key == "/" {
+		return c.chroot
+	}
+	if !strings.HasPrefix(key, "/") {
+		key = "/" + key
+	}
+	return c.chroot + key
+}
+
+// key returns the key of a zookeeper path below the chroot.
+func (c *Client) key(path string) string {
+	if c.chroot == "" {
+		return path
+	}
+	if key := strings.TrimPrefix(path, c.chroot); key != "" {
+		return key
+	}
+	return "/"
 }
 
 // Close closes the zookeper client connection.
@@ -46,36 +144,47 @@ func (c *Client) Close() {
 	if c.client != nil {
 		c.client.Close()
 	}
+	if c.krb != nil {
+		c.krb.client.Destroy()
+	}
 }
 
-func nodeWalk(prefix string, c *Client, vars map[string]string) error {
+func nodeWalk(prefix string, c *Client, vars map[string]string, tr *easykv.Trace) error {
+	start := time.Now()
 	l, stat, err := c.client.Children(prefix)
+	tr.Record("CHILDREN", prefix, start, err)
 	if err != nil {
 		return err
 	}
 
 	if stat.NumChildren == 0 {
+		start := time.Now()
 		b, _, err := c.client.Get(prefix)
+		tr.Record("GET", prefix, start, err)
 		if err != nil {
 			return err
 		}
-		vars[prefix] = string(b)
+		vars[c.key(prefix)] = string(b)
 
 	} else {
 		for _, key := range l {
 			s := prefix + "/" + key
+			start := time.Now()
 			_, stat, err := c.client.Exists(s)
+			tr.Record("EXISTS", s, start, err)
 			if err != nil {
 				return err
 			}
 			if stat.NumChildren == 0 {
+				start := time.Now()
 				b, _, err := c.client.Get(s)
+				tr.Record("GET", s, start, err)
 				if err != nil {
 					return err
 				}
-				vars[s] = string(b)
-			} else {
-				nodeWalk(s, c, vars)
+				vars[c.key(s)] = string(b)
+			} else if err := nodeWalk(s, c, vars, tr); err != nil {
+				c.logger.Debug("skipping zookeeper node", "key", s, "err", err)
 			}
 		}
 	}
@@ -85,17 +194,32 @@ func nodeWalk(prefix string, c *Client, vars map[string]string) error {
 // GetValues is used to lookup all keys with a prefix.
 // Several prefixes can be specified in the keys array.
 func (c *Client) GetValues(keys []string) (map[string]string, error) {
+	vars, err := c.getValues(keys, nil)
+	return vars, classify(err)
+}
+
+// GetValuesExplain is used to lookup all keys with a prefix.
+// It additionally returns all requests that were sent to zookeeper.
+func (c *Client) GetValuesExplain(keys []string) (map[string]string, []easykv.Operation, error) {
+	var tr easykv.Trace
+	vars, err := c.getValues(keys, &tr)
+	return vars, tr.Operations(), classify(err)
+}
+
+func (c *Client) getValues(keys []string, tr *easykv.Trace) (map[string]string, error) {
 	vars := make(map[string]string)
 	for _, v := range keys {
-		v = strings.Replace(v, "/*", "", -1)
+		v = c.path(strings.Replace(v, "/*", "", -1))
+		start := time.Now()
 		_, _, err := c.client.Exists(v)
+		tr.Record("EXISTS", v, start, err)
 		if err != nil {
 			return vars, err
 		}
 		if v == "/" {
 			v = ""
 		}
-		err = nodeWalk(v, c, vars)
+		err = nodeWalk(v, c, vars, tr)
 		if err != nil {
 			return vars, err
 		}
@@ -103,105 +227,62 @@ func (c *Client) GetValues(keys []string) (map[string]string, error) {
 	return vars, nil
 }
 
-type watchResponse struct {
-	waitIndex uint64
-	err       error
-}
-
-func (c *Client) watch(ctx context.Context, key string, respChan chan watchResponse) {
-	_, _, keyWatcher, err := c.client.GetW(key)
-	if err != nil {
-		respChan <- watchResponse{0, err}
-	}
-	_, _, childWatcher, err := c.client.ChildrenW(key)
-	if err != nil {
-		respChan <- watchResponse{0, err}
-	}
-
-	for {
-		select {
-		case e := <-keyWatcher.EvtCh:
-			if e.Type == zk.EventNodeDataChanged {
-				respChan <- watchResponse{1, e.Err}
-			}
-		case e := <-childWatcher.EvtCh:
-			if e.Type == zk.EventNodeChildrenChanged {
-				respChan <- watchResponse{1, e.Err}
-			}
-		case <-ctx.Done():
-			c.client.RemoveWatcher(childWatcher)
-			c.client.RemoveWatcher(keyWatcher)
-			return
+// SetValues writes all key-value pairs to zookeeper.
+// Missing parent nodes are created.
+func (c *Client) SetValues(values map[string]string) error {
+	for k, v := range values {
+		k = c.path(k)
+		if err := c.createParents(k); err != nil {
+			return err
+		}
+		exists, _, err := c.client.Exists(k)
+		if err != nil {
+			return classify(err)
+		}
+		if exists {
+			_, err = c.client.Set(k, []byte(v), -1)
+		} else {
+			_, err = c.client.Create(k, []byte(v), 0, c.acl)
+		}
+		if err != nil {
+			return classify(err)
 		}
 	}
+	return nil
 }
 
-// WatchPrefix watches a specific prefix for changes.
-func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
-	var options easykv.WatchOptions
-	for _, o := range opts {
-		o(&options)
-	}
-
-	// List the childrens first
-	entries, err := c.GetValues([]string{prefix})
-	if err != nil {
-		return 0, err
-	}
-
-	respChan := make(chan watchResponse)
-	wg := sync.WaitGroup{}
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	//watch all subfolders for changes
-	watchMap := make(map[string]struct{})
-	for k := range entries {
-		for _, v := range options.Keys {
-			if strings.HasPrefix(k, v) {
-				for dir := filepath.Dir(k); dir != "/"; dir = filepath.Dir(dir) {
-					if _, ok := watchMap[dir]; !ok {
-						watchMap[dir] = struct{}{}
-						wg.Add(1)
-						go func(dir string) {
-							defer wg.Done()
-							c.watch(ctx, dir, respChan)
-						}(dir)
-					}
-				}
-				break
-			}
+// createParents creates all missing parent nodes of key.
+func (c *Client) createParents(key string) error {
+	parts := strings.Split(strings.Trim(key, "/"), "/")
+	p := ""
+	for _, part := range parts[:len(parts)-1] {
+		p = p + "/" + part
+		_, err := c.client.Create(p, []byte(""), 0, c.acl)
+		if err != nil && err != zk.ErrNodeExists {
+			return classify(err)
 		}
 	}
+	return nil
+}
 
-	//watch all keys in prefix for changes
-	for k := range entries {
-		for _, v := range options.Keys {
-			if strings.HasPrefix(k, v) {
-				wg.Add(1)
-				go func(k string) {
-					defer wg.Done()
-					c.watch(ctx, k, respChan)
-				}(k)
-				break
-			}
+// DeleteValues deletes all given keys from zookeeper.
+func (c *Client) DeleteValues(keys []string) error {
+	for _, k := range keys {
+		if err := c.client.Delete(c.path(k), -1); err != nil && err != zk.ErrNoNode {
+			return classify(err)
 		}
 	}
+	return nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			wg.Wait()
-			return options.WaitIndex, nil
-		case r := <-respChan:
-			cancel()
-			go func() {
-				for range respChan {
-				}
-			}()
-			wg.Wait()
-			close(respChan)
-			return r.waitIndex, r.err
-		}
+// GetValue returns the value of a single key.
+func (c *Client) GetValue(ctx context.Context, key string) (string, error) {
+	b, _, err := c.client.Get(c.path(key))
+	if err == zk.ErrNoNode {
+		return "", easykv.ErrKeyNotFound
+	}
+	if err != nil {
+		return "", classify(err)
 	}
+	return string(b), nil
 }
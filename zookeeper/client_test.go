@@ -10,11 +10,26 @@ package zookeeper
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/HeavyHorst/easykv"
+	"github.com/HeavyHorst/easykv/conformance"
 	"github.com/HeavyHorst/easykv/testutils"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/types"
 	"github.com/tevino/go-zookeeper/zk"
 
 	. "gopkg.in/check.v1"
@@ -89,3 +104,312 @@ func (s *FilterSuite) TestWatchPrefixCancel(t *C) {
 	cancel()
 	wg.Wait()
 }
+
+func (s *FilterSuite) TestLock(t *C) {
+	c, err := New([]string{"127.0.0.1"})
+	t.Assert(err, IsNil)
+	defer c.Close()
+	testutils.Lock(t, c, "/locktest")
+	testutils.LockValues(t, c, "/lockvaluetest")
+}
+
+func (s *FilterSuite) TestLockLost(t *C) {
+	c, err := New([]string{"127.0.0.1"})
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	session, err := c.Lock(context.Background(), "/locklost", time.Second)
+	t.Assert(err, IsNil)
+	node, err := c.lockNode("/locklost")
+	t.Assert(err, IsNil)
+	t.Assert(c.client.Delete(node, -1), IsNil)
+
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost lock wasn't reported")
+	}
+}
+
+func (s *FilterSuite) TestLockSeq(t *C) {
+	seq, ok := lockSeq("_c_0f8e1c2a5b7d4e3f9a6b8c7d5e4f3a2b-lock-0000000012")
+	t.Check(ok, Equals, true)
+	t.Check(seq, Equals, 12)
+	_, ok = lockSeq("config")
+	t.Check(ok, Equals, false)
+}
+
+func (s *FilterSuite) TestConformance(t *C) {
+	// zookeeper matches prefixes as directories
+	conformance.Run(t, func(data map[string]string) (easykv.ReadWatcher, error) {
+		c, err := New([]string{"127.0.0.1"})
+		if err != nil {
+			return nil, err
+		}
+		if err := c.SetValues(data); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}, conformance.WithSkip("plain string prefix"))
+}
+
+func (s *FilterSuite) TestParseACL(t *C) {
+	acl, err := ParseACL("digest:app:NFZ5o5E1m1bJ+kd/gB7Aj9yNDcY=:cdrwa,world:anyone:r,auth::rw")
+	t.Assert(err, IsNil)
+	t.Check(acl, DeepEquals, []zk.ACL{
+		{Perms: zk.PermAll, Scheme: "digest", ID: "app:NFZ5o5E1m1bJ+kd/gB7Aj9yNDcY="},
+		{Perms: zk.PermRead, Scheme: "world", ID: "anyone"},
+		{Perms: zk.PermRead | zk.PermWrite, Scheme: "auth", ID: ""},
+	})
+
+	_, err = ParseACL("world:anyone")
+	t.Check(err, ErrorMatches, `invalid zookeeper ACL "world:anyone"`)
+	_, err = ParseACL("world:anyone:rx")
+	t.Check(err, ErrorMatches, `invalid zookeeper ACL permission 'x' in "world:anyone:rx"`)
+
+	t.Check(easykv.ErrorKind(classify(zk.ErrNoAuth)), Equals, easykv.ErrPermissionDenied)
+	t.Check(easykv.ErrorKind(classify(zk.ErrAuthFailed)), Equals, easykv.ErrAuthentication)
+}
+
+func (s *FilterSuite) TestTLS(t *C) {
+	// borrow the certificate of an httptest server, it is issued for example.com and 127.0.0.1
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	ca := filepath.Join(t.MkDir(), "ca.pem")
+	t.Assert(ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600), IsNil)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: ts.TLS.Certificates})
+	t.Assert(err, IsNil)
+	defer l.Close()
+	handshakes := make(chan error, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			handshakes <- conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	c, err := New([]string{l.Addr().String()}, WithTLS(easykv.TLSOptions{ClientCaKeys: ca}))
+	t.Assert(err, IsNil)
+	defer c.Close()
+	select {
+	case err := <-handshakes:
+		t.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no TLS handshake")
+	}
+}
+
+func (s *FilterSuite) TestWatchedNodes(t *C) {
+	w := &treeWatch{keys: []string{"/app/db", "/other"}}
+	t.Check(w.inside("/app/db"), Equals, true)
+	t.Check(w.inside("/app/db/url"), Equals, true)
+	t.Check(w.inside("/app"), Equals, false)
+	t.Check(w.above("/"), Equals, true)
+	t.Check(w.above("/app"), Equals, true)
+	t.Check(w.above("/app/cache"), Equals, false)
+
+	all := &treeWatch{}
+	t.Check(all.inside("/anything"), Equals, true)
+}
+
+func (s *FilterSuite) TestWatchReleasesWatchers(t *C) {
+	c, err := New([]string{"127.0.0.1"})
+	t.Assert(err, IsNil)
+	defer c.Close()
+
+	c.client.Create("/watchtest", []byte(""), int32(0), zk.WorldACL(zk.PermAll))
+	c.client.Create("/watchtest/db", []byte("url"), int32(0), zk.WorldACL(zk.PermAll))
+
+	w := &treeWatch{c: c, keys: []string{"/watchtest/db"}, events: make(chan zk.Event)}
+	defer w.release()
+	first, err := w.walk("/watchtest")
+	t.Assert(err, IsNil)
+	watchers := len(w.watchers)
+	t.Check(watchers > 0, Equals, true)
+
+	// churn of siblings of the watched keys makes the watch walk again
+	for i := 0; i < 10; i++ {
+		c.client.Create("/watchtest/other", []byte(""), int32(0), zk.WorldACL(zk.PermAll))
+		c.client.Delete("/watchtest/other", int32(-1))
+		index, err := w.walk("/watchtest")
+		t.Assert(err, IsNil)
+		t.Check(index, Equals, first)
+	}
+	t.Check(w.watchers, HasLen, watchers)
+}
+
+func (s *FilterSuite) TestChroot(t *C) {
+	machines, chroot := splitChroot([]string{"10.0.0.1:2181", "10.0.0.2:2181/app/config"})
+	t.Check(machines, DeepEquals, []string{"10.0.0.1:2181", "10.0.0.2:2181"})
+	t.Check(chroot, Equals, "/app/config")
+	machines, chroot = splitChroot([]string{"10.0.0.1:2181"})
+	t.Check(machines, DeepEquals, []string{"10.0.0.1:2181"})
+	t.Check(chroot, Equals, "")
+
+	c := &Client{chroot: "/app"}
+	t.Check(c.path("/database/url"), Equals, "/app/database/url")
+	t.Check(c.path("/"), Equals, "/app")
+	t.Check(c.key("/app/database/url"), Equals, "/database/url")
+	t.Check(c.key("/app"), Equals, "/")
+
+	c = &Client{}
+	t.Check(c.path("/database/url"), Equals, "/database/url")
+	t.Check(c.key("/database/url"), Equals, "/database/url")
+}
+
+// saslServer answers the connect request and the SASL requests on conn with the tokens of answers.
+// The xids and tokens of the requests are sent to requests.
+func saslServer(conn net.Conn, answers map[string][]byte, codes map[string]int32, requests chan<- string) {
+	if _, err := readFrame(conn); err != nil {
+		return
+	}
+	connected := make([]byte, 4+16)
+	binary.BigEndian.PutUint32(connected, 16)
+	binary.BigEndian.PutUint64(connected[4+8:], 42)
+	conn.Write(connected)
+	for {
+		req, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		token := string(req[12:])
+		requests <- fmt.Sprintf("%d %s", int32(binary.BigEndian.Uint32(req[0:])), token)
+		resp := make([]byte, 4+16, 4+20+len(answers[token]))
+		binary.BigEndian.PutUint32(resp[4:], binary.BigEndian.Uint32(req[0:]))
+		binary.BigEndian.PutUint32(resp[4+12:], uint32(codes[token]))
+		if codes[token] == 0 {
+			resp = resp[:4+20]
+			binary.BigEndian.PutUint32(resp[4+16:], uint32(len(answers[token])))
+			resp = append(resp, answers[token]...)
+		}
+		binary.BigEndian.PutUint32(resp, uint32(len(resp)-4))
+		conn.Write(resp)
+	}
+}
+
+// deadlineConn records the deadlines that are set on the connection.
+type deadlineConn struct {
+	net.Conn
+	read, write time.Time
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.read, c.write = t, t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.read = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.write = t
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// request returns a packet with the xid, the SASL opcode and the token, like the requests of the library.
+func request(xid int32, token string) []byte {
+	b := make([]byte, 16+len(token))
+	binary.BigEndian.PutUint32(b[0:], uint32(12+len(token)))
+	binary.BigEndian.PutUint32(b[4:], uint32(xid))
+	binary.BigEndian.PutUint32(b[8:], opSASL)
+	binary.BigEndian.PutUint32(b[12:], uint32(len(token)))
+	copy(b[16:], token)
+	return b
+}
+
+func (s *FilterSuite) TestSASLConn(t *C) {
+	client, server := net.Pipe()
+	defer client.Close()
+	requests := make(chan string, 10)
+	go saslServer(server, map[string][]byte{"ap-req": []byte("ap-rep")}, map[string]int32{"wrong": errCodeAuthFailed}, requests)
+
+	var answer []byte
+	var failed error
+	dc := &deadlineConn{Conn: client}
+	conn := newSASLConn(dc, func(exchange func([]byte) ([]byte, error)) error {
+		var err error
+		answer, err = exchange([]byte("ap-req"))
+		if err != nil {
+			return err
+		}
+		_, failed = exchange([]byte("wrong"))
+		return nil
+	})
+
+	// the deadlines of the library are kept
+	deadline := time.Now().Add(time.Minute)
+	t.Assert(conn.SetReadDeadline(deadline), IsNil)
+
+	// the connect request is complete with the second write, the first request of the
+	// library, which is written with it, is sent after the handshake
+	_, err := conn.Write([]byte{0, 0, 0, 1})
+	t.Assert(err, IsNil)
+	_, err = conn.Write(append([]byte{0}, request(7, "get")...))
+	t.Assert(err, IsNil)
+	t.Check(string(answer), Equals, "ap-rep")
+	t.Check(failed, Equals, zk.ErrAuthFailed)
+	t.Check(dc.read, Equals, deadline)
+	t.Check(dc.write.IsZero(), Equals, true)
+
+	// the connect response is passed on after the handshake
+	resp, err := readFrame(conn)
+	t.Assert(err, IsNil)
+	t.Check(resp, HasLen, 16)
+	t.Check(binary.BigEndian.Uint64(resp[8:]), Equals, uint64(42))
+	resp, err = readFrame(conn)
+	t.Assert(err, IsNil)
+	t.Check(int32(binary.BigEndian.Uint32(resp)), Equals, int32(7))
+	t.Check([]string{<-requests, <-requests, <-requests}, DeepEquals, []string{"1 ap-req", "2 wrong", "7 get"})
+
+	// a failed handshake fails the connection
+	client, server = net.Pipe()
+	defer client.Close()
+	go saslServer(server, nil, nil, requests)
+	conn = newSASLConn(client, func(exchange func([]byte) ([]byte, error)) error {
+		return errors.New("no ticket")
+	})
+	_, err = conn.Write([]byte{0, 0, 0, 1, 0})
+	t.Check(err, ErrorMatches, "no ticket")
+	_, err = conn.Read(make([]byte, 4))
+	t.Check(err, ErrorMatches, "no ticket")
+	_, err = conn.Write(request(8, "get"))
+	t.Check(err, ErrorMatches, "no ticket")
+}
+
+func (s *FilterSuite) TestSASLSecurityLayer(t *C) {
+	key := types.EncryptionKey{KeyType: 18, KeyValue: make([]byte, 32)}
+	offer := func(layers byte) []byte {
+		token := gssapi.WrapToken{Flags: 0x01 | acceptorSubkey, EC: 12, SndSeqNum: 7, Payload: []byte{layers, 0, 0x10, 0}}
+		t.Assert(token.SetCheckSum(key, keyusage.GSSAPI_ACCEPTOR_SEAL), IsNil)
+		b, err := token.Marshal()
+		t.Assert(err, IsNil)
+		return b
+	}
+
+	b, err := securityLayer(offer(0x07), key, acceptorSubkey, 42, "app@EXAMPLE.COM")
+	t.Assert(err, IsNil)
+	var reply gssapi.WrapToken
+	t.Assert(reply.Unmarshal(b, false), IsNil)
+	_, err = reply.Verify(key, keyusage.GSSAPI_INITIATOR_SEAL)
+	t.Check(err, IsNil)
+	t.Check(reply.Flags, Equals, byte(acceptorSubkey))
+	t.Check(reply.SndSeqNum, Equals, uint64(42))
+	t.Check(string(reply.Payload), Equals, "\x01\x00\x00\x00app@EXAMPLE.COM")
+
+	// only integrity or confidentiality
+	_, err = securityLayer(offer(0x06), key, acceptorSubkey, 42, "app@EXAMPLE.COM")
+	t.Check(err, ErrorMatches, "zookeeper requires a sasl security layer")
+
+	tampered := offer(0x01)
+	tampered[16] = 0x07
+	_, err = securityLayer(tampered, key, acceptorSubkey, 42, "app@EXAMPLE.COM")
+	t.Check(err, ErrorMatches, "checksum mismatch.*")
+}
@@ -0,0 +1,218 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package zookeeper
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	zk "github.com/tevino/go-zookeeper/zk"
+)
+
+// session is a lock held with an ephemeral sequential node.
+type session struct {
+	c    *Client
+	lock *zk.Lock
+	// cancel stops the monitoring of the lock node
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu sync.Mutex
+	// values are the ephemeral nodes written with SetValue
+	values []string
+}
+
+// Done is closed when the lock node is deleted, the zookeeper session expires
+// or the client is closed.
+func (s *session) Done() <-chan struct{} {
+	return s.done
+}
+
+// SetValue writes value to an ephemeral node at key, a node that already
+// exists is replaced. The node is deleted by Unlock or when the zookeeper session expires.
+func (s *session) SetValue(ctx context.Context, key, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return easykv.ErrLockLost
+	default:
+	}
+
+	k := s.c.path(key)
+	if err := s.c.createParents(k); err != nil {
+		return err
+	}
+	_, err := s.c.client.Create(k, []byte(value), zk.FlagEphemeral, s.c.acl)
+	if err == zk.ErrNodeExists {
+		if err = s.c.client.Delete(k, -1); err == nil || err == zk.ErrNoNode {
+			_, err = s.c.client.Create(k, []byte(value), zk.FlagEphemeral, s.c.acl)
+		}
+	}
+	if err != nil {
+		return classify(err)
+	}
+	s.values = append(s.values, k)
+	return nil
+}
+
+// deleteValues deletes the nodes written with SetValue.
+func (s *session) deleteValues() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, k := range s.values {
+		if derr := s.c.client.Delete(k, -1); derr != nil && derr != zk.ErrNoNode && err == nil {
+			err = classify(derr)
+		}
+	}
+	s.values = nil
+	return err
+}
+
+// Unlock stops the monitoring first, the deletion of the node isn't a lost lock.
+func (s *session) Unlock() error {
+	s.cancel()
+	<-s.done
+	err := s.deleteValues()
+	if uerr := s.lock.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// Lock acquires the lock on key with an ephemeral sequential node below key.
+// The ttl is ignored, the node lives as long as the zookeeper session.
+func (c *Client) Lock(ctx context.Context, key string, ttl time.Duration) (easykv.Session, error) {
+	l := zk.NewLock(c.client, c.path(key), c.acl)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.Lock()
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			return nil, classify(err)
+		}
+		node, err := c.lockNode(c.path(key))
+		if err != nil {
+			l.Unlock()
+			return nil, classify(err)
+		}
+		c.logger.Debug("acquired zookeeper lock", "key", key, "node", node)
+		mctx, cancel := context.WithCancel(context.Background())
+		s := &session{c: c, lock: l, cancel: cancel, done: make(chan struct{})}
+		go c.monitorLock(mctx, s, key, node)
+		return s, nil
+	case <-ctx.Done():
+		// the lock can't be interrupted, release it as soon as it's acquired
+		go func() {
+			if err := <-errc; err == nil {
+				l.Unlock()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// lockNode returns the node of the lock held on dir, the node with the lowest
+// sequence number that belongs to the session of the client.
+func (c *Client) lockNode(dir string) (string, error) {
+	children, _, err := c.client.Children(dir)
+	if err != nil {
+		return "", err
+	}
+	owner := c.client.SessionID()
+	node, lowest := "", -1
+	for _, child := range children {
+		seq, ok := lockSeq(child)
+		if !ok || (lowest >= 0 && seq >= lowest) {
+			continue
+		}
+		exists, stat, err := c.client.Exists(dir + "/" + child)
+		if err != nil {
+			return "", err
+		}
+		if exists && stat.EphemeralOwner == owner {
+			node, lowest = dir+"/"+child, seq
+		}
+	}
+	if node == "" {
+		return "", errors.New("zookeeper lock node not found below " + dir)
+	}
+	return node, nil
+}
+
+// lockSeq returns the sequence number of a lock node name.
+func lockSeq(name string) (int, bool) {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return 0, false
+	}
+	seq, err := strconv.Atoi(name[i+1:])
+	return seq, err == nil
+}
+
+// monitorLock closes s.done when the lock node is gone or its watch is lost,
+// which happens if the session expires or the client is closed, or when ctx is canceled.
+// A disconnect alone doesn't lose the lock, the watch is set again on reconnect.
+func (c *Client) monitorLock(ctx context.Context, s *session, key, node string) {
+	defer close(s.done)
+	for {
+		exists, _, w, err := c.client.ExistsW(node)
+		if err == zk.ErrClosing {
+			c.logger.Warn("lost zookeeper lock, the client was closed", "key", key)
+			return
+		}
+		if err != nil {
+			// the node is checked again once the session is back,
+			// it's gone if the session expired in the meantime
+			c.logger.Debug("can't watch zookeeper lock node, retrying", "key", key, "err", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(sessionPoll):
+			}
+			if c.waitForSession(ctx) != nil {
+				return
+			}
+			continue
+		}
+		if !exists {
+			c.logger.Warn("lost zookeeper lock, the lock node was deleted", "key", key)
+			c.client.RemoveWatcher(w)
+			s.deleteValues()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			c.client.RemoveWatcher(w)
+			return
+		case e, ok := <-w.EvtCh:
+			if !ok || e.Type == zk.EventNotWatching || e.Type == zk.EventNodeDeleted {
+				c.logger.Warn("lost zookeeper lock", "key", key, "event", e.Type)
+				if e.Type == zk.EventNodeDeleted {
+					// the session is still alive, its ephemeral values have to be deleted
+					s.deleteValues()
+				}
+				return
+			}
+		}
+	}
+}
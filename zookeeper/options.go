@@ -0,0 +1,120 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package zookeeper
+
+import (
+	"fmt"
+
+	"github.com/HeavyHorst/easykv"
+	zk "github.com/tevino/go-zookeeper/zk"
+)
+
+// Options contains all values that are needed to connect to zookeeper.
+type Options struct {
+	Logger easykv.Logger
+	TLS    easykv.TLSOptions
+	// UseTLS enables TLS without TLSOptions, the server certificate is verified with the system roots.
+	UseTLS bool
+	// Auth are added to the session with AddAuth, e.g. digest credentials.
+	Auth []Auth
+	// ACL is the ACL of the nodes created by SetValues and Lock.
+	// Everyone has all permissions by default.
+	ACL []zk.ACL
+	// Chroot is the node all keys are relative to, e.g. /app.
+	Chroot string
+	// Kerberos authenticates the connections with SASL GSSAPI if a Principal is set.
+	Kerberos KerberosOptions
+}
+
+// KerberosOptions configures the SASL GSSAPI authentication with Kerberos.
+type KerberosOptions struct {
+	// Principal is the client principal, e.g. app/host@EXAMPLE.COM.
+	// Without a realm, the default realm of the kerberos config is used.
+	Principal string
+	// Keytab is the path of the keytab with the keys of the Principal.
+	Keytab string
+	// Config is the path of the kerberos config, KRB5_CONFIG or /etc/krb5.conf by default.
+	Config string
+	// Service is the service of the server principals Service/host, zookeeper by default.
+	Service string
+}
+
+// Auth are the credentials of an authentication scheme.
+type Auth struct {
+	Scheme      string
+	Credentials string
+}
+
+// Option configures the zookeeper client.
+type Option func(*Options)
+
+// WithLogger sets the Logger that reports session events, skipped nodes and watch errors.
+func WithLogger(l easykv.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithTLS enables TLS for the connections to the zookeeper servers, the secure client port
+// of zookeeper 3.5 and newer. The certificates are verified against the name of every server,
+// unless the TLSOptions set a ServerName or SkipHostnameVerification.
+func WithTLS(tls easykv.TLSOptions) Option {
+	return func(o *Options) {
+		o.TLS = tls
+	}
+}
+
+// WithUseTLS enables TLS even without TLSOptions.
+func WithUseTLS() Option {
+	return func(o *Options) {
+		o.UseTLS = true
+	}
+}
+
+// WithDigestAuth authenticates the session with the digest scheme, the credentials are user:password.
+// The credentials are sent again after every reconnect.
+func WithDigestAuth(user, password string) Option {
+	return func(o *Options) {
+		o.Auth = append(o.Auth, Auth{Scheme: "digest", Credentials: user + ":" + password})
+	}
+}
+
+// WithKerberos authenticates every connection with SASL GSSAPI, as zookeeper servers with
+// Kerberos authentication require it. The principal logs in with its keytab, the server principal
+// is zookeeper/host with the host of the connected server.
+func WithKerberos(k KerberosOptions) Option {
+	return func(o *Options) {
+		o.Kerberos = k
+	}
+}
+
+// WithACL sets the ACL of the nodes that are created, see ParseACL.
+// zk.DigestACL and zk.AuthACL restrict them to a digest user or to the authenticated users.
+func WithACL(acl []zk.ACL) Option {
+	return func(o *Options) {
+		o.ACL = acl
+	}
+}
+
+// WithChroot resolves all keys relative to the node path, like the chroot suffix of a
+// zookeeper connection string (127.0.0.1:2181/app). Missing nodes of path are created by SetValues.
+func WithChroot(path string) Option {
+	return func(o *Options) {
+		o.Chroot = path
+	}
+}
+
+// zkLogger passes the messages of the zookeeper library to an easykv.Logger.
+type zkLogger struct {
+	logger easykv.Logger
+}
+
+func (l zkLogger) Printf(format string, args ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,424 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package zookeeper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	krb "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	zk "github.com/tevino/go-zookeeper/zk"
+)
+
+// saslTimeout limits the SASL handshake of a connection and how long New waits for the first one.
+var saslTimeout = 10 * time.Second
+
+// opSASL is the zookeeper opcode of SASL requests.
+const opSASL = 102
+
+// errCodeAuthFailed is the zookeeper error code of a failed authentication.
+const errCodeAuthFailed = -115
+
+// acceptorSubkey is the flag of GSSAPI wrap tokens that are protected with the subkey of the acceptor.
+const acceptorSubkey = 0x04
+
+// kerberos authenticates the connections to zookeeper with SASL GSSAPI.
+type kerberos struct {
+	client  *krb.Client
+	service string
+	logger  easykv.Logger
+	// results receives the result of the first handshake.
+	results chan error
+}
+
+// newKerberos logs in with the keytab of the principal.
+func newKerberos(o KerberosOptions, logger easykv.Logger) (*kerberos, error) {
+	path := o.Config
+	if path == "" {
+		path = os.Getenv("KRB5_CONFIG")
+	}
+	if path == "" {
+		path = "/etc/krb5.conf"
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load the kerberos config: %v", err)
+	}
+	kt, err := keytab.Load(o.Keytab)
+	if err != nil {
+		return nil, fmt.Errorf("can't load the keytab: %v", err)
+	}
+	user, realm := o.Principal, cfg.LibDefaults.DefaultRealm
+	if i := strings.LastIndex(user, "@"); i >= 0 {
+		user, realm = user[:i], user[i+1:]
+	}
+	cl := krb.NewWithKeytab(user, realm, kt, cfg)
+	if err := cl.Login(); err != nil {
+		return nil, &easykv.BackendError{Kind: easykv.ErrAuthentication, Err: err}
+	}
+	service := o.Service
+	if service == "" {
+		service = "zookeeper"
+	}
+	return &kerberos{client: cl, service: service, logger: logger, results: make(chan error, 1)}, nil
+}
+
+// dialer authenticates the connections of dial.
+func (k *kerberos) dialer(dial zk.Dialer) zk.Dialer {
+	return func(network, address string, timeout time.Duration) (net.Conn, error) {
+		conn, err := dial(network, address, timeout)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		return newSASLConn(conn, func(exchange func([]byte) ([]byte, error)) error {
+			err := k.authenticate(exchange, host)
+			if err != nil {
+				k.logger.Warn("zookeeper sasl authentication failed", "server", address, "err", err)
+			}
+			select {
+			case k.results <- err:
+			default:
+			}
+			return err
+		}), nil
+	}
+}
+
+// wait waits for the result of the first handshake.
+func (k *kerberos) wait() error {
+	select {
+	case err := <-k.results:
+		return err
+	case <-time.After(saslTimeout):
+		return errors.New("no zookeeper sasl handshake within " + saslTimeout.String())
+	}
+}
+
+// authenticate runs the GSSAPI mechanism of RFC 4752 with the server principal service/host,
+// exchange sends a SASL token to the server and returns the answer.
+func (k *kerberos) authenticate(exchange func([]byte) ([]byte, error), host string) error {
+	tkt, key, err := k.client.GetServiceTicket(k.service + "/" + host)
+	if err != nil {
+		return err
+	}
+	token, err := spnego.NewKRB5TokenAPREQ(k.client, tkt, key,
+		[]int{gssapi.ContextFlagMutual, gssapi.ContextFlagConf, gssapi.ContextFlagInteg},
+		[]int{flags.APOptionMutualRequired})
+	if err != nil {
+		return err
+	}
+	if err := token.APReq.DecryptAuthenticator(key); err != nil {
+		return err
+	}
+	auth := token.APReq.Authenticator
+	apReq, err := token.Marshal()
+	if err != nil {
+		return err
+	}
+
+	resp, err := exchange(apReq)
+	if err != nil {
+		return err
+	}
+	wrapKey, wrapFlags, err := verifyAPRep(resp, key, auth)
+	if err != nil {
+		return err
+	}
+	// the server answers the empty response with the offered security layers
+	offer, err := exchange([]byte{})
+	if err != nil {
+		return err
+	}
+	creds := k.client.Credentials
+	reply, err := securityLayer(offer, wrapKey, wrapFlags, uint64(auth.SeqNumber),
+		creds.CName().PrincipalNameString()+"@"+creds.Domain())
+	if err != nil {
+		return err
+	}
+	_, err = exchange(reply)
+	return err
+}
+
+// verifyAPRep checks the mutual authentication of the server and returns the key and
+// the flags of the wrap tokens, the subkey of the server if it sent one.
+func verifyAPRep(b []byte, key types.EncryptionKey, auth types.Authenticator) (types.EncryptionKey, byte, error) {
+	var token spnego.KRB5Token
+	if err := token.Unmarshal(b); err != nil {
+		return key, 0, err
+	}
+	if token.IsKRBError() {
+		return key, 0, token.KRBError
+	}
+	if !token.IsAPRep() {
+		return key, 0, errors.New("zookeeper didn't answer with an AP-REP")
+	}
+	plain, err := crypto.DecryptEncPart(token.APRep.EncPart, key, keyusage.AP_REP_ENCPART)
+	if err != nil {
+		return key, 0, err
+	}
+	var part messages.EncAPRepPart
+	if err := part.Unmarshal(plain); err != nil {
+		return key, 0, err
+	}
+	if !part.CTime.Equal(auth.CTime) || part.Cusec != auth.Cusec {
+		return key, 0, errors.New("the AP-REP of zookeeper doesn't match the authenticator")
+	}
+	if part.Subkey.KeyType != 0 {
+		return part.Subkey, acceptorSubkey, nil
+	}
+	return key, 0, nil
+}
+
+// securityLayer verifies the security layers offered by the server and chooses no security layer,
+// the connection is protected with TLS if at all.
+func securityLayer(b []byte, key types.EncryptionKey, wrapFlags byte, seq uint64, authzid string) ([]byte, error) {
+	var offer gssapi.WrapToken
+	if err := offer.Unmarshal(b, true); err != nil {
+		return nil, err
+	}
+	if _, err := offer.Verify(key, keyusage.GSSAPI_ACCEPTOR_SEAL); err != nil {
+		return nil, err
+	}
+	if len(offer.Payload) != 4 || offer.Payload[0]&1 == 0 {
+		return nil, errors.New("zookeeper requires a sasl security layer")
+	}
+	et, err := crypto.GetEtype(key.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	reply := gssapi.WrapToken{
+		Flags:     wrapFlags,
+		EC:        uint16(et.GetHMACBitLength() / 8),
+		SndSeqNum: seq,
+		Payload:   append([]byte{1, 0, 0, 0}, authzid...),
+	}
+	if err := reply.SetCheckSum(key, keyusage.GSSAPI_INITIATOR_SEAL); err != nil {
+		return nil, err
+	}
+	return reply.Marshal()
+}
+
+// saslExchange sends a SASL token to zookeeper and returns the answer.
+func saslExchange(conn net.Conn, xid int32, token []byte) ([]byte, error) {
+	req := make([]byte, 16+len(token))
+	binary.BigEndian.PutUint32(req[0:], uint32(12+len(token)))
+	binary.BigEndian.PutUint32(req[4:], uint32(xid))
+	binary.BigEndian.PutUint32(req[8:], opSASL)
+	binary.BigEndian.PutUint32(req[12:], uint32(len(token)))
+	copy(req[16:], token)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 16 {
+		return nil, errors.New("short zookeeper sasl response")
+	}
+	if x := int32(binary.BigEndian.Uint32(resp[0:])); x != xid {
+		return nil, fmt.Errorf("unexpected zookeeper response %d to sasl request %d", x, xid)
+	}
+	switch code := int32(binary.BigEndian.Uint32(resp[12:])); code {
+	case 0:
+	case errCodeAuthFailed:
+		return nil, zk.ErrAuthFailed
+	default:
+		return nil, fmt.Errorf("zookeeper sasl request failed with code %d", code)
+	}
+	if len(resp) < 20 {
+		return nil, nil
+	}
+	n := int32(binary.BigEndian.Uint32(resp[16:]))
+	if n <= 0 {
+		// an empty or null token
+		return nil, nil
+	}
+	if int(n) > len(resp)-20 {
+		return nil, errors.New("short zookeeper sasl token")
+	}
+	return resp[20 : 20+n], nil
+}
+
+// readFrame reads a length prefixed zookeeper packet.
+func readFrame(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(length[:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// saslConn authenticates a connection in the write of the connect request, the first packet
+// of the zookeeper protocol: the request is sent, the connect response is read and the handshake
+// runs on the established session before the write returns. Packets the zookeeper library writes
+// later wait for the handshake, the connect response is returned by the first reads.
+type saslConn struct {
+	net.Conn
+	authenticate func(exchange func([]byte) ([]byte, error)) error
+
+	// mu is held by the write of the connect request until the handshake is done.
+	mu sync.Mutex
+	// connect buffers the connect request until it is complete.
+	connect []byte
+	done    bool
+	err     error
+	// xid numbers the SASL requests, the server answers them with the same xid.
+	xid      int32
+	response []byte
+	// ready is closed when the handshake is done or the connection is closed.
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	// the deadlines set by the zookeeper library, which are restored after the handshake
+	deadlineMu    sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func newSASLConn(conn net.Conn, authenticate func(exchange func([]byte) ([]byte, error)) error) *saslConn {
+	return &saslConn{Conn: conn, authenticate: authenticate, ready: make(chan struct{})}
+}
+
+func (c *saslConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.done {
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		return c.Conn.Write(b)
+	}
+	defer c.mu.Unlock()
+
+	c.connect = append(c.connect, b...)
+	if len(c.connect) < 4 || len(c.connect) < 4+int(binary.BigEndian.Uint32(c.connect)) {
+		return len(b), nil
+	}
+	n := 4 + int(binary.BigEndian.Uint32(c.connect))
+	c.done = true
+	c.err = c.handshake(c.connect[:n])
+	if c.err == nil && len(c.connect) > n {
+		// the packets written together with the connect request
+		_, c.err = c.Conn.Write(c.connect[n:])
+	}
+	c.connect = nil
+	c.readyOnce.Do(func() { close(c.ready) })
+	if c.err != nil {
+		return 0, c.err
+	}
+	return len(b), nil
+}
+
+// handshake sends the connect request, reads the connect response and authenticates the session.
+func (c *saslConn) handshake(req []byte) error {
+	c.Conn.SetDeadline(time.Now().Add(saslTimeout))
+	defer c.restoreDeadlines()
+
+	if _, err := c.Conn.Write(req); err != nil {
+		return err
+	}
+	resp, err := readFrame(c.Conn)
+	if err != nil {
+		return err
+	}
+	c.response = make([]byte, 4+len(resp))
+	binary.BigEndian.PutUint32(c.response, uint32(len(resp)))
+	copy(c.response[4:], resp)
+	// the session id of an expired session is 0, the library connects again
+	if len(resp) < 16 || binary.BigEndian.Uint64(resp[8:]) == 0 {
+		return nil
+	}
+	return c.authenticate(c.exchange)
+}
+
+// exchange sends a SASL token with the next xid and returns the answer.
+func (c *saslConn) exchange(token []byte) ([]byte, error) {
+	c.xid++
+	return saslExchange(c.Conn, c.xid, token)
+}
+
+func (c *saslConn) Read(b []byte) (int, error) {
+	<-c.ready
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return 0, c.err
+	}
+	if len(c.response) > 0 {
+		n := copy(b, c.response)
+		c.response = c.response[n:]
+		c.mu.Unlock()
+		return n, nil
+	}
+	c.mu.Unlock()
+	return c.Conn.Read(b)
+}
+
+func (c *saslConn) Close() error {
+	err := c.Conn.Close()
+	c.readyOnce.Do(func() { close(c.ready) })
+	return err
+}
+
+func (c *saslConn) SetDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.deadlineMu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *saslConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	c.readDeadline = t
+	c.deadlineMu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *saslConn) SetWriteDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	c.writeDeadline = t
+	c.deadlineMu.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// restoreDeadlines replaces the deadline of the handshake with the deadlines of the library.
+func (c *saslConn) restoreDeadlines() {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.Conn.SetReadDeadline(c.readDeadline)
+	c.Conn.SetWriteDeadline(c.writeDeadline)
+}
@@ -0,0 +1,263 @@
+/*
+ * This file is part of easyKV.
+ * © 2016 The easyKV Authors
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package zookeeper
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HeavyHorst/easykv"
+	zk "github.com/tevino/go-zookeeper/zk"
+)
+
+// watchCoalesce is how long a watch waits for further events after the first one,
+// a burst of changes in a churning tree is handled with a single walk.
+var watchCoalesce = 50 * time.Millisecond
+
+// sessionPoll is the interval in which a watch checks if the session is back.
+var sessionPoll = 100 * time.Millisecond
+
+// WatchPrefix watches a specific prefix for changes.
+// Data and child watches are set on every node of the subtree that contains the watched keys
+// and set again after every event, so changes in deep trees and new nodes are noticed.
+// The watches of the previous walk are removed before they are set again.
+// If the session expired, the watches are set again as soon as a new session is established.
+// The index is a fingerprint of the watched nodes and their last modifications. A WaitIndex
+// that doesn't match it is answered immediately, without one the watch waits for the next change.
+// Failed watches are retried if a Backoff is set in the WatchOptions.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, opts ...easykv.WatchOption) (uint64, error) {
+	var options easykv.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options.Retry(ctx, func() (uint64, error) {
+		return c.watchPrefix(ctx, prefix, options)
+	})
+}
+
+// treeWatch is a single run of WatchPrefix.
+type treeWatch struct {
+	c      *Client
+	keys   []string
+	events chan zk.Event
+	// watchers are the watchers set by the last walk, closing done stops their forwarding.
+	watchers []*zk.Watcher
+	done     chan struct{}
+}
+
+// watchPrefix runs a single watch.
+func (c *Client) watchPrefix(ctx context.Context, prefix string, options easykv.WatchOptions) (uint64, error) {
+	root := strings.TrimSuffix(c.path(strings.Replace(prefix, "/*", "", -1)), "/")
+	if root == "" {
+		root = "/"
+	}
+	keys := make([]string, len(options.Keys))
+	for i, k := range options.Keys {
+		keys[i] = c.path(k)
+	}
+	w := &treeWatch{c: c, keys: keys, events: make(chan zk.Event)}
+	defer w.release()
+
+	current, err := w.walk(root)
+	if err != nil {
+		return options.WaitIndex, classify(err)
+	}
+	if options.WaitIndex != 0 && current != options.WaitIndex {
+		return current, nil
+	}
+
+	c.logger.Debug("watching zookeeper prefix", "prefix", root)
+	for {
+		expired, err := w.wait(ctx)
+		if err != nil {
+			return options.WaitIndex, err
+		}
+		if expired {
+			c.logger.Info("zookeeper watches were lost, setting them again", "prefix", root)
+			if err := c.waitForSession(ctx); err != nil {
+				return options.WaitIndex, err
+			}
+		}
+
+		index, err := w.walk(root)
+		if err != nil {
+			c.logger.Warn("zookeeper watch failed", "prefix", root, "err", err)
+			return options.WaitIndex, classify(err)
+		}
+		if index != current {
+			return index, nil
+		}
+	}
+}
+
+// wait waits for the next event and the events that follow it within watchCoalesce.
+// It reports whether the watches were lost, e.g. because the session expired.
+func (w *treeWatch) wait(ctx context.Context) (bool, error) {
+	var timeout <-chan time.Time
+	expired := false
+	for {
+		select {
+		case <-ctx.Done():
+			return false, easykv.ErrWatchCanceled
+		case e := <-w.events:
+			if e.Type == zk.EventNotWatching {
+				expired = true
+			}
+			if timeout == nil {
+				timeout = time.After(watchCoalesce)
+			}
+		case <-timeout:
+			return expired, nil
+		}
+	}
+}
+
+// waitForSession waits until the client has a session again.
+func (c *Client) waitForSession(ctx context.Context) error {
+	ticker := time.NewTicker(sessionPoll)
+	defer ticker.Stop()
+	for c.client.State() != zk.StateHasSession {
+		select {
+		case <-ctx.Done():
+			return easykv.ErrWatchCanceled
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// forward passes the event of watcher on to w.events until the watchers are released.
+func (w *treeWatch) forward(watcher *zk.Watcher) {
+	w.watchers = append(w.watchers, watcher)
+	done := w.done
+	go func() {
+		select {
+		case e, ok := <-watcher.EvtCh:
+			if !ok {
+				return
+			}
+			select {
+			case w.events <- e:
+			case <-done:
+			}
+		case <-done:
+		}
+	}()
+}
+
+// release removes the watchers of the last walk and stops forwarding their events.
+func (w *treeWatch) release() {
+	if w.done != nil {
+		close(w.done)
+		w.done = nil
+	}
+	for _, watcher := range w.watchers {
+		w.c.client.RemoveWatcher(watcher)
+	}
+	w.watchers = nil
+}
+
+// inside reports whether node is one of the watched keys or below one.
+func (w *treeWatch) inside(node string) bool {
+	if len(w.keys) == 0 {
+		return true
+	}
+	for _, k := range w.keys {
+		if strings.HasPrefix(node, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// above reports whether node is a parent of a watched key.
+func (w *treeWatch) above(node string) bool {
+	for _, k := range w.keys {
+		if node == "/" || strings.HasPrefix(k, node+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// walk sets the watches on root and the relevant nodes below it and returns the fingerprint
+// of the watched nodes. Missing nodes are watched for their creation. The watches of the
+// previous walk are removed first, a change in the meantime is part of the new fingerprint.
+func (w *treeWatch) walk(root string) (uint64, error) {
+	w.release()
+	w.done = make(chan struct{})
+	h := fnv.New64a()
+	if err := w.walkNode(root, h); err != nil {
+		return 0, err
+	}
+	// 0 is reserved for "no index"
+	if index := h.Sum64(); index != 0 {
+		return index, nil
+	}
+	return 1, nil
+}
+
+func (w *treeWatch) walkNode(node string, h hash.Hash64) error {
+	inside := w.inside(node)
+	if !inside && !w.above(node) {
+		return nil
+	}
+
+	children, _, cw, err := w.c.client.ChildrenW(node)
+	if err == zk.ErrNoNode {
+		exists, _, ew, err := w.c.client.ExistsW(node)
+		if err != nil {
+			return err
+		}
+		w.forward(ew)
+		if exists {
+			// created in the meantime
+			return w.walkNode(node, h)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.forward(cw)
+
+	if inside {
+		// the data watch reports the changes of the value
+		_, stat, dw, err := w.c.client.GetW(node)
+		if err == zk.ErrNoNode {
+			// deleted in the meantime, the child watch reports it
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		w.forward(dw)
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", node, stat.Mzxid, stat.Pzxid)
+	} else {
+		fmt.Fprintf(h, "%s\x00", node)
+	}
+
+	sort.Strings(children)
+	for _, child := range children {
+		path := node + "/" + child
+		if node == "/" {
+			path = "/" + child
+		}
+		if err := w.walkNode(path, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}